@@ -40,6 +40,8 @@ package main
 //
 // sentenceToFacts() calls the function makeFacts(). This function
 // makes facts which associate each word with a grammatical fact.
+// The facts are added to an overlay of the knowledge base, which
+// is dropped after the sentence has been parsed.
 // For example:
 //
 //    word(we, pronoun(we , subject, first, plural))
@@ -60,21 +62,19 @@ package main
 //     knowledge base
 //     an empty substitution set
 //
-// During analysis, the rule words_to_pos/2 is applied to convert
-// the input word list, created by sentenceToFacts(), into a list
-// of terms which identify part of speech.
+// The grammar in demo_grammar.txt is written as DCG rules (-->).
+// The rule 'parse' calls phrase/2, which parses the word list with
+// the nonterminal 'sentence':
 //
-//   words_to_pos([$H1 | $T1], [$H2 | $T2]) :-
-//                          word($H1, $H2), words_to_pos($T1, $T2).
-//   words_to_pos([], []).
+//   parse($In, $Out) :- phrase(sentence($Out), $In).
 //
-// The sentence "They envy us." will become:
+// Nonterminals for parts of speech take one word from the list, and
+// look up its part of speech in the word facts:
 //
-// [pronoun(They, subject, third, plural), verb(envy, present, base),
-//          pronoun(us, object, first, plural), period(.)]
+//   verb($V, $VerbForm) --> [$V], { word($V, verb($V, $_, $VerbForm)) }.
 //
-// The inference rule 'sentence' identifies (unifies with) various
-// types of sentence, such as:
+// The nonterminal 'sentence' identifies various types of sentence,
+// such as:
 //
 //   subject pronoun, verb
 //   subject noun, verb
@@ -117,55 +117,18 @@ func main() {
 
     // -------------------------------
     parse  := Atom("parse")
-    words_to_pos := Atom("words_to_pos")
-    word         := Atom("word")
-
-    // Define variables.
-    H1, _ := LogicVar("$H1")
-    H2, _ := LogicVar("$H2")
-    T1, _ := LogicVar("$T1")
-    T2, _ := LogicVar("$T2")
     X, _   := LogicVar("$X")
 
-    /*
-     words_to_pos/2 is a rule to convert a list of words into a list
-     of parts of speech. For example, the atom 'the' is converted to
-     the Complex term 'article(the, definite)':
-
-         words_to_pos([$H1 | $T1], [$H2 | $T2]) :- word($H1, $H2),
-                                                   words_to_pos($T1, $T2).
-         words_to_pos([], []).
-
-     */
-
-    head := Complex{words_to_pos, MakeLinkedList(true, H1, T1),
-                                  MakeLinkedList(true, H2, T2)}
-    body := And(Complex{word, H1, H2}, Complex{words_to_pos, T1, T2})
-    rule := Rule(head, body)
-
-    // Note: The Atom, MakeVar and Rule definitions above can be
-    // replaced by a single line:
-    // rule = ParseRule("words_to_pos([$H1 | $T1], [$H2 | $T2]) :- " +
-    //                  "word($H1, $H2), words_to_pos($T1, $T2)")
+    // Rules for noun phrases.
     // ParseRule will parse the given string to produce a RuleStruct.
     // In Prolog, variables begin with a capital letter and atoms
     // begin with a lower case letter. Suiron is a little different.
     // The parser requires a dollar sign to identify variables.
     // An atom can begin with an upper case or lower case letter.
-
-    kb.Add(rule)  // Add the rule to our knowledge base.
-
-    rule = Fact(Complex{words_to_pos, LinkedListStruct{}, LinkedListStruct{}})
-    // Alternative (simpler) rule definition:
-    //rule, _ = ParseRule("words_to_pos([], [])")
-
-    kb.Add(rule)
-
-    // Rules for noun phrases.
-    rule, _ = ParseRule("make_np([adjective($Adj, $_), " +
+    rule, _ := ParseRule("make_np([adjective($Adj, $_), " +
                         "noun($Noun, $Plur) | $T], [$NP | $Out]) :- " +
                         "!, $NP = np([$Adj, $Noun], $Plur), make_np($T, $Out)")
-    kb.Add(rule)
+    kb.Add(rule)  // Add the rule to our knowledge base.
     rule, _ = ParseRule("make_np([$H | $T], [$H | $T2]) :- make_np($T, $T2)")
     kb.Add(rule)
     rule, _ = ParseRule("make_np([], [])")
@@ -190,17 +153,19 @@ func main() {
 
         fmt.Print(sentence)
 
-        // Delete previous 'word' facts. Don't want them to accumulate.
-        delete(kb, "word/2")
+        // The 'word' facts of each sentence go into an overlay,
+        // so that they do not accumulate in the knowledge base.
+        session := kb.Overlay(OverlayFirst)
 
-        inList := sentenceToFacts(sentence, kb, pos)
-        //DBKB(kb)
+        inList := sentenceToFacts(sentence, session, pos)
+        //DBKB(session)
 
         query := MakeQuery(parse, inList, X)
 
-        _, failure := Solve(query, kb, SubstitutionSet{})
+        _, failure := Solve(query, session, SubstitutionSet{})
         if len(failure) != 0 { fmt.Println(failure) }
         fmt.Print("\n")
+        session.Drop()
     }
 } // main
 
@@ -49,13 +49,13 @@ func makePunctuationTerm(symbol string) (Complex, bool) {
     } else if (symbol == "”") {
         c, _ = ParseComplex("quote_mark(”, “)")
     } else if (symbol == "(") {
-        c, _ = ParseComplex("bracket((, ()")
+        c, _ = ParseComplex("bracket(\\(, \\()")
     } else if (symbol == ")") {
-        c, _ = ParseComplex("bracket(), ()")
+        c, _ = ParseComplex("bracket(\\), \\()")
     } else if (symbol == "[") {
-        c, _ = ParseComplex("bracket([, [)")
+        c, _ = ParseComplex("bracket(\\[, \\[)")
     } else if (symbol == "]") {
-        c, _ = ParseComplex("bracket(], [)")
+        c, _ = ParseComplex("bracket(\\], \\[)")
     } else if (symbol == "<") {
         c, _ = ParseComplex("bracket(<, <)")
     } else if (symbol == ">") {
@@ -1,3 +1,3 @@
 module github.com/indrikoterio/suiron
 
-go 1.17
+go 1.19
@@ -29,12 +29,27 @@ package main
 //
 // Type <enter> to end the program.
 //
+// The REPL has a step debugger. (See suiron/debugger.go.) These
+// commands are typed at the prompt. They begin with a colon, so that
+// they are not mistaken for queries, such as the built-in trace.
+//
+// ?- :trace              <- stop at every port of the next queries
+// ?- :debug              <- stop only at spy points and breakpoints
+// ?- :nodebug            <- turn the debugger off
+// ?- :spy father/2       <- set a spy point
+// ?- :nospy father/2     <- remove a spy point
+// ?- :break kings.txt:27 <- set a breakpoint on a line of a source file
+// ?- :nobreak kings.txt:27
+//
+// At a port, type h to list the debugger's commands.
+//
 // Cleve Lendon
 
 import (
     . "github.com/indrikoterio/suiron/suiron"
     "strings"
     "errors"
+    "sort"
     "bufio"
     "fmt"
     "os"
@@ -67,6 +82,10 @@ func main() {
     reader := bufio.NewReader(os.Stdin)
     previous := ""   // Previous query.
 
+    debugger := MakeDebugger(reader, os.Stdout)
+    debugging := false
+    creep := false   // trace mode: stop at the first port
+
     for {
 
         fmt.Print("?- ")  // Prompt for query.
@@ -77,25 +96,36 @@ func main() {
 
         if queryStr == "." {
             queryStr = previous
+        } else if handled, err := debugCommand(queryStr, debugger,
+                                               &debugging, &creep); handled {
+            if err != nil { fmt.Println(err.Error()) }
+            continue
         } else {
             previous = queryStr
         }
 
-        query, err := ParseQuery(queryStr)
+        goal, query, err := parseGoal(queryStr)
         if err != nil {
             fmt.Println(err.Error())
             continue
         }
 
+        if debugging { debugger.Start(creep) }
+
         // Get the root solution node.
-        root := query.GetSolver(kb, SubstitutionSet{}, nil)
+        root := goal.GetSolver(kb, SubstitutionSet{}, nil)
 
         for {
             solution, found := root.NextSolution()
+            if debugging && debugger.Aborted() {
+                fmt.Println("Aborted")
+                break
+            }
             if !found {
                 fmt.Println("No")
                 break
             }
+            if debugging { debugger.Start(creep) }
             result := FormatSolution(query, solution)
             fmt.Print(result)
             _, _ = reader.ReadString('\n')
@@ -103,3 +133,85 @@ func main() {
     } // for
 
 } // main
+
+// parseGoal - parses a query. A query which is not a complex term,
+// such as the built-in predicate trace, or $X = 1, is solved as a
+// goal. Its variables are gathered into a term for FormatSolution().
+// Params:  query string
+// Returns: goal to solve
+//          query term (for FormatSolution)
+//          error
+func parseGoal(str string) (Goal, Complex, error) {
+
+    goal, err := ParseSubgoal(str)
+    if err != nil { return nil, nil, err }
+    if _, ok := goal.(Complex); ok {
+        query, err := ParseQuery(str)
+        return query, query, err
+    }
+
+    query := MakeQuery(Atom("query"))  // Resets the variable IDs.
+    vars := VarMap{}
+    goal = goal.RecreateVariables(vars).(Goal)
+    names := []string{}
+    for name := range vars { names = append(names, name) }
+    sort.Strings(names)
+    for _, name := range names { query = append(query, vars[name]) }
+    return goal, query, nil
+
+} // parseGoal
+
+// debugCommand - executes a debugger command typed at the prompt:
+// :trace, :debug, :nodebug, :spy, :nospy, :break or :nobreak.
+// Params:  command
+//          debugger
+//          debugging flag
+//          creep flag (trace mode)
+// Returns: true if the text was a debugger command
+//          error
+func debugCommand(command string, debugger *Debugger,
+                  debugging *bool, creep *bool) (bool, error) {
+
+    if !strings.HasPrefix(command, ":") { return false, nil }
+    words := strings.Fields(command[1:])
+    if len(words) == 0 { return false, nil }
+    name := words[0]
+    arg := ""
+    if len(words) > 1 { arg = strings.Join(words[1:], " ") }
+
+    var err error
+    switch name {
+    case "trace", "debug":
+        if arg != "" { return false, nil }
+        *creep = name == "trace"
+    case "nodebug":
+        if arg != "" { return false, nil }
+        *debugging = false
+        TraceOff()
+        SetTracer(nil)
+        fmt.Println("Debugger off.")
+        return true, nil
+    case "spy", "break":
+        if arg == "" { return false, nil }
+        err = debugger.SetBreakpoint(arg)
+        if !*debugging { *creep = false }
+    case "nospy", "nobreak":
+        if arg == "" { return false, nil }
+        return true, debugger.RemoveBreakpoint(arg)
+    default:
+        return false, nil
+    }
+    if err != nil { return true, err }
+
+    // Setting a breakpoint turns the debugger on.
+    *debugging = true
+    SetTracer(debugger)
+    TraceOn()
+    if *creep {
+        fmt.Println("Trace mode: the debugger stops at every port.")
+    } else {
+        fmt.Println("Debug mode: the debugger stops at spy points and breakpoints.")
+    }
+    return true, nil
+
+} // debugCommand
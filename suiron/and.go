@@ -45,11 +45,17 @@ func (a AndOp) ReplaceVariables(ss SubstitutionSet) Expression {
 }
 
 // String - Creates a string for debugging purposes.
+// An Or binds less tightly than an And, so an Or operand is put
+// in parentheses: (a; b), c
 func (a AndOp) String() string {
     var sb strings.Builder
     for n, k := range a {
         if n != 0 { sb.WriteString(", ") }
-        sb.WriteString(k.String())
+        if _, ok := k.(OrOp); ok {
+            sb.WriteString("(" + k.String() + ")")
+        } else {
+            sb.WriteString(k.String())
+        }
     }
     return sb.String()
 }
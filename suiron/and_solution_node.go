@@ -61,12 +61,19 @@ func (n *AndSolutionNodeStruct) NextSolution() (SubstitutionSet, bool) {
 
     solution, found = n.headSolutionNode.NextSolution()
     for found {
-        if len(n.operatorTail) == 0 {
+        // Goals woken up by the head (see suspension.go) are run
+        // before the rest of the conjunction.
+        tail := n.operatorTail
+        woken, ss := solution.takeWokenGoals()
+        if len(woken) > 0 {
+            solution = ss
+            tail = AndOp(append(woken, tail...))
+        }
+        if len(tail) == 0 {
             return solution, true
         } else {
             // tailSolutionNode has to be a new AndSolutionNode.
-            n.tailSolutionNode = n.operatorTail.
-                                   GetSolver(n.KnowledgeBase, solution, n)
+            n.tailSolutionNode = tail.GetSolver(n.KnowledgeBase, solution, n)
             tailSolution, found := n.tailSolutionNode.NextSolution()
             if found { return tailSolution, true }
         }
@@ -0,0 +1,293 @@
+package suiron
+
+// Attributes - an unbound logic variable can carry attributes, such as
+// the domain of a finite-domain (CLP(FD)) variable, and the constraints
+// which refer to it. Constraints include finite-domain constraints
+// (clpfd.go), dif/2, and goals delayed by freeze/2 and when/2
+// (coroutining.go).
+//
+// Attributes are recorded in the substitution set, at the index of the
+// variable, in place of a binding. A variable which has attributes is
+// still unbound. When the variable is bound, its attributes are checked
+// (is the value in the domain?), and its constraints are propagated.
+// When two attributed variables are unified, their attributes are
+// merged, and the constraints of both are propagated.
+//
+// Because the substitution set is copied whenever it changes, attributes
+// are restored automatically when the inference engine backtracks.
+//
+// Cleve Lendon
+
+import (
+    "strings"
+    "fmt"
+)
+
+type attributes struct {
+    domain      fdDomain       // nil means no domain
+    constraints []propagator
+}
+
+// TermType - Returns an integer constant which identifies this type.
+func (a *attributes) TermType() int { return ATTRIBUTES }
+
+// Unify - attributes are not terms, and do not unify with anything.
+// This function satisfies the Unifiable interface.
+func (a *attributes) Unify(other Unifiable, ss SubstitutionSet) (SubstitutionSet, bool) {
+    return ss, false
+}
+
+// RecreateVariables - returns the attributes unchanged.
+// This function satisfies the Expression interface.
+func (a *attributes) RecreateVariables(vars VarMap) Expression { return a }
+
+// ReplaceVariables - returns the attributes unchanged.
+// This function satisfies the Expression interface.
+func (a *attributes) ReplaceVariables(ss SubstitutionSet) Expression { return a }
+
+// String - creates a string representation, for debugging.
+func (a *attributes) String() string {
+    var sb strings.Builder
+    sb.WriteString("attributes{")
+    if a.domain != nil {
+        sb.WriteString("domain: " + a.domain.String())
+    }
+    if len(a.constraints) > 0 {
+        sb.WriteString(fmt.Sprintf(", constraints: %d", len(a.constraints)))
+    }
+    sb.WriteString("}")
+    return sb.String()
+}
+
+// copyAttributes - makes a shallow copy of the attributes, so that
+// they can be modified without affecting previous substitution sets.
+func (a *attributes) copyAttributes() *attributes {
+    newAttr := &attributes{ domain: a.domain }
+    newAttr.constraints = append([]propagator{}, a.constraints...)
+    return newAttr
+}
+
+// propagator - the interface for all constraints.
+type propagator interface {
+    // propagate - checks the constraint, and narrows the domains
+    // of its variables. Returns false if the constraint cannot be
+    // satisfied.
+    propagate(st *constraintStore) bool
+    // variables - returns the terms constrained by this propagator.
+    variables() []Unifiable
+}
+
+// constraintStore - holds the substitution set during propagation,
+// and a queue of propagators which must be run.
+type constraintStore struct {
+    ss     SubstitutionSet
+    queue  []propagator
+    queued map[propagator]bool
+}
+
+// makeConstraintStore - creates a constraint store for the given
+// substitution set.
+func makeConstraintStore(ss SubstitutionSet) *constraintStore {
+    return &constraintStore{ ss: ss, queued: make(map[propagator]bool) }
+}
+
+// enqueue - schedules propagators to be run.
+func (st *constraintStore) enqueue(props []propagator) {
+    for _, p := range props {
+        if st.queued[p] { continue }
+        st.queued[p] = true
+        st.queue = append(st.queue, p)
+    }
+}
+
+// run - runs queued propagators until no more variables change.
+// Returns false if a constraint fails.
+func (st *constraintStore) run() bool {
+    for len(st.queue) > 0 {
+        p := st.queue[0]
+        st.queue = st.queue[1:]
+        delete(st.queued, p)
+        if !p.propagate(st) { return false }
+    }
+    return true
+}
+
+// attach - records a propagator with an unbound variable, so that
+// the propagator will be run when the variable is bound. If the term
+// is not an unbound variable, nothing is done.
+func (st *constraintStore) attach(p propagator, term Unifiable) {
+    ground, ok := st.ss.GetGroundTerm(term)
+    if ok || ground.TermType() != VARIABLE { return }
+    v := ground.(VariableStruct)
+    var newAttr *attributes
+    if a, hasAttr := st.ss.getAttributes(v); hasAttr {
+        for _, c := range a.constraints {
+            if c == p { return }
+        }
+        newAttr = a.copyAttributes()
+    } else {
+        newAttr = &attributes{}
+    }
+    newAttr.constraints = append(newAttr.constraints, p)
+    st.ss = st.ss.setAttributes(v, newAttr)
+} // attach
+
+// detach - removes a propagator from all variables. This is done
+// when a constraint has become true, or when a delayed goal is run.
+func (st *constraintStore) detach(p propagator) {
+    var newSS SubstitutionSet
+    for i := 1; i < len(st.ss); i++ {
+        u := st.ss[i]
+        if u == nil { continue }
+        a, ok := (*u).(*attributes)
+        if !ok { continue }
+        index := -1
+        for j, c := range a.constraints {
+            if c == p { index = j; break }
+        }
+        if index < 0 { continue }
+        if newSS == nil {
+            newSS = make(SubstitutionSet, len(st.ss))
+            copy(newSS, st.ss)
+        }
+        newAttr := &attributes{ domain: a.domain }
+        newAttr.constraints = append(newAttr.constraints, a.constraints[:index]...)
+        newAttr.constraints = append(newAttr.constraints, a.constraints[index + 1:]...)
+        if newAttr.domain == nil && len(newAttr.constraints) == 0 {
+            newSS[i] = nil
+        } else {
+            var term Unifiable = newAttr
+            newSS[i] = &term
+        }
+    }
+    if newSS != nil { st.ss = newSS }
+} // detach
+
+// propagateConstraints - runs the given propagators until no more
+// variables change.
+// Params: substitution set
+//         propagators
+// Return: new substitution set
+//         success/failure flag
+func propagateConstraints(ss SubstitutionSet,
+                          props []propagator) (SubstitutionSet, bool) {
+    if len(props) == 0 { return ss, true }
+    st := makeConstraintStore(ss)
+    st.enqueue(props)
+    if !st.run() { return ss, false }
+    return st.ss, true
+}
+
+// bindAttributed - binds a variable which has attributes to a value.
+// If the variable has a domain, the value must be an Integer in
+// that domain. Constraints on the variable are propagated.
+// Params: variable
+//         attributes of the variable
+//         value
+//         substitution set
+// Return: new substitution set
+//         success/failure flag
+func bindAttributed(v VariableStruct, a *attributes,
+                    value Unifiable, ss SubstitutionSet) (SubstitutionSet, bool) {
+    if a.domain != nil {
+        if value.TermType() != INTEGER { return ss, false }
+        if !a.domain.contains(int64(value.(Integer))) { return ss, false }
+    }
+    newSS := ss.bind(v, value)
+    return propagateConstraints(newSS, a.constraints)
+}
+
+// getAttributes - gets the attributes of an unbound variable.
+// Params: logic variable
+// Return: attributes
+//         true if the variable has attributes
+func (ss SubstitutionSet) getAttributes(v VariableStruct) (*attributes, bool) {
+    if v.id >= len(ss) { return nil, false }
+    u := ss[v.id]
+    if u == nil { return nil, false }
+    a, ok := (*u).(*attributes)
+    return a, ok
+}
+
+// setAttributes - records the attributes of an unbound variable.
+// Params: logic variable
+//         attributes
+// Return: new substitution set
+func (ss SubstitutionSet) setAttributes(v VariableStruct, a *attributes) SubstitutionSet {
+    var u Unifiable = a
+    return ss.bind(v, u)
+}
+
+// bind - records a binding (or attributes) in a copy of the
+// substitution set. The store of an engine is changed in place,
+// while the engine unifies terms. (See trail.go.)
+// Params: logic variable
+//         term to bind to
+// Return: new substitution set
+func (ss SubstitutionSet) bind(v VariableStruct, term Unifiable) SubstitutionSet {
+    if t := ss.trail(); t != nil && t.owns(ss) { return t.bind(v.id, &term) }
+    length := len(ss)
+    if v.id >= length { length = v.id + 1 }
+    newSS := make(SubstitutionSet, length)
+    copy(newSS, ss)
+    newSS[v.id] = &term
+    return newSS
+}
+
+// unifyAttributed - unifies an unbound variable which has attributes
+// with another term. If the other term is also a variable, the
+// attributes of both variables are merged. Otherwise, the term
+// must satisfy the attributes.
+// Params: variable with attributes
+//         attributes
+//         other term
+//         substitution set
+// Return: new substitution set
+//         success/failure flag
+func unifyAttributed(v VariableStruct, a *attributes,
+                     other Unifiable, ss SubstitutionSet) (SubstitutionSet, bool) {
+
+    if other.TermType() == VARIABLE {
+        otherVar := other.(VariableStruct)
+        if otherVar.id == 0 { return ss, false }
+        otherTerm, ground := ss.GetGroundTerm(otherVar)
+        if ground { return v.Unify(otherTerm, ss) }
+        otherVar = otherTerm.(VariableStruct)
+        if otherVar.id == v.id { return ss, true }
+        otherAttr, hasAttr := ss.getAttributes(otherVar)
+        if !hasAttr {
+            // Bind the plain variable to the attributed one.
+            return ss.bind(otherVar, v), true
+        }
+        return mergeAttributes(v, a, otherVar, otherAttr, ss)
+    }
+
+    // Bind the variable, and propagate constraints.
+    if !occursCheck(v, other, ss) { return ss, false }
+    return bindAttributed(v, a, other, ss)
+
+} // unifyAttributed
+
+// mergeAttributes - unifies two variables which both have attributes.
+// The first variable is bound to the second, which receives the merged
+// attributes.
+func mergeAttributes(v1 VariableStruct, a1 *attributes,
+                     v2 VariableStruct, a2 *attributes,
+                     ss SubstitutionSet) (SubstitutionSet, bool) {
+    merged := a2.copyAttributes()
+    if a1.domain != nil {
+        if merged.domain == nil {
+            merged.domain = a1.domain
+        } else {
+            merged.domain = merged.domain.intersect(a1.domain)
+            if merged.domain.isEmpty() { return ss, false }
+        }
+    }
+    merged.constraints = append(merged.constraints, a1.constraints...)
+    newSS := ss.bind(v1, v2)
+    newSS = newSS.setAttributes(v2, merged)
+    if merged.domain != nil && merged.domain.isSingleton() {
+        return bindAttributed(v2, merged, Integer(merged.domain.min()), newSS)
+    }
+    return propagateConstraints(newSS, merged.constraints)
+} // mergeAttributes
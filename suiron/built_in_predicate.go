@@ -111,7 +111,7 @@ func (bips BuiltInPredicateStruct) String() string {
     sb.WriteString("(")
     for n, k := range bips.Arguments {
         if n != 0 { sb.WriteString(", ") }
-        sb.WriteString(k.String())
+        sb.WriteString(formatOperand(k, 999))
     }
     sb.WriteString(")")
     return sb.String()
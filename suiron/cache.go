@@ -0,0 +1,298 @@
+package suiron
+
+// Cache - a cache of query results.
+//
+// Many programs repeat the same queries against a knowledge base which
+// rarely changes. A query cache stores the complete list of solutions
+// of each query. The cache is opt-in:
+//
+//    cache := MakeQueryCache(kb)
+//    solutions, failure := cache.SolveAll(query)
+//    ...
+//    stats := cache.Stats()   // hits, misses, invalidations
+//
+// Queries are keyed by their canonical form: variables are numbered by
+// position, so grammar($X, $Y) and grammar($A, $B) share an entry, but
+// grammar($X, $X) does not. On a hit, the stored solutions are renamed
+// to use the variables of the new query.
+//
+// Each entry records the predicates which the query depends on: the
+// predicate of the query, and all predicates which can be reached from
+// it through the bodies of rules. When a rule is added to the knowledge
+// base (Add()) or removed from it (Retract()), the entries which depend
+// on its predicate are invalidated.
+//
+// Only complete searches are cached. Searches which time out are not.
+//
+// Cleve Lendon
+
+import (
+    "reflect"
+    "fmt"
+    "sync"
+    "sync/atomic"
+)
+
+// QueryCache - stores the solutions of queries to a knowledge base.
+type QueryCache struct {
+    kb             KnowledgeBase
+    mutex          sync.Mutex
+    entries        map[string]*cacheEntry
+    dependents     map[string]map[string]bool  // predicate -> entry keys
+    generation     int64   // incremented on invalidation
+    hits           int64
+    misses         int64
+    invalidations  int64
+}
+
+// cacheEntry - the solutions of one query.
+type cacheEntry struct {
+    variables  []VariableStruct  // variables of the query, in order
+                                 // (see suspension.go)
+    solutions  []Complex
+    failure    string
+    depends    map[string]bool   // predicates the query depends on
+}
+
+// CacheStats - statistics of a query cache.
+type CacheStats struct {
+    Hits           int64
+    Misses         int64
+    Invalidations  int64  // entries removed because the knowledge base changed
+    Entries        int
+}
+
+// Caches are registered by knowledge base, so that Add() and Retract()
+// can invalidate their entries.
+var queryCaches = struct {
+    mutex   sync.Mutex
+    count   int32
+    byKB    map[uintptr][]*QueryCache
+}{ byKB: map[uintptr][]*QueryCache{} }
+
+// kbIdentity - identifies a knowledge base (a map) by its address.
+func kbIdentity(kb KnowledgeBase) uintptr {
+    return reflect.ValueOf(kb).Pointer()
+}
+
+// MakeQueryCache - makes a cache for queries to the given knowledge base.
+// Call Close() when the cache is no longer needed.
+// Param:  knowledge base
+// Return: query cache
+func MakeQueryCache(kb KnowledgeBase) *QueryCache {
+    cache := &QueryCache{
+                 kb: kb,
+                 entries: map[string]*cacheEntry{},
+                 dependents: map[string]map[string]bool{},
+             }
+    id := kbIdentity(kb)
+    queryCaches.mutex.Lock()
+    queryCaches.byKB[id] = append(queryCaches.byKB[id], cache)
+    atomic.AddInt32(&queryCaches.count, 1)
+    queryCaches.mutex.Unlock()
+    return cache
+}
+
+// Close - unregisters the cache. Its entries are discarded.
+func (c *QueryCache) Close() {
+    id := kbIdentity(c.kb)
+    queryCaches.mutex.Lock()
+    caches := queryCaches.byKB[id]
+    for i, cache := range caches {
+        if cache == c {
+            caches = append(caches[:i:i], caches[i + 1:]...)
+            atomic.AddInt32(&queryCaches.count, -1)
+            break
+        }
+    }
+    if len(caches) == 0 {
+        delete(queryCaches.byKB, id)
+    } else {
+        queryCaches.byKB[id] = caches
+    }
+    queryCaches.mutex.Unlock()
+    c.Clear()
+}
+
+// Clear - removes all entries from the cache.
+func (c *QueryCache) Clear() {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    c.entries = map[string]*cacheEntry{}
+    c.dependents = map[string]map[string]bool{}
+    c.generation++
+}
+
+// Stats - returns the statistics of the cache.
+func (c *QueryCache) Stats() CacheStats {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    return CacheStats{ Hits: c.hits, Misses: c.misses,
+                       Invalidations: c.invalidations,
+                       Entries: len(c.entries) }
+}
+
+// SolveAll - finds all solutions for the given query, as SolveAll()
+// in solutions.go does. If the query was solved before, and the
+// predicates it depends on have not changed, the stored solutions
+// are returned.
+// Param:   query
+// Returns: solutions
+//          reason for failure
+func (c *QueryCache) SolveAll(query Complex) ([]Complex, string) {
+
+    key := termKey(query)
+    variables := termVariables(query, SubstitutionSet{})
+
+    c.mutex.Lock()
+    entry, ok := c.entries[key]
+    if ok {
+        c.hits++
+        c.mutex.Unlock()
+        return entry.rename(variables), entry.failure
+    }
+    c.misses++
+    generation := c.generation
+    c.mutex.Unlock()
+
+    solutions, failure := SolveAll(query, c.kb, SubstitutionSet{})
+    if failure != "" && failure != "No" { return solutions, failure }
+
+    entry = &cacheEntry{
+                variables: variables,
+                solutions: solutions,
+                failure: failure,
+                depends: c.kb.dependencies(query.Key()),
+            }
+
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    // If the knowledge base changed during the search,
+    // the solutions may be out of date.
+    if generation == c.generation {
+        c.entries[key] = entry
+        for predicate := range entry.depends {
+            if c.dependents[predicate] == nil {
+                c.dependents[predicate] = map[string]bool{}
+            }
+            c.dependents[predicate][key] = true
+        }
+    }
+    return solutions, failure
+
+} // SolveAll
+
+// invalidate - removes the entries which depend on a predicate.
+// Param: predicate key (eg. "mother/2")
+func (c *QueryCache) invalidate(predicate string) {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    c.generation++
+    for key := range c.dependents[predicate] {
+        entry, ok := c.entries[key]
+        if !ok { continue }
+        delete(c.entries, key)
+        c.invalidations++
+        for p := range entry.depends {
+            if p != predicate { delete(c.dependents[p], key) }
+        }
+    }
+    delete(c.dependents, predicate)
+}
+
+// rename - returns the solutions of an entry, with the variables of
+// the original query replaced by the variables of a new query.
+// Param:  variables of new query
+// Return: solutions
+func (entry *cacheEntry) rename(variables []VariableStruct) []Complex {
+    if len(entry.variables) == 0 {
+        return append([]Complex{}, entry.solutions...)
+    }
+    solutions := make([]Complex, len(entry.solutions))
+    for i, solution := range entry.solutions {
+        vars := VarMap{}
+        for j, v := range entry.variables {
+            vars[v.String()] = variables[j]
+        }
+        solutions[i] = solution.RecreateVariables(vars).(Complex)
+    }
+    return solutions
+}
+
+// invalidateCaches - invalidates the cache entries which depend on
+// a predicate, after the knowledge base has changed.
+// Params: knowledge base
+//         predicate key
+func invalidateCaches(kb KnowledgeBase, predicate string) {
+    if atomic.LoadInt32(&queryCaches.count) == 0 { return }
+    queryCaches.mutex.Lock()
+    caches := queryCaches.byKB[kbIdentity(kb)]
+    queryCaches.mutex.Unlock()
+    for _, cache := range caches { cache.invalidate(predicate) }
+}
+
+// dependencies - returns the predicates which a predicate depends on:
+// the predicate itself, and the predicates which are called in the
+// bodies of its rules, recursively.
+// Param:  predicate key
+// Return: set of predicate keys
+func (kb KnowledgeBase) dependencies(predicate string) map[string]bool {
+    depends := map[string]bool{}
+    var visit func(key string)
+    visit = func(key string) {
+        if depends[key] { return }
+        depends[key] = true
+        for _, rule := range kb.rules(key) {
+            for _, called := range calledPredicates(rule.body) {
+                visit(called)
+            }
+        }
+    }
+    visit(predicate)
+    return depends
+}
+
+// calledPredicates - returns the keys of the predicates which may be
+// called by a goal. The arguments of built-in predicates are included,
+// because they may be goals (eg. freeze($X, goal)).
+// Param:  goal
+// Return: list of predicate keys
+func calledPredicates(goal Expression) []string {
+    keys := []string{}
+    var collect func(e Expression)
+    collect = func(e Expression) {
+        switch g := e.(type) {
+        case Complex:
+            keys = append(keys, g.Key())
+            for _, arg := range g[1:] { collect(arg) }
+        case AndOp:
+            for _, operand := range g { collect(operand) }
+        case OrOp:
+            for _, operand := range g { collect(operand) }
+        case NotOp:
+            for _, operand := range g { collect(operand) }
+        default:
+            b, _, ok := asBuiltIn(e)
+            if !ok { return }
+            // phrase(name, ...) calls name/2: it adds two arguments.
+            meta, isMeta := metaArguments[b.Name]
+            for n, arg := range b.Arguments {
+                if isMeta && n == meta.index && meta.extra > 0 {
+                    switch t := arg.(type) {
+                    case Atom:
+                        keys = append(keys, fmt.Sprintf("%v/%d", t, meta.extra))
+                        continue
+                    case Complex:
+                        keys = append(keys, fmt.Sprintf("%v/%d", t[0],
+                                                         len(t) - 1 + meta.extra))
+                        for _, a := range t[1:] { collect(a) }
+                        continue
+                    }
+                }
+                collect(arg)
+            }
+        }
+    }
+    if goal != nil { collect(goal) }
+    return keys
+} // calledPredicates
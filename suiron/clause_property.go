@@ -0,0 +1,156 @@
+package suiron
+
+// ClauseProperty
+//
+// The predicate clause_property/2 finds the properties of the clauses
+// (rules and facts) whose heads unify with the first argument. The
+// properties are:
+//
+//    file(File)      - source file of the clause
+//    line_count(N)   - line where the clause begins
+//    column(N)       - column where the clause begins
+//    fact            - the clause is a fact
+//    module(Name)    - module of the clause (see module.go)
+//
+// The source properties exist only for clauses which were loaded
+// by LoadKBFromFile(). For example:
+//
+//    ..., clause_property(grandfather($X, $Y), line_count($L)), ...
+//
+// binds $L to the line of each rule for grandfather/2. On backtracking,
+// clause_property/2 tries the next property, then the next clause.
+//
+// Cleve Lendon
+
+type ClausePropertyStruct BuiltInPredicateStruct
+
+// ClauseProperty - creates the struct which defines this built-in
+// predicate. Checks input arguments.
+func ClauseProperty(arguments ...Unifiable) ClausePropertyStruct {
+    if len(arguments) != 2 {
+        panic("ClauseProperty - This predicate requires 2 arguments.")
+    }
+    return ClausePropertyStruct {
+        Name: "clause_property",
+        Arguments: arguments,
+    }
+}
+
+// GetSolver - gets a solution node for this predicate.
+// This function satisfies the Goal interface.
+func (s ClausePropertyStruct) GetSolver(kb KnowledgeBase,
+                                        parentSolution SubstitutionSet,
+                                        parentNode SolutionNode) SolutionNode {
+    return makeClausePropertySolutionNode(s, kb, parentSolution, parentNode)
+}
+
+//----------------------------------------------------------------
+// RecreateVariables(), ReplaceVariables(), and String() satisfy
+// the Expression interface.
+//----------------------------------------------------------------
+
+// RecreateVariables - Refer to comments in expression.go.
+func (s ClausePropertyStruct) RecreateVariables(vars VarMap) Expression {
+    bip := BuiltInPredicateStruct(s).RecreateVariables(vars)
+    return Expression(ClausePropertyStruct(*bip))
+}
+
+// ReplaceVariables - Refer to comments in expression.go.
+func (s ClausePropertyStruct) ReplaceVariables(ss SubstitutionSet) Expression {
+    return BuiltInPredicateStruct(s).ReplaceVariables(ss)
+}  // ReplaceVariables
+
+// String - creates a string representation.
+// Returns: clause_property(grandfather($X, $Y), line_count($L))
+func (s ClausePropertyStruct) String() string {
+    return BuiltInPredicateStruct(s).String()
+}
+
+//----------------------------------------------------------------
+// Solution Node functions.
+//----------------------------------------------------------------
+
+// A solution node holds the current clause and property.
+type ClausePropertySolutionNodeStruct struct {
+    SolutionNodeStruct
+    rules     []RuleStruct  // clauses of the predicate
+    clause    int           // index of current clause
+    property  int           // index of next property
+}
+
+// makeClausePropertySolutionNode - creates a solution node.
+// The clauses are those of the predicate of the first argument.
+func makeClausePropertySolutionNode(goal ClausePropertyStruct,
+                                    kb KnowledgeBase,
+                                    parentSolution SubstitutionSet,
+                                    parentNode SolutionNode) SolutionNode {
+    node := ClausePropertySolutionNodeStruct{
+                SolutionNodeStruct: MakeSolutionNode(goal, kb,
+                                        parentSolution, parentNode),
+            }
+    head, ok := parentSolution.CastComplex(goal.Arguments[0])
+    if !ok { panic("ClauseProperty - First argument must be a complex term.") }
+    node.rules = kb.rules(head.Key())
+    return &node
+}
+
+// clauseProperties - makes the properties of a clause.
+// Param:  rule or fact
+// Return: properties, eg. [file(kings.txt), line_count(28), column(1)]
+func clauseProperties(rule RuleStruct) []Unifiable {
+    properties := []Unifiable{}
+    source := rule.source
+    if source.File != "" {
+        properties = append(properties,
+                            Complex{ Atom("file"), Atom(source.File) })
+    }
+    if source.Line > 0 {
+        properties = append(properties,
+                 Complex{ Atom("line_count"), Integer(source.Line) },
+                 Complex{ Atom("column"), Integer(source.Column) })
+    }
+    if rule.body == nil { properties = append(properties, Atom("fact")) }
+    if rule.module != "" {
+        properties = append(properties,
+                            Complex{ Atom("module"), Atom(rule.module) })
+    }
+    return properties
+} // clauseProperties
+
+// NextSolution - unifies the second argument with the next property
+// of a clause whose head unifies with the first argument.
+// Returns:
+//    updated substitution set
+//    success/failure flag
+// This function satisfies the SolutionNode interface.
+func (sn *ClausePropertySolutionNodeStruct) NextSolution() (SubstitutionSet, bool) {
+    if sn.NoBackTracking { return nil, false }
+    goal := sn.Goal.(ClausePropertyStruct)
+    for ; sn.clause < len(sn.rules); sn.clause++ {
+        rule := sn.rules[sn.clause]
+        head := fetchRule(rule).head
+        ss, ok := head.Unify(goal.Arguments[0], sn.ParentSolution)
+        if !ok { continue }
+        properties := clauseProperties(rule)
+        for sn.property < len(properties) {
+            property := properties[sn.property]
+            sn.property++
+            ss2, ok := goal.Arguments[1].Unify(property, ss)
+            if ok { return ss2, true }
+        }
+        sn.property = 0
+    }
+    return nil, false
+} // NextSolution
+
+// SetNoBackTracking - set the NoBackTracking flag,
+// which is used to implement Cuts.
+// This function satisfies the SolutionNode interface.
+func (sn *ClausePropertySolutionNodeStruct) SetNoBackTracking() {
+    sn.NoBackTracking = true
+}
+
+// GetParentNode
+func (sn *ClausePropertySolutionNodeStruct) GetParentNode() SolutionNode {
+    return sn.ParentNode
+}
@@ -0,0 +1,383 @@
+package suiron
+
+// CLP(FD) - Constraint Logic Programming over Finite Domains.
+//
+// This file contains the propagators which implement Suiron's
+// finite-domain constraints. For example:
+//
+//    $X in 1..9, $Y in 1..9, $X #\= $Y, $X #> 7
+//
+// After the goals above, the domain of $X is 8..9. The comparison
+// predicates (<, >, etc.) fail or panic when their arguments are not
+// bound. Constraints do not. A constraint is recorded with each of its
+// variables (see attributes.go), and it is woken up whenever one of
+// those variables is narrowed or bound. This is called 'propagation'.
+//
+// Arithmetic expressions in constraints are written with Suiron's
+// built-in functions. Expressions must be linear:
+//
+//    $Z #= add($X, multiply(2, $Y))
+//
+// Propagation is not complete. Search is done by labeling, which
+// tries the values of each variable in turn. See label.go.
+//
+// Cleve Lendon
+
+import (
+    "fmt"
+)
+
+// Relations for linear constraints.
+const (
+    fdEQ = iota   // sum = 0
+    fdNE          // sum \= 0
+    fdLE          // sum <= 0
+)
+
+// domainOf - returns the domain of a term. The domain of an Integer
+// is the Integer itself. The domain of an unconstrained variable is
+// inf..sup. If the term is bound to something other than an Integer,
+// the success flag is false.
+func (st *constraintStore) domainOf(term Unifiable) (fdDomain, bool) {
+    ground, ok := st.ss.GetGroundTerm(term)
+    if ok {
+        if ground.TermType() != INTEGER { return nil, false }
+        i := int64(ground.(Integer))
+        return fdRange(i, i), true
+    }
+    if ground.TermType() != VARIABLE { return nil, false }
+    a, ok := st.ss.getAttributes(ground.(VariableStruct))
+    if !ok || a.domain == nil { return fdFullDomain(), true }
+    return a.domain, true
+}
+
+// narrow - intersects the domain of a term with the given domain.
+// If the domain of a variable is reduced to one value, the variable
+// is bound to that value. Propagators of changed variables are queued.
+// Returns false if the new domain is empty.
+func (st *constraintStore) narrow(term Unifiable, d fdDomain) bool {
+    ground, ok := st.ss.GetGroundTerm(term)
+    if ok {
+        if ground.TermType() != INTEGER { return false }
+        return d.contains(int64(ground.(Integer)))
+    }
+    if ground.TermType() != VARIABLE { return false }
+    v := ground.(VariableStruct)
+
+    var newAttr *attributes
+    oldAttr, hasAttr := st.ss.getAttributes(v)
+    if hasAttr {
+        newAttr = oldAttr.copyAttributes()
+    } else {
+        newAttr = &attributes{}
+    }
+    oldDomain := newAttr.domain
+    if oldDomain == nil { oldDomain = fdFullDomain() }
+
+    newDomain := oldDomain.intersect(d)
+    if newDomain.isEmpty() { return false }
+    if hasAttr && newDomain.equal(oldDomain) { return true }
+
+    st.enqueue(newAttr.constraints)
+    if newDomain.isSingleton() {
+        st.ss = st.ss.bind(v, Integer(newDomain.min()))
+    } else {
+        newAttr.domain = newDomain
+        st.ss = st.ss.setAttributes(v, newAttr)
+    }
+    return true
+} // narrow
+
+// removeValue - removes a value from the domain of a term.
+func (st *constraintStore) removeValue(term Unifiable, x int64) bool {
+    d, ok := st.domainOf(term)
+    if !ok { return false }
+    return st.narrow(term, d.remove(x))
+}
+
+
+// fdPost - records a new constraint with each of its variables,
+// then propagates it.
+// Params: substitution set
+//         propagator
+// Return: new substitution set
+//         success/failure flag
+func fdPost(ss SubstitutionSet, p propagator) (SubstitutionSet, bool) {
+    newSS := ss
+    for _, term := range p.variables() {
+        ground, ok := newSS.GetGroundTerm(term)
+        if ok || ground.TermType() != VARIABLE { continue }
+        v := ground.(VariableStruct)
+        var newAttr *attributes
+        if a, hasAttr := newSS.getAttributes(v); hasAttr {
+            newAttr = a.copyAttributes()
+        } else {
+            newAttr = &attributes{ domain: fdFullDomain() }
+        }
+        if newAttr.domain == nil { newAttr.domain = fdFullDomain() }
+        newAttr.constraints = append(newAttr.constraints, p)
+        newSS = newSS.setAttributes(v, newAttr)
+    }
+    return propagateConstraints(newSS, []propagator{p})
+} // fdPost
+
+//----------------------------------------------------------------
+// Linear expressions.
+//----------------------------------------------------------------
+
+// fdExpression - a linear expression: c1*x1 + c2*x2 + ... + constant
+type fdExpression struct {
+    coefficients []int64
+    variables    []Unifiable
+    constant     int64
+}
+
+// addVariable - adds coefficient * variable to the expression.
+// If the variable is already in the expression, the coefficients
+// are added together.
+func (e *fdExpression) addVariable(v VariableStruct, coefficient int64) {
+    for i, term := range e.variables {
+        if term.(VariableStruct).id == v.id {
+            e.coefficients[i] += coefficient
+            return
+        }
+    }
+    e.variables = append(e.variables, v)
+    e.coefficients = append(e.coefficients, coefficient)
+}
+
+// fdLinearize - converts an arithmetic term into a linear expression.
+// The term can be an Integer, a Variable, or one of the built-in
+// functions add(), subtract() and multiply(). The term is multiplied
+// by the given factor, and added to the expression.
+// Params: term
+//         factor
+//         expression (accumulator)
+//         substitution set
+// Return: error
+func fdLinearize(term Unifiable, factor int64,
+                 e *fdExpression, ss SubstitutionSet) error {
+
+    switch t := term.(type) {
+    case Integer:
+        e.constant += factor * int64(t)
+        return nil
+    case VariableStruct:
+        ground, ok := ss.GetGroundTerm(t)
+        if ok { return fdLinearize(ground, factor, e, ss) }
+        e.addVariable(ground.(VariableStruct), factor)
+        return nil
+    case AddStruct:
+        for _, arg := range t.Arguments {
+            err := fdLinearize(arg, factor, e, ss)
+            if err != nil { return err }
+        }
+        return nil
+    case SubtractStruct:
+        for n, arg := range t.Arguments {
+            f := -factor
+            if n == 0 { f = factor }
+            err := fdLinearize(arg, f, e, ss)
+            if err != nil { return err }
+        }
+        return nil
+    case MultiplyStruct:
+        // Only one factor may contain variables.
+        var variablePart *fdExpression
+        product := factor
+        for _, arg := range t.Arguments {
+            sub := &fdExpression{}
+            err := fdLinearize(arg, 1, sub, ss)
+            if err != nil { return err }
+            if len(sub.variables) == 0 {
+                product *= sub.constant
+            } else {
+                if variablePart != nil {
+                    return fmt.Errorf("Non-linear expression: %v", term)
+                }
+                variablePart = sub
+            }
+        }
+        if variablePart == nil {
+            e.constant += product
+            return nil
+        }
+        for i, v := range variablePart.variables {
+            e.addVariable(v.(VariableStruct),
+                          variablePart.coefficients[i] * product)
+        }
+        e.constant += variablePart.constant * product
+        return nil
+    }
+    return fmt.Errorf("Invalid arithmetic expression: %v", term)
+
+} // fdLinearize
+
+//----------------------------------------------------------------
+// Propagators.
+//----------------------------------------------------------------
+
+// fdLinear - constrains a linear expression:  c1*x1 + ... + k REL 0
+// where REL is =, \= or <=.
+type fdLinear struct {
+    fdExpression
+    relation int
+}
+
+func (p *fdLinear) variables() []Unifiable { return p.fdExpression.variables }
+
+// propagate - narrows the bounds of the variables in the expression.
+func (p *fdLinear) propagate(st *constraintStore) bool {
+    switch p.relation {
+    case fdLE:
+        return fdBoundsLE(st, p.coefficients, p.fdExpression.variables,
+                          p.constant)
+    case fdEQ:
+        if !fdBoundsLE(st, p.coefficients, p.fdExpression.variables,
+                       p.constant) {
+            return false
+        }
+        negated := make([]int64, len(p.coefficients))
+        for i, c := range p.coefficients { negated[i] = -c }
+        return fdBoundsLE(st, negated, p.fdExpression.variables, -p.constant)
+    }
+    return fdNotEqual(st, p.coefficients, p.fdExpression.variables,
+                      p.constant)
+} // propagate
+
+// fdMinTerm - calculates the minimum of coefficient * x, for the
+// domain of x. The second return value is false if the minimum
+// is infinite.
+func fdMinTerm(c int64, d fdDomain) (int64, bool) {
+    if c > 0 {
+        if d.min() == fdInf { return 0, false }
+        return c * d.min(), true
+    }
+    if c < 0 {
+        if d.max() == fdSup { return 0, false }
+        return c * d.max(), true
+    }
+    return 0, true
+}
+
+// floorDiv - integer division, rounding towards negative infinity.
+func floorDiv(a int64, b int64) int64 {
+    q := a / b
+    if (a % b != 0) && ((a < 0) != (b < 0)) { q-- }
+    return q
+}
+
+// ceilDiv - integer division, rounding towards positive infinity.
+func ceilDiv(a int64, b int64) int64 {
+    q := a / b
+    if (a % b != 0) && ((a < 0) == (b < 0)) { q++ }
+    return q
+}
+
+// fdBoundsLE - bounds propagation for:  c1*x1 + ... + k <= 0
+// For each variable xi:  ci*xi <= -(k + minimum of the other terms)
+func fdBoundsLE(st *constraintStore, coefficients []int64,
+                vars []Unifiable, k int64) bool {
+
+    n := len(vars)
+    domains := make([]fdDomain, n)
+    minTerms := make([]int64, n)
+    finite := make([]bool, n)
+    sumMin := k
+    numInfinite := 0
+
+    for i, term := range vars {
+        d, ok := st.domainOf(term)
+        if !ok { return false }
+        domains[i] = d
+        minTerms[i], finite[i] = fdMinTerm(coefficients[i], d)
+        if finite[i] {
+            sumMin += minTerms[i]
+        } else {
+            numInfinite++
+        }
+    }
+
+    if numInfinite == 0 && sumMin > 0 { return false }
+
+    for i, term := range vars {
+        c := coefficients[i]
+        if c == 0 { continue }
+        var rest int64
+        if finite[i] {
+            if numInfinite > 0 { continue }
+            rest = sumMin - minTerms[i]
+        } else {
+            if numInfinite > 1 { continue }
+            rest = sumMin
+        }
+        // c * x <= -rest
+        if c > 0 {
+            if !st.narrow(term, domains[i].restrictMax(floorDiv(-rest, c))) {
+                return false
+            }
+        } else {
+            if !st.narrow(term, domains[i].restrictMin(ceilDiv(-rest, c))) {
+                return false
+            }
+        }
+    }
+    return true
+
+} // fdBoundsLE
+
+// fdNotEqual - propagation for:  c1*x1 + ... + k \= 0
+// When all variables but one are bound, the value which would make
+// the sum equal to 0 is removed from the domain of the last variable.
+func fdNotEqual(st *constraintStore, coefficients []int64,
+                vars []Unifiable, k int64) bool {
+    sum := k
+    unbound := -1
+    for i, term := range vars {
+        if coefficients[i] == 0 { continue }
+        d, ok := st.domainOf(term)
+        if !ok { return false }
+        if d.isSingleton() {
+            sum += coefficients[i] * d.min()
+        } else {
+            if unbound >= 0 { return true }  // Two unbound variables.
+            unbound = i
+        }
+    }
+    if unbound < 0 { return sum != 0 }
+    c := coefficients[unbound]
+    if (-sum) % c != 0 { return true }
+    return st.removeValue(vars[unbound], -sum / c)
+} // fdNotEqual
+
+// fdAllDifferent - constrains a list of terms to have different values.
+type fdAllDifferent struct {
+    terms []Unifiable
+}
+
+func (p *fdAllDifferent) variables() []Unifiable { return p.terms }
+
+// propagate - removes the values of bound terms from the domains
+// of the other terms. Fails if two terms have the same value, or
+// if there are fewer values than terms.
+func (p *fdAllDifferent) propagate(st *constraintStore) bool {
+    fixed := map[int64]int{}
+    union := fdDomain{}
+    for i, term := range p.terms {
+        d, ok := st.domainOf(term)
+        if !ok { return false }
+        union = union.union(d)
+        if d.isSingleton() {
+            if _, found := fixed[d.min()]; found { return false }
+            fixed[d.min()] = i
+        }
+    }
+    if int64(len(p.terms)) > union.size() { return false }
+    for i, term := range p.terms {
+        for value, index := range fixed {
+            if index == i { continue }
+            if !st.removeValue(term, value) { return false }
+        }
+    }
+    return true
+} // propagate
@@ -129,18 +129,21 @@ func compareAtoms(term1 Unifiable, type1 int,
         }
     }
 
+    // Interned atoms which are equal share their bytes.
+    // (See intern.go.)
+    if a1 == a2 { return 0 }
     return strings.Compare(string(a1), string(a2))
 
 } // compareAtoms
 
 
 // comparisonString - creates a string representation for comparisons,
-// for example, "$X <= 5".
+// for example, "$X <= 5". Operations are put in parentheses, if they
+// are needed to read the goal back. (See formatOperand() in op.go.)
 // Params: slice of arguments
 //         operator (eg. " <= ", " >= ")
 // Return: string representation
 func comparisonString(terms []Unifiable, operator string) string {
-    term1 := terms[0].String()
-    term2 := terms[1].String()
-    return term1 + operator + term2
+    return formatOperand(terms[0], 699) + operator +
+           formatOperand(terms[1], 699)
 } // comparisonString
@@ -34,85 +34,6 @@ import (
 
 type Complex []Unifiable
 
-// ParseComplex - parses a string to produce a complex term.
-//
-// Example of usage:
-//     c := ParseComplex("symptom(covid, fever)")
-// Important: Backslash is used to escape characters, such as the comma.
-// For example:
-//     c := ParseComplex("punctuation(comma, \\,)")
-// The backslash is doubled, because the Go compiler also interprets
-// the backslash.
-//
-// Params: string representation
-// Return: complex term
-//         error
-func ParseComplex(str string) (Complex, error) {
-
-    s := strings.TrimSpace(str)
-    length := len(s)
-
-    if length == 0 {
-        e := complexError("Length of string is 0", s)
-        return Complex{}, e
-    }
-
-    if length > 1000 {
-        e := complexError("String is too long", s)
-        return Complex{}, e
-    }
-
-    first :=  s[0:1]
-    if first == "$" || first == "(" {
-        e := complexError("First character is invalid", s)
-        return Complex{}, e
-    }
-
-    // Get indices.
-    left, right, err := indicesOfParentheses([]rune(s))
-    if err != nil { return Complex{}, err }
-
-    if left == -1 { // If left is -1, right must also be -1.
-        // Complex term without arguments.
-        f := Atom(s)
-        return Complex{f}, nil
-    }
-
-    functor := strings.TrimSpace(s[0: left])
-    args    := strings.TrimSpace(s[left + 1: right])
-    return parseFunctorTerms(functor, args)
-
-} // ParseComplex
-
-
-// parseFunctorTerms - produces a complex term from two string arguments,
-// the functor and a list of terms. For example:
-//
-//     c, err := parseFunctorTerms("father", "Anakin, Luke")
-// produces (c =)
-//     father(Anakin, Luke)
-//
-// Params: functor (string)
-//         list of terms (string)
-// Return: complex term
-//         error
-//
-func parseFunctorTerms(functor string, terms string) (Complex, error) {
-    f := Atom(functor)
-    if terms == "" { return Complex{f}, nil }
-    t, err := parseArguments(terms)
-    if err != nil { return Complex{f}, err }
-    unifiables := append([]Unifiable{f}, t...)
-    return Complex(unifiables), nil
-} // parseFunctorTerms
-
-// complexError - creates an error for Complex terms.
-// msg - error message
-// str - string which caused the error
-func complexError(msg string, str string) error {
-    return fmt.Errorf("ParseComplex - %v: >%v<", msg, str)
-}
-
 // Arity - Returns the arity of a complex term.
 // address(Tokyo, Shinjuku, Takadanobaba) has an arity of 3.
 func (c Complex) Arity() int { return len(c) - 1 }
@@ -167,10 +88,11 @@ func (c Complex) Unify(other Unifiable, ss SubstitutionSet) (SubstitutionSet, bo
 } // Unify
 
 // GetSolver - returns a solution node for Complex terms.
+// Complex terms are solved iteratively. (See engine.go.)
 func (c Complex) GetSolver(kb KnowledgeBase,
                            parentSolution SubstitutionSet,
                            parentNode SolutionNode) SolutionNode {
-    return MakeComplexSolutionNode(c, kb, parentSolution, parentNode)
+    return makeEngineSolutionNode(c, kb, parentSolution, parentNode)
 }
 
 
@@ -197,9 +119,17 @@ func (c Complex) RecreateVariables(vars VarMap) Expression {
 // This method is used for displaying final results.
 // Refer to comments in expression.go.
 func (c Complex) ReplaceVariables(ss SubstitutionSet) Expression {
+    return c.replaceVariables(ss, map[int]bool{})
+}
+
+// replaceVariables - replaces bound variables with their bindings,
+// except for the variables being expanded. (See variable.go.)
+func (c Complex) replaceVariables(ss SubstitutionSet,
+                                  visiting map[int]bool) Expression {
     newTerms := []Unifiable{}
     for i := 0; i < len(c); i++ {
-        newTerms = append(newTerms, c[i].ReplaceVariables(ss).(Unifiable))
+        term := replaceBindings(c[i], ss, visiting).(Unifiable)
+        newTerms = append(newTerms, term)
     }
     return Complex(newTerms)
 }
@@ -210,12 +140,14 @@ func (c Complex) String() string {
     length := len(c)
     functor := c[0].String()
     if length == 1 { return functor }
+    // Operations are written in operator form. (See op.go.)
+    if op, ok := termOperator(c); ok { return formatOperation(c, op) }
     var sb strings.Builder
     sb.WriteString(functor)
     sb.WriteString("(")
     for i := 1; i < length; i++ {
         if i != 1 { sb.WriteString(", ") }
-        sb.WriteString(c[i].String())
+        sb.WriteString(formatOperand(c[i], 999))
     }
     sb.WriteString(")")
     return sb.String()
@@ -0,0 +1,278 @@
+package suiron
+
+// Consult - loads files for the directives of a rule file:
+//
+//    :- consult(family.txt).       loads the facts and rules of a file
+//    :- include(lib/lists.txt).    the same as consult
+//    :- ensure_loaded(util.txt).   loads a file, unless it has been
+//                                  loaded already
+//    :- initialization(main).      runs a goal after loading
+//
+// The directives module/2 and use_module/1,2 are explained in module.go.
+//
+// The argument of consult, include and ensure_loaded may be a list of
+// files. The clauses of a loaded file are added where the directive
+// occurs. A relative path is resolved from the directory of the file
+// which contains the directive. A file which loads itself, directly
+// or through other files, is an error:
+//
+//    a.txt:1:4: Circular consult: a.txt -> b.txt -> a.txt
+//
+// Initialization goals run after all files have been loaded, in the
+// order in which they were read. They see the clauses of the files,
+// which are added to the knowledge base only if all goals succeed.
+// (See transaction.go.) A goal may be a conjunction:
+// initialization((setup, report)).
+//
+// Files are read from the operating system by LoadKBFromFile(), or
+// from a file system (fs.FS) by LoadKBFS(). With LoadKBFS(), libraries
+// can be embedded in a program with go:embed. These directives cannot
+// be used in ParseRules(), which has no file and no knowledge base.
+//
+// Cleve Lendon
+
+import (
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "path"
+    "path/filepath"
+    "strings"
+)
+
+// loader - loads a file and the files which it consults.
+type loader struct {
+    fsys     fs.FS            // file system, or nil for the OS
+    loading  []string         // files being loaded, to detect cycles
+    loaded   map[string]bool  // files which have been loaded
+    goals    []initGoal       // initialization goals
+    module   string           // module of the file being loaded, or ""
+    started  bool             // a clause of the file has been read
+    fileModules  map[string]string  // module of each file (see module.go)
+    imports  map[string]map[string]string  // imports of each module
+    declared map[string]ParseError  // modules declared, and where
+    tx       *Transaction     // receives the rules as they are parsed
+    ops      operatorTable    // operators of the files (see op.go)
+    pending  []RuleStruct     // rules of modules (see add())
+    errors   ParseErrors      // errors of resolution (see module.go)
+}
+
+// initGoal - an initialization goal, and where it occurs.
+type initGoal struct {
+    goal     Goal
+    module   string       // module of the file
+    source   ParseError   // location and excerpt, for errors
+}
+
+// makeLoader - makes a loader for the given file system.
+// Param: file system, or nil for the operating system's files
+func makeLoader(fsys fs.FS) *loader {
+    return &loader{ fsys: fsys, loaded: map[string]bool{},
+                    fileModules: map[string]string{},
+                    imports: map[string]map[string]string{},
+                    declared: map[string]ParseError{} }
+}
+
+// open - opens a file.
+func (l *loader) open(name string) (io.ReadCloser, error) {
+    if l.fsys == nil { return os.Open(name) }
+    return l.fsys.Open(name)
+}
+
+// resolve - resolves a path relative to the directory of the file
+// which contains the directive.
+// Params: file which contains the directive
+//         path in the directive
+// Return: path of the file to load
+func (l *loader) resolve(from string, name string) string {
+    if l.fsys == nil {
+        if filepath.IsAbs(name) { return name }
+        return filepath.Join(filepath.Dir(from), name)
+    }
+    return path.Join(path.Dir(from), name)
+}
+
+// key - identifies a file, so that the same file is recognized
+// by different paths.
+func (l *loader) key(name string) string {
+    if l.fsys == nil {
+        if abs, err := filepath.Abs(name); err == nil { return abs }
+        return filepath.Clean(name)
+    }
+    return path.Clean(name)
+}
+
+// load - reads and parses a file, and the files which it consults.
+// The rules go to the loader's transaction. (See add().)
+// Param:  file name
+// Return: syntax errors
+//         error, if the file cannot be read
+func (l *loader) load(name string) (ParseErrors, error) {
+    file, err := l.open(name)
+    if err != nil { return nil, err }
+    defer file.Close()
+    return l.loadReader(file, name)
+} // load
+
+// loadReader - parses a text as it is read, and loads the files
+// which it consults.
+// Params: reader
+//         file name
+// Return: syntax errors
+//         error, if the text cannot be read
+func (l *loader) loadReader(r io.Reader, name string) (ParseErrors, error) {
+    key := l.key(name)
+    l.loaded[key] = true
+    l.loading = append(l.loading, key)
+    module, started := l.module, l.started
+    l.module, l.started = "", false
+    defer func() {
+        l.loading = l.loading[:len(l.loading) - 1]
+        l.module, l.started = module, started
+    }()
+    _, _, errors, err := parseStream(r, name, l)
+    return errors, err
+} // loadReader
+
+// add - adds rules to the transaction, as they are parsed, so that the
+// rules of a large file are not all kept in a list. The goals of a rule
+// are resolved by the imports which have been read. (See module.go.)
+// The rules of modules, and rules whose heads are qualified by a module,
+// are kept until loading is finished, because their goals can only be
+// resolved when all the predicates of their modules are known.
+// Param: rules
+func (l *loader) add(rules []RuleStruct) {
+    ready := make([]RuleStruct, 0, len(rules))
+    r := &resolver{ l: l }
+    view := l.tx.KnowledgeBase()
+    for _, rule := range rules {
+        if _, _, ok := splitQualified(view, rule.head.GetFunctor());
+           ok || rule.module != "" {
+            l.pending = append(l.pending, rule)
+            continue
+        }
+        ready = append(ready, r.rule(rule))
+    }
+    l.errors = append(l.errors, r.errors...)
+    l.tx.Add(ready...)
+} // add
+
+// cycle - if the file is being loaded, returns the chain of files
+// which leads to it, eg. a.txt -> b.txt -> a.txt
+func (l *loader) cycle(name string) (string, bool) {
+    key := l.key(name)
+    for i, loading := range l.loading {
+        if loading == key {
+            chain := append(append([]string{}, l.loading[i:]...), key)
+            for j := range chain { chain[j] = filepath.Base(chain[j]) }
+            return strings.Join(chain, " -> "), true
+        }
+    }
+    return "", false
+}
+
+// initialize - runs the initialization goals. Each goal which fails
+// produces an error at the location of its directive.
+// Param:  knowledge base
+// Return: error or nil
+func (l *loader) initialize(kb KnowledgeBase) error {
+    errors := ParseErrors{}
+    for _, g := range l.goals {
+        restoreVariableId(0)
+        goal := g.goal.RecreateVariables(VarMap{}).(Goal)
+        _, failure := solveFirst(Complex{ Atom("initialization") },
+                                 func() SolutionNode {
+            return goal.GetSolver(kb, SubstitutionSet{}, nil)
+        })
+        if failure == "" { continue }
+        err := g.source
+        err.Message = fmt.Sprintf("Initialization goal failed: %v", g.goal)
+        if failure != "No" { err.Message += " (" + failure + ")" }
+        errors = append(errors, err)
+    }
+    if len(errors) > 0 { return errors }
+    return nil
+} // initialize
+
+// run - loads files into the knowledge base in a transaction. The
+// rules are added as they are parsed. Then the rules of modules are
+// resolved (see module.go), and the initialization goals are run. If
+// there are errors, or a goal fails, the transaction is rolled back,
+// and nothing is added.
+// Params: knowledge base
+//         function which loads the files, eg. l.load(fileName)
+// Return: error or nil
+func (l *loader) run(kb KnowledgeBase,
+                     load func() (ParseErrors, error)) error {
+    l.tx = kb.Begin()
+    l.ops = kb.operators()
+    errors, err := load()
+    if err == nil && len(errors) == 0 {
+        errors = l.resolveModules()
+    }
+    if err == nil && len(errors) == 0 {
+        err = l.initialize(l.tx.KnowledgeBase())
+    }
+    if err != nil || len(errors) > 0 {
+        l.tx.Rollback()
+        if err != nil { return err }
+        return errors
+    }
+    return l.tx.Commit()
+} // run
+
+//----------------------------------------------------------------
+// Directives
+//----------------------------------------------------------------
+
+// loadDirective - loads the files of consult/1, include/1,
+// ensure_loaded/1, use_module/1 or use_module/2. The rules of
+// the files go to the loader.
+// Params: first token of directive
+//         directive, eg. consult([a.txt, b.txt])
+func (p *parser) loadDirective(t token, directive Complex) {
+    name := string(directive[0].(Atom))
+    if p.loader == nil {
+        p.fail(t, fmt.Sprintf("%v/%d can only be used in a file which " +
+                              "is loaded into a knowledge base",
+                              name, len(directive) - 1))
+    }
+    once := name == "ensure_loaded" || name == "use_module"
+    for _, file := range listItems(directive[1]) {
+        atom, ok := file.(Atom)
+        if !ok { p.fail(t, fmt.Sprintf("Invalid file name: %v", file)) }
+        fileName := p.loader.resolve(p.file, atom.String())
+        if chain, ok := p.loader.cycle(fileName); ok {
+            p.fail(t, fmt.Sprintf("Circular %v: %v", name, chain))
+        }
+        if !once || !p.loader.loaded[p.loader.key(fileName)] {
+            errors, err := p.loader.load(fileName)
+            if err != nil { p.fail(t, err.Error()) }
+            p.errors = append(p.errors, errors...)
+        }
+        if name == "use_module" {
+            var imports Unifiable
+            if len(directive) == 3 { imports = directive[2] }
+            p.importModule(t, fileName, imports)
+        }
+    }
+    // The files may have defined operators.
+    p.lx.refreshOperators(p.ops)
+} // loadDirective
+
+// initialization - records the goal of initialization/1, which
+// runs after loading.
+// Params: first token of directive
+//         goal
+func (p *parser) initialization(t token, goal Goal) {
+    if p.loader == nil {
+        p.fail(t, "initialization/1 can only be used in a file which " +
+                  "is loaded into a knowledge base")
+    }
+    line, column := p.lx.position(t.start)
+    source := ParseError{ File: p.file, Line: line, Column: column,
+                         Excerpt: p.lx.lineText(line) }
+    p.loader.goals = append(p.loader.goals,
+                            initGoal{ goal, p.loader.module, source })
+}
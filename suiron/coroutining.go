@@ -0,0 +1,177 @@
+package suiron
+
+// Coroutining - built-in predicates which delay goals until their
+// variables are bound. (See suspension.go.)
+//
+//    freeze($X, Goal)            runs Goal when $X is bound
+//    when(Condition, Goal)       runs Goal when Condition is true
+//    dif($X, $Y)                 $X and $Y must remain different
+//
+// For example:
+//
+//    freeze($X, print(got, $X)), $X = 7
+//
+// The goal is a term, such as print(got, $X), or a call to a rule.
+// If the variable is already bound, the goal is run immediately.
+// Otherwise the goal is delayed, and run as soon as the variable is
+// bound, before the next goal of the conjunction.
+//
+// dif/2 succeeds if its terms cannot be unified, and fails if they
+// are identical. Otherwise, the constraint is recorded, and checked
+// again whenever the variables of the terms are bound.
+//
+// Goals which are still delayed at the end of a query are returned with
+// each solution by SolveWithDelayed() and SolveAllWithDelayed(). They can
+// also be listed by SubstitutionSet.DelayedGoals(). FormatSolution()
+// includes them.
+//
+// Cleve Lendon
+
+import (
+    "fmt"
+)
+
+type CoroutineStruct BuiltInPredicateStruct
+
+// makeCoroutine - creates a coroutining predicate, after
+// checking the number of arguments.
+func makeCoroutine(name string, arguments []Unifiable) CoroutineStruct {
+    if len(arguments) != 2 {
+        panic(fmt.Sprintf("%v - This predicate requires 2 arguments.", name))
+    }
+    return CoroutineStruct {
+        Name: name,
+        Arguments: arguments,
+    }
+}
+
+// Freeze - creates the predicate: freeze($X, Goal)
+func Freeze(arguments ...Unifiable) CoroutineStruct {
+    return makeCoroutine("freeze", arguments)
+}
+
+// When - creates the predicate: when(Condition, Goal)
+func When(arguments ...Unifiable) CoroutineStruct {
+    return makeCoroutine("when", arguments)
+}
+
+// Dif - creates the predicate: dif($X, $Y)
+func Dif(arguments ...Unifiable) CoroutineStruct {
+    return makeCoroutine("dif", arguments)
+}
+
+// GetSolver - gets a solution node for this predicate.
+// This function satisfies the Goal interface.
+func (s CoroutineStruct) GetSolver(kb KnowledgeBase,
+                                   parentSolution SubstitutionSet,
+                                   parentNode SolutionNode) SolutionNode {
+    return makeCoroutineSolutionNode(s, kb, parentSolution, parentNode)
+}
+
+//----------------------------------------------------------------
+// RecreateVariables(), ReplaceVariables(), and String() satisfy
+// the Expression interface.
+//----------------------------------------------------------------
+
+// RecreateVariables - Refer to comments in expression.go.
+func (s CoroutineStruct) RecreateVariables(vars VarMap) Expression {
+    bip := BuiltInPredicateStruct(s).RecreateVariables(vars)
+    return Expression(CoroutineStruct(*bip))
+}
+
+// ReplaceVariables - Refer to comments in expression.go.
+func (s CoroutineStruct) ReplaceVariables(ss SubstitutionSet) Expression {
+    return BuiltInPredicateStruct(s).ReplaceVariables(ss)
+}  // ReplaceVariables
+
+// String - creates a string representation.
+// Returns:  freeze($X, print($X))
+func (s CoroutineStruct) String() string {
+    return BuiltInPredicateStruct(s).String()
+}
+
+//----------------------------------------------------------------
+// Solution Node functions.
+//    makeCoroutineSolutionNode()
+//    NextSolution()
+//    SetNoBackTracking()
+//----------------------------------------------------------------
+
+// The solution node posts the constraint. If a goal is woken up
+// immediately, a child node solves it.
+type CoroutineSolutionNodeStruct struct {
+    SolutionNodeStruct
+    moreSolutions bool
+    child         SolutionNode
+}
+
+// makeCoroutineSolutionNode - creates a solution node for
+// a coroutining predicate.
+func makeCoroutineSolutionNode(goal Goal, kb KnowledgeBase,
+                               parentSolution SubstitutionSet,
+                               parentNode SolutionNode) SolutionNode {
+    node := CoroutineSolutionNodeStruct{
+                SolutionNodeStruct: MakeSolutionNode(goal, kb,
+                                        parentSolution, parentNode),
+                moreSolutions: true,
+            }
+    return &node
+}
+
+// NextSolution - posts the constraint. If the goal of freeze/2 or
+// when/2 can be run immediately, its solutions are returned.
+// Returns:
+//    updated substitution set
+//    success/failure flag
+// This function satisfies the SolutionNode interface.
+func (sn *CoroutineSolutionNodeStruct) NextSolution() (SubstitutionSet, bool) {
+
+    if sn.NoBackTracking { return nil, false }
+
+    if sn.child != nil { return sn.child.NextSolution() }
+    if !sn.moreSolutions { return nil, false }
+    sn.moreSolutions = false
+
+    goal := sn.Goal.(CoroutineStruct)
+    ss, ok := postCoroutine(goal.Name, goal.Arguments, sn.ParentSolution)
+    if !ok { return nil, false }
+
+    woken, ss := ss.takeWokenGoals()
+    if len(woken) == 0 { return ss, true }
+    sn.child = And(woken...).GetSolver(sn.KnowledgeBase, ss, sn)
+    return sn.child.NextSolution()
+
+} // NextSolution
+
+// SetNoBackTracking - set the NoBackTracking flag,
+// which is used to implement Cuts.
+// This function satisfies the SolutionNode interface.
+func (sn *CoroutineSolutionNodeStruct) SetNoBackTracking() {
+    sn.NoBackTracking = true
+}
+
+// GetParentNode
+func (sn *CoroutineSolutionNodeStruct) GetParentNode() SolutionNode {
+    return sn.ParentNode
+}
+
+// postCoroutine - posts a delayed goal or a dif constraint.
+// Params: name of predicate
+//         arguments
+//         substitution set
+// Return: new substitution set
+//         success/failure flag
+func postCoroutine(name string, arguments []Unifiable,
+                   ss SubstitutionSet) (SubstitutionSet, bool) {
+    var p propagator
+    switch name {
+    case "freeze":
+        condition := Complex{ Atom("nonvar"), arguments[0] }
+        p = &suspension{ name: name, condition: condition, goal: arguments[1] }
+    case "when":
+        p = &suspension{ name: name, condition: arguments[0], goal: arguments[1] }
+    default:
+        p = &difConstraint{ left: arguments[0], right: arguments[1] }
+    }
+    return propagateConstraints(ss, []propagator{ p })
+} // postCoroutine
@@ -0,0 +1,336 @@
+package suiron
+
+// Coverage - rule coverage, for test suites of rule files.
+//
+// Coverage records, for each clause (rule or fact) of a knowledge base,
+// how often its head unified with a goal, and how often its body
+// succeeded (the goal exited through the clause):
+//
+//    coverage := StartCoverage(kb)
+//    ... run tests ...
+//    coverage.Stop()
+//    fmt.Print(coverage.Text())
+//    coverage.WriteLCOV(file)
+//
+// Clauses are tied back to their source file and line, if they were
+// loaded by LoadKBFromFile(). There are three reports:
+//
+//    Text()      - a table of clauses, with a summary
+//    WriteHTML() - an HTML page, with a table for each file
+//    WriteLCOV() - the LCOV trace file format, for CI tools; a clause
+//                  is a line (DA) whose count is the number of head
+//                  unifications, and has two branches (BRDA): head
+//                  unified, and body succeeded
+//
+// Only the clauses of the given knowledge base are counted. They are
+// identified by their IDs (see Add() in knowledgebase.go), so goals
+// which are solved with an overlay, a transaction or a shared copy
+// of the knowledge base are counted too. Goals solved by the compiled
+// knowledge base (wam_machine.go) or by the strategies of search.go
+// are not counted. While coverage is collected, goals are traced,
+// which makes the search slower.
+//
+// Cleve Lendon
+
+import (
+    "fmt"
+    "html"
+    "io"
+    "sort"
+    "strings"
+    "sync"
+)
+
+// Coverage - collects the coverage of the clauses of a knowledge base.
+type Coverage struct {
+    mutex   sync.Mutex
+    kb      KnowledgeBase
+    counts  map[uint64]*clauseCoverage   // by ID of clause
+}
+
+// clauseCoverage - the counts of one clause.
+type clauseCoverage struct {
+    unified    int64
+    succeeded  int64
+}
+
+// ClauseCoverage - the coverage of one clause.
+type ClauseCoverage struct {
+    Key        string  // predicate, eg. father/2
+    Clause     int     // number of clause (1 = first)
+    File       string  // source file, or ""
+    Line       int     // source line, or 0
+    Rule       RuleStruct
+    Unified    int64   // times the head unified with a goal
+    Succeeded  int64   // times the body succeeded
+}
+
+// CoverageSummary - the totals of a coverage report.
+type CoverageSummary struct {
+    Clauses    int  // number of clauses
+    Unified    int  // clauses whose head unified
+    Succeeded  int  // clauses whose body succeeded
+}
+
+// StartCoverage - starts collecting the coverage of a knowledge base.
+// Coverage which was being collected before is stopped.
+// Param:  knowledge base
+// Return: coverage
+func StartCoverage(kb KnowledgeBase) *Coverage {
+    c := &Coverage{ kb: kb, counts: map[uint64]*clauseCoverage{} }
+    traceState.mutex.Lock()
+    defer traceState.mutex.Unlock()
+    traceState.coverage = c
+    updateTraceActive()
+    return c
+}
+
+// Stop - stops collecting coverage. The results remain available.
+func (c *Coverage) Stop() {
+    traceState.mutex.Lock()
+    defer traceState.mutex.Unlock()
+    if traceState.coverage == c {
+        traceState.coverage = nil
+        updateTraceActive()
+    }
+}
+
+// port - counts the Unify and Exit ports of a clause. A clause is
+// identified by its ID, which it keeps in overlays and transactions of
+// the knowledge base, so goals which are solved with them are counted.
+// Params: port
+//         clause
+func (c *Coverage) port(port Port, rule RuleStruct) {
+    if rule.id == 0 || (port != UnifyPort && port != ExitPort) { return }
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    id := rule.id
+    counts := c.counts[id]
+    if counts == nil {
+        counts = &clauseCoverage{}
+        c.counts[id] = counts
+    }
+    if port == UnifyPort {
+        counts.unified++
+    } else {
+        counts.succeeded++
+    }
+}
+
+// Clauses - returns the coverage of all clauses of the knowledge base,
+// sorted by file and line. Clauses without a source follow, sorted
+// by predicate.
+func (c *Coverage) Clauses() []ClauseCoverage {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    clauses := []ClauseCoverage{}
+    for key, rules := range c.kb.Flatten() {
+        if isSystemKey(key) { continue }
+        for i, rule := range rules {
+            cc := ClauseCoverage{ Key: key, Clause: i + 1,
+                                  File: rule.source.File,
+                                  Line: rule.source.Line, Rule: rule }
+            if counts := c.counts[rule.id]; counts != nil {
+                cc.Unified, cc.Succeeded = counts.unified, counts.succeeded
+            }
+            clauses = append(clauses, cc)
+        }
+    }
+    sort.Slice(clauses, func(i, j int) bool {
+        a, b := clauses[i], clauses[j]
+        if (a.Line > 0) != (b.Line > 0) { return a.Line > 0 }
+        if a.File != b.File { return a.File < b.File }
+        if a.Line != b.Line { return a.Line < b.Line }
+        if a.Key != b.Key { return a.Key < b.Key }
+        return a.Clause < b.Clause
+    })
+    return clauses
+} // Clauses
+
+// summarize - computes the totals of a list of clauses.
+func summarize(clauses []ClauseCoverage) CoverageSummary {
+    s := CoverageSummary{ Clauses: len(clauses) }
+    for _, cc := range clauses {
+        if cc.Unified > 0 { s.Unified++ }
+        if cc.Succeeded > 0 { s.Succeeded++ }
+    }
+    return s
+}
+
+// Summary - returns the totals of the coverage.
+func (c *Coverage) Summary() CoverageSummary {
+    return summarize(c.Clauses())
+}
+
+// percent - formats a fraction as a percentage, eg. 75.0%
+func percent(count, total int) string {
+    if total == 0 { return "100.0%" }
+    return fmt.Sprintf("%.1f%%", float64(count) * 100 / float64(total))
+}
+
+// String - formats the summary, eg.:
+// 8 of 10 clauses unified (80.0%), 6 of 10 succeeded (60.0%)
+func (s CoverageSummary) String() string {
+    return fmt.Sprintf("%v of %v clauses unified (%v), " +
+                       "%v of %v succeeded (%v)",
+                       s.Unified, s.Clauses, percent(s.Unified, s.Clauses),
+                       s.Succeeded, s.Clauses, percent(s.Succeeded, s.Clauses))
+}
+
+// source - formats the source of a clause, eg. kings.txt:28
+func (cc ClauseCoverage) source() string {
+    if cc.Line == 0 { return "-" }
+    return fmt.Sprintf("%v:%v", cc.File, cc.Line)
+}
+
+// Text - formats the coverage as a table of clauses, followed by
+// the summary. Clauses which were never used are marked with ***.
+func (c *Coverage) Text() string {
+    clauses := c.Clauses()
+    var sb strings.Builder
+    format := "%-24v %-24v %6v %9v %9v %v\n"
+    sb.WriteString(fmt.Sprintf(format, "Source", "Predicate", "Clause",
+                               "Unified", "Succeeded", ""))
+    for _, cc := range clauses {
+        mark := ""
+        if cc.Unified == 0 { mark = "***" }
+        sb.WriteString(fmt.Sprintf(format, cc.source(), cc.Key, cc.Clause,
+                                   cc.Unified, cc.Succeeded, mark))
+    }
+    sb.WriteString(summarize(clauses).String() + "\n")
+    return sb.String()
+} // Text
+
+// groupByFile - groups clauses by source file. Clauses without
+// a source are grouped under "".
+// Return: file names (sorted)
+//         clauses of each file
+func groupByFile(clauses []ClauseCoverage) ([]string, map[string][]ClauseCoverage) {
+    files := []string{}
+    byFile := map[string][]ClauseCoverage{}
+    for _, cc := range clauses {
+        if _, ok := byFile[cc.File]; !ok { files = append(files, cc.File) }
+        byFile[cc.File] = append(byFile[cc.File], cc)
+    }
+    sort.Strings(files)
+    return files, byFile
+}
+
+// WriteHTML - writes the coverage as an HTML page, with a table of
+// clauses for each source file. Clauses whose body succeeded are green,
+// clauses whose head unified are yellow, and unused clauses are red.
+// Param:  writer
+// Return: error
+func (c *Coverage) WriteHTML(w io.Writer) error {
+    clauses := c.Clauses()
+    files, byFile := groupByFile(clauses)
+    var sb strings.Builder
+    sb.WriteString("<!DOCTYPE html>\n<html>\n<head>\n" +
+                   "<meta charset=\"utf-8\">\n<title>Rule coverage</title>\n" +
+                   "<style>\n" +
+                   "table { border-collapse: collapse; }\n" +
+                   "td, th { border: 1px solid #ccc; padding: 2px 6px; }\n" +
+                   "td.rule { font-family: monospace; }\n" +
+                   "tr.succeeded { background: #dfd; }\n" +
+                   "tr.unified { background: #ffd; }\n" +
+                   "tr.unused { background: #fdd; }\n" +
+                   "</style>\n</head>\n<body>\n")
+    sb.WriteString(fmt.Sprintf("<h1>Rule coverage</h1>\n<p>%v</p>\n",
+                               html.EscapeString(summarize(clauses).String())))
+    for _, file := range files {
+        name := file
+        if name == "" { name = "(no source)" }
+        sb.WriteString(fmt.Sprintf("<h2>%v</h2>\n<p>%v</p>\n",
+                       html.EscapeString(name),
+                       html.EscapeString(summarize(byFile[file]).String())))
+        sb.WriteString("<table>\n<tr><th>Line</th><th>Predicate</th>" +
+                       "<th>Clause</th><th>Unified</th><th>Succeeded</th>" +
+                       "<th>Rule</th></tr>\n")
+        for _, cc := range byFile[file] {
+            class := "unused"
+            if cc.Succeeded > 0 {
+                class = "succeeded"
+            } else if cc.Unified > 0 {
+                class = "unified"
+            }
+            line := ""
+            if cc.Line > 0 { line = fmt.Sprint(cc.Line) }
+            sb.WriteString(fmt.Sprintf("<tr class=\"%v\"><td>%v</td>" +
+                "<td>%v</td><td>%v</td><td>%v</td><td>%v</td>" +
+                "<td class=\"rule\">%v</td></tr>\n", class, line,
+                html.EscapeString(cc.Key), cc.Clause, cc.Unified,
+                cc.Succeeded, html.EscapeString(cc.Rule.String())))
+        }
+        sb.WriteString("</table>\n")
+    }
+    sb.WriteString("</body>\n</html>\n")
+    _, err := io.WriteString(w, sb.String())
+    return err
+} // WriteHTML
+
+// WriteLCOV - writes the coverage in the LCOV trace file format.
+// Each clause is a line (DA), and a predicate is a function (FN).
+// Branch 0 of a clause is 'head unified', branch 1 is 'body succeeded'.
+// Clauses without a source are not written.
+// Param:  writer
+// Return: error
+func (c *Coverage) WriteLCOV(w io.Writer) error {
+    files, byFile := groupByFile(c.Clauses())
+    var sb strings.Builder
+    for _, file := range files {
+        if file == "" { continue }
+        clauses := byFile[file]
+        sb.WriteString("TN:\nSF:" + file + "\n")
+
+        // Predicates: the line of the first clause, and the number
+        // of head unifications.
+        keys := []string{}
+        first := map[string]int{}
+        calls := map[string]int64{}
+        for _, cc := range clauses {
+            if _, ok := first[cc.Key]; !ok {
+                keys = append(keys, cc.Key)
+                first[cc.Key] = cc.Line
+            }
+            calls[cc.Key] += cc.Unified
+        }
+        hit := 0
+        for _, key := range keys {
+            sb.WriteString(fmt.Sprintf("FN:%v,%v\n", first[key], key))
+        }
+        for _, key := range keys {
+            sb.WriteString(fmt.Sprintf("FNDA:%v,%v\n", calls[key], key))
+            if calls[key] > 0 { hit++ }
+        }
+        sb.WriteString(fmt.Sprintf("FNF:%v\nFNH:%v\n", len(keys), hit))
+
+        branchesHit := 0
+        for _, cc := range clauses {
+            taken := []int64{ cc.Unified, cc.Succeeded }
+            for branch, count := range taken {
+                sb.WriteString(fmt.Sprintf("BRDA:%v,%v,%v,%v\n",
+                               cc.Line, cc.Clause - 1, branch, count))
+                if count > 0 { branchesHit++ }
+            }
+        }
+        sb.WriteString(fmt.Sprintf("BRF:%v\nBRH:%v\n",
+                                   2 * len(clauses), branchesHit))
+
+        // Lines. Two clauses may begin on the same line.
+        lines := []int{}
+        counts := map[int]int64{}
+        for _, cc := range clauses {
+            if _, ok := counts[cc.Line]; !ok { lines = append(lines, cc.Line) }
+            counts[cc.Line] += cc.Unified
+        }
+        linesHit := 0
+        for _, line := range lines {
+            sb.WriteString(fmt.Sprintf("DA:%v,%v\n", line, counts[line]))
+            if counts[line] > 0 { linesHit++ }
+        }
+        sb.WriteString(fmt.Sprintf("LF:%v\nLH:%v\nend_of_record\n",
+                                   len(lines), linesHit))
+    }
+    _, err := io.WriteString(w, sb.String())
+    return err
+} // WriteLCOV
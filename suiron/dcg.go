@@ -0,0 +1,226 @@
+package suiron
+
+// DCG - Definite Clause Grammar rules. A grammar rule has an arrow
+// (-->) instead of a neck (:-). For example:
+//
+//    greeting --> [hello], name.
+//    name --> [world].
+//    name --> [Cleve].
+//
+// Grammar rules are translated into ordinary rules when they are
+// read. Each nonterminal (greeting, name) gets two extra arguments:
+// the list of words to parse, and the list of words which remain
+// after parsing. The rules above become:
+//
+//    greeting($S0, $S) :- $S0 = [hello | $S1], name($S1, $S).
+//    name($S0, $S) :- $S0 = [world | $S].
+//    name($S0, $S) :- $S0 = [Cleve | $S].
+//
+// The body of a grammar rule may contain:
+//
+//    nonterminals     noun($N)     noun($N, $S0, $S)
+//    terminals        [the, cat]   $S0 = [the, cat | $S]
+//    empty list       []           $S0 = $S
+//    goals in braces  {$N > 2}     $N > 2, $S0 = $S
+//    cut              !            !, $S0 = $S
+//    groups           (a ; b)      a($S0, $S); b($S0, $S)
+//
+// A list after the head is pushed back onto the remaining words.
+// The following rule parses 'not' and leaves 'not' in the list:
+//
+//    peek_not, [not] --> [not].
+//
+// The variables $S0, $S, $S1 etc. are new variables. If a rule has
+// a variable with the same name, a suffix is added: $S1_1.
+//
+// The built-in predicates phrase/2 and phrase/3 call a nonterminal
+// with a list of words. (See phrase.go.)
+//
+// Cleve Lendon
+
+import (
+    "fmt"
+)
+
+// dcgBody - a parsed grammar body. It makes the goal which parses
+// the words from list s0 to list s.
+type dcgBody func(d *dcgTranslator, s0, s Unifiable) Goal
+
+// dcgTranslator - makes new variables for a grammar rule.
+type dcgTranslator struct {
+    names  map[string]bool  // names of variables in the rule
+    count  int              // number of the last intermediate list
+}
+
+// fresh - makes a variable whose name is not used in the rule.
+// Param:  name, eg. $S0
+// Return: variable
+func (d *dcgTranslator) fresh(name string) VariableStruct {
+    candidate := name
+    for i := 1; d.names[candidate]; i++ {
+        candidate = fmt.Sprintf("%v_%d", name, i)
+    }
+    d.names[candidate] = true
+    v, _ := LogicVar(candidate)
+    return v
+}
+
+// intermediate - makes a variable for a list between two parts
+// of a grammar body: $S1, $S2, etc.
+func (d *dcgTranslator) intermediate() VariableStruct {
+    d.count++
+    return d.fresh(fmt.Sprintf("$S%d", d.count))
+}
+
+// translateDCG - translates a grammar rule into an ordinary rule.
+// Params: head
+//         pushback list, or nil
+//         body
+// Return: head and body of the rule
+func (p *parser) translateDCG(head Complex, pushback Unifiable,
+                              body dcgBody) (Complex, Goal) {
+    d := &dcgTranslator{ names: p.names }
+    s0, s := d.fresh("$S0"), d.fresh("$S")
+    var goal Goal
+    if pushback == nil {
+        goal = body(d, s0, s)
+    } else {
+        middle := d.intermediate()
+        goal = dcgAnd(body(d, s0, middle),
+                      Unify(s, appendTail(pushback, middle)))
+    }
+    newHead := make(Complex, 0, len(head) + 2)
+    newHead = append(append(newHead, head...), s0, s)
+    return newHead, goal
+} // translateDCG
+
+// dcgAnd - makes a conjunction. Conjunctions in the operands are
+// flattened.
+func dcgAnd(operands ...Goal) Goal {
+    goals := []Goal{}
+    for _, operand := range operands {
+        if and, ok := operand.(AndOp); ok {
+            goals = append(goals, and...)
+        } else {
+            goals = append(goals, operand)
+        }
+    }
+    if len(goals) == 1 { return goals[0] }
+    return And(goals...)
+}
+
+// appendTail - makes a list of terminals with the given tail.
+// Eg.: [the, cat] and $S -> [the, cat | $S]
+func appendTail(terminals Unifiable, tail Unifiable) Unifiable {
+    list := terminals.(LinkedListStruct)
+    if list.count == 0 { return tail }
+    items := []Unifiable{}
+    for ptr := &list; ptr != nil && ptr.term != nil; ptr = ptr.next {
+        items = append(items, ptr.term)
+    }
+    return MakeLinkedList(true, append(items, tail)...)
+}
+
+// parseDCGTerminals - parses a list of terminals. The list cannot
+// have a tail variable.
+// Return: list
+func (p *parser) parseDCGTerminals() Unifiable {
+    t := p.next(goalMode)
+    list := p.parseList(t)
+    for ptr := &list; ptr != nil && ptr.term != nil; ptr = ptr.next {
+        if ptr.tailVar {
+            p.fail(t, fmt.Sprintf("Invalid list of terminals: %v", list))
+        }
+    }
+    return list
+}
+
+// parseDCGBody - parses the body of a grammar rule: parts separated
+// by commas (and) and semicolons (or).
+func (p *parser) parseDCGBody() dcgBody {
+    alternatives := []dcgBody{ p.parseDCGSequence() }
+    for p.peek(goalMode).kind == tkSemicolon {
+        p.next(goalMode)
+        alternatives = append(alternatives, p.parseDCGSequence())
+    }
+    if len(alternatives) == 1 { return alternatives[0] }
+    return func(d *dcgTranslator, s0, s Unifiable) Goal {
+        goals := []Goal{}
+        for _, alternative := range alternatives {
+            goals = append(goals, alternative(d, s0, s))
+        }
+        return Or(goals...)
+    }
+} // parseDCGBody
+
+// parseDCGSequence - parses parts separated by commas. Each part
+// parses from the list where the previous part ended.
+func (p *parser) parseDCGSequence() dcgBody {
+    parts := []dcgBody{ p.parseDCGPart() }
+    for p.peek(goalMode).kind == tkComma {
+        p.next(goalMode)
+        parts = append(parts, p.parseDCGPart())
+    }
+    if len(parts) == 1 { return parts[0] }
+    return func(d *dcgTranslator, s0, s Unifiable) Goal {
+        goals := []Goal{}
+        in := s0
+        for i, part := range parts {
+            var out Unifiable = s
+            if i < len(parts) - 1 { out = d.intermediate() }
+            goals = append(goals, part(d, in, out))
+            in = out
+        }
+        return dcgAnd(goals...)
+    }
+} // parseDCGSequence
+
+// parseDCGPart - parses one part of a grammar body: a group in
+// parentheses, a list of terminals, goals in braces, a cut, or a
+// nonterminal.
+func (p *parser) parseDCGPart() dcgBody {
+    t := p.peek(goalMode)
+    switch t.kind {
+    case tkLParen:
+        p.next(goalMode)
+        body := p.parseDCGBody()
+        p.expect(goalMode, tkRParen, "closing parenthesis")
+        return body
+    case tkLBracket:
+        terminals := p.parseDCGTerminals()
+        return func(d *dcgTranslator, s0, s Unifiable) Goal {
+            return Unify(s0, appendTail(terminals, s))
+        }
+    case tkLBrace:
+        p.next(goalMode)
+        goal := p.parseBody()
+        p.expect(goalMode, tkRBrace, "closing brace")
+        return func(d *dcgTranslator, s0, s Unifiable) Goal {
+            return dcgAnd(goal, Unify(s0, s))
+        }
+    }
+    term, op := p.parseExpression(goalMode, 999)
+    if goalOperators[op] {
+        c := term.(Complex)
+        p.fail(t, fmt.Sprintf("Invalid nonterminal: %v %v %v. " +
+                              "Goals must be in braces.", c[1], op, c[2]))
+    }
+    switch nt := term.(type) {
+    case Atom:
+        if nt == "!" && !t.escaped {
+            return func(d *dcgTranslator, s0, s Unifiable) Goal {
+                return And(Cut(), Unify(s0, s))
+            }
+        }
+        return func(d *dcgTranslator, s0, s Unifiable) Goal {
+            return Complex{ nt, s0, s }
+        }
+    case Complex:
+        return func(d *dcgTranslator, s0, s Unifiable) Goal {
+            c := make(Complex, 0, len(nt) + 2)
+            return append(append(c, nt...), s0, s)
+        }
+    }
+    p.fail(t, fmt.Sprintf("Invalid nonterminal: %v", term))
+    return nil
+} // parseDCGPart
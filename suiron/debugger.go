@@ -0,0 +1,270 @@
+package suiron
+
+// Debugger - an interactive step debugger, in the style of Prolog
+// debuggers. It is a tracer (see trace.go) which stops at the ports
+// of goals and asks the user what to do:
+//
+//    SetTracer(MakeDebugger(os.Stdin, os.Stdout))
+//    TraceOn()
+//
+//     Call: (1) parent($X_1, $Z_3) ?
+//
+// The commands are:
+//
+//    c (or enter) - creep: go to the next port
+//    s            - skip: run the goal without stopping, to its
+//                   Exit or Fail port
+//    l            - leap: run to the next spy point or breakpoint
+//    f            - fail: make the goal fail
+//    r            - retry: restart the goal from its Call port
+//    a            - abort: stop the search
+//    n            - nodebug: run to the end without stopping
+//    p            - print the goal, with its bindings and source
+//    h            - help
+//
+// Breakpoints can be set on predicates, as spy points (father/2), or
+// on the line of a source file (kings.txt:27). A line breakpoint
+// stops at the Unify port of the clause which begins on that line.
+// The debugger needs only the file and line of each clause, which it
+// takes from the clause's source location (Source() in rule.go).
+// Clauses which were not loaded from a file have no line.
+//
+// Start() must be called before each query.
+//
+// The solver waits while the debugger reads a command. (See DebugTracer
+// in trace.go.) An abort stops only the query which is being debugged.
+// (See timeout.go.)
+//
+// Cleve Lendon
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "sync"
+)
+
+// Modes of the debugger.
+const (
+    debugCreep = iota  // stop at every port
+    debugSkip          // stop when the skipped goal exits or fails
+    debugLeap          // stop at spy points and breakpoints
+    debugOff           // do not stop
+)
+
+// lineBreakpoint - a breakpoint on a line of a source file.
+type lineBreakpoint struct {
+    file  string
+    line  int
+}
+
+// Debugger - reads commands at the ports of goals.
+type Debugger struct {
+    mutex        sync.Mutex
+    reader       *bufio.Reader
+    writer       io.Writer
+    mode         int
+    skipDepth    int
+    breakpoints  []lineBreakpoint
+    aborted      bool
+}
+
+// MakeDebugger - makes a debugger which reads commands from the
+// given reader, and writes to the given writer.
+// Params: reader (eg. os.Stdin)
+//         writer (eg. os.Stdout)
+// Return: debugger
+func MakeDebugger(r io.Reader, w io.Writer) *Debugger {
+    reader, ok := r.(*bufio.Reader)
+    if !ok { reader = bufio.NewReader(r) }
+    return &Debugger{ reader: reader, writer: w }
+}
+
+// Start - prepares the debugger for a new query.
+// Param: true to stop at the first port (trace),
+//        false to leap to the first spy point or breakpoint (debug)
+func (d *Debugger) Start(creep bool) {
+    d.mutex.Lock()
+    defer d.mutex.Unlock()
+    d.mode = debugLeap
+    if creep { d.mode = debugCreep }
+    d.aborted = false
+}
+
+// Aborted - returns true if the user aborted the last query.
+func (d *Debugger) Aborted() bool {
+    d.mutex.Lock()
+    defer d.mutex.Unlock()
+    return d.aborted
+}
+
+// SetBreakpoint - sets a breakpoint, on a predicate or on a line.
+// Param:  predicate (father/2, father) or file:line (kings.txt:27)
+// Return: error
+func (d *Debugger) SetBreakpoint(spec string) error {
+    bp, isLine, err := parseBreakpoint(spec)
+    if err != nil { return err }
+    if !isLine {
+        Spy(spec)
+        return nil
+    }
+    d.mutex.Lock()
+    defer d.mutex.Unlock()
+    for _, b := range d.breakpoints {
+        if b == bp { return nil }
+    }
+    d.breakpoints = append(d.breakpoints, bp)
+    ShowUnifyPort(true)  // Line breakpoints stop at the Unify port.
+    return nil
+}
+
+// RemoveBreakpoint - removes a breakpoint.
+// Param:  predicate or file:line
+// Return: error
+func (d *Debugger) RemoveBreakpoint(spec string) error {
+    bp, isLine, err := parseBreakpoint(spec)
+    if err != nil { return err }
+    if !isLine {
+        NoSpy(spec)
+        return nil
+    }
+    d.mutex.Lock()
+    defer d.mutex.Unlock()
+    for i, b := range d.breakpoints {
+        if b == bp {
+            d.breakpoints = append(d.breakpoints[:i:i], d.breakpoints[i + 1:]...)
+            break
+        }
+    }
+    if len(d.breakpoints) == 0 { ShowUnifyPort(false) }
+    return nil
+}
+
+// parseBreakpoint - parses the specification of a breakpoint.
+// Param:   predicate or file:line
+// Returns: line breakpoint
+//          true if the breakpoint is on a line
+//          error
+func parseBreakpoint(spec string) (lineBreakpoint, bool, error) {
+    spec = strings.TrimSpace(spec)
+    if len(spec) == 0 {
+        return lineBreakpoint{}, false, fmt.Errorf("Missing breakpoint.")
+    }
+    i := strings.LastIndex(spec, ":")
+    if i < 0 { return lineBreakpoint{}, false, nil }
+    line, err := strconv.Atoi(spec[i + 1:])
+    if err != nil || line < 1 || i == 0 {
+        return lineBreakpoint{}, false,
+               fmt.Errorf("Invalid breakpoint: %v", spec)
+    }
+    return lineBreakpoint{ file: spec[:i], line: line }, true, nil
+}
+
+// atBreakpoint - returns true if the clause of an event begins on
+// a line with a breakpoint. The lock must be held.
+func (d *Debugger) atBreakpoint(e TraceEvent) bool {
+    if e.Line == 0 { return false }
+    for _, b := range d.breakpoints {
+        if b.matches(e.File, e.Line) { return true }
+    }
+    return false
+}
+
+// matches - returns true if a clause which begins at the given file
+// and line is at the breakpoint. File names match if their base names
+// are the same.
+func (b lineBreakpoint) matches(file string, line int) bool {
+    if b.line != line { return false }
+    return b.file == file || filepath.Base(b.file) == filepath.Base(file)
+}
+
+// stops - returns true if the debugger should stop at an event.
+// The lock must be held.
+func (d *Debugger) stops(e TraceEvent) bool {
+    if d.mode == debugOff { return false }
+    if e.Port == UnifyPort { return d.atBreakpoint(e) }
+    switch d.mode {
+    case debugSkip:
+        return e.Depth <= d.skipDepth
+    case debugLeap:
+        return IsSpyPoint(e.Key)
+    }
+    return true
+}
+
+// Trace - writes an event.
+// This function satisfies the Tracer interface.
+func (d *Debugger) Trace(e TraceEvent) {
+    d.mutex.Lock()
+    defer d.mutex.Unlock()
+    fmt.Fprint(d.writer, FormatTraceEvent(e), "\n")
+}
+
+// Debug - stops at a port, if necessary, and reads commands until
+// the search can continue. At the end of input, the search continues
+// without stopping.
+// This function satisfies the DebugTracer interface.
+// Param:  event
+// Return: action for the solver
+func (d *Debugger) Debug(e TraceEvent) DebugAction {
+    d.mutex.Lock()
+    defer d.mutex.Unlock()
+    if !d.stops(e) { return DebugContinue }
+    d.mode = debugCreep
+    for {
+        fmt.Fprint(d.writer, FormatTraceEvent(e), " ? ")
+        line, err := d.reader.ReadString('\n')
+        if err != nil && len(line) == 0 {
+            fmt.Fprintln(d.writer)
+            d.mode = debugOff
+            return DebugContinue
+        }
+        switch strings.TrimSpace(line) {
+        case "", "c":
+            return DebugContinue
+        case "s":
+            if e.Port == CallPort || e.Port == RedoPort || e.Port == UnifyPort {
+                d.mode = debugSkip
+                d.skipDepth = e.Depth
+            }
+            return DebugContinue
+        case "l":
+            d.mode = debugLeap
+            return DebugContinue
+        case "n":
+            d.mode = debugOff
+            return DebugContinue
+        case "f":
+            return DebugFail
+        case "r":
+            return DebugRetry
+        case "a":
+            d.aborted = true
+            return DebugAbort
+        case "p":
+            fmt.Fprintln(d.writer, "   ", e.Goal)
+            if e.Line > 0 {
+                fmt.Fprintf(d.writer, "    clause %v, %v\n", e.Clause,
+                            SourceLocation{ e.File, e.Line, e.Column })
+            }
+        case "h", "?":
+            fmt.Fprint(d.writer, debuggerHelp)
+        default:
+            fmt.Fprintln(d.writer, "Unknown command. Type h for help.")
+        }
+    }
+} // Debug
+
+const debuggerHelp = `    c, enter - creep: go to the next port
+    s - skip: run to the Exit or Fail port of this goal
+    l - leap: run to the next spy point or breakpoint
+    f - fail: make this goal fail
+    r - retry: restart this goal
+    a - abort: stop the search
+    n - nodebug: run to the end
+    p - print the goal and its source
+    h - help
+`
@@ -0,0 +1,814 @@
+package suiron
+
+// Engine - an iterative solver for complex terms (goals which are
+// solved by the rules and facts of the knowledge base).
+//
+// The solution nodes of operators and built-in predicates call each
+// other recursively. Each subgoal of a long recursive predicate adds
+// to the Go stack. The engine avoids this by keeping the search state
+// in two explicit data structures:
+//
+// The continuation is a linked list of the goals which remain to be
+// solved. When a rule is used to solve a goal, the goals of the rule's
+// body are put in front of the continuation. Nothing else is kept for
+// the rule, so the last goal of a body does not need a stack frame.
+// (This is called last call optimization.) A tail-recursive predicate
+// runs with a constant amount of stack.
+//
+// The depth of recursion is not limited by the stack, but by time and
+// memory. The engine binds variables in a substitution set of its own,
+// without copying it, and records the bindings on a trail, so that they
+// can be undone when it backtracks. (See trail.go.) A recursion takes
+// time in proportion to its depth. Walking a list of 1,000,000 items
+// takes a few seconds.
+//
+// The choice point stack records the alternatives which remain to be
+// tried: untried rules of a goal, the remaining operands of an Or,
+// or a built-in predicate which may have more solutions. When a goal
+// fails, the engine backtracks to the most recent choice point. If a
+// choice point has no more alternatives after the one being tried,
+// it is removed immediately.
+//
+// Each goal of the continuation records the height of the choice point
+// stack when the rule it came from was called. A cut (!) removes all
+// choice points above that height. This is the same as the cut of the
+// recursive solution nodes: alternatives for the rule's goal, and for
+// the goals before the cut, are discarded.
+//
+// And, Or, Cut, Fail and Unify are handled by the engine directly.
+// Other built-in predicates are solved by their own solution nodes.
+//
+// The engine can also solve the body of a single clause, for parallel
+// searches. (See parallel.go.) A cut in that body is reported, because
+// it prunes the clauses which follow, and the search can be cancelled.
+//
+// Each goal records its depth: the number of rules which were used to
+// reach it. The engine can limit the depth of the search, for the
+// search strategies of search.go.
+//
+// Built-in predicates such as not() solve their goals with engines of
+// their own. The engine passes a search context to a built-in predicate
+// as its parent node. A nested engine finds the context by following
+// the chain of parent nodes, and takes the depth of the built-in's goal,
+// the depth limit and the query limits (limits.go) from it. The context
+// is also the limit of a cut.
+//
+// When tracing is on (see trace.go), the engine reports the Call, Exit,
+// Redo and Fail ports of goals. To report Exit, a marker is put in the
+// continuation after the body of each rule. A debugger (debugger.go)
+// can make a goal fail at any port, or retry it from its Call port.
+//
+// To explain solutions (explain.go), the engine keeps a log of the
+// steps of the current proof: rules which were entered and exited, and
+// built-in predicates which succeeded. The log is a linked list, which
+// is saved in choice points, so backtracking restores it.
+//
+// When the profiler (profiler.go) is running, each goal records the
+// clause whose body it came from, as a node in a tree of stacks. The
+// time of each step is charged to the stack of the goal.
+//
+// Cleve Lendon
+
+import (
+    "sync/atomic"
+    "time"
+)
+
+// goalFrame - one goal of the continuation.
+type goalFrame struct {
+    goal    Goal
+    cutTo   int        // height of choice point stack, for cut
+                       // (-1 for the body of a clause in a parallel search)
+    depth   int        // number of rules used to reach this goal
+    exit    *traceExit // Exit marker, for tracing and explanations
+                       // (goal is nil)
+    prof    *profileNode  // clause of this goal, for the profiler
+    next    *goalFrame
+}
+
+// Kinds of choice points.
+const (
+    cpRules = iota   // untried rules of a goal
+    cpOr             // remaining operands of an Or
+    cpNode           // solution node which may have more solutions
+)
+
+// choicePoint - records alternatives which remain to be tried.
+type choicePoint struct {
+    kind         int
+    mark         int         // length of the trail (see trail.go)
+    continuation *goalFrame  // goals after the alternative
+    cutTo        int
+    pathDepth    int         // depth of the proof, before the alternative
+    goal         Complex     // cpRules
+    depth        int         // cpRules - depth of goal
+    ruleNumber   int         // cpRules - next rule to try
+    operands     []Goal      // cpOr
+    node         SolutionNode  // cpNode
+    builtIn      Goal          // cpNode - for tracing
+    context      *searchContext  // cpNode - context of the node
+    proof        *proofStep    // log of the proof, before the alternative
+    prof         *profileNode  // clause of the alternative, for the profiler
+}
+
+// EngineSolutionNodeStruct - the solution node for a complex term.
+type EngineSolutionNodeStruct struct {
+    SolutionNodeStruct
+    started    bool
+    stack      []choicePoint
+    clauseBody bool    // solving the body of a clause (parallel.go)
+    onCut      func()  // called when the body's cut is executed
+    cancelled  *int32  // set to 1 to stop the search
+    maxDepth   int     // goals at this depth fail (0 = no limit)
+    minDepth   int     // only proofs deeper than this are solutions
+    pathDepth  int     // depth of the current proof
+    limitHit   *bool   // set to true when a goal fails at maxDepth
+    explored   int     // 1 + depth of the deepest goal whose rules were tried
+    limits     *queryLimits  // resource limits (limits.go)
+    trail      *bindingTrail   // bindings of the search (trail.go)
+    context    *searchContext  // context of a nested engine, or nil
+    stopContext  *searchContext  // context for built-in predicates,
+                                 // when the search is not limited
+    explain    bool          // keep a log of the proof (explain.go)
+    proof      *proofStep    // log of the current proof
+    profiler   *Profiler     // profiler.go
+    profNode   *profileNode  // clause of the current goal
+    profLeaf   *profileNode  // top of the stack of the current step
+    profMark   time.Time     // start of the current step
+}
+
+// makeEngineSolutionNode - creates a solution node which solves
+// a complex term iteratively.
+// Params: goal
+//         knowledge base
+//         parent solution (substitution set)
+//         parent node
+// Return: solution node
+func makeEngineSolutionNode(goal Complex, kb KnowledgeBase,
+                            parentSolution SubstitutionSet,
+                            parentNode SolutionNode) SolutionNode {
+    // The parent node is nil. The engine is the limit of a cut.
+    node := EngineSolutionNodeStruct{
+                SolutionNodeStruct: MakeSolutionNode(goal, kb,
+                                        parentSolution, nil),
+            }
+    node.stop = stopFlagOf(parentNode)
+    if ctx := findSearchContext(parentNode); ctx != nil {
+        node.context = ctx
+        node.maxDepth = ctx.maxDepth
+        node.limitHit = ctx.limitHit
+        node.limits = ctx.limits
+    }
+    return &node
+}
+
+// makeClauseBodySolutionNode - creates a solution node which solves
+// the body of one clause, for a parallel search.
+// Params: body of clause
+//         knowledge base
+//         substitution set, after the head has been unified
+//         function to call when the clause's cut is executed
+//         cancel flag
+// Return: solution node
+func makeClauseBodySolutionNode(body Goal, kb KnowledgeBase,
+                                ss SubstitutionSet, onCut func(),
+                                cancelled *int32) *EngineSolutionNodeStruct {
+    if body == nil { body = And() }
+    node := EngineSolutionNodeStruct{
+                SolutionNodeStruct: MakeSolutionNode(body, kb, ss, nil),
+                clauseBody: true,
+                onCut: onCut,
+                cancelled: cancelled,
+            }
+    return &node
+}
+
+// NextSolution - initiates or continues the search for a solution.
+// Returns:
+//    updated substitution set
+//    success/failure flag
+// This function satisfies the SolutionNode interface.
+func (n *EngineSolutionNodeStruct) NextSolution() (SubstitutionSet, bool) {
+    if n.NoBackTracking { return nil, false }
+    if !n.started {
+        n.started = true
+        n.profiler = activeProfiler()
+        // A query which was not given a stop flag gets its own,
+        // so that the debugger can abort it.
+        if n.stop == nil { n.stop = &stopFlag{} }
+        frame := &goalFrame{ goal: n.Goal, cutTo: 0 }
+        if n.clauseBody { frame.cutTo = -1 }
+        if n.context != nil { frame.depth = n.context.depth }
+        n.trail = makeBindingTrail(n.ParentSolution)
+        return n.run(frame, n.trail.store, false)
+    }
+    return n.run(nil, nil, true)
+}
+
+// SetNoBackTracking - set the NoBackTracking flag,
+// which is used to implement Cuts.
+// This function satisfies the SolutionNode interface.
+func (n *EngineSolutionNodeStruct) SetNoBackTracking() {
+    n.NoBackTracking = true
+}
+
+// GetParentNode
+func (n *EngineSolutionNodeStruct) GetParentNode() SolutionNode {
+    return n.ParentNode
+}
+
+// run - solves the goals of the continuation. When a goal fails,
+// the engine backtracks to the most recent choice point. This is
+// a loop; the Go stack does not grow.
+// Params: continuation
+//         substitution set
+//         backtrack first (to get the next solution)
+// Return: solution (substitution set)
+//         success/failure flag
+func (n *EngineSolutionNodeStruct) run(continuation *goalFrame,
+                                       ss SubstitutionSet,
+                                       failed bool) (SubstitutionSet, bool) {
+    if n.profiler != nil {
+        n.profMark = time.Now()
+        defer n.profileFlush()
+    }
+    for {
+        // A limit may have been exceeded by a nested engine.
+        if n.stopped() ||
+           (n.cancelled != nil && atomic.LoadInt32(n.cancelled) != 0) ||
+           (n.limits != nil && n.limits.exceeded != nil) {
+            n.stack = nil
+            return nil, false
+        }
+
+        // Without choice points, the changes cannot be undone.
+        // Exit markers of the debugger undo them too.
+        if len(n.stack) == 0 && !tracingEnabled() { n.trail.forget() }
+
+        if failed {
+            var ok bool
+            continuation, ss, ok = n.backtrack()
+            if !ok {
+                if n.context != nil { n.context.reach(n.explored) }
+                return nil, false
+            }
+            failed = false
+        }
+
+        // Goals woken up by the last step (see suspension.go) are run
+        // first. A cut in a woken goal is local to that goal.
+        if woken, newSS := ss.takeWokenGoals(); len(woken) > 0 {
+            ss = n.trail.adopt(newSS)
+            depth := 0
+            if continuation != nil { depth = continuation.depth }
+            continuation = n.pushGoals(woken, len(n.stack), depth,
+                                       continuation)
+        }
+
+        if continuation == nil {
+            // Shallow proofs were found by a previous iteration.
+            // (See iterative deepening in search.go.)
+            if n.minDepth > 0 && n.pathDepth <= n.minDepth {
+                failed = true
+                continue
+            }
+            if n.context != nil { n.context.reach(n.pathDepth) }
+            return n.trail.solution(), true
+        }
+
+        frame := continuation
+        continuation = frame.next
+        ok := true
+        n.profNode = frame.prof
+        if n.profiler != nil { n.profileStep(frame.goal) }
+
+        if exit := frame.exit; exit != nil {
+            if n.explain {
+                n.proof = &proofStep{ kind: stepExit, prev: n.proof }
+            }
+            action := DebugContinue
+            if tracingEnabled() {
+                action = traceGoal(ExitPort, exit.depth, exit.goal,
+                                   exit.clause, n.KnowledgeBase, ss, n.stop)
+            }
+            if action == DebugFail || action == DebugRetry {
+                // Discard the choice points of the goal.
+                if len(n.stack) > exit.height { n.stack = n.stack[:exit.height] }
+                n.pathDepth = exit.pathDepth
+                n.proof = exit.proof
+                n.trail.undo(exit.mark)
+                ss = n.trail.store
+                if action == DebugFail {
+                    continuation, ss, ok = n.failGoal(exit.goal, exit.depth,
+                                           exit.height, continuation, ss)
+                } else {
+                    continuation, ss, ok = n.callGoal(exit.goal, exit.depth,
+                                           exit.height, continuation, ss)
+                }
+                failed = !ok
+            }
+            continue
+        }
+
+        if n.limits != nil && !n.limits.call(frame.goal, frame.depth) {
+            n.stack = nil
+            return nil, false
+        }
+        allocated := n.trail.allocated
+
+        switch goal := frame.goal.(type) {
+        case Complex:
+            continuation, ss, ok = n.callGoal(goal, frame.depth,
+                                              len(n.stack), continuation, ss)
+        case AndOp:
+            continuation = n.pushGoals(goal, frame.cutTo, frame.depth,
+                                       continuation)
+        case OrOp:
+            continuation = n.tryOr(goal, frame.cutTo, frame.depth,
+                                   continuation, ss)
+        case CutOp:
+            cutTo := frame.cutTo
+            if cutTo < 0 {  // the cut of a clause body
+                cutTo = 0
+                if n.onCut != nil { n.onCut() }
+            }
+            if len(n.stack) > cutTo { n.stack = n.stack[:cutTo] }
+        case FailOp:
+            ok = false
+        case UnifyStruct:
+            if goal.Name == "unify" && !tracingEnabled() && !n.explain {
+                ss, ok = n.unify(goal.Arguments[0], goal.Arguments[1])
+            } else {
+                ss, ok = n.callNode(goal, frame.cutTo, frame.depth,
+                                    continuation, ss)
+            }
+        default:
+            ss, ok = n.callNode(goal, frame.cutTo, frame.depth,
+                                continuation, ss)
+        }
+
+        if n.limits != nil &&
+           !n.limits.bind(n.trail.allocated - allocated) {
+            n.stack = nil
+            return nil, false
+        }
+        failed = !ok
+    }
+} // run
+
+// pushGoals - puts a list of goals in front of the continuation.
+func (n *EngineSolutionNodeStruct) pushGoals(goals []Goal, cutTo int,
+                                             depth int,
+                                             continuation *goalFrame) *goalFrame {
+    for i := len(goals) - 1; i >= 0; i-- {
+        continuation = &goalFrame{ goal: goals[i], cutTo: cutTo,
+                                   depth: depth, prof: n.profNode,
+                                   next: continuation }
+    }
+    return continuation
+}
+
+// callGoal - calls a complex goal: reports the Call port, if tracing,
+// then tries the goal's rules.
+// Params: goal
+//         depth of goal
+//         height of choice point stack, for cut
+//         continuation
+//         substitution set
+// Return: new continuation
+//         new substitution set
+//         success/failure flag
+func (n *EngineSolutionNodeStruct) callGoal(goal Complex, depth int,
+                                            cutTo int, continuation *goalFrame,
+                                            ss SubstitutionSet) (*goalFrame,
+                                            SubstitutionSet, bool) {
+    if tracingEnabled() &&
+       traceGoal(CallPort, depth, goal, 0, n.KnowledgeBase, ss,
+                 n.stop) == DebugFail {
+        return n.failGoal(goal, depth, cutTo, continuation, ss)
+    }
+    return n.tryRules(goal, depth, 0, cutTo, continuation, ss)
+}
+
+// failGoal - reports the Fail port of a complex goal, if tracing.
+// A debugger may retry the goal.
+// Params: as for callGoal
+// Return: continuation, substitution set, success/failure flag
+func (n *EngineSolutionNodeStruct) failGoal(goal Complex, depth int,
+                                            cutTo int, continuation *goalFrame,
+                                            ss SubstitutionSet) (*goalFrame,
+                                            SubstitutionSet, bool) {
+    if tracingEnabled() &&
+       traceGoal(FailPort, depth, goal, 0, n.KnowledgeBase, ss,
+                 n.stop) == DebugRetry {
+        return n.callGoal(goal, depth, cutTo, continuation, ss)
+    }
+    return continuation, ss, false
+}
+
+// tryRules - tries the rules of a goal, beginning with the given
+// rule number. If the head of a rule unifies with the goal, the body
+// of the rule is put in front of the continuation. If there are more
+// rules to try, a choice point is pushed. If the goal is at the depth
+// limit, it fails.
+// Params: goal
+//         depth of goal
+//         number of first rule to try
+//         height of choice point stack, for cut
+//         continuation
+//         substitution set
+// Return: new continuation
+//         new substitution set
+//         success/failure flag
+func (n *EngineSolutionNodeStruct) tryRules(goal Complex, depth int,
+                                            ruleNumber int, cutTo int,
+                                            continuation *goalFrame,
+                                            ss SubstitutionSet) (*goalFrame,
+                                            SubstitutionSet, bool) {
+    kb := n.KnowledgeBase
+    count := kb.getRuleCount(goal)
+    if count > 0 && n.maxDepth > 0 && depth >= n.maxDepth {
+        if n.limitHit != nil { *n.limitHit = true }
+        if n.context != nil { n.context.limitReached() }
+        return continuation, ss, false
+    }
+    if count > 0 && depth + 1 > n.explored { n.explored = depth + 1 }
+    rules := kb.rules(goal.Key())
+    for ; ruleNumber < count; ruleNumber++ {
+
+        // The fallback id saves the variableId, in case the
+        // rule fails. (See restoreVariableId() in variable.go.)
+        fallbackId := currentVariableId()
+
+        var rule RuleStruct
+        var vars VarMap  // variables of the rule, for explanations
+        if n.explain && !rules[ruleNumber].ground {
+            vars = VarMap{}
+            rule = rules[ruleNumber].RecreateVariables(vars).(RuleStruct)
+        } else {
+            rule = fetchRule(rules[ruleNumber])
+        }
+        mark := n.trail.mark()
+        solution, success := n.unify(rule.GetHead(), goal)
+        if !success {
+            restoreVariableId(fallbackId)
+            continue
+        }
+
+        tracing := tracingEnabled()
+        if tracing && traceGoal(UnifyPort, depth, goal, ruleNumber + 1,
+                                kb, solution, n.stop) == DebugFail {
+            // The debugger rejected this clause.
+            n.trail.undo(mark)
+            ss = n.trail.store
+            restoreVariableId(fallbackId)
+            continue
+        }
+
+        // When tracing, a choice point is kept for the last rule,
+        // so that the Fail port of the goal can be reported.
+        if ruleNumber + 1 < count || tracing {
+            n.stack = append(n.stack, choicePoint{
+                kind: cpRules, mark: mark, continuation: continuation,
+                cutTo: cutTo, pathDepth: n.pathDepth, goal: goal,
+                depth: depth, ruleNumber: ruleNumber + 1, proof: n.proof,
+                prof: n.profNode,
+            })
+        }
+
+        pathDepth := n.pathDepth
+        proof := n.proof
+        if depth + 1 > n.pathDepth { n.pathDepth = depth + 1 }
+        if n.explain {
+            n.proof = &proofStep{ kind: stepEnter, goal: goal,
+                                  rule: &rules[ruleNumber],
+                                  clause: ruleNumber + 1, vars: vars,
+                                  prev: n.proof }
+        }
+        bodyNode := n.profileClause(goal, ruleNumber + 1, &rules[ruleNumber])
+        if tracing || n.explain {
+            continuation = &goalFrame{ depth: depth, prof: bodyNode,
+                                       next: continuation,
+                exit: &traceExit{ goal: goal, depth: depth,
+                                  clause: ruleNumber + 1, mark: mark,
+                                  height: cutTo, pathDepth: pathDepth,
+                                  proof: proof } }
+        }
+        body := rule.GetBody()
+        if body != nil {
+            continuation = &goalFrame{ goal: body, cutTo: cutTo,
+                                       depth: depth + 1, prof: bodyNode,
+                                       next: continuation }
+        }
+        return continuation, solution, true
+    }
+    return n.failGoal(goal, depth, cutTo, continuation, ss)
+} // tryRules
+
+// unify - unifies two terms, binding variables in the store of the
+// engine. If unification fails, the store is restored.
+// Params: terms to unify
+// Return: store (substitution set)
+//         success/failure flag
+func (n *EngineSolutionNodeStruct) unify(a, b Unifiable) (SubstitutionSet, bool) {
+    t := n.trail
+    mark := t.mark()
+    t.active = true
+    ss, ok := a.Unify(b, t.store)
+    t.active = false
+    if !ok {
+        t.undo(mark)
+        return t.store, false
+    }
+    return t.adopt(ss), true
+}
+
+// tryOr - tries the first operand of an Or. If there are more
+// operands, a choice point is pushed.
+func (n *EngineSolutionNodeStruct) tryOr(operands []Goal, cutTo int,
+                                         depth int, continuation *goalFrame,
+                                         ss SubstitutionSet) *goalFrame {
+    if len(operands) > 1 {
+        n.stack = append(n.stack, choicePoint{
+            kind: cpOr, mark: n.trail.mark(), continuation: continuation,
+            cutTo: cutTo, pathDepth: n.pathDepth, depth: depth,
+            operands: operands[1:], proof: n.proof, prof: n.profNode,
+        })
+    }
+    return &goalFrame{ goal: operands[0], cutTo: cutTo,
+                       depth: depth, prof: n.profNode, next: continuation }
+}
+
+// callNode - solves a built-in predicate with its own solution node.
+// If the predicate may have more solutions, a choice point is pushed.
+// When a debugger retries the predicate, it is called again.
+func (n *EngineSolutionNodeStruct) callNode(goal Goal, cutTo int, depth int,
+                                            continuation *goalFrame,
+                                            ss SubstitutionSet) (SubstitutionSet, bool) {
+    tracing := tracingEnabled()
+    kb := n.KnowledgeBase
+    mark := n.trail.mark()
+    for {
+        action := DebugContinue
+        if tracing {
+            action = traceGoal(CallPort, depth, goal, 0, kb, ss, n.stop)
+        }
+        if action != DebugFail {
+            // Nested engines need a context only if the search is limited.
+            var ctx *searchContext
+            var parent SolutionNode
+            if n.maxDepth > 0 || n.limits != nil {
+                ctx = n.makeSearchContext(depth)
+                parent = ctx
+            } else if n.stop != nil {
+                // A context which only passes on the stop flag.
+                if n.stopContext == nil {
+                    n.stopContext = &searchContext{ parent: n.context,
+                                                    stop: n.stop }
+                }
+                parent = n.stopContext
+            }
+            node := goal.GetSolver(kb, ss, parent)
+            solution, found := node.NextSolution()
+            if found {
+                if tracing {
+                    action = traceGoal(ExitPort, depth, goal, 0, kb,
+                                       solution, n.stop)
+                }
+                if action == DebugRetry { continue }
+                if action != DebugFail {
+                    if !hasOneSolution(goal) {
+                        n.stack = append(n.stack, choicePoint{
+                            kind: cpNode, mark: mark, continuation: continuation,
+                            cutTo: cutTo, pathDepth: n.pathDepth, depth: depth,
+                            node: node, builtIn: goal, context: ctx,
+                            proof: n.proof, prof: n.profNode,
+                        })
+                    }
+                    n.addNestedDepth(ctx)
+                    n.logBuiltIn(goal)
+                    return n.trail.adopt(solution), true
+                }
+            }
+        }
+        if tracing &&
+           traceGoal(FailPort, depth, goal, 0, kb, ss, n.stop) == DebugRetry {
+            continue
+        }
+        return ss, false
+    }
+} // callNode
+
+// backtrack - takes the next alternative from the choice point stack.
+// Return: continuation
+//         substitution set
+//         success/failure flag (false if there are no more choices)
+func (n *EngineSolutionNodeStruct) backtrack() (*goalFrame,
+                                                SubstitutionSet, bool) {
+    for len(n.stack) > 0 && !n.stopped() {
+        top := len(n.stack) - 1
+        cp := n.stack[top]
+        n.stack = n.stack[:top]
+        n.trail.undo(cp.mark)
+        ss := n.trail.store
+        n.pathDepth = cp.pathDepth
+        n.proof = cp.proof
+        n.profNode = cp.prof
+        if n.profiler != nil { n.profileStep(cp.alternative()) }
+        switch cp.kind {
+        case cpRules:
+            if n.profiler != nil {
+                // The previous clause has no more solutions.
+                n.profiler.clauseFail(cp.goal.Key(), cp.ruleNumber)
+            }
+            if tracingEnabled() {
+                var action DebugAction
+                if cp.ruleNumber >= len(n.KnowledgeBase.rules(cp.goal.Key())) {
+                    action = DebugFail
+                } else {
+                    action = traceGoal(RedoPort, cp.depth, cp.goal,
+                                       cp.ruleNumber + 1, n.KnowledgeBase,
+                                       ss, n.stop)
+                }
+                if action != DebugContinue {
+                    var continuation *goalFrame
+                    var ok bool
+                    if action == DebugRetry {
+                        continuation, ss, ok = n.callGoal(cp.goal, cp.depth,
+                                          cp.cutTo, cp.continuation, ss)
+                    } else {
+                        continuation, ss, ok = n.failGoal(cp.goal, cp.depth,
+                                          cp.cutTo, cp.continuation, ss)
+                    }
+                    if ok { return continuation, ss, true }
+                    continue
+                }
+            }
+            continuation, ss, ok := n.tryRules(cp.goal, cp.depth,
+                                               cp.ruleNumber, cp.cutTo,
+                                               cp.continuation, ss)
+            if ok { return continuation, ss, true }
+        case cpOr:
+            continuation := n.tryOr(cp.operands, cp.cutTo, cp.depth,
+                                    cp.continuation, ss)
+            return continuation, ss, true
+        case cpNode:
+            tracing := tracingEnabled()
+            kb := n.KnowledgeBase
+            action := DebugContinue
+            if tracing {
+                action = traceGoal(RedoPort, cp.depth, cp.builtIn, 0, kb,
+                                   ss, n.stop)
+            }
+            if action == DebugContinue {
+                solution, found := cp.node.NextSolution()
+                if found {
+                    if tracing {
+                        action = traceGoal(ExitPort, cp.depth, cp.builtIn, 0,
+                                           kb, solution, n.stop)
+                    }
+                    if action == DebugContinue {
+                        n.stack = append(n.stack, cp)
+                        n.addNestedDepth(cp.context)
+                        n.logBuiltIn(cp.builtIn)
+                        return cp.continuation, n.trail.adopt(solution), true
+                    }
+                }
+            }
+            if action != DebugRetry && tracing {
+                action = traceGoal(FailPort, cp.depth, cp.builtIn, 0, kb,
+                                   ss, n.stop)
+            }
+            if action == DebugRetry {  // Call the predicate again.
+                return &goalFrame{ goal: cp.builtIn, cutTo: cp.cutTo,
+                                   depth: cp.depth, prof: cp.prof,
+                                   next: cp.continuation },
+                       ss, true
+            }
+        }
+    }
+    return nil, nil, false
+} // backtrack
+
+// alternative - returns the goal which is retried by a choice point,
+// or nil for an Or.
+func (cp *choicePoint) alternative() Goal {
+    switch cp.kind {
+    case cpRules:
+        return cp.goal
+    case cpNode:
+        return cp.builtIn
+    }
+    return nil
+}
+
+// logBuiltIn - adds a built-in predicate which succeeded to the log
+// of the proof, if solutions are being explained.
+func (n *EngineSolutionNodeStruct) logBuiltIn(goal Goal) {
+    if n.explain {
+        n.proof = &proofStep{ kind: stepBuiltIn, goal: goal, prev: n.proof }
+    }
+}
+
+// hasOneSolution - returns true for built-in predicates which never
+// produce more than one solution. No choice point is needed for them.
+func hasOneSolution(goal Goal) bool {
+    switch goal.(type) {
+    case UnifyStruct, EqualStruct, LessThanStruct, LessThanOrEqualStruct,
+         GreaterThanStruct, GreaterThanOrEqualStruct, PrintStruct,
+         PrintListStruct, NewLineStruct, AppendStruct, FunctorStruct,
+         IncludeStruct, ExcludeStruct, CountStruct, FDConstraintStruct,
+         TimeStruct, TraceStruct, ProfileStruct, OpStruct, NotOp:
+        return true
+    }
+    return false
+}
+
+//----------------------------------------------------------------
+// Search context of nested engines
+//----------------------------------------------------------------
+
+// searchContext - passed to a built-in predicate as its parent node,
+// so that nested engines keep the limits of the search.
+type searchContext struct {
+    depth      int     // depth of the built-in predicate's goal
+    maxDepth   int     // goals at this depth fail (0 = no limit)
+    limitHit   *bool   // flag of the search strategy
+    limits     *queryLimits
+    hit        bool    // a nested search reached the depth limit
+    pathDepth  int     // greatest depth of a nested search
+    parent     *searchContext
+    stop       *stopFlag  // stops the query (see timeout.go)
+}
+
+// makeSearchContext - makes a context for the goal of a built-in
+// predicate.
+// Param:  depth of goal
+// Return: search context
+func (n *EngineSolutionNodeStruct) makeSearchContext(depth int) *searchContext {
+    return &searchContext{ depth: depth, maxDepth: n.maxDepth,
+                           limitHit: n.limitHit, limits: n.limits,
+                           parent: n.context, stop: n.stop }
+}
+
+// nested - makes a context for a part of a built-in predicate, such as
+// the operand of not(), which must know whether its own search reached
+// the depth limit.
+func (ctx *searchContext) nested() *searchContext {
+    return &searchContext{ depth: ctx.depth, maxDepth: ctx.maxDepth,
+                           limitHit: ctx.limitHit, limits: ctx.limits,
+                           parent: ctx, stop: ctx.stop }
+}
+
+// limitReached - records that a nested search reached the depth limit.
+// The searches which contain it are incomplete too.
+func (ctx *searchContext) limitReached() {
+    for ; ctx != nil; ctx = ctx.parent { ctx.hit = true }
+}
+
+// reach - records the depth of a nested search. When a nested search
+// succeeds, this is the depth of its proof. When it fails, it is the
+// greatest depth which it explored, because a search with a lower
+// limit would not have failed in the same way. (See iterative
+// deepening in search.go.)
+func (ctx *searchContext) reach(depth int) {
+    for ; ctx != nil; ctx = ctx.parent {
+        if depth > ctx.pathDepth { ctx.pathDepth = depth }
+    }
+}
+
+// addNestedDepth - adds the depth of the nested searches of a built-in
+// predicate to the depth of the current proof.
+func (n *EngineSolutionNodeStruct) addNestedDepth(ctx *searchContext) {
+    if ctx != nil && ctx.pathDepth > n.pathDepth { n.pathDepth = ctx.pathDepth }
+}
+
+// findSearchContext - finds the search context in a chain of parent
+// nodes.
+// Param:  parent node
+// Return: search context, or nil
+func findSearchContext(node SolutionNode) *searchContext {
+    for node != nil {
+        if ctx, ok := node.(*searchContext); ok { return ctx }
+        node = node.GetParentNode()
+    }
+    return nil
+}
+
+// NextSolution - a context has no solutions.
+// This function satisfies the SolutionNode interface.
+func (ctx *searchContext) NextSolution() (SubstitutionSet, bool) {
+    return nil, false
+}
+
+// SetNoBackTracking - does nothing. The context is the limit of a cut.
+// This function satisfies the SolutionNode interface.
+func (ctx *searchContext) SetNoBackTracking() {}
+
+// GetParentNode - returns nil. The context is the limit of a cut.
+func (ctx *searchContext) GetParentNode() SolutionNode {
+    return nil
+}
+
+// stopFlag - returns the stop flag of the query.
+func (ctx *searchContext) stopFlag() *stopFlag { return ctx.stop }
@@ -42,10 +42,8 @@ func Equal(arguments ...Unifiable) EqualStruct {
 //     equal predicate
 //     success/failure flag
 func ParseEqual(str string) (EqualStruct, bool) {
-    runes := []rune(str)
-    infix, index := identifyInfix(runes)
-    if infix != EQUAL { return EqualStruct{}, false }
-    term1, term2 := getLeftAndRight(runes, index, 2)
+    term1, term2, ok := parseInfix(str, "==")
+    if !ok { return EqualStruct{}, false }
     return Equal(term1, term2), true
 } // ParseEqual
 
@@ -76,10 +74,10 @@ func (eq EqualStruct) ReplaceVariables(ss SubstitutionSet) Expression {
 
 
 // String - creates a string representation of this comparison.
-// For example: $X < 8.
+// For example: $X == 8.
 // Returns: string representation
 func (eq EqualStruct) String() string {
-    return comparisonString(eq.Arguments, " < ")
+    return comparisonString(eq.Arguments, " == ")
 }
 
 //----------------------------------------------------------------
@@ -0,0 +1,346 @@
+package suiron
+
+// Explain - explains solutions with proof trees.
+//
+// To show why a conclusion holds, SolveExplain() returns each solution
+// of a query with its proof:
+//
+//    explanations, failure := SolveExplain(query, kb, SubstitutionSet{})
+//    for _, e := range explanations {
+//        fmt.Println(e.Solution)
+//        fmt.Print(e.Proof.Text())
+//    }
+//
+// A proof tree has a node for each goal which was proved. A goal which
+// was proved by a rule or a fact has the clause (RuleStruct) which proved
+// it, the bindings of the clause's variables, and the proofs of the goals
+// of the clause's body as children. Built-in predicates are leaves.
+// A negated goal, not(goal), is a leaf which shows the goal which could
+// not be proved. Goals are shown with the bindings of the solution.
+//
+// For example, the proof of grandfather(Godwin, Harold) is:
+//
+//    grandfather(Godwin, Harold)  [rule 1, kings.txt:28] {$X = Godwin, ...}
+//        parent(Godwin, Harold II)  [fact 1, kings.txt:14]
+//        parent(Harold II, Harold)  [fact 9, kings.txt:22]
+//        male(Godwin)  [fact 1, kings.txt:2]
+//
+// Proofs can also be written as JSON (Proof.JSON()) or in the DOT
+// language of Graphviz (Proof.DOT()).
+//
+// Goals which are solved inside built-in predicates, such as not(),
+// are not part of the proof.
+//
+// Cleve Lendon
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// Kinds of steps in the log of a proof. (See engine.go.)
+const (
+    stepEnter = iota  // a rule or fact was used to solve a goal
+    stepExit          // the body of the rule has been solved
+    stepBuiltIn       // a built-in predicate succeeded
+)
+
+// proofStep - one step in the log of a proof. The log is a linked
+// list, from the last step to the first.
+type proofStep struct {
+    kind    int
+    goal    Goal
+    rule    *RuleStruct  // stepEnter - rule in the knowledge base
+    clause  int          // stepEnter - number of clause (1 = first)
+    vars    VarMap       // stepEnter - variables of the rule
+    prev    *proofStep
+}
+
+// ProofKind - identifies how a goal was proved.
+type ProofKind int
+
+const (
+    RuleProof ProofKind = iota  // proved by a rule
+    FactProof                   // proved by a fact
+    BuiltInProof                // a built-in predicate
+    NegationProof               // not(goal): the goal failed to prove
+)
+
+// String - returns the name of a kind of proof.
+func (k ProofKind) String() string {
+    switch k {
+    case RuleProof:
+        return "rule"
+    case FactProof:
+        return "fact"
+    case BuiltInProof:
+        return "built-in"
+    }
+    return "failed to prove"
+}
+
+// Proof - a node of a proof tree.
+type Proof struct {
+    Kind      ProofKind
+    Goal      Goal        // with the bindings of the solution; for
+                          // NegationProof, the goal which failed
+    Rule      *RuleStruct // clause which proved the goal, or nil
+    Clause    int         // number of clause (1 = first), or 0
+    Bindings  map[string]Expression  // variables of the clause
+    Children  []*Proof
+}
+
+// Explanation - a solution and its proof.
+type Explanation struct {
+    Solution  Complex
+    Proof     *Proof
+}
+
+// explainSolutionNode - builds the proof of each solution
+// found by the engine.
+type explainSolutionNode struct {
+    SolutionNodeStruct
+    engine  *EngineSolutionNodeStruct
+    proofs  []*Proof
+}
+
+// setStopFlag - gives the stop flag of the query to the node and to
+// its engine.
+func (n *explainSolutionNode) setStopFlag(stop *stopFlag) {
+    n.stop = stop
+    n.engine.stop = stop
+}
+
+// NextSolution - continues the search for a solution.
+// This function satisfies the SolutionNode interface.
+func (n *explainSolutionNode) NextSolution() (SubstitutionSet, bool) {
+    if n.NoBackTracking { return nil, false }
+    solution, found := n.engine.NextSolution()
+    if !found { return nil, false }
+    n.proofs = append(n.proofs, n.engine.proofTree(solution))
+    return solution, true
+}
+
+// SetNoBackTracking - set the NoBackTracking flag.
+func (n *explainSolutionNode) SetNoBackTracking() {
+    n.NoBackTracking = true
+}
+
+// GetParentNode
+func (n *explainSolutionNode) GetParentNode() SolutionNode {
+    return n.ParentNode
+}
+
+// SolveExplain - finds all solutions for the given query, with
+// their proofs. The reason for failure is as for SolveAll(). If the
+// search times out, no solutions are returned.
+// Params:  query
+//          knowledge base
+//          substitution set (previous bindings)
+// Returns: solutions and proofs
+//          reason for failure
+func SolveExplain(query Complex, kb KnowledgeBase,
+                  ss SubstitutionSet) ([]Explanation, string) {
+
+    var node *explainSolutionNode
+    solutions, failure := solveEvery(query, func() SolutionNode {
+        engine := &EngineSolutionNodeStruct{
+                      SolutionNodeStruct: MakeSolutionNode(query, kb, ss, nil),
+                      explain: true,
+                  }
+        node = &explainSolutionNode{
+                   SolutionNodeStruct: MakeSolutionNode(query, kb, ss, nil),
+                   engine: engine,
+               }
+        return node
+    })
+    if failure != "" && failure != "No" { return nil, failure }
+
+    explanations := make([]Explanation, len(solutions))
+    for i, solution := range solutions {
+        explanations[i] = Explanation{ Solution: solution,
+                                       Proof: node.proofs[i] }
+    }
+    return explanations, failure
+
+} // SolveExplain
+
+// proofTree - builds a proof tree from the log of the current proof.
+// Param:  substitution set of the solution
+// Return: proof of the engine's goal
+func (n *EngineSolutionNodeStruct) proofTree(ss SubstitutionSet) *Proof {
+    steps := []*proofStep{}
+    for step := n.proof; step != nil; step = step.prev {
+        steps = append(steps, step)
+    }
+    root := &Proof{}
+    open := []*Proof{ root }  // proofs of rules which are not closed
+    for i := len(steps) - 1; i >= 0; i-- {
+        step := steps[i]
+        top := open[len(open) - 1]
+        switch step.kind {
+        case stepEnter:
+            proof := step.makeProof(ss)
+            top.Children = append(top.Children, proof)
+            open = append(open, proof)
+        case stepExit:
+            if len(open) > 1 { open = open[:len(open) - 1] }
+        case stepBuiltIn:
+            top.Children = append(top.Children, step.makeProof(ss))
+        }
+    }
+    if len(root.Children) == 0 { return nil }
+    return root.Children[0]
+} // proofTree
+
+// makeProof - makes a node of a proof tree from a step of the log.
+// Param:  substitution set of the solution
+// Return: proof
+func (step *proofStep) makeProof(ss SubstitutionSet) *Proof {
+    if step.kind == stepBuiltIn {
+        if not, ok := step.goal.(NotOp); ok {
+            return &Proof{ Kind: NegationProof, Goal: bindGoal(not[0], ss) }
+        }
+        return &Proof{ Kind: BuiltInProof, Goal: bindGoal(step.goal, ss) }
+    }
+    proof := &Proof{
+                 Kind: RuleProof,
+                 Goal: bindGoal(step.goal, ss),
+                 Rule: step.rule,
+                 Clause: step.clause,
+             }
+    if step.rule.body == nil { proof.Kind = FactProof }
+    if len(step.vars) > 0 {
+        proof.Bindings = map[string]Expression{}
+        for name, v := range step.vars {
+            proof.Bindings[name] = v.ReplaceVariables(ss)
+        }
+    }
+    return proof
+} // makeProof
+
+// label - describes how a goal was proved, eg. "rule 1, kings.txt:28".
+func (p *Proof) label() string {
+    switch p.Kind {
+    case BuiltInProof, NegationProof:
+        return p.Kind.String()
+    }
+    s := fmt.Sprintf("%v %v", p.Kind, p.Clause)
+    if p.Rule != nil && p.Rule.source.Line > 0 {
+        s += ", " + p.Rule.source.String()
+    }
+    return s
+}
+
+// formatBindings - formats the bindings of a proof, sorted by name.
+// Eg.: {$X = Godwin, $Y = Harold}
+func (p *Proof) formatBindings() string {
+    names := make([]string, 0, len(p.Bindings))
+    for name := range p.Bindings { names = append(names, name) }
+    sort.Strings(names)
+    for i, name := range names {
+        names[i] = fmt.Sprintf("%v = %v", name, p.Bindings[name])
+    }
+    return "{" + strings.Join(names, ", ") + "}"
+}
+
+// Text - formats a proof tree as indented text, one goal per line.
+func (p *Proof) Text() string {
+    var sb strings.Builder
+    var write func(p *Proof, indent int)
+    write = func(p *Proof, indent int) {
+        sb.WriteString(strings.Repeat("    ", indent))
+        sb.WriteString(fmt.Sprintf("%v  [%v]", p.Goal, p.label()))
+        if len(p.Bindings) > 0 {
+            sb.WriteString(" " + p.formatBindings())
+        }
+        sb.WriteString("\n")
+        for _, child := range p.Children { write(child, indent + 1) }
+    }
+    if p != nil { write(p, 0) }
+    return sb.String()
+} // Text
+
+// proofJSON - the JSON form of a proof.
+type proofJSON struct {
+    Goal      string            `json:"goal"`
+    Kind      string            `json:"kind"`
+    Clause    int               `json:"clause,omitempty"`
+    Rule      string            `json:"rule,omitempty"`
+    File      string            `json:"file,omitempty"`
+    Line      int               `json:"line,omitempty"`
+    Column    int               `json:"column,omitempty"`
+    Bindings  map[string]string `json:"bindings,omitempty"`
+    Children  []*proofJSON      `json:"children,omitempty"`
+}
+
+// toJSON - converts a proof to its JSON form.
+func (p *Proof) toJSON() *proofJSON {
+    pj := &proofJSON{ Goal: p.Goal.String(), Kind: p.Kind.String(),
+                      Clause: p.Clause }
+    if p.Rule != nil {
+        pj.Rule = p.Rule.String()
+        pj.File = p.Rule.source.File
+        pj.Line = p.Rule.source.Line
+        pj.Column = p.Rule.source.Column
+    }
+    if len(p.Bindings) > 0 {
+        pj.Bindings = map[string]string{}
+        for name, value := range p.Bindings {
+            pj.Bindings[name] = value.String()
+        }
+    }
+    for _, child := range p.Children {
+        pj.Children = append(pj.Children, child.toJSON())
+    }
+    return pj
+}
+
+// JSON - formats a proof tree as indented JSON. Each node has the
+// goal, the kind of proof (rule, fact, built-in, failed to prove),
+// and, for rules and facts, the clause, its source and bindings.
+func (p *Proof) JSON() ([]byte, error) {
+    if p == nil { return []byte("null"), nil }
+    var buffer bytes.Buffer
+    encoder := json.NewEncoder(&buffer)
+    encoder.SetEscapeHTML(false)  // Keep operators, eg. $X > 2
+    encoder.SetIndent("", "    ")
+    err := encoder.Encode(p.toJSON())
+    return buffer.Bytes(), err
+}
+
+// DOT - formats a proof tree as a graph in the DOT language of Graphviz.
+// Built-in predicates are ellipses. Goals which failed to prove are
+// dashed.
+func (p *Proof) DOT() string {
+    var sb strings.Builder
+    sb.WriteString("digraph proof {\n    node [shape=box];\n")
+    count := 0
+    var write func(p *Proof) int
+    write = func(p *Proof) int {
+        id := count
+        count++
+        label := fmt.Sprintf("%v\n%v", p.Goal, p.label())
+        attributes := ""
+        switch p.Kind {
+        case BuiltInProof:
+            attributes = ", shape=ellipse"
+        case NegationProof:
+            attributes = ", style=dashed"
+        }
+        sb.WriteString(fmt.Sprintf("    p%v [label=%v%v];\n",
+                                   id, strconv.Quote(label), attributes))
+        for _, child := range p.Children {
+            childId := write(child)
+            sb.WriteString(fmt.Sprintf("    p%v -> p%v;\n", id, childId))
+        }
+        return id
+    }
+    if p != nil { write(p) }
+    sb.WriteString("}\n")
+    return sb.String()
+} // DOT
@@ -0,0 +1,333 @@
+package suiron
+
+// FD Constraints - built-in predicates which post finite-domain
+// constraints. (See clpfd.go.)
+//
+//    $X in 1..9                  domain of a variable
+//    [$X, $Y, $Z] ins 0..9       domain of a list of variables
+//    $X #= $Y                    equal
+//    $X #\= $Y                   not equal
+//    $X #< $Y                    less than
+//    $X #> $Y                    greater than
+//    $X #=< $Y                   less than or equal
+//    $X #>= $Y                   greater than or equal
+//    all_different([$X, $Y, $Z]) all values are different
+//    sum([$X, $Y, $Z], #=, 10)   sum of a list
+//    fd_dom($X, $Dom)            gets the domain of a variable, as an atom
+//
+// The sides of a comparison can be Integers, Variables, or linear
+// expressions built with add(), subtract() and multiply():
+//
+//    $Total #= add($Price, multiply(2, $Tax))
+//
+// Note: In a Suiron source file, a hash (#) normally begins a comment.
+// If the hash is followed by =, \, < or >, it is an operator.
+//
+// All of these predicates produce one solution. A constraint which
+// cannot be satisfied fails immediately. A constraint which is not
+// yet decided is recorded, and checked again whenever its variables
+// are narrowed or bound.
+//
+// Cleve Lendon
+
+import (
+    "fmt"
+)
+
+type FDConstraintStruct BuiltInPredicateStruct
+
+// makeFDConstraint - creates a constraint predicate, after
+// checking the number of arguments.
+func makeFDConstraint(name string, nArgs int,
+                      arguments []Unifiable) FDConstraintStruct {
+    if len(arguments) != nArgs {
+        msg := fmt.Sprintf("%v - This predicate requires %d arguments.",
+                           name, nArgs)
+        panic(msg)
+    }
+    return FDConstraintStruct {
+        Name: name,
+        Arguments: arguments,
+    }
+}
+
+// In - creates the constraint: $X in Domain
+// The domain is an Integer, or an Atom such as '1..9'.
+func In(arguments ...Unifiable) FDConstraintStruct {
+    return makeFDConstraint("in", 2, arguments)
+}
+
+// Ins - creates the constraint: [$X, $Y] ins Domain
+func Ins(arguments ...Unifiable) FDConstraintStruct {
+    return makeFDConstraint("ins", 2, arguments)
+}
+
+// FDEqual - creates the constraint: $X #= $Y
+func FDEqual(arguments ...Unifiable) FDConstraintStruct {
+    return makeFDConstraint("#=", 2, arguments)
+}
+
+// FDNotEqual - creates the constraint: $X #\= $Y
+func FDNotEqual(arguments ...Unifiable) FDConstraintStruct {
+    return makeFDConstraint("#\\=", 2, arguments)
+}
+
+// FDLessThan - creates the constraint: $X #< $Y
+func FDLessThan(arguments ...Unifiable) FDConstraintStruct {
+    return makeFDConstraint("#<", 2, arguments)
+}
+
+// FDGreaterThan - creates the constraint: $X #> $Y
+func FDGreaterThan(arguments ...Unifiable) FDConstraintStruct {
+    return makeFDConstraint("#>", 2, arguments)
+}
+
+// FDLessThanOrEqual - creates the constraint: $X #=< $Y
+func FDLessThanOrEqual(arguments ...Unifiable) FDConstraintStruct {
+    return makeFDConstraint("#=<", 2, arguments)
+}
+
+// FDGreaterThanOrEqual - creates the constraint: $X #>= $Y
+func FDGreaterThanOrEqual(arguments ...Unifiable) FDConstraintStruct {
+    return makeFDConstraint("#>=", 2, arguments)
+}
+
+// AllDifferent - creates the constraint: all_different([$X, $Y, $Z])
+func AllDifferent(arguments ...Unifiable) FDConstraintStruct {
+    return makeFDConstraint("all_different", 1, arguments)
+}
+
+// Sum - creates the constraint: sum([$X, $Y, $Z], #=, $Total)
+// The second argument is a comparison operator (#=, #<, etc.).
+func Sum(arguments ...Unifiable) FDConstraintStruct {
+    return makeFDConstraint("sum", 3, arguments)
+}
+
+// FDDom - creates the predicate: fd_dom($X, $Domain)
+// $Domain is bound to an Atom which represents the domain of $X.
+func FDDom(arguments ...Unifiable) FDConstraintStruct {
+    return makeFDConstraint("fd_dom", 2, arguments)
+}
+
+// GetSolver - gets a solution node for this predicate.
+// This function satisfies the Goal interface.
+func (s FDConstraintStruct) GetSolver(kb KnowledgeBase,
+                                      parentSolution SubstitutionSet,
+                                      parentNode SolutionNode) SolutionNode {
+    return makeFDConstraintSolutionNode(s, kb, parentSolution, parentNode)
+}
+
+//----------------------------------------------------------------
+// RecreateVariables(), ReplaceVariables(), and String() satisfy
+// the Expression interface.
+//----------------------------------------------------------------
+
+// RecreateVariables - Refer to comments in expression.go.
+func (s FDConstraintStruct) RecreateVariables(vars VarMap) Expression {
+    bip := BuiltInPredicateStruct(s).RecreateVariables(vars)
+    return Expression(FDConstraintStruct(*bip))
+}
+
+// ReplaceVariables - Refer to comments in expression.go.
+func (s FDConstraintStruct) ReplaceVariables(ss SubstitutionSet) Expression {
+    return BuiltInPredicateStruct(s).ReplaceVariables(ss)
+}  // ReplaceVariables
+
+// String - creates a string representation. Infix constraints
+// are written with the operator between the terms: $X #< 8
+func (s FDConstraintStruct) String() string {
+    switch s.Name {
+    case "in", "ins", "#=", "#\\=", "#<", "#>", "#=<", "#>=":
+        return comparisonString(s.Arguments, " " + s.Name + " ")
+    }
+    return BuiltInPredicateStruct(s).String()
+}
+
+//----------------------------------------------------------------
+// Solution Node functions.
+//    makeFDConstraintSolutionNode()
+//    NextSolution()
+//    SetNoBackTracking()
+//----------------------------------------------------------------
+
+type FDConstraintSolutionNodeStruct struct {
+    SolutionNodeStruct
+    moreSolutions bool
+}
+
+// makeFDConstraintSolutionNode - creates a solution node for
+// a constraint predicate.
+func makeFDConstraintSolutionNode(goal Goal, kb KnowledgeBase,
+                                  parentSolution SubstitutionSet,
+                                  parentNode SolutionNode) SolutionNode {
+    node := FDConstraintSolutionNodeStruct{
+                SolutionNodeStruct: MakeSolutionNode(goal, kb,
+                                        parentSolution, parentNode),
+                moreSolutions: true,
+            }
+    return &node
+}
+
+// NextSolution - posts the constraint.
+// Returns:
+//    updated substitution set
+//    success/failure flag
+// This function satisfies the SolutionNode interface.
+func (sn *FDConstraintSolutionNodeStruct) NextSolution() (SubstitutionSet, bool) {
+    if sn.NoBackTracking || !sn.moreSolutions { return nil, false }
+    sn.moreSolutions = false  // Only one solution.
+    goal := sn.Goal.(FDConstraintStruct)
+    return evaluateFDConstraint(goal.Name, goal.Arguments, sn.ParentSolution)
+}
+
+// SetNoBackTracking - set the NoBackTracking flag,
+// which is used to implement Cuts.
+// This function satisfies the SolutionNode interface.
+func (sn *FDConstraintSolutionNodeStruct) SetNoBackTracking() {
+    sn.NoBackTracking = true
+}
+
+// GetParentNode
+func (sn *FDConstraintSolutionNodeStruct) GetParentNode() SolutionNode {
+    return sn.ParentNode
+}
+
+//----------------------------------------------------------------
+// evaluateFDConstraint - posts a constraint.
+//
+// Invalid arguments (for example, an Atom in an arithmetic expression)
+// cause a panic, as in the other built-in predicates.
+//
+// Params:
+//      name of constraint
+//      list of arguments
+//      substitution set (= solution so far)
+// Return:
+//      updated substitution set
+//      success/failure flag
+//
+func evaluateFDConstraint(name string, arguments []Unifiable,
+                          ss SubstitutionSet) (SubstitutionSet, bool) {
+
+    switch name {
+
+    case "in":
+        return fdIn([]Unifiable{arguments[0]}, arguments[1], ss)
+
+    case "ins":
+        terms, ok := fdListTerms(arguments[0], ss)
+        if !ok { panic("ins - First argument must be a list.") }
+        return fdIn(terms, arguments[1], ss)
+
+    case "all_different":
+        terms, ok := fdListTerms(arguments[0], ss)
+        if !ok { panic("all_different - Argument must be a list.") }
+        return fdPost(ss, &fdAllDifferent{ terms: terms })
+
+    case "sum":
+        terms, ok := fdListTerms(arguments[0], ss)
+        if !ok { panic("sum - First argument must be a list.") }
+        op, ok := ss.CastAtom(arguments[1])
+        if !ok { panic("sum - Second argument must be an operator.") }
+        total := Unifiable(Add(append(terms, Integer(0))...))
+        if len(terms) == 0 { total = Integer(0) }
+        return fdCompare(string(op), total, arguments[2], ss)
+
+    case "fd_dom":
+        st := makeConstraintStore(ss)
+        d, ok := st.domainOf(arguments[0])
+        if !ok { return ss, false }
+        return arguments[1].Unify(Atom(d.String()), ss)
+    }
+
+    return fdCompare(name, arguments[0], arguments[1], ss)
+
+} // evaluateFDConstraint
+
+// fdIn - restricts the domains of a list of terms.
+// Params: terms (variables or integers)
+//         domain term
+//         substitution set
+// Return: new substitution set
+//         success/failure flag
+func fdIn(terms []Unifiable, domainTerm Unifiable,
+          ss SubstitutionSet) (SubstitutionSet, bool) {
+    d, err := termToFDDomain(domainTerm, ss)
+    if err != nil { panic("in - " + err.Error()) }
+    st := makeConstraintStore(ss)
+    for _, term := range terms {
+        if !st.narrow(term, d) { return ss, false }
+    }
+    if !st.run() { return ss, false }
+    return st.ss, true
+} // fdIn
+
+// fdCompare - posts a comparison between two arithmetic expressions.
+// Params: operator (#=, #\=, #<, #>, #=<, #>=)
+//         left expression
+//         right expression
+//         substitution set
+// Return: new substitution set
+//         success/failure flag
+func fdCompare(op string, left Unifiable, right Unifiable,
+               ss SubstitutionSet) (SubstitutionSet, bool) {
+
+    // Convert the comparison into:  expression REL 0
+    e := fdExpression{}
+    leftFactor, rightFactor := int64(1), int64(-1)
+    relation := fdLE
+
+    switch op {
+    case "#=":  relation = fdEQ
+    case "#\\=": relation = fdNE
+    case "#=<": // left - right <= 0
+    case "#<":  e.constant = 1  // left - right + 1 <= 0
+    case "#>=": leftFactor, rightFactor = -1, 1
+    case "#>":  leftFactor, rightFactor = -1, 1
+                e.constant = 1
+    default:
+        panic("Invalid constraint operator: " + op)
+    }
+
+    if err := fdLinearize(left, leftFactor, &e, ss); err != nil {
+        panic(op + " - " + err.Error())
+    }
+    if err := fdLinearize(right, rightFactor, &e, ss); err != nil {
+        panic(op + " - " + err.Error())
+    }
+
+    // Both sides are ground. No need to record a constraint.
+    if len(e.variables) == 0 {
+        switch relation {
+        case fdEQ: return ss, e.constant == 0
+        case fdNE: return ss, e.constant != 0
+        }
+        return ss, e.constant <= 0
+    }
+
+    return fdPost(ss, &fdLinear{ fdExpression: e, relation: relation })
+
+} // fdCompare
+
+// fdListTerms - gets the terms of a linked list.
+// Params: list (or variable bound to a list)
+//         substitution set
+// Return: terms
+//         success flag
+func fdListTerms(term Unifiable, ss SubstitutionSet) ([]Unifiable, bool) {
+    list, ok := ss.CastLinkedList(term)
+    if !ok { return nil, false }
+    terms := []Unifiable{}
+    ptr := &list
+    for ptr != nil && ptr.term != nil {
+        if ptr.tailVar {
+            tail, ok := ss.CastLinkedList(ptr.term)
+            if !ok { return nil, false }
+            ptr = &tail
+            continue
+        }
+        terms = append(terms, ptr.term)
+        ptr = ptr.next
+    }
+    return terms, true
+} // fdListTerms
@@ -0,0 +1,279 @@
+package suiron
+
+// FDDomain - defines the domain of a finite-domain (CLP(FD)) variable.
+//
+// A domain is a sorted list of disjoint integer intervals. For example,
+// the domain 1..3 \/ 7..9 consists of two intervals, 1 to 3 and 7 to 9.
+// The lower bound of an interval can be 'inf' (negative infinity), and
+// the upper bound can be 'sup' (positive infinity):
+//
+//    $X in 1..9
+//    $Y in 0..sup
+//    $Z in 1..3 \/ 7..9
+//
+// Domains are immutable. Operations such as intersect() and remove()
+// return a new domain. This is important, because the domains are
+// stored in the substitution set, and backtracking depends on the
+// previous substitution set remaining unchanged.
+//
+// Cleve Lendon
+
+import (
+    "strings"
+    "strconv"
+    "math"
+    "fmt"
+)
+
+const fdInf int64 = math.MinInt64  // inf, negative infinity
+const fdSup int64 = math.MaxInt64  // sup, positive infinity
+
+type fdInterval struct {
+    lo int64
+    hi int64
+}
+
+type fdDomain []fdInterval
+
+// fdFullDomain - returns the domain inf..sup.
+func fdFullDomain() fdDomain {
+    return fdDomain{ fdInterval{ lo: fdInf, hi: fdSup } }
+}
+
+// fdRange - returns a domain with one interval, lo..hi.
+// If lo is greater than hi, the domain is empty.
+func fdRange(lo int64, hi int64) fdDomain {
+    if lo > hi { return fdDomain{} }
+    return fdDomain{ fdInterval{ lo: lo, hi: hi } }
+}
+
+// isEmpty - returns true if the domain has no values.
+func (d fdDomain) isEmpty() bool { return len(d) == 0 }
+
+// min - returns the lower bound of the domain.
+// The domain must not be empty.
+func (d fdDomain) min() int64 { return d[0].lo }
+
+// max - returns the upper bound of the domain.
+// The domain must not be empty.
+func (d fdDomain) max() int64 { return d[len(d) - 1].hi }
+
+// isFinite - returns true if the domain has finite bounds.
+func (d fdDomain) isFinite() bool {
+    if d.isEmpty() { return true }
+    return d.min() != fdInf && d.max() != fdSup
+}
+
+// isSingleton - returns true if the domain holds only one value.
+func (d fdDomain) isSingleton() bool {
+    return len(d) == 1 && d[0].lo == d[0].hi
+}
+
+// size - returns the number of values in the domain. If the
+// domain is infinite, or too large to count, returns MaxInt64.
+func (d fdDomain) size() int64 {
+    var count int64 = 0
+    for _, iv := range d {
+        if iv.lo == fdInf || iv.hi == fdSup { return math.MaxInt64 }
+        n := iv.hi - iv.lo + 1
+        if n <= 0 || count > math.MaxInt64 - n { return math.MaxInt64 }
+        count += n
+    }
+    return count
+}
+
+// contains - returns true if the given value is in the domain.
+func (d fdDomain) contains(x int64) bool {
+    for _, iv := range d {
+        if x < iv.lo { return false }
+        if x <= iv.hi { return true }
+    }
+    return false
+}
+
+// intersect - returns the intersection of two domains.
+func (d fdDomain) intersect(other fdDomain) fdDomain {
+    result := fdDomain{}
+    i, j := 0, 0
+    for i < len(d) && j < len(other) {
+        a := d[i]
+        b := other[j]
+        lo := a.lo
+        if b.lo > lo { lo = b.lo }
+        hi := a.hi
+        if b.hi < hi { hi = b.hi }
+        if lo <= hi {
+            result = append(result, fdInterval{ lo: lo, hi: hi })
+        }
+        if a.hi < b.hi { i++ } else { j++ }
+    }
+    return result
+} // intersect
+
+// union - returns the union of two domains.
+func (d fdDomain) union(other fdDomain) fdDomain {
+    all := append(append(fdDomain{}, d...), other...)
+    // Insertion sort. Domains have few intervals.
+    for i := 1; i < len(all); i++ {
+        for j := i; j > 0 && all[j].lo < all[j - 1].lo; j-- {
+            all[j], all[j - 1] = all[j - 1], all[j]
+        }
+    }
+    result := fdDomain{}
+    for _, iv := range all {
+        n := len(result)
+        if n > 0 && (result[n - 1].hi == fdSup ||
+                     iv.lo <= result[n - 1].hi + 1) {
+            if iv.hi > result[n - 1].hi { result[n - 1].hi = iv.hi }
+        } else {
+            result = append(result, iv)
+        }
+    }
+    return result
+} // union
+
+// remove - returns a domain without the given value.
+func (d fdDomain) remove(x int64) fdDomain {
+    if !d.contains(x) { return d }
+    result := fdDomain{}
+    for _, iv := range d {
+        if x < iv.lo || x > iv.hi {
+            result = append(result, iv)
+            continue
+        }
+        if iv.lo < x { result = append(result, fdInterval{ iv.lo, x - 1 }) }
+        if x < iv.hi { result = append(result, fdInterval{ x + 1, iv.hi }) }
+    }
+    return result
+} // remove
+
+// restrictMin - removes all values less than lo.
+func (d fdDomain) restrictMin(lo int64) fdDomain {
+    if d.isEmpty() || lo <= d.min() { return d }
+    return d.intersect(fdRange(lo, fdSup))
+}
+
+// restrictMax - removes all values greater than hi.
+func (d fdDomain) restrictMax(hi int64) fdDomain {
+    if d.isEmpty() || hi >= d.max() { return d }
+    return d.intersect(fdRange(fdInf, hi))
+}
+
+// equal - returns true if two domains have the same values.
+func (d fdDomain) equal(other fdDomain) bool {
+    if len(d) != len(other) { return false }
+    for i := range d {
+        if d[i] != other[i] { return false }
+    }
+    return true
+}
+
+// nextValue - returns the smallest value in the domain which is
+// greater than x. The success flag is false if there is none.
+func (d fdDomain) nextValue(x int64) (int64, bool) {
+    if x == fdSup { return 0, false }
+    for _, iv := range d {
+        if x < iv.lo { return iv.lo, true }
+        if x < iv.hi { return x + 1, true }
+    }
+    return 0, false
+}
+
+// previousValue - returns the largest value in the domain which
+// is less than x. The success flag is false if there is none.
+func (d fdDomain) previousValue(x int64) (int64, bool) {
+    if x == fdInf { return 0, false }
+    for i := len(d) - 1; i >= 0; i-- {
+        iv := d[i]
+        if x > iv.hi { return iv.hi, true }
+        if x > iv.lo { return x - 1, true }
+    }
+    return 0, false
+}
+
+// fdBoundString - formats a bound. Infinite bounds are
+// written as 'inf' and 'sup'.
+func fdBoundString(b int64) string {
+    if b == fdInf { return "inf" }
+    if b == fdSup { return "sup" }
+    return strconv.FormatInt(b, 10)
+}
+
+// String - formats the domain as it would be written in a
+// Suiron program. Eg.: 1..3\/7..9
+func (d fdDomain) String() string {
+    if d.isEmpty() { return "1..0" }
+    var sb strings.Builder
+    for n, iv := range d {
+        if n > 0 { sb.WriteString("\\/") }
+        if iv.lo == iv.hi {
+            sb.WriteString(fdBoundString(iv.lo))
+        } else {
+            sb.WriteString(fdBoundString(iv.lo))
+            sb.WriteString("..")
+            sb.WriteString(fdBoundString(iv.hi))
+        }
+    }
+    return sb.String()
+} // String
+
+// parseFDBound - parses one bound of a range: an integer, inf or sup.
+func parseFDBound(str string) (int64, error) {
+    s := strings.TrimSpace(str)
+    if s == "inf" { return fdInf, nil }
+    if s == "sup" { return fdSup, nil }
+    i, err := strconv.ParseInt(s, 10, 64)
+    if err != nil {
+        return 0, fmt.Errorf("ParseFDDomain() - Invalid bound: >%v<", s)
+    }
+    return i, nil
+}
+
+// parseFDDomain - parses a domain from its string representation.
+// Ranges are separated by \/ (union). Eg.:  1..3 \/ 5 \/ 7..sup
+// Params:  string representation
+// Return:  domain
+//          error
+func parseFDDomain(str string) (fdDomain, error) {
+    s := strings.TrimSpace(str)
+    if len(s) == 0 {
+        return fdDomain{}, fmt.Errorf("ParseFDDomain() - Empty domain.")
+    }
+    result := fdDomain{}
+    for _, part := range strings.Split(s, "\\/") {
+        var lo, hi int64
+        var err error
+        index := strings.Index(part, "..")
+        if index < 0 {
+            lo, err = parseFDBound(part)
+            if err != nil { return result, err }
+            hi = lo
+        } else {
+            lo, err = parseFDBound(part[0: index])
+            if err != nil { return result, err }
+            hi, err = parseFDBound(part[index + 2:])
+            if err != nil { return result, err }
+        }
+        result = result.union(fdRange(lo, hi))
+    }
+    return result, nil
+} // parseFDDomain
+
+// termToFDDomain - converts a term to a domain. The term can be an
+// Integer (a domain with one value), or an Atom which represents a
+// domain, such as '1..9'.
+func termToFDDomain(term Unifiable, ss SubstitutionSet) (fdDomain, error) {
+    ground, ok := ss.GetGroundTerm(term)
+    if !ok {
+        return fdDomain{}, fmt.Errorf("Domain is not ground: %v", term)
+    }
+    tt := ground.TermType()
+    if tt == INTEGER {
+        i := int64(ground.(Integer))
+        return fdRange(i, i), nil
+    }
+    if tt == ATOM {
+        return parseFDDomain(ground.String())
+    }
+    return fdDomain{}, fmt.Errorf("Invalid domain: %v", ground)
+} // termToFDDomain
@@ -22,7 +22,7 @@ func MakeQuery(terms ...Unifiable) Complex {
     // to copy the substitution set. The substitution set
     // is as large as the highest variable ID. Therefore
     // variableId should be set to 0 for every query.
-    variableId = 0
+    restoreVariableId(0)
 
     newTerms := makeLogicVariablesUnique(terms...)
     return Complex(newTerms)
@@ -41,7 +41,7 @@ func ParseQuery(str string) (Complex, error) {
     // to copy the substitution set. The substitution set
     // is as large as the highest variable ID. Therefore
     // variableId should be set to 0 for every query.
-    variableId = 0
+    restoreVariableId(0)
 
     c, err := ParseComplex(str)
     if err != nil { return c, err }
@@ -43,10 +43,8 @@ func GreaterThan(arguments ...Unifiable) GreaterThanStruct {
 //     greater-than predicate
 //     success/failure flag
 func ParseGreaterThan(str string) (GreaterThanStruct, bool) {
-    runes := []rune(str)
-    infix, index := identifyInfix(runes)
-    if infix != GREATER_THAN { return GreaterThanStruct{}, false }
-    term1, term2 := getLeftAndRight(runes, index, 2)
+    term1, term2, ok := parseInfix(str, ">")
+    if !ok { return GreaterThanStruct{}, false }
     return GreaterThan(term1, term2), true
 } // ParseGreaterThan
 
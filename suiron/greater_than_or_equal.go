@@ -43,10 +43,8 @@ func GreaterThanOrEqual(arguments ...Unifiable) GreaterThanOrEqualStruct {
 //     greater-than-or-equal predicate
 //     success/failure flag
 func ParseGreaterThanOrEqual(str string) (GreaterThanOrEqualStruct, bool) {
-    runes := []rune(str)
-    infix, index := identifyInfix(runes)
-    if infix != GREATER_THAN_OR_EQUAL { return GreaterThanOrEqualStruct{}, false }
-    term1, term2 := getLeftAndRight(runes, index, 2)
+    term1, term2, ok := parseInfix(str, ">=")
+    if !ok { return GreaterThanOrEqualStruct{}, false }
     return GreaterThanOrEqual(term1, term2), true
 } // ParseGreaterThanOrEqual
 
@@ -0,0 +1,198 @@
+package suiron
+
+// Intern - shared atoms, and hash-consing of ground terms.
+//
+// An Atom is a Go string, so that atoms can be declared as constants:
+//
+//    const noun = Atom("noun")
+//
+// Atoms which are parsed from the same text share their bytes. (See
+// atom() in parser.go.) Comparing two atoms which share their bytes
+// is a pointer comparison, not a comparison of their characters. Atoms
+// which differ usually differ in length or in their first byte.
+//
+// Hash-consing goes further: identical atoms and ground terms (terms
+// without variables), such as the facts of a lexicon, share the same
+// memory. This saves memory in large fact bases, where the same atoms
+// (noun, verb, etc.) occur thousands of times. The shared terms are kept in a hash-cons table, which belongs to the
+// program that makes it. Rules and facts which are shared through the
+// table can be added to any knowledge base:
+//
+//    table := MakeHashConsTable()
+//    kb.Add(table.Share(fact1, fact2)...)
+//
+// The table is freed when it is no longer referenced. The terms which
+// it has shared remain in the knowledge bases which hold them.
+//
+// A fact without variables does not need to be copied when it is
+// fetched from the knowledge base. (See GetRule() in knowledgebase.go.)
+//
+// A hash-cons table is safe for concurrent use.
+//
+// Cleve Lendon
+
+import (
+    "strconv"
+    "strings"
+    "sync"
+)
+
+// HashConsTable - identical ground terms, shared by their key.
+type HashConsTable struct {
+    mutex  sync.RWMutex
+    terms  map[string]Unifiable
+}
+
+// MakeHashConsTable - makes an empty hash-cons table.
+// Return: table
+func MakeHashConsTable() *HashConsTable {
+    return &HashConsTable{ terms: map[string]Unifiable{} }
+}
+
+// Share - shares the ground parts of the heads of rules and facts,
+// through the table.
+// Params: rules and facts
+// Return: shared rules and facts
+func (t *HashConsTable) Share(rules ...RuleStruct) []RuleStruct {
+    shared := make([]RuleStruct, len(rules))
+    for i, rule := range rules {
+        rule.head = t.HashCons(rule.head).(Complex)
+        shared[i] = rule
+    }
+    return shared
+}
+
+// HashCons - returns a shared copy of a term. The atoms and ground
+// parts of the term (complex terms and lists without variables) are
+// replaced by identical terms from the hash-cons table.
+// Variables and built-in functions are not changed.
+// Param:  term
+// Return: shared term
+func (t *HashConsTable) HashCons(term Unifiable) Unifiable {
+    shared, _ := t.hashCons(term)
+    return shared
+}
+
+// hashCons - shares a term and its subterms.
+// Return: shared term
+//         true if the term is ground
+func (table *HashConsTable) hashCons(term Unifiable) (Unifiable, bool) {
+    switch t := term.(type) {
+    case Atom:
+        return table.lookUpTerm(t), true
+    case Integer, Float, Anonymous:
+        return term, true
+    case Complex:
+        args := make(Complex, len(t))
+        ground := true
+        for i, arg := range t {
+            shared, g := table.hashCons(arg)
+            args[i] = shared
+            ground = ground && g
+        }
+        if !ground { return args, false }
+        return table.lookUpTerm(args), true
+    case LinkedListStruct:
+        if t.term == nil { return emptyList, true }
+        terms := []Unifiable{}
+        ground := true
+        ptr := &t
+        vbar := false
+        for ptr != nil && ptr.term != nil {
+            shared, g := table.hashCons(ptr.term)
+            terms = append(terms, shared)
+            ground = ground && g
+            vbar = ptr.tailVar
+            ptr = ptr.next
+        }
+        list := MakeLinkedList(vbar, terms...)
+        if !ground { return list, false }
+        return table.lookUpTerm(list), true
+    }
+    return term, false
+} // hashCons
+
+// lookUpTerm - finds an identical ground term in the hash-cons
+// table, or adds the term to the table.
+func (table *HashConsTable) lookUpTerm(term Unifiable) Unifiable {
+    key := termKey(term)
+    table.mutex.RLock()
+    shared, ok := table.terms[key]
+    table.mutex.RUnlock()
+    if ok { return shared }
+    table.mutex.Lock()
+    defer table.mutex.Unlock()
+    if shared, ok = table.terms[key]; ok { return shared }
+    table.terms[key] = term
+    return term
+}
+
+// termKey - makes a key for the hash-cons table. The key includes
+// the types of terms, so that the atom 1 and the integer 1 differ.
+// Variables are numbered in order of appearance, so that terms which
+// differ only in the names of their variables have the same key.
+// (See cache.go.)
+func termKey(term Unifiable) string {
+    var sb strings.Builder
+    vars := map[string]int{}
+    var write func(t Unifiable)
+    write = func(t Unifiable) {
+        switch x := t.(type) {
+        case VariableStruct:
+            n, ok := vars[x.String()]
+            if !ok {
+                n = len(vars)
+                vars[x.String()] = n
+            }
+            sb.WriteString("v" + strconv.Itoa(n) + ";")
+        case Atom:
+            name := string(x)
+            sb.WriteString("a" + strconv.Itoa(len(name)) + ":" + name + ";")
+        case Integer:
+            sb.WriteString("i" + strconv.FormatInt(int64(x), 10) + ";")
+        case Float:
+            sb.WriteString("f" + strconv.FormatFloat(float64(x), 'g', -1, 64) + ";")
+        case Anonymous:
+            sb.WriteString("_;")
+        case Complex:
+            sb.WriteString("c" + strconv.Itoa(len(x)) + "(")
+            for _, arg := range x { write(arg) }
+            sb.WriteString(")")
+        case LinkedListStruct:
+            sb.WriteString("[")
+            for ptr := &x; ptr != nil && ptr.term != nil; ptr = ptr.next {
+                if ptr.tailVar { sb.WriteString("|") }
+                write(ptr.term)
+            }
+            sb.WriteString("]")
+        }
+    }
+    write(term)
+    return sb.String()
+} // termKey
+
+// isGroundRule - returns true if a rule is a fact without variables.
+// Such a fact does not need to be copied by GetRule().
+func isGroundRule(rule RuleStruct) bool {
+    return rule.body == nil && isGroundTerm(rule.head)
+}
+
+// isGroundTerm - returns true if a term consists of atoms, numbers,
+// complex terms and lists, without variables.
+func isGroundTerm(term Unifiable) bool {
+    switch t := term.(type) {
+    case Atom, Integer, Float, Anonymous:
+        return true
+    case Complex:
+        for _, arg := range t {
+            if !isGroundTerm(arg) { return false }
+        }
+        return true
+    case LinkedListStruct:
+        for ptr := &t; ptr != nil && ptr.term != nil; ptr = ptr.next {
+            if !isGroundTerm(ptr.term) { return false }
+        }
+        return true
+    }
+    return false
+}
@@ -1,6 +1,6 @@
 package suiron
 
-// IntStack - LIFO stack for integers. Used by the tokenizer.
+// IntStack - LIFO stack for integers.
 //
 // Reference:
 // https://www.educative.io/edpresso/how-to-implement-a-stack-in-golang
@@ -6,11 +6,18 @@ package suiron
 // a slash. For example, for the fact mother(Carla, Caitlyn), the index, or key,
 // would be "mother/2".
 //
+// A knowledge base can be layered on top of another one, for the facts
+// of a session. (See overlay.go.) Changes can be grouped in transactions,
+// which are made all at once, or not at all. A knowledge base which is
+// read by other goroutines while it is changed must be shared, with
+// MakeSharedKB(). (See transaction.go.)
+//
 // Cleve Lendon
 
 import (
     "sort"
     "strings"
+    "sync/atomic"
     "fmt"
 )
 
@@ -18,10 +25,27 @@ import (
 // Each indexed item is a slice of rules and/or facts.
 type KnowledgeBase map[string][]RuleStruct
 
+// rules - returns the clauses of a predicate. For an overlay, these
+// are the visible clauses of the overlay and its parent.
+// Param:  key, eg. word/2
+// Return: list of clauses
+func (kb KnowledgeBase) rules(key string) []RuleStruct {
+    if o := kb.overlay(); o != nil { return o.rules(kb, key) }
+    return kb[key]
+}
+
+// lastClauseId - the ID of the last clause which was added to
+// a knowledge base.
+var lastClauseId uint64
+
 // Add - adds facts and rules to the knowledge base.
+// Each clause is given an ID, which it keeps in overlays, transactions
+// and snapshots of the knowledge base. (See overlay.go and coverage.go.)
 // Eg.  knowledgebase.Add(fact1, fact2, rule1, rule2)
 func (kb KnowledgeBase) Add(rules ...RuleStruct) {
     for _, rule := range rules {
+        rule.ground = isGroundRule(rule)
+        if rule.id == 0 { rule.id = atomic.AddUint64(&lastClauseId, 1) }
         key := rule.Key()
         sliceOfRules, found := kb[key]
         if !found {
@@ -30,9 +54,62 @@ func (kb KnowledgeBase) Add(rules ...RuleStruct) {
         } else {
             kb[key] = append(sliceOfRules, rule)
         }
+        invalidateCaches(kb, key)
+        if o := kb.overlay(); o != nil {
+            o.changed(key)
+            if o.tx != nil {
+                o.tx.changes = append(o.tx.changes, txChange{ rule: rule })
+            }
+        }
     }
 } // Add
 
+// Retract - removes the first rule or fact whose head unifies with
+// the given term. The list of rules is copied, not changed, so that
+// snapshots of the knowledge base are not disturbed. (See snapshot.go.)
+// In an overlay, a rule of the parent is hidden. (See overlay.go.)
+// Param:  head of rule or fact, eg. mother(Carla, $X)
+// Return: true if a rule was removed
+func (kb KnowledgeBase) Retract(head Complex) bool {
+    // The variables of the head need IDs, to be bound.
+    head = head.RecreateVariables(VarMap{}).(Complex)
+    if o := kb.overlay(); o != nil {
+        if !o.retract(kb, head) { return false }
+        if o.tx != nil {
+            o.tx.changes = append(o.tx.changes, txChange{ retract: head })
+        }
+        return true
+    }
+    key := head.Key()
+    for i, rule := range kb[key] {
+        if _, ok := fetchRule(rule).GetHead().Unify(head, SubstitutionSet{}); ok {
+            kb.removeRule(key, i)
+            invalidateCaches(kb, key)
+            return true
+        }
+    }
+    return false
+} // Retract
+
+// isSystemKey - returns true if a key is not the key of a predicate,
+// but of facts which the engine keeps in the knowledge base, such as
+// its modules. (See module.go.)
+func isSystemKey(key string) bool { return strings.HasPrefix(key, "(") }
+
+// removeRule - removes a rule from a list, by copying the list.
+// Params: key
+//         index of rule
+func (kb KnowledgeBase) removeRule(key string, i int) {
+    rules := kb[key]
+    if len(rules) == 1 {
+        delete(kb, key)
+    } else {
+        newRules := make([]RuleStruct, 0, len(rules) - 1)
+        newRules = append(newRules, rules[:i]...)
+        kb[key] = append(newRules, rules[i + 1:]...)
+    }
+}
+
 
 // GetRule - fetches a rule (or fact) from the knowledge base.
 // Rules are indexed by functor/arity (eg. sister/2) and by index number.
@@ -40,8 +117,8 @@ func (kb KnowledgeBase) Add(rules ...RuleStruct) {
 // recreateVariables().
 func (kb KnowledgeBase) GetRule(goal Goal, i int) RuleStruct {
     key := goal.(Complex).Key()
-    list, ok := kb[key]
-    if !ok {
+    list := kb.rules(key)
+    if len(list) == 0 {
         // Should never happen.
         panic("KnowledgeBase, GetRule - rule does not exist: " + key + "\n")
     }
@@ -50,23 +127,54 @@ func (kb KnowledgeBase) GetRule(goal Goal, i int) RuleStruct {
         msg := fmt.Sprintf("KnowledgeBase, GetRule - index out of range: %v %d\n", key, i)
         panic(msg)
     }
-    rule := list[i]
+    return fetchRule(list[i])
+}
+
+// fetchRule - makes the variables of a rule unique. A fact without
+// variables can be shared, so it is not copied. (See intern.go.)
+func fetchRule(rule RuleStruct) RuleStruct {
+    if rule.ground { return rule }
     rule2 := rule.RecreateVariables(make(VarMap))
     return rule2.(RuleStruct)
 }
 
 // FormatKB - formats the knowledge base facts and rules for display.
-// This method is useful for diagnostics. The keys are sorted.
+// This method is useful for diagnostics. The keys are sorted. Rules
+// which are not in a module come first, then the rules of each module,
+// under a heading. (See module.go.) Rules which were loaded from a file
+// are followed by their source, as a comment.
+// Eg.:  male(Godwin).  % kings.txt:2:1
+// The clauses of an overlay include those of its parent.
 func (kb KnowledgeBase) FormatKB() string {
+    kb = kb.Flatten()
     var sb strings.Builder
     sb.WriteString("\n########## Contents of Knowledge Base ##########\n")
     keys := make([]string, 0, len(kb))
-    for k := range kb { keys = append(keys, k) }
-    sort.Strings(keys)
+    for k := range kb {
+        if !isSystemKey(k) { keys = append(keys, k) }
+    }
+    module := func(k string) string {
+        if len(kb[k]) == 0 { return "" }
+        return kb[k][0].module
+    }
+    sort.Slice(keys, func(i, j int) bool {
+        mi, mj := module(keys[i]), module(keys[j])
+        if mi != mj { return mi < mj }
+        return keys[i] < keys[j]
+    })
+    previous := ""
     for _, k := range keys {
+        if m := module(k); m != previous {
+            sb.WriteString("---------- Module " + m + " ----------\n")
+            previous = m
+        }
         sb.WriteString(k + "\n")
         for i := 0; i < len(kb[k]); i++ {
-            sb.WriteString("    " + kb[k][i].String() + "\n")
+            sb.WriteString("    " + kb[k][i].String())
+            if source := kb[k][i].source.String(); source != "" {
+                sb.WriteString("  % " + source)
+            }
+            sb.WriteString("\n")
         }
     }
     return sb.String()
@@ -74,19 +182,11 @@ func (kb KnowledgeBase) FormatKB() string {
 
 
 // getRuleCount - counts the number of rules for the given goal.
-// When the execution time has been exceeded, this function will
-// return 0. Zero indicates that all rules have been exhausted.
 // Params:  goal
 // Returns: count
 func (kb KnowledgeBase) getRuleCount(goal Goal) int {
-
-    // The following line will stop the search for a solution if
-    // the execution time (300 msecs by default) has timed out.
-    if suironHasTimedOut { return 0 }
-
     key := goal.(Complex).Key()
-    listOfRules := kb[key]
+    listOfRules := kb.rules(key)
     return len(listOfRules)
-
 } // getRuleCount
 
@@ -0,0 +1,288 @@
+package suiron
+
+// Label - assigns values to finite-domain variables, by searching
+// their domains. (See clpfd.go.)
+//
+//    label([$X, $Y, $Z])
+//    labeling([ff], [$X, $Y, $Z])
+//
+// Propagation narrows domains, but usually it does not find a solution
+// by itself. Labeling tries the values of each variable in turn, and
+// propagates constraints after each assignment. On backtracking, the
+// next value is tried.
+//
+// The first argument of labeling/2 is a list of options. Options for
+// selecting the next variable:
+//
+//    leftmost       the leftmost unbound variable (default)
+//    ff             first fail: the variable with the smallest domain
+//    first_fail     same as ff
+//    min            the variable with the smallest lower bound
+//    max            the variable with the largest upper bound
+//
+// Options for the order of values:
+//
+//    up             smallest value first (default)
+//    down           largest value first
+//
+// The domains of all variables must be finite.
+//
+// Cleve Lendon
+
+import (
+    "fmt"
+)
+
+type LabelStruct BuiltInPredicateStruct
+
+// Label - creates the label predicate: label([$X, $Y])
+// Params: list of variables
+// Return: LabelStruct
+func Label(arguments ...Unifiable) LabelStruct {
+    if len(arguments) != 1 {
+        panic("Label - This predicate requires 1 argument.")
+    }
+    return LabelStruct {
+        Name: "label",
+        Arguments: arguments,
+    }
+}
+
+// Labeling - creates the labeling predicate: labeling([ff], [$X, $Y])
+// Params: list of options
+//         list of variables
+// Return: LabelStruct
+func Labeling(arguments ...Unifiable) LabelStruct {
+    if len(arguments) != 2 {
+        panic("Labeling - This predicate requires 2 arguments.")
+    }
+    return LabelStruct {
+        Name: "labeling",
+        Arguments: arguments,
+    }
+}
+
+// GetSolver - gets a solution node for this predicate.
+// This function satisfies the Goal interface.
+func (s LabelStruct) GetSolver(kb KnowledgeBase,
+                               parentSolution SubstitutionSet,
+                               parentNode SolutionNode) SolutionNode {
+
+    var vars, options Unifiable
+    if len(s.Arguments) == 1 {
+        vars = s.Arguments[0]
+    } else {
+        options = s.Arguments[0]
+        vars = s.Arguments[1]
+    }
+
+    terms, ok := fdListTerms(vars, parentSolution)
+    if !ok { panic(s.Name + " - The list of variables is invalid.") }
+    selection, order := labelOptions(s.Name, options, parentSolution)
+
+    return makeLabelSolutionNode(s, kb, terms, selection, order,
+                                 parentSolution, parentNode)
+}
+
+// labelOptions - reads the options of labeling/2.
+// Return: variable selection strategy
+//         value order
+func labelOptions(name string, options Unifiable,
+                  ss SubstitutionSet) (string, string) {
+    selection := "leftmost"
+    order := "up"
+    if options == nil { return selection, order }
+    terms, ok := fdListTerms(options, ss)
+    if !ok { panic(name + " - The list of options is invalid.") }
+    for _, term := range terms {
+        option, ok := ss.CastAtom(term)
+        if !ok {
+            panic(fmt.Sprintf("%v - Invalid option: %v", name, term))
+        }
+        switch option {
+        case "leftmost", "ff", "min", "max":
+            selection = string(option)
+        case "first_fail":
+            selection = "ff"
+        case "up", "down":
+            order = string(option)
+        default:
+            panic(fmt.Sprintf("%v - Invalid option: %v", name, term))
+        }
+    }
+    return selection, order
+} // labelOptions
+
+//----------------------------------------------------------------
+// RecreateVariables(), ReplaceVariables(), and String() satisfy
+// the Expression interface.
+//----------------------------------------------------------------
+
+// RecreateVariables - Refer to comments in expression.go.
+func (s LabelStruct) RecreateVariables(vars VarMap) Expression {
+    bip := BuiltInPredicateStruct(s).RecreateVariables(vars)
+    return Expression(LabelStruct(*bip))
+}
+
+// ReplaceVariables - Refer to comments in expression.go.
+func (s LabelStruct) ReplaceVariables(ss SubstitutionSet) Expression {
+    return BuiltInPredicateStruct(s).ReplaceVariables(ss)
+}  // ReplaceVariables
+
+// String - creates a string representation.
+// Returns:  label([$X, $Y])
+func (s LabelStruct) String() string {
+    return BuiltInPredicateStruct(s).String()
+}
+
+//----------------------------------------------------------------
+// Solution Node functions.
+//----------------------------------------------------------------
+
+// A label solution node selects one variable, and tries each value
+// in its domain. For each value, a child node labels the remaining
+// variables.
+type LabelSolutionNodeStruct struct {
+    SolutionNodeStruct
+    terms     []Unifiable   // terms to label
+    selection string
+    order     string
+    started   bool
+    selected  VariableStruct
+    domain    fdDomain
+    value     int64         // last value tried
+    hasValue  bool
+    rest      []Unifiable   // terms remaining after selected
+    child     SolutionNode
+}
+
+// makeLabelSolutionNode - creates a solution node for labeling.
+func makeLabelSolutionNode(goal Goal, kb KnowledgeBase,
+                           terms []Unifiable,
+                           selection string, order string,
+                           parentSolution SubstitutionSet,
+                           parentNode SolutionNode) SolutionNode {
+    node := LabelSolutionNodeStruct{
+                SolutionNodeStruct: MakeSolutionNode(goal, kb,
+                                        parentSolution, parentNode),
+                terms: terms,
+                selection: selection,
+                order: order,
+            }
+    return &node
+}
+
+// selectVariable - chooses the next variable to label, according
+// to the selection strategy. If all terms are bound, the success
+// flag is false.
+func (sn *LabelSolutionNodeStruct) selectVariable() bool {
+    st := makeConstraintStore(sn.ParentSolution)
+    bestIndex := -1
+    var bestDomain fdDomain
+    for i, term := range sn.terms {
+        ground, ok := sn.ParentSolution.GetGroundTerm(term)
+        if ok {
+            if ground.TermType() != INTEGER {
+                panic(fmt.Sprintf("%v - Not an integer: %v",
+                                  sn.Goal.(LabelStruct).Name, ground))
+            }
+            continue
+        }
+        d, _ := st.domainOf(ground)
+        if !d.isFinite() {
+            panic(fmt.Sprintf("%v - Domain of %v is not finite.",
+                              sn.Goal.(LabelStruct).Name, term))
+        }
+        better := bestIndex < 0
+        if !better {
+            switch sn.selection {
+            case "ff":  better = d.size() < bestDomain.size()
+            case "min": better = d.min() < bestDomain.min()
+            case "max": better = d.max() > bestDomain.max()
+            }
+        }
+        if better {
+            bestIndex = i
+            bestDomain = d
+            if sn.selection == "leftmost" { break }
+        }
+    }
+    if bestIndex < 0 { return false }
+    ground, _ := sn.ParentSolution.GetGroundTerm(sn.terms[bestIndex])
+    sn.selected = ground.(VariableStruct)
+    sn.domain = bestDomain
+    sn.rest = []Unifiable{}
+    for i, term := range sn.terms {
+        if i != bestIndex { sn.rest = append(sn.rest, term) }
+    }
+    return true
+} // selectVariable
+
+// nextValue - gets the next value to try for the selected variable.
+func (sn *LabelSolutionNodeStruct) nextValue() (int64, bool) {
+    if !sn.hasValue {
+        sn.hasValue = true
+        if sn.order == "down" { sn.value = sn.domain.max()
+        } else { sn.value = sn.domain.min() }
+        return sn.value, true
+    }
+    var ok bool
+    if sn.order == "down" {
+        sn.value, ok = sn.domain.previousValue(sn.value)
+    } else {
+        sn.value, ok = sn.domain.nextValue(sn.value)
+    }
+    return sn.value, ok
+}
+
+// NextSolution - binds the selected variable to the next value of
+// its domain, then labels the remaining variables.
+// Returns:
+//    updated substitution set
+//    success/failure flag
+// This function satisfies the SolutionNode interface.
+func (sn *LabelSolutionNodeStruct) NextSolution() (SubstitutionSet, bool) {
+
+    if sn.NoBackTracking { return nil, false }
+
+    if sn.child != nil {
+        solution, found := sn.child.NextSolution()
+        if found { return solution, true }
+        sn.child = nil
+    }
+
+    if !sn.started {
+        sn.started = true
+        if !sn.selectVariable() {
+            // Everything is labeled. There is one solution.
+            sn.NoBackTracking = true
+            return sn.ParentSolution, true
+        }
+    }
+
+    for {
+        value, ok := sn.nextValue()
+        if !ok { return nil, false }
+        ss, ok := sn.selected.Unify(Integer(value), sn.ParentSolution)
+        if !ok { continue }
+        sn.child = makeLabelSolutionNode(sn.Goal, sn.KnowledgeBase,
+                                         sn.rest, sn.selection, sn.order,
+                                         ss, sn)
+        solution, found := sn.child.NextSolution()
+        if found { return solution, true }
+        sn.child = nil
+    }
+
+} // NextSolution
+
+// SetNoBackTracking - set the NoBackTracking flag,
+// which is used to implement Cuts.
+// This function satisfies the SolutionNode interface.
+func (sn *LabelSolutionNodeStruct) SetNoBackTracking() {
+    sn.NoBackTracking = true
+}
+
+// GetParentNode
+func (sn *LabelSolutionNodeStruct) GetParentNode() SolutionNode {
+    return sn.ParentNode
+}
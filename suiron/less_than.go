@@ -43,10 +43,8 @@ func LessThan(arguments ...Unifiable) LessThanStruct {
 //     less-than predicate
 //     success/failure flag
 func ParseLessThan(str string) (LessThanStruct, bool) {
-    runes := []rune(str)
-    infix, index := identifyInfix(runes)
-    if infix != LESS_THAN { return LessThanStruct{}, false }
-    term1, term2 := getLeftAndRight(runes, index, 2)
+    term1, term2, ok := parseInfix(str, "<")
+    if !ok { return LessThanStruct{}, false }
     return LessThan(term1, term2), true
 } // ParseLessThan
 
@@ -43,10 +43,8 @@ func LessThanOrEqual(arguments ...Unifiable) LessThanOrEqualStruct {
 //     less-than-or-equal predicate
 //     success/failure flag
 func ParseLessThanOrEqual(str string) (LessThanOrEqualStruct, bool) {
-    runes := []rune(str)
-    infix, index := identifyInfix(runes)
-    if infix != LESS_THAN_OR_EQUAL { return LessThanOrEqualStruct{}, false }
-    term1, term2 := getLeftAndRight(runes, index, 2)
+    term1, term2, ok := parseInfix(str, "<=")
+    if !ok { return LessThanOrEqualStruct{}, false }
     return LessThanOrEqual(term1, term2), true
 } // ParseLessThanOrEqual
 
@@ -0,0 +1,471 @@
+package suiron
+
+// Lexer - divides the text of a Suiron program into tokens, for the
+// parser. (See parser.go.)
+//
+// Suiron's syntax depends on context. Atoms may contain spaces and
+// punctuation, so the text of an atom ends at a character which has
+// a meaning in the current context. The parser tells the lexer which
+// context it is in:
+//
+//    goalMode - facts, rules and goals. Comments, the neck (:-),
+//               the arrow of grammar rules (-->), the braces of
+//               grammar rules, the period which ends a rule, the
+//               semicolon (or) and infix operators ($X = $Y) are
+//               recognized.
+//    argMode  - the arguments of a complex term: print(Rank: %s., $R)
+//               Only commas, brackets and quote marks end an argument.
+//    listMode - the items of a list. As argMode, but a vertical bar
+//               separates the tail of the list.
+//    termMode - a term in parentheses: $X = (a = b). As argMode, but
+//               the built-in operators (=, <, etc.) are recognized,
+//               and so are :- --> ; and the comma, which make
+//               complex terms: $X = (a :- b, c)
+//
+// In all contexts, a backslash escapes the next character: \, \( \"
+// Text between double quotes is an atom. Within quotes, \" is a quote
+// mark and \\ is a backslash.
+//
+// Comments begin with %, # or //, and continue to the end of the line.
+// A hash which begins an operator (#=, #\=, #<, #>, #=<, #>=) does not
+// begin a comment. Comments are recognized only between goals, not in
+// the arguments of complex terms or lists.
+//
+// An infix operator must be preceded and followed by white space:
+// $X = 1 is a goal, but print($X=1) has an atom $X=1 as its argument.
+// The operators are listed in the operator table. (See op.go.)
+//
+// In goal mode, unquoted text ends at the end of a line. A period at
+// the end of a line always ends a rule, even in arguments, so that a
+// missing parenthesis is reported on the line where it is missing.
+//
+// Cleve Lendon
+
+import (
+    "sort"
+    "strings"
+    "unicode"
+)
+
+// lexMode - the context of the lexer.
+type lexMode int
+
+const (
+    goalMode lexMode = iota
+    argMode
+    listMode
+    termMode
+)
+
+// tokenKind - kinds of tokens.
+type tokenKind int
+
+const (
+    tkEOF tokenKind = iota
+    tkText        // unquoted text: atom, number or variable
+    tkQuoted      // text between double quotes
+    tkLParen      // (
+    tkRParen      // )
+    tkLBracket    // [
+    tkRBracket    // ]
+    tkComma       // ,
+    tkSemicolon   // ;
+    tkBar         // |
+    tkNeck        // :-
+    tkArrow       // --> (see dcg.go)
+    tkLBrace      // {
+    tkRBrace      // }
+    tkEnd         // period at the end of a rule
+    tkInfix       // infix operator, eg. =
+)
+
+// token - a token, and its position in the text.
+type token struct {
+    kind     tokenKind
+    text     string  // text of atom, variable or operator
+    escaped  bool    // the text contains escaped characters
+    start    int     // offset of first character (in runes)
+    end      int     // offset after last character
+    next     int     // offset where the next token is scanned
+    err      string  // lexical error, eg. unmatched quote
+}
+
+// punctuation - the text of punctuation tokens.
+var punctuation = map[tokenKind]string{
+    tkLParen: "(", tkRParen: ")", tkLBracket: "[", tkRBracket: "]",
+    tkComma: ",", tkSemicolon: ";", tkBar: "|", tkNeck: ":-", tkEnd: ".",
+    tkArrow: "-->", tkLBrace: "{", tkRBrace: "}",
+}
+
+// describe - describes a token for error messages.
+func (t token) describe() string {
+    switch t.kind {
+    case tkEOF:
+        return "end of text"
+    case tkText, tkInfix:
+        return t.text
+    case tkQuoted:
+        return "\"" + t.text + "\""
+    }
+    return punctuation[t.kind]
+}
+
+// lexer - holds the text to be divided into tokens.
+type lexer struct {
+    runes       []rune
+    lineStarts  []int     // offset of the first character of each line
+    firstLine   int       // number of the first line (see parseStream())
+    goalInfix   []string  // infix operators in goals (see op.go)
+    termInfix   []string  // infix operators in terms
+}
+
+// makeLexer - makes a lexer for the given text.
+func makeLexer(text string) *lexer {
+    lx := &lexer{ runes: []rune(text), lineStarts: []int{0}, firstLine: 1 }
+    for i, ch := range lx.runes {
+        if ch == '\n' { lx.lineStarts = append(lx.lineStarts, i + 1) }
+    }
+    return lx
+}
+
+// refreshOperators - gets the infix operators from the operator
+// table of the parser. This is called again after an op/3 directive.
+func (lx *lexer) refreshOperators(table operatorTable) {
+    lx.goalInfix, lx.termInfix = infixNames(table)
+}
+
+// position - returns the line and column of an offset.
+// Lines and columns are numbered from 1.
+func (lx *lexer) position(offset int) (int, int) {
+    line := sort.Search(len(lx.lineStarts), func(i int) bool {
+        return lx.lineStarts[i] > offset
+    })
+    return line + lx.firstLine - 1, offset - lx.lineStarts[line - 1] + 1
+}
+
+// lineText - returns the text of a line, without the newline.
+func (lx *lexer) lineText(line int) string {
+    line -= lx.firstLine - 1
+    start := lx.lineStarts[line - 1]
+    end := len(lx.runes)
+    if line < len(lx.lineStarts) { end = lx.lineStarts[line] - 1 }
+    return strings.TrimRight(string(lx.runes[start: end]), "\r")
+}
+
+// at - returns the character at an offset, or 0 after the end.
+func (lx *lexer) at(offset int) rune {
+    if offset < 0 || offset >= len(lx.runes) { return 0 }
+    return lx.runes[offset]
+}
+
+// isHashOperator - A hash (#) begins a comment, but finite domain
+// constraints (#=, #\=, #<, #>, #=<, #>=) also begin with a hash.
+// If the character after the hash is =, \, < or >, the hash is
+// part of an operator.
+// Param:  character after the hash
+// Return: true if the hash is part of an operator
+func isHashOperator(next rune) bool {
+    return next == '=' || next == '\\' || next == '<' || next == '>'
+}
+
+// commentAt - returns true if a comment begins at the offset.
+func (lx *lexer) commentAt(offset int) bool {
+    switch lx.at(offset) {
+    case '%':
+        return true
+    case '#':
+        return !isHashOperator(lx.at(offset + 1))
+    case '/':
+        return lx.at(offset + 1) == '/'
+    }
+    return false
+}
+
+// endAt - returns true if the period which ends a rule is at the
+// offset. The period must be followed by white space, a comment,
+// or the end of the text. (The periods in 1.5 or 1..9 do not end
+// a rule.)
+func (lx *lexer) endAt(offset int) bool {
+    if lx.at(offset) != '.' { return false }
+    next := offset + 1
+    return next >= len(lx.runes) || unicode.IsSpace(lx.runes[next]) ||
+           lx.commentAt(next)
+}
+
+// arrowAt - returns true if the arrow of a grammar rule (-->)
+// is at the offset.
+func (lx *lexer) arrowAt(offset int) bool {
+    return lx.at(offset) == '-' && lx.at(offset + 1) == '-' &&
+           lx.at(offset + 2) == '>'
+}
+
+// lineEndAt - returns true if the offset is at the end of a line,
+// or at the end of the text.
+func (lx *lexer) lineEndAt(offset int) bool {
+    ch := lx.at(offset)
+    return ch == '\n' || ch == '\r' || ch == 0
+}
+
+// termPunctuation - the punctuation which is an infix operator in
+// term mode: $X = (a :- b). (The comma is a token of its own.)
+var termPunctuation = []string{ "-->", ":-", ";" }
+
+// infixAt - returns the infix operator at the offset, if there is one.
+// An operator must be followed by white space. The built-in operators
+// at priority 700 (=, <, etc.) are recognized in goal mode and term
+// mode. In arguments and lists, they are recognized only next to an
+// operand in parentheses: t(a = (b = c))
+func (lx *lexer) infixAt(offset int, mode lexMode) (string, bool) {
+    if mode == termMode {
+        if op, ok := lx.matchInfix(offset, termPunctuation); ok {
+            return op, true
+        }
+    }
+    if mode == goalMode || mode == termMode {
+        return lx.matchInfix(offset, lx.goalInfix)
+    }
+    if op, ok := lx.matchInfix(offset, lx.termInfix); ok { return op, true }
+    op, ok := lx.matchInfix(offset, lx.goalInfix)
+    if !ok { return "", false }
+    before := offset - 1
+    for before >= 0 && unicode.IsSpace(lx.runes[before]) { before-- }
+    after := lx.skipSpace(offset + len([]rune(op)), mode)
+    if lx.at(before) == ')' || lx.at(after) == '(' { return op, true }
+    return "", false
+}
+
+// matchInfix - returns the operator of the list which is at the offset.
+// An operator must be followed by white space.
+func (lx *lexer) matchInfix(offset int, names []string) (string, bool) {
+    for _, op := range names {
+        n := len([]rune(op))
+        if offset + n > len(lx.runes) { continue }
+        if string(lx.runes[offset: offset + n]) != op { continue }
+        if offset + n < len(lx.runes) &&
+           !unicode.IsSpace(lx.runes[offset + n]) { continue }
+        return op, true
+    }
+    return "", false
+}
+
+// skipSpace - skips white space, and comments in goal mode.
+// Return: offset of next token
+func (lx *lexer) skipSpace(offset int, mode lexMode) int {
+    for offset < len(lx.runes) {
+        ch := lx.runes[offset]
+        if unicode.IsSpace(ch) {
+            offset++
+        } else if mode == goalMode && lx.commentAt(offset) {
+            for offset < len(lx.runes) && lx.runes[offset] != '\n' {
+                offset++
+            }
+        } else {
+            break
+        }
+    }
+    return offset
+}
+
+// delimiter - returns true if the character at the offset ends
+// unquoted text in the given mode.
+func (lx *lexer) delimiter(offset int, mode lexMode) bool {
+    switch lx.runes[offset] {
+    case '(', ')', '[', ']', ',', '"':
+        return true
+    case '|':
+        return mode == listMode
+    case ';', '{', '}':
+        return mode == goalMode
+    case ':':
+        return mode == goalMode && lx.at(offset + 1) == '-'
+    case '-':
+        return mode == goalMode && lx.arrowAt(offset)
+    }
+    if mode == goalMode {
+        return lx.endAt(offset) || lx.commentAt(offset)
+    }
+    return lx.endAt(offset) && lx.lineEndAt(offset + 1)
+}
+
+// scan - scans the token which begins at (or after) the offset.
+// Params: offset
+//         mode
+// Return: token
+func (lx *lexer) scan(offset int, mode lexMode) token {
+    start := lx.skipSpace(offset, mode)
+    t := token{ start: start, end: start + 1, next: start + 1 }
+    if start >= len(lx.runes) {
+        t.kind, t.end, t.next = tkEOF, start, start
+        return t
+    }
+    switch lx.runes[start] {
+    case '(':
+        t.kind = tkLParen
+        return t
+    case ')':
+        t.kind = tkRParen
+        return t
+    case '[':
+        t.kind = tkLBracket
+        return t
+    case ']':
+        t.kind = tkRBracket
+        return t
+    case ',':
+        t.kind = tkComma
+        return t
+    case '"':
+        return lx.scanQuoted(start)
+    }
+    if mode == listMode && lx.runes[start] == '|' {
+        t.kind = tkBar
+        return t
+    }
+    if lx.endAt(start) && (mode == goalMode || lx.lineEndAt(start + 1)) {
+        t.kind = tkEnd
+        return t
+    }
+    if mode == goalMode {
+        if lx.runes[start] == ';' {
+            t.kind = tkSemicolon
+            return t
+        }
+        if lx.runes[start] == ':' && lx.at(start + 1) == '-' {
+            t.kind, t.end, t.next = tkNeck, start + 2, start + 2
+            return t
+        }
+        if lx.arrowAt(start) {
+            t.kind, t.end, t.next = tkArrow, start + 3, start + 3
+            return t
+        }
+        if lx.runes[start] == '{' {
+            t.kind = tkLBrace
+            return t
+        }
+        if lx.runes[start] == '}' {
+            t.kind = tkRBrace
+            return t
+        }
+    }
+    if op, ok := lx.infixAt(start, mode); ok {
+        n := len([]rune(op))
+        t.kind, t.text, t.end, t.next = tkInfix, op, start + n, start + n
+        return t
+    }
+    return lx.scanText(start, mode)
+} // scan
+
+// scanText - scans unquoted text. Leading and trailing white space
+// is not included. White space which contains a new line becomes
+// a single space.
+func (lx *lexer) scanText(start int, mode lexMode) token {
+    t := token{ kind: tkText, start: start }
+    var sb strings.Builder
+    keep := 0       // length of text, without trailing white space
+    end := start    // offset after last character kept
+    offset := start
+    for offset < len(lx.runes) {
+        ch := lx.runes[offset]
+        if ch == '\\' && offset + 1 < len(lx.runes) {
+            sb.WriteRune(lx.runes[offset + 1])
+            t.escaped = true
+            offset += 2
+            keep, end = sb.Len(), offset
+            continue
+        }
+        if unicode.IsSpace(ch) {
+            // A run of white space.
+            next := offset
+            newLine := false
+            for next < len(lx.runes) && unicode.IsSpace(lx.runes[next]) {
+                if lx.runes[next] == '\n' { newLine = true }
+                next++
+            }
+            if mode == goalMode && newLine { break }
+            if _, ok := lx.infixAt(next, mode); ok { break }
+            if newLine {
+                sb.WriteRune(' ')
+            } else {
+                sb.WriteString(string(lx.runes[offset: next]))
+            }
+            offset = next
+            continue
+        }
+        if lx.delimiter(offset, mode) { break }
+        sb.WriteRune(ch)
+        offset++
+        keep, end = sb.Len(), offset
+    }
+    t.text = sb.String()[:keep]
+    t.end, t.next = end, end
+    return t
+} // scanText
+
+// scanQuoted - scans text between double quotes. A quoted atom
+// must end on the line where it begins.
+func (lx *lexer) scanQuoted(start int) token {
+    t := token{ kind: tkQuoted, start: start }
+    var sb strings.Builder
+    offset := start + 1
+    for {
+        if offset >= len(lx.runes) || lx.runes[offset] == '\n' {
+            t.err = "Unmatched quote mark"
+            break
+        }
+        ch := lx.runes[offset]
+        if ch == '\\' && (lx.at(offset + 1) == '"' ||
+                          lx.at(offset + 1) == '\\') {
+            sb.WriteRune(lx.runes[offset + 1])
+            offset += 2
+            continue
+        }
+        offset++
+        if ch == '"' { break }
+        sb.WriteRune(ch)
+    }
+    t.text = sb.String()
+    t.end, t.next = offset, offset
+    return t
+} // scanQuoted
+
+// skipRule - finds the end of a rule which has an error, so that
+// the parser can continue with the next rule. The rule ends at a
+// period which is not between brackets or quote marks, or at a
+// period at the end of a line.
+// Param:  offset of error
+// Return: offset after the end of the rule
+func (lx *lexer) skipRule(offset int) int {
+    depth := 0
+    quoted := false
+    for ; offset < len(lx.runes); offset++ {
+        ch := lx.runes[offset]
+        switch {
+        case ch == '\n':
+            quoted = false
+        case quoted:
+            if ch == '\\' {
+                offset++
+            } else if ch == '"' {
+                quoted = false
+            }
+        case ch == '\\':
+            offset++
+        case ch == '"':
+            quoted = true
+        case ch == '(' || ch == '[':
+            depth++
+        case ch == ')' || ch == ']':
+            depth--
+        case depth <= 0 && lx.commentAt(offset):
+            for offset < len(lx.runes) && lx.runes[offset] != '\n' {
+                offset++
+            }
+            offset--
+        case lx.endAt(offset):
+            if depth <= 0 || lx.lineEndAt(offset + 1) {
+                return offset + 1
+            }
+        }
+    }
+    return len(lx.runes)
+} // skipRule
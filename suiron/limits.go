@@ -0,0 +1,212 @@
+package suiron
+
+// Limits - resource limits for a single query.
+//
+// The time limit (SetMaxTimeMilliseconds()) is global. A query can
+// use a lot of memory well within its time, because each binding takes
+// memory, and built-in predicates copy substitution sets when they bind
+// variables. SolveWithLimits() sets limits for one query:
+//
+//    limits := Limits{ MaxInferences: 100000, MaxDepth: 500,
+//                      MaxSolutions: 10, MaxBindings: 1000000 }
+//    solutions, stats, err := SolveWithLimits(query, kb, ss, limits)
+//    if limitErr, ok := err.(*LimitError); ok {
+//        fmt.Println(limitErr.Kind, "limit exceeded")
+//    }
+//
+// The limits are:
+//
+//    MaxInferences - number of goals called (built-in predicates too)
+//    MaxDepth      - depth of recursion: the number of rules used to
+//                    reach a goal
+//    MaxSolutions  - number of solutions
+//    MaxBindings   - approximate number of bindings allocated; each
+//                    binding counts one, and each copy of a
+//                    substitution set counts its length
+//
+// Zero means no limit. When a limit is exceeded, the search stops, and
+// the solutions which were found before are returned, with a *LimitError.
+// The counters (QueryStats) are returned in any case.
+//
+// The counters include the goals of the query and of the rules which
+// solve it, and the goals which are solved inside built-in predicates,
+// such as not(). If a limit is exceeded inside not(), the whole query
+// stops; not() does not succeed.
+//
+// Cleve Lendon
+
+import (
+    "errors"
+    "fmt"
+)
+
+// Limits - resource limits for one query. Zero means no limit.
+type Limits struct {
+    MaxInferences  int64
+    MaxDepth       int
+    MaxSolutions   int
+    MaxBindings    int64
+}
+
+// QueryStats - the resources used by a query.
+type QueryStats struct {
+    Inferences  int64  // goals called
+    MaxDepth    int    // greatest depth of a goal
+    Solutions   int
+    Bindings    int64  // approximate number of bindings allocated
+}
+
+// LimitKind - identifies a resource limit.
+type LimitKind int
+
+const (
+    InferenceLimit LimitKind = iota + 1
+    DepthLimit
+    SolutionLimit
+    BindingLimit
+)
+
+// String - returns the name of a limit.
+func (k LimitKind) String() string {
+    switch k {
+    case InferenceLimit:
+        return "Inference"
+    case DepthLimit:
+        return "Depth"
+    case SolutionLimit:
+        return "Solution"
+    case BindingLimit:
+        return "Binding"
+    }
+    return "Unknown"
+}
+
+// LimitError - the error which is returned when a limit is exceeded.
+type LimitError struct {
+    Kind   LimitKind
+    Limit  int64
+}
+
+// Error - satisfies the error interface.
+func (e *LimitError) Error() string {
+    return fmt.Sprintf("%v limit exceeded: %v", e.Kind, e.Limit)
+}
+
+// queryLimits - the limits and counters of a running query.
+type queryLimits struct {
+    Limits
+    stats     QueryStats
+    exceeded  *LimitError
+}
+
+// call - counts a goal which is called.
+// Params: goal
+//         depth of goal
+// Return: false if a limit was exceeded
+func (q *queryLimits) call(goal Goal, depth int) bool {
+    if q.exceeded != nil { return false }
+    switch goal.(type) {
+    case AndOp, OrOp:  // control, not inferences
+        return true
+    }
+    q.stats.Inferences++
+    if depth > q.stats.MaxDepth { q.stats.MaxDepth = depth }
+    if q.MaxInferences > 0 && q.stats.Inferences > q.MaxInferences {
+        q.exceeded = &LimitError{ Kind: InferenceLimit, Limit: q.MaxInferences }
+        q.stats.Inferences--
+        return false
+    }
+    if q.MaxDepth > 0 && depth > q.MaxDepth {
+        q.exceeded = &LimitError{ Kind: DepthLimit, Limit: int64(q.MaxDepth) }
+        return false
+    }
+    return true
+} // call
+
+// bind - counts the bindings allocated by a step of the search:
+// one for each binding, and the length of each copy of a substitution
+// set. (See trail.go.)
+// Param:  number of bindings
+// Return: false if a limit was exceeded
+func (q *queryLimits) bind(count int64) bool {
+    if count == 0 { return true }
+    q.stats.Bindings += count
+    if q.MaxBindings > 0 && q.stats.Bindings > q.MaxBindings {
+        q.exceeded = &LimitError{ Kind: BindingLimit, Limit: q.MaxBindings }
+        return false
+    }
+    return true
+}
+
+// limitedSolutionNode - counts the solutions of a query, and stops
+// the search when a limit is exceeded.
+type limitedSolutionNode struct {
+    SolutionNodeStruct
+    engine  *EngineSolutionNodeStruct
+    limits  *queryLimits
+}
+
+// setStopFlag - gives the stop flag of the query to the node and to
+// its engine.
+func (n *limitedSolutionNode) setStopFlag(stop *stopFlag) {
+    n.stop = stop
+    n.engine.stop = stop
+}
+
+// NextSolution - continues the search for a solution.
+// This function satisfies the SolutionNode interface.
+func (n *limitedSolutionNode) NextSolution() (SubstitutionSet, bool) {
+    if n.NoBackTracking || n.limits.exceeded != nil { return nil, false }
+    solution, found := n.engine.NextSolution()
+    if !found { return nil, false }
+    max := n.limits.MaxSolutions
+    if max > 0 && n.limits.stats.Solutions >= max {
+        n.limits.exceeded = &LimitError{ Kind: SolutionLimit, Limit: int64(max) }
+        return nil, false
+    }
+    n.limits.stats.Solutions++
+    return solution, true
+}
+
+// SetNoBackTracking - set the NoBackTracking flag.
+func (n *limitedSolutionNode) SetNoBackTracking() {
+    n.NoBackTracking = true
+}
+
+// GetParentNode
+func (n *limitedSolutionNode) GetParentNode() SolutionNode {
+    return n.ParentNode
+}
+
+// SolveWithLimits - finds all solutions for the given query, within
+// the given resource limits. The error is nil if the search finished,
+// even if there were no solutions. If a limit was exceeded, the error
+// is a *LimitError.
+// Params:  query
+//          knowledge base
+//          substitution set (previous bindings)
+//          limits
+// Returns: solutions
+//          resources used
+//          error
+func SolveWithLimits(query Complex, kb KnowledgeBase, ss SubstitutionSet,
+                     limits Limits) ([]Complex, QueryStats, error) {
+
+    ql := &queryLimits{ Limits: limits }
+    solutions, failure := solveEvery(query, func() SolutionNode {
+        engine := &EngineSolutionNodeStruct{
+                      SolutionNodeStruct: MakeSolutionNode(query, kb, ss, nil),
+                      limits: ql,
+                  }
+        return &limitedSolutionNode{
+                   SolutionNodeStruct: MakeSolutionNode(query, kb, ss, nil),
+                   engine: engine,
+                   limits: ql,
+               }
+    })
+
+    if ql.exceeded != nil { return solutions, ql.stats, ql.exceeded }
+    if failure == "" || failure == "No" { return solutions, ql.stats, nil }
+    return solutions, ql.stats, errors.New(failure)
+
+} // SolveWithLimits
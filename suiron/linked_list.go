@@ -41,7 +41,6 @@ package suiron
 
 import (
     "strings"
-    "fmt"
 )
 
 type LinkedListStruct struct {
@@ -116,169 +115,6 @@ func MakeLinkedList(vbar bool, args ...Unifiable) LinkedListStruct {
                        count: num, tailVar: tailVar }
 }
 
-// equalEscape - compares the indexed character (rune) with the given
-// character. If they are equal, the function will return true, except
-// if the indexed character is proceeded by an backslash. Because...
-// characters which are escaped by a backslash should not be interpreted
-// by the parser; they need to be included as they are. Examples:
-//
-//    text := []rune("OK, sure.")
-//    if equalEscape(text, 2, ',')  <-- returns true
-//
-//    text2 := []rune("OK\\, sure.")  <-- double backslash for Go
-//    if equalEscape(text2, 3, ',')  <-- returns false
-//
-func equalEscape(runes []rune, index int, ch rune) bool {
-    r := runes[index]
-    if r == ch {
-        if index > 0 {
-            previous := runes[index - 1]
-            if previous == '\\' { return false }
-        }
-        return true
-    }
-    return false
-}
-
-// ParseLinkedList - parses a string to create a linked list.
-// For example,
-//     list, err := ParseLinkedList("[a, b, c | $X]")
-// Produces an error if the string is invalid.
-func ParseLinkedList(str string) (LinkedListStruct, error) {
-
-    s := strings.TrimSpace(str)
-    r := []rune(s)
-    length := len(r)
-
-    list := LinkedListStruct{}   // Make an empty list.
-
-    if length < 2 {
-        err := parseLinkedListError("String is too short", s)
-        return list, err
-    }
-
-    first := r[0]
-    if first != '[' {
-        err := parseLinkedListError("Missing opening bracket", s)
-        return list, err
-    }
-    last := r[length - 1]
-    if last != ']' {
-        err := parseLinkedListError("Missing closing bracket", s)
-        return list, err
-    }
-
-    if length == 2 { return list, nil }
-
-    arguments := r[1: length-1] // remove brackets
-    argLength := len(arguments)
-
-    vbar        := false
-    endIndex    := argLength
-
-    openQuote   := false
-    numQuotes   := 0
-    roundDepth  := 0   // depth of round parentheses (())
-    squareDepth := 0   // depth of square brackets [[]]
-
-    var i int
-    for i = argLength - 1; i >= 0; i-- {
-        if openQuote {
-            if equalEscape(arguments, i, '"') {
-                openQuote = false
-                numQuotes += 1
-            }
-        } else {
-            if equalEscape(arguments, i, ']') {
-                squareDepth++
-            } else if equalEscape(arguments, i, '[') {
-                squareDepth--
-            } else if equalEscape(arguments, i, ')') {
-                 roundDepth++;
-            } else if equalEscape(arguments, i, '(') {
-                 roundDepth--
-            } else if roundDepth == 0 && squareDepth == 0 {
-                if equalEscape(arguments, i, '"') {
-                    openQuote = true  // first quote
-                    numQuotes += 1
-                } else if equalEscape(arguments, i, ',') {
-                    strTerm := string(arguments[i + 1: endIndex])
-                    strTerm = strings.TrimSpace(strTerm)
-                    if len(strTerm) == 0 {
-                        err := parseLinkedListError("Missing argument", s)
-                        return list, err
-                    }
-                    error := checkQuotes(strTerm, numQuotes)
-                    if error != nil { return list, error }
-                    term, err := parseTerm(strTerm)
-                    if err == nil {
-                        list = linkFront(term, false, list)
-                        endIndex = i
-                    } else { return emptyList, err }
-                    numQuotes = 0
-                } else if equalEscape(arguments, i, '|') {  // Must be a tail variable.
-                    if vbar {
-                        err := parseLinkedListError("Too many vertical bars.", s)
-                        return list, err
-                    }
-                    strTerm := string(arguments[i + 1: endIndex])
-                    strTerm = strings.TrimSpace(strTerm)
-                    if len(strTerm) == 0 {
-                        err := parseLinkedListError("Missing argument", s)
-                        return list, err
-                    }
-                    term, err := LogicVar(strTerm)
-                    if err != nil {
-                        err := parseLinkedListError("Require variable after vertical bar", s)
-                        return list, err
-                    }
-                    vbar = true
-                    list = linkFront(term, true, list);  // tail variable
-                    endIndex = i
-                }
-            }
-        } // openQuote
-
-        if i == 0 {
-            strTerm := string(arguments[0: endIndex])
-            strTerm = strings.TrimSpace(strTerm)
-            if len(strTerm) == 0 {
-                err := parseLinkedListError("Missing argument", strTerm)
-                return list, err
-            }
-            error := checkQuotes(strTerm, numQuotes)
-            if error != nil { return list, error }
-            term, err := parseTerm(strTerm)
-            if err != nil { return list, err }
-            list = linkFront(term, false, list)
-            endIndex = i
-        }
-
-    } // for
-
-    return list, nil
-
-} // ParseLinkedList()
-
-
-// linkFront - adds a term to the front of the given linked list (LinkedList).
-// Params:  term to add in
-//          tail variable flag
-//          linked list
-// Return:  new linked list
-// Note: The tail variable flag is true when the term is tail variable
-func linkFront(term Unifiable, tailVar bool, list LinkedListStruct) LinkedListStruct {
-    count := list.count + 1
-    return LinkedListStruct{ term: term, next: &list, count: count, tailVar: tailVar }
-}
-
-// parseLinkedListError - creates an error for ParseLinkedList().
-// msg - error message
-// str - string which caused the error
-func parseLinkedListError(msg string, str string) error {
-    return fmt.Errorf("ParseLinkedList() - %v: %v", msg, str)
-}
-
 // Flatten - partially flattens this linked list.
 // If the number of terms requested is two, this function will return
 // a slice of the first and second terms, and the tail of the linked
@@ -424,43 +260,69 @@ func (ll LinkedListStruct) RecreateVariables(vars VarMap) Expression {
 // This method is used for displaying final results.
 // Refer to comments in expression.go.
 func (ll LinkedListStruct) ReplaceVariables(ss SubstitutionSet) Expression {
+    return ll.replaceVariables(ss, map[int]bool{})
+}
+
+// replaceVariables - replaces bound variables with their bindings,
+// except for the variables being expanded. (See variable.go.)
+// A tail variable which is bound to a list is followed iteratively,
+// so the time is proportional to the length of the list.
+func (ll LinkedListStruct) replaceVariables(ss SubstitutionSet,
+                                            visiting map[int]bool) Expression {
+    if ll.term == nil { return ll }
     newTerms := []Unifiable{}
-    var newTerm Unifiable
-    thisList := &ll
-    term     := thisList.term
-    if term == nil { return ll }
-    for term != nil {
-        newTerm = term.ReplaceVariables(ss).(Unifiable)
-        tt := newTerm.TermType()
-        if tt == LINKEDLIST {
-            list := newTerm.(LinkedListStruct)
-            ptr := &list
-            head := ptr.term
-            for head != nil {
-                newTerms = append(newTerms, head)
-                ptr = ptr.next
-                if ptr == nil { break }
-                head = ptr.term
+    vbar := false
+    tails := []int{}  // tail variables being expanded
+    for ptr := &ll; ptr != nil && ptr.term != nil; ptr = ptr.next {
+        if !ptr.tailVar {
+            newTerms = append(newTerms,
+                              replaceBindings(ptr.term, ss, visiting).(Unifiable))
+            continue
+        }
+        // Follow the tail variable to its binding.
+        term := ptr.term
+        for term.TermType() == VARIABLE {
+            v := term.(VariableStruct)
+            if v.id >= len(ss) || ss[v.id] == nil ||
+               (*ss[v.id]).TermType() == ATTRIBUTES { break }
+            if (*ss[v.id]).TermType() == LINKEDLIST && visiting[v.id] { break }
+            term = *ss[v.id]
+            if term.TermType() == LINKEDLIST {
+                visiting[v.id] = true
+                tails = append(tails, v.id)
             }
-        } else {  // not a list
-           newTerms = append(newTerms, newTerm)
         }
-        thisList = thisList.next
-        if thisList == nil { break }
-        term = thisList.term
+        if term.TermType() == LINKEDLIST {
+            list := term.(LinkedListStruct)
+            ptr = &LinkedListStruct{ next: &list }
+            continue
+        }
+        // An unbound or cyclic tail variable stays a tail.
+        newTerms = append(newTerms, replaceBindings(term, ss, visiting).(Unifiable))
+        vbar = term.TermType() == VARIABLE
+    }
+    for _, id := range tails { delete(visiting, id) }
+
+    // Build the new list from its last term.
+    last := len(newTerms) - 1
+    tail := &emptyList
+    count := 1
+    for i := last; i > 0; i-- {
+        tail = &LinkedListStruct{ term: newTerms[i], next: tail,
+                                  count: count, tailVar: vbar && i == last }
+        count++
     }
-    
-    result := MakeLinkedList(false, newTerms...)
-    return result
+    return LinkedListStruct{ term: newTerms[0], next: tail,
+                             count: count, tailVar: vbar && last == 0 }
 
-} // ReplaceVariables()
+} // replaceVariables()
 
 // String - returns a string representation of this list.
 func (ll LinkedListStruct) String() string {
     ptr := &ll
     if ptr.term == nil { return "[]" }
     var sb strings.Builder
-    sb.WriteString("[" + ptr.term.String())
+    sb.WriteString("[" + formatOperand(ptr.term, 999))
     for ptr.next != nil {
         ptr = ptr.next
         if ptr.term == nil {
@@ -468,7 +330,7 @@ func (ll LinkedListStruct) String() string {
         } else if ptr.tailVar {
             sb.WriteString(" | " + ptr.term.String())
         } else {
-            sb.WriteString(", " + ptr.term.String())
+            sb.WriteString(", " + formatOperand(ptr.term, 999))
         }
     }
     sb.WriteString("]")
@@ -34,15 +34,49 @@ const (
     LESS_THAN
     GREATER_THAN_OR_EQUAL
     LESS_THAN_OR_EQUAL
+
+//-----------CLP(FD)-----------
+    IN             // in   Domain of a finite-domain variable.
+    INS            // ins  Domain of a list of variables.
+    FD_EQUAL       // #=
+    FD_NOT_EQUAL   // #\=
+    FD_LESS_THAN   // #<
+    FD_GREATER_THAN           // #>
+    FD_LESS_THAN_OR_EQUAL     // #=<
+    FD_GREATER_THAN_OR_EQUAL  // #>=
+
+//--------binding store--------
+    ATTRIBUTES     // attributes of an unbound variable
 )
 
 var suironConstString = [...]string{ "NONE", "ATOM", "INTEGER",
     "FLOAT", "VARIABLE", "COMPLEX", "LINKEDLIST", "ANONYMOUS",
     "FUNCTION", "SUBGOAL", "COMMA", "SEMICOLON", "LPAREN", "RPAREN",
     "GROUP", "AND", "OR", "UNIFY", "EQUAL", "GREATER_THAN",
-    "LESS_THAN", "GREATER_THAN_OR_EQUAL", "LESS_THAN_OR_EQUAL" }
+    "LESS_THAN", "GREATER_THAN_OR_EQUAL", "LESS_THAN_OR_EQUAL",
+    "IN", "INS", "FD_EQUAL", "FD_NOT_EQUAL", "FD_LESS_THAN",
+    "FD_GREATER_THAN", "FD_LESS_THAN_OR_EQUAL",
+    "FD_GREATER_THAN_OR_EQUAL", "ATTRIBUTES" }
 
 func srConstToString(c int) string {
     if c < 0 || c >= len(suironConstString) { return "" }
     return suironConstString[c]
 }
+
+// LetterNumberHyphen - determines whether the given character (rune)
+// is a letter, a number, or a hyphen. This excludes punctuation.
+//
+// Param:  character (rune)
+// Return: true/false
+//
+func LetterNumberHyphen(ch rune) bool {
+    if ch >= 'a' && ch <= 'z' { return true }
+    if ch >= 'A' && ch <= 'Z' { return true }
+    if ch >= '0' && ch <= '9' { return true }
+    // hyphen or soft hyphen
+    if ch == '-' || ch == 0xAD { return true }
+    if ch == '_' { return true }
+    if ch >= 0xC0  && ch < 0x2C0 { return true }
+    if ch >= 0x380 && ch < 0x510 { return true }
+    return false
+}
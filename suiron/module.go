@@ -0,0 +1,442 @@
+package suiron
+
+// Module - modules give the predicates of a file a namespace of their
+// own, so that rule files from different sources can be combined.
+// A module file begins with the directive module/2, which names the
+// module and lists the predicates which it exports:
+//
+//    :- module(grammar, [sentence/2, parse/2]).
+//
+// The predicates of a module are qualified by its name. The rules for
+// check/2 in the module grammar have the head grammar:check($X, $Y),
+// and their key is grammar:check/2. Predicates which are not exported
+// are private. Another module can define its own check/2 without
+// a collision.
+//
+// A file imports the predicates which a module exports with
+// use_module/1, or some of them with use_module/2:
+//
+//    :- use_module(grammar.txt).
+//    :- use_module(lib/lists.txt, [reverse/2]).
+//
+// The module file is loaded once, as with ensure_loaded/1. An exported
+// predicate can also be called by its qualified name, without being
+// imported: grammar:parse($In, $Out). A private predicate can only be
+// called from its own module:
+//
+//    main.txt:4:9: check/2 is private to module grammar
+//
+// When all files have been loaded, the goals of each rule are resolved.
+// An unqualified goal calls the predicate of the rule's module, if the
+// module defines it, or else an imported predicate. Otherwise, the goal
+// is not qualified. Goals in the arguments of freeze/2, when/2, time/1
+// and phrase/2,3 are resolved as well.
+//
+// Files which do not declare a module are not qualified. Their facts
+// and rules, and the predicates they import, are shared, as before.
+// So that queries can call an imported predicate by its unqualified
+// name, a rule which calls it by its qualified name is added, unless
+// a file outside a module defines the predicate:
+//
+//    check($A1) :- grammar:check($A1).
+// A file loaded by consult/1 or include/1 is in a module only if it
+// declares one.
+//
+// The modules of a knowledge base are recorded in it, by facts which
+// queries cannot call, so they are added in the transaction of the
+// load (see transaction.go), and a load which fails leaves none behind.
+// In a knowledge base, a module can only be defined by one file. Go code
+// can call any predicate of a module by its qualified name.
+//
+// Cleve Lendon
+
+import (
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// ModuleStruct - a module: its name, the file which defines it,
+// and the predicates which it exports, eg. parse/2.
+type ModuleStruct struct {
+    Name     string
+    File     string
+    Exports  []string
+    key      string   // identifies the file (see loader.key())
+}
+
+// exports - returns true if the module exports the predicate.
+// Param: key of predicate, eg. parse/2
+func (m ModuleStruct) exports(key string) bool {
+    for _, export := range m.Exports {
+        if export == key { return true }
+    }
+    return false
+}
+
+// moduleFunctor - the functor of the facts which record the modules
+// of a knowledge base: (module)(Name, File, Exports, Key). It cannot be
+// the functor of a predicate.
+const moduleFunctor = "(module)"
+const moduleKey = moduleFunctor + "/4"
+
+// moduleFact - makes the fact which records a module.
+// Param:  module
+// Return: fact
+func moduleFact(m ModuleStruct) RuleStruct {
+    exports := Atom(strings.Join(m.Exports, " "))
+    return Fact(Complex{ Atom(moduleFunctor), Atom(m.Name), Atom(m.File),
+                         exports, Atom(m.key) })
+}
+
+// Module - returns the module of the knowledge base which has
+// the given name.
+// Params: name
+// Return: module
+//         success/failure flag
+func (kb KnowledgeBase) Module(name string) (ModuleStruct, bool) {
+    for _, fact := range kb.rules(moduleKey) {
+        head := fact.GetHead()
+        if head[1].String() == name {
+            return ModuleStruct{ Name: name, File: head[2].String(),
+                                 Exports: strings.Fields(head[3].String()),
+                                 key: head[4].String() }, true
+        }
+    }
+    return ModuleStruct{}, false
+}
+
+// Modules - returns the modules of the knowledge base, sorted by name.
+func (kb KnowledgeBase) Modules() []ModuleStruct {
+    list := []ModuleStruct{}
+    for _, fact := range kb.rules(moduleKey) {
+        m, _ := kb.Module(fact.GetHead()[1].String())
+        list = append(list, m)
+    }
+    sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+    return list
+}
+
+// defineModule - records a module in the transaction of the load.
+// A module which is defined by another file is an error.
+// Param:  module
+// Return: error or nil
+func (l *loader) defineModule(module ModuleStruct) error {
+    if m, ok := l.tx.KnowledgeBase().Module(module.Name); ok {
+        if m.key != module.key {
+            return fmt.Errorf("Module %v is already defined in %v",
+                              module.Name, m.File)
+        }
+        return nil
+    }
+    l.tx.Add(moduleFact(module))
+    return nil
+}
+
+// qualifiedName - qualifies a predicate name by a module name.
+// Eg.: grammar, check -> grammar:check
+// Outside a module (""), the name is unchanged.
+func qualifiedName(module string, name Atom) Atom {
+    if module == "" { return name }
+    return Atom(module + ":" + string(name))
+}
+
+// splitQualified - if a name is qualified by a module of the knowledge
+// base, returns the module and the unqualified name.
+// Eg.: grammar:check -> grammar module, check
+func splitQualified(kb KnowledgeBase,
+                    name Atom) (ModuleStruct, Atom, bool) {
+    str := string(name)
+    i := strings.Index(str, ":")
+    if i <= 0 { return ModuleStruct{}, name, false }
+    module, ok := kb.Module(str[:i])
+    return module, Atom(str[i + 1:]), ok
+}
+
+// predicateIndicator - checks a predicate indicator, eg. parse/2.
+// Param:  term
+// Return: key of predicate
+//         success/failure flag
+func predicateIndicator(term Unifiable) (string, bool) {
+    atom, ok := term.(Atom)
+    if !ok { return "", false }
+    str := atom.String()
+    i := strings.LastIndex(str, "/")
+    if i <= 0 { return "", false }
+    arity, err := strconv.Atoi(str[i + 1:])
+    if err != nil || arity < 0 { return "", false }
+    return fmt.Sprintf("%v/%d", str[:i], arity), true
+}
+
+// listItems - returns the items of a list, or the term itself
+// if it is not a list.
+func listItems(term Unifiable) []Unifiable {
+    list, ok := term.(LinkedListStruct)
+    if !ok { return []Unifiable{ term } }
+    items := []Unifiable{}
+    for ptr := &list; ptr != nil && ptr.term != nil; ptr = ptr.next {
+        items = append(items, ptr.term)
+    }
+    return items
+}
+
+//----------------------------------------------------------------
+// Directives
+//----------------------------------------------------------------
+
+// moduleDirective - declares the module of a file:
+// module(Name, Exports)
+// Params: first token of directive
+//         directive
+func (p *parser) moduleDirective(t token, directive Complex) {
+    if p.loader == nil {
+        p.fail(t, "module/2 can only be used in a file which " +
+                  "is loaded into a knowledge base")
+    }
+    if p.loader.started {
+        p.fail(t, "module/2 must be the first clause of a file")
+    }
+    name, ok := directive[1].(Atom)
+    if !ok || string(name) == "" || strings.Contains(string(name), ":") {
+        p.fail(t, fmt.Sprintf("Invalid module name: %v", directive[1]))
+    }
+    if _, ok := directive[2].(LinkedListStruct); !ok {
+        p.fail(t, fmt.Sprintf("Exports must be a list: %v", directive[2]))
+    }
+    exports := []string{}
+    for _, item := range listItems(directive[2]) {
+        key, ok := predicateIndicator(item)
+        if !ok { p.fail(t, fmt.Sprintf("Invalid export: %v", item)) }
+        exports = append(exports, key)
+    }
+    key := p.loader.key(p.file)
+    module := ModuleStruct{ Name: string(name), File: p.file,
+                            Exports: exports, key: key }
+    if err := p.loader.defineModule(module); err != nil {
+        p.fail(t, err.Error())
+    }
+    p.loader.module = string(name)
+    p.loader.fileModules[key] = string(name)
+    line, column := p.lx.position(t.start)
+    p.loader.declared[string(name)] = ParseError{ File: p.file,
+                    Line: line, Column: column, Excerpt: p.lx.lineText(line) }
+} // moduleDirective
+
+// importModule - imports the predicates of a module file into the
+// module of the file being loaded, for use_module/1 and use_module/2.
+// Params: first token of directive
+//         module file
+//         predicates to import, or nil for all exports
+func (p *parser) importModule(t token, fileName string, imports Unifiable) {
+    name, ok := p.loader.fileModules[p.loader.key(fileName)]
+    if !ok { p.fail(t, fmt.Sprintf("Not a module: %v", fileName)) }
+    module, _ := p.loader.tx.KnowledgeBase().Module(name)
+    keys := module.Exports
+    if imports != nil {
+        keys = []string{}
+        for _, item := range listItems(imports) {
+            key, ok := predicateIndicator(item)
+            if !ok { p.fail(t, fmt.Sprintf("Invalid import: %v", item)) }
+            if !module.exports(key) {
+                p.fail(t, fmt.Sprintf("%v is not exported by module %v",
+                                      key, name))
+            }
+            keys = append(keys, key)
+        }
+    }
+    into := p.loader.imports[p.loader.module]
+    if into == nil {
+        into = map[string]string{}
+        p.loader.imports[p.loader.module] = into
+    }
+    for _, key := range keys {
+        if from, ok := into[key]; ok && from != name {
+            p.fail(t, fmt.Sprintf("Import conflict: %v is exported by " +
+                                  "%v and %v", key, from, name))
+        }
+        into[key] = name
+    }
+} // importModule
+
+//----------------------------------------------------------------
+// Resolution of goals
+//----------------------------------------------------------------
+
+// metaArguments - the arguments of built-in predicates which are
+// goals, and the number of arguments which the predicate adds to
+// the goal (phrase adds two lists).
+var metaArguments = map[string]struct{ index, extra int }{
+    "freeze":  { 1, 0 },
+    "when":    { 1, 0 },
+    "time":    { 0, 0 },
+    "profile": { 0, 0 },
+    "phrase":  { 0, 2 },
+}
+
+// resolver - resolves the goals of loaded rules.
+type resolver struct {
+    l        *loader
+    defined  map[string]map[string]bool  // keys defined in each module
+    module   string       // module of the rule being resolved
+    source   ParseError   // location of the rule, for errors
+    errors   ParseErrors
+}
+
+// resolveModules - qualifies the heads of the rules which are in
+// modules, resolves their goals and the initialization goals, and adds
+// the rules to the transaction. (The other rules were resolved as they
+// were loaded. See add() in consult.go.) Calls to private predicates,
+// and exports which are not defined, are errors.
+// Return: errors
+func (l *loader) resolveModules() ParseErrors {
+    rules := l.pending
+    l.pending = nil
+    view := l.tx.KnowledgeBase()
+    r := &resolver{ l: l, defined: map[string]map[string]bool{} }
+    for _, rule := range rules {
+        if r.defined[rule.module] == nil {
+            r.defined[rule.module] = map[string]bool{}
+        }
+        if _, _, ok := splitQualified(view, rule.head.GetFunctor()); !ok {
+            r.defined[rule.module][rule.Key()] = true
+        }
+    }
+    names := make([]string, 0, len(l.declared))
+    for name := range l.declared { names = append(names, name) }
+    sort.Strings(names)
+    for _, name := range names {
+        module, _ := view.Module(name)
+        for _, export := range module.Exports {
+            if r.defined[name][export] { continue }
+            err := l.declared[name]
+            err.Message = fmt.Sprintf("Module %v exports %v, " +
+                                      "which is not defined", name, export)
+            r.errors = append(r.errors, err)
+        }
+    }
+    for i, rule := range rules {
+        if _, _, ok := splitQualified(view, rule.head.GetFunctor()); !ok {
+            head := append(Complex{}, rule.head...)
+            head[0] = qualifiedName(rule.module, head.GetFunctor())
+            rule.head = head
+        }
+        rules[i] = r.rule(rule)
+    }
+    for i, g := range l.goals {
+        r.module, r.source = g.module, g.source
+        l.goals[i].goal = r.goal(g.goal)
+    }
+    l.tx.Add(rules...)
+    l.tx.Add(l.aliases()...)
+    return append(l.errors, r.errors...)
+} // resolveModules
+
+// aliases - makes a rule for each predicate which is imported outside
+// a module, so that a query can call it by its unqualified name.
+// Eg.: check($A1) :- grammar:check($A1).
+// Predicates which are defined outside a module are skipped.
+// Return: rules
+func (l *loader) aliases() []RuleStruct {
+    imports := l.imports[""]
+    keys := make([]string, 0, len(imports))
+    for key := range imports { keys = append(keys, key) }
+    sort.Strings(keys)
+    view := l.tx.KnowledgeBase()
+    rules := []RuleStruct{}
+    for _, key := range keys {
+        if len(view.rules(key)) > 0 { continue }
+        i := strings.LastIndex(key, "/")
+        name := Atom(key[:i])
+        arity, _ := strconv.Atoi(key[i + 1:])
+        head := Complex{ name }
+        for j := 1; j <= arity; j++ {
+            v, _ := LogicVar(fmt.Sprintf("$A%d", j))
+            head = append(head, v)
+        }
+        body := append(Complex{ qualifiedName(imports[key], name) },
+                       head[1:]...)
+        rules = append(rules, Rule(head, body))
+    }
+    return rules
+} // aliases
+
+// rule - resolves the goals of a rule.
+func (r *resolver) rule(rule RuleStruct) RuleStruct {
+    if rule.body == nil { return rule }
+    r.module = rule.module
+    r.source = ParseError{ File: rule.source.File, Line: rule.source.Line,
+                           Column: rule.source.Column }
+    rule.body = r.goal(rule.body)
+    return rule
+}
+
+// name - resolves the name of a predicate which is called from the
+// current module.
+// Params: name
+//         arity
+// Return: qualified name, or the name
+func (r *resolver) name(name Atom, arity int) Atom {
+    view := r.l.tx.KnowledgeBase()
+    if module, local, ok := splitQualified(view, name); ok {
+        key := fmt.Sprintf("%v/%d", local, arity)
+        if module.Name != r.module && !module.exports(key) {
+            err := r.source
+            err.Message = fmt.Sprintf("%v is private to module %v",
+                                      key, module.Name)
+            r.errors = append(r.errors, err)
+        }
+        return name
+    }
+    key := fmt.Sprintf("%v/%d", name, arity)
+    if r.defined[r.module][key] { return qualifiedName(r.module, name) }
+    if from, ok := r.l.imports[r.module][key]; ok {
+        return qualifiedName(from, name)
+    }
+    return name
+} // name
+
+// term - resolves a goal which is a term (an argument of a built-in
+// predicate).
+// Params: term
+//         number of arguments which will be added
+func (r *resolver) term(term Unifiable, extra int) Unifiable {
+    switch t := term.(type) {
+    case Atom:
+        return r.name(t, extra)
+    case Complex:
+        c := append(Complex{}, t...)
+        c[0] = r.name(t.GetFunctor(), len(t) - 1 + extra)
+        return c
+    }
+    return term
+}
+
+// goal - resolves the goals of an expression.
+func (r *resolver) goal(goal Goal) Goal {
+    switch g := goal.(type) {
+    case Complex:
+        return r.term(g, 0).(Complex)
+    case AndOp:
+        return AndOp(r.operands(g))
+    case OrOp:
+        return OrOp(r.operands(g))
+    case NotOp:
+        return NotOp(r.operands(g))
+    }
+    b, rebuild, ok := asBuiltIn(goal)
+    if !ok { return goal }
+    meta, ok := metaArguments[b.Name]
+    if !ok || meta.index >= len(b.Arguments) { return goal }
+    args := append([]Unifiable{}, b.Arguments...)
+    args[meta.index] = r.term(args[meta.index], meta.extra)
+    b.Arguments = args
+    return rebuild(b).(Goal)
+} // goal
+
+// operands - resolves the operands of And, Or or Not.
+func (r *resolver) operands(operands []Goal) []Goal {
+    resolved := make([]Goal, len(operands))
+    for i, operand := range operands { resolved[i] = r.goal(operand) }
+    return resolved
+}
@@ -12,14 +12,25 @@ import (
 type NotSolutionNodeStruct struct {
     SolutionNodeStruct
     operandSolutionNode SolutionNode
+    context *searchContext  // of a depth-limited search (engine.go)
 }
 
 func makeNotSolutionNode(n NotOp, kb KnowledgeBase,
                          parentSolution SubstitutionSet,
                          parentNode SolutionNode) SolutionNode {
 
-    operand := n[0]  // There must be 1 operand.
-    osn := operand.GetSolver(kb, parentSolution, parentNode)
+    // There must be 1 operand. It is wrapped in an And, so that
+    // goals woken up by the operand (see suspension.go) are run.
+    operand := And(n[0])
+
+    // In a depth-limited search, the operand has a context of its own.
+    var ctx *searchContext
+    operandParent := parentNode
+    if outer := findSearchContext(parentNode); outer != nil {
+        ctx = outer.nested()
+        operandParent = ctx
+    }
+    osn := operand.GetSolver(kb, parentSolution, operandParent)
 
     node := NotSolutionNodeStruct{
                 SolutionNodeStruct: SolutionNodeStruct {
@@ -30,6 +41,7 @@ func makeNotSolutionNode(n NotOp, kb KnowledgeBase,
 
                 // There is only one operand.
                 operandSolutionNode: osn,
+                context: ctx,
             }
     return &node
 }
@@ -38,6 +50,9 @@ func makeNotSolutionNode(n NotOp, kb KnowledgeBase,
 // If there is a solution, the function will set the success flag to false.
 // If there is no solution, the function will set the success flag to true.
 // ('Not' means 'not unifiable'.) 
+// If the search for the operand reached the depth limit, a solution
+// may have been missed, so the operand is not known to fail, and the
+// function fails.
 // Returns:  substitution set
 //           success/failure flag
 func (n *NotSolutionNodeStruct) NextSolution() (SubstitutionSet, bool) {
@@ -46,7 +61,7 @@ func (n *NotSolutionNodeStruct) NextSolution() (SubstitutionSet, bool) {
     if n.ParentSolution == nil { return nil, false }
 
     _, found := n.operandSolutionNode.NextSolution()
-    if found {
+    if found || (n.context != nil && n.context.hit) {
         return nil, false
     } else {
         solution := n.ParentSolution
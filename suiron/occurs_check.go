@@ -0,0 +1,145 @@
+package suiron
+
+// Occurs Check - determines whether a variable occurs in a term.
+//
+// Without an occurs check, the unification $X = f($X) succeeds, and
+// creates a cyclic term: f(f(f(f(...)))). This is the default, as in
+// most Prolog implementations, because the check takes time. There are
+// three modes:
+//
+//    OccursCheckFalse  - no occurs check (default)
+//    OccursCheckTrue   - unification fails if it would create a cycle
+//    OccursCheckError  - unification panics if it would create a cycle
+//
+// The mode is set for a query by the substitution set which it starts
+// with. It is kept in slot 0 (see suspension.go), so it applies to every
+// goal of the query, and to no other query:
+//
+//    ss := SubstitutionSet{}.WithOccursCheck(OccursCheckTrue)
+//    solutions, failure := SolveAll(query, kb, ss)
+//
+// The predicate unify_with_occurs_check/2 always does the check. It fails
+// if the unification would create a cycle, whatever the mode. Cycles
+// which were created before it was called are tolerated.
+//
+// Cyclic terms are not expanded by ReplaceVariables(). The variable
+// which creates the cycle is left in place, so that a cyclic term
+// can be printed. For example, after $X = f($X), $X is shown as
+// f($X_1). The variables being expanded are kept in a set, so cycles
+// are detected in one pass over the term.
+//
+// Cleve Lendon
+
+import (
+    "fmt"
+)
+
+// OccursCheckMode - what unification does when a variable occurs
+// in the term which it would be bound to.
+type OccursCheckMode int
+
+const (
+    OccursCheckFalse OccursCheckMode = iota
+    OccursCheckTrue
+    OccursCheckError
+)
+
+// WithOccursCheck - makes a copy of the substitution set which sets
+// the occurs check mode of a query.
+// Param:  mode (OccursCheckFalse, OccursCheckTrue or OccursCheckError)
+// Return: substitution set
+func (ss SubstitutionSet) WithOccursCheck(mode OccursCheckMode) SubstitutionSet {
+    if mode < OccursCheckFalse || mode > OccursCheckError { return ss }
+    q := ss.state()
+    q.occursCheck = mode
+    return ss.withState(q)
+}
+
+// OccursCheck - gets the occurs check mode of the substitution set.
+func (ss SubstitutionSet) OccursCheck() OccursCheckMode {
+    return ss.state().occursCheck
+}
+
+// ParseOccursCheck - converts the name of an occurs check mode
+// (false, true or error) to a mode.
+// Param:  name
+// Return: mode
+//         error
+func ParseOccursCheck(name string) (OccursCheckMode, error) {
+    switch name {
+    case "false": return OccursCheckFalse, nil
+    case "true":  return OccursCheckTrue, nil
+    case "error": return OccursCheckError, nil
+    }
+    return OccursCheckFalse, fmt.Errorf("Invalid occurs check mode: %v", name)
+}
+
+// occursCheck - checks whether a variable can be bound to a term,
+// according to the occurs check mode.
+// Params: variable
+//         term
+//         substitution set
+// Return: true if the binding is allowed
+func occursCheck(v VariableStruct, term Unifiable, ss SubstitutionSet) bool {
+    if len(ss) == 0 || ss[0] == nil { return true }
+    mode := ss.OccursCheck()
+    if mode == OccursCheckFalse { return true }
+    if !occursIn(v, term, ss) { return true }
+    if mode == OccursCheckError {
+        panic(fmt.Sprintf("Occurs check - %v occurs in %v", v, term))
+    }
+    return false
+}
+
+// occursIn - determines whether a variable occurs in a term.
+// Cycles which are already in the substitution set are tolerated.
+// Params: variable
+//         term
+//         substitution set
+// Return: true if the variable occurs in the term
+func occursIn(v VariableStruct, term Unifiable, ss SubstitutionSet) bool {
+    visiting := map[int]bool{}  // variables being expanded
+    checked  := map[int]bool{}  // variables already checked
+    var walk func(t Unifiable) bool
+    walk = func(t Unifiable) bool {
+        switch t.TermType() {
+        case VARIABLE:
+            w := t.(VariableStruct)
+            if w.id == v.id { return true }
+            if visiting[w.id] || checked[w.id] || !ss.IsBound(w) {
+                return false
+            }
+            visiting[w.id] = true
+            found := walk(*ss[w.id])
+            delete(visiting, w.id)
+            checked[w.id] = true
+            return found
+        case COMPLEX:
+            for _, arg := range t.(Complex)[1:] {
+                if walk(arg) { return true }
+            }
+        case LINKEDLIST:
+            list := t.(LinkedListStruct)
+            for ptr := &list; ptr != nil && ptr.term != nil; ptr = ptr.next {
+                if walk(ptr.term) { return true }
+            }
+        }
+        return false
+    }
+    return walk(term)
+} // occursIn
+
+// unifyWithOccursCheck - unifies two terms, and fails if a variable
+// would be bound to a term which contains it. The mode of the query is
+// not changed.
+// Params: terms
+//         substitution set
+// Return: new substitution set
+//         success flag
+func unifyWithOccursCheck(term1, term2 Unifiable,
+                          ss SubstitutionSet) (SubstitutionSet, bool) {
+    mode := ss.OccursCheck()
+    newSS, ok := term1.Unify(term2, ss.WithOccursCheck(OccursCheckTrue))
+    if !ok { return nil, false }
+    return newSS.WithOccursCheck(mode), true
+} // unifyWithOccursCheck
@@ -0,0 +1,470 @@
+package suiron
+
+// Op - the table of infix operators, and the built-in predicate op/3.
+//
+// Each operator has a priority (1 to 1200) and a type. A lower priority
+// binds more tightly. The type tells how the operator associates:
+//
+//    xfx - not associative:   a = b = c is an error
+//    xfy - right associative: a implies b implies c = a implies (b implies c)
+//    yfx - left associative:  a - b - c = (a - b) - c
+//
+// Only infix operators are supported. The default operators are:
+//
+//    1200 xfx  :- -->
+//    1100 xfy  ;
+//    1000 xfy  ,
+//     700 xfx  = == < <= > >= in ins #= #\= #< #> #=< #>=
+//
+// The neck (:-), the arrow of grammar rules (-->), semicolon and comma
+// are punctuation, and cannot be changed. The operators at 700 make
+// built-in predicates (Unify, LessThan, FDEqual, etc.), and are
+// recognized only between goals: print(I am in love) has one atom
+// as its argument.
+// They are also recognized in parentheses, and next to an operand in
+// parentheses, where they make complex terms:
+//
+//    t($Y) :- $Y = (a = b).    <- $Y is bound to =(a, b)
+//    t(a = (b = c)).           <- t(=(a, =(b, c)))
+//
+// Operators defined by op/3 are recognized everywhere: in goals, in
+// heads of rules and in arguments. An operation is a complex term:
+//
+//    :- op(700, xfx, isa).
+//    :- op(800, xfy, implies).
+//    mammal isa animal.
+//    rule(a implies b implies c).   <- rule(implies(a, implies(b, c)))
+//
+// Operators must be preceded and followed by white space. Complex terms
+// whose functors are operators are written in operator form, with the
+// parentheses which are needed to read them back:
+//
+//    rule((a implies b) implies c).
+//    t((a = (b = c))).
+//
+// Goals are written in the same way: t :- (a; b), c. A goal may begin
+// with an operand in parentheses: g :- (x implies y) implies z.
+//
+// In parentheses, the punctuation :- --> ; and the comma also make
+// complex terms, which are written back in parentheses:
+//
+//    t($X) :- $X = (a :- b, c).    <- $X is bound to :-(a, ','(b, c))
+//    t($X) :- freeze($X, ($Y = 1, $Z = 2)).
+//
+// There is a global table of operators, which holds the default
+// operators and those defined in Go code with Op(). A parser starts
+// with the global table. The directive :- op(Priority, Type, Name)
+// defines an operator for the rest of the text, and for the files
+// which it consults. When a text is loaded into a knowledge base, the
+// operator is recorded in the knowledge base, so that texts loaded into
+// it later see it, and other knowledge bases do not. If the load fails,
+// the operator is discarded with its clauses. (See transaction.go.)
+// op/3 can also be called from a rule, to define an operator in the
+// knowledge base of the query. A priority of 0 removes an operator.
+// The name may be a list of names.
+//
+// Terms are written in operator form only for the operators of the
+// global table. An operation of an operator which is defined in a
+// knowledge base is written in standard form, eg. isa(mammal, animal),
+// which can be read back anywhere. CurrentOp() and Operators() list
+// the global table, which is safe for concurrent use. ResetOperators()
+// restores the defaults.
+//
+// Cleve Lendon
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "sync"
+    "unicode"
+)
+
+// OperatorStruct - an infix operator: its name, priority and type.
+type OperatorStruct struct {
+    Name      string
+    Priority  int     // 1 to 1200
+    Type      string  // xfx, xfy or yfx
+}
+
+// String - formats an operator as op/3, eg. op(700, xfx, isa)
+func (op OperatorStruct) String() string {
+    return fmt.Sprintf("op(%v, %v, %v)", op.Priority, op.Type, op.Name)
+}
+
+// operatorTable - infix operators, by name.
+type operatorTable map[string]OperatorStruct
+
+// operators - the global table: the default operators, and those
+// which are defined by Op().
+var operators = struct {
+    mutex  sync.RWMutex
+    table  operatorTable
+}{ table: defaultOperators() }
+
+// punctuationOperators - operators which are punctuation. They are
+// listed in the table, but cannot be changed.
+var punctuationOperators = map[string]bool{ ":-": true, "-->": true,
+                                            ";": true, ",": true, "|": true }
+
+// goalOperators - the operators which make built-in predicates.
+// (See makeInfixGoal() in parser.go.) They are recognized only
+// between goals.
+var goalOperators = map[string]bool{
+    "=": true, "==": true, "<": true, "<=": true, ">": true, ">=": true,
+    "in": true, "ins": true, "#=": true, "#\\=": true, "#<": true,
+    "#>": true, "#=<": true, "#>=": true,
+}
+
+// defaultOperators - makes the table of default operators.
+func defaultOperators() operatorTable {
+    ops := operatorTable{
+        ":-": { ":-", 1200, "xfx" },
+        "-->": { "-->", 1200, "xfx" },
+        ";":  { ";", 1100, "xfy" },
+        ",":  { ",", 1000, "xfy" },
+    }
+    for name := range goalOperators {
+        ops[name] = OperatorStruct{ name, 700, "xfx" }
+    }
+    return ops
+}
+
+// makeOperator - checks the priority, type and name of an operator.
+// Params: priority (0 to 1200)
+//         type: xfx, xfy or yfx
+//         name of operator
+// Return: operator
+//         error or nil
+func makeOperator(priority int, opType string,
+                  name string) (OperatorStruct, error) {
+    if priority < 0 || priority > 1200 {
+        return OperatorStruct{},
+               fmt.Errorf("Op - Priority must be between 0 and 1200: %v",
+                          priority)
+    }
+    if opType != "xfx" && opType != "xfy" && opType != "yfx" {
+        return OperatorStruct{},
+               fmt.Errorf("Op - Type must be xfx, xfy or yfx: %v", opType)
+    }
+    if err := checkOperatorName(name); err != nil {
+        return OperatorStruct{}, err
+    }
+    return OperatorStruct{ name, priority, opType }, nil
+} // makeOperator
+
+// define - adds an operator to the table, or changes it.
+// An operator with a priority of 0 is removed.
+func (t operatorTable) define(op OperatorStruct) {
+    if op.Priority == 0 {
+        delete(t, op.Name)
+    } else {
+        t[op.Name] = op
+    }
+}
+
+// Op - defines an infix operator in the global table, or changes its
+// priority or type. A priority of 0 removes the operator. The global
+// table is used by all parsers and knowledge bases.
+// Params: priority (0 to 1200)
+//         type: xfx, xfy or yfx
+//         name of operator
+// Return: error or nil
+func Op(priority int, opType string, name string) error {
+    op, err := makeOperator(priority, opType, name)
+    if err != nil { return err }
+    operators.mutex.Lock()
+    operators.table.define(op)
+    operators.mutex.Unlock()
+    return nil
+} // Op
+
+// checkOperatorName - checks that a name can be an operator.
+// The name cannot be punctuation, contain white space or brackets,
+// or begin a comment.
+func checkOperatorName(name string) error {
+    if name == "" { return fmt.Errorf("Op - Name is empty.") }
+    if punctuationOperators[name] {
+        return fmt.Errorf("Op - Cannot change operator: %v", name)
+    }
+    if strings.ContainsAny(name, "()[],;|\"\\") && !goalOperators[name] ||
+       strings.IndexFunc(name, unicode.IsSpace) >= 0 {
+        return fmt.Errorf("Op - Invalid operator name: %v", name)
+    }
+    lx := makeLexer(name)
+    if lx.commentAt(0) || lx.endAt(0) || strings.HasPrefix(name, ":-") {
+        return fmt.Errorf("Op - Invalid operator name: %v", name)
+    }
+    return nil
+} // checkOperatorName
+
+// CurrentOp - returns the operator of the global table which has
+// the given name.
+// Params: name
+// Return: operator
+//         success/failure flag
+func CurrentOp(name string) (OperatorStruct, bool) {
+    operators.mutex.RLock()
+    defer operators.mutex.RUnlock()
+    op, ok := operators.table[name]
+    return op, ok
+}
+
+// Operators - returns all operators of the global table, by priority
+// (highest first), then by name.
+func Operators() []OperatorStruct {
+    table := globalOperators()
+    list := make([]OperatorStruct, 0, len(table))
+    for _, op := range table { list = append(list, op) }
+    sort.Slice(list, func(i, j int) bool {
+        if list[i].Priority != list[j].Priority {
+            return list[i].Priority > list[j].Priority
+        }
+        return list[i].Name < list[j].Name
+    })
+    return list
+} // Operators
+
+// ResetOperators - restores the default operators of the global table.
+func ResetOperators() {
+    operators.mutex.Lock()
+    operators.table = defaultOperators()
+    operators.mutex.Unlock()
+}
+
+// globalOperators - returns a copy of the global table, for a parser.
+func globalOperators() operatorTable {
+    operators.mutex.RLock()
+    defer operators.mutex.RUnlock()
+    table := make(operatorTable, len(operators.table))
+    for name, op := range operators.table { table[name] = op }
+    return table
+}
+
+// opFunctor - the functor of the facts which record the operators
+// of a knowledge base: (op)(Priority, Type, Name). It cannot be the
+// functor of a predicate.
+const opFunctor = "(op)"
+const opKey = opFunctor + "/3"
+
+// opFact - makes the fact which records an operator.
+func opFact(op OperatorStruct) RuleStruct {
+    return Fact(Complex{ Atom(opFunctor), Integer(op.Priority),
+                         Atom(op.Type), Atom(op.Name) })
+}
+
+// operators - returns the operators for a text which is loaded into
+// the knowledge base: those of the global table, changed by the
+// operators which the knowledge base records, in order.
+func (kb KnowledgeBase) operators() operatorTable {
+    table := globalOperators()
+    for _, fact := range kb.rules(opKey) {
+        head := fact.GetHead()
+        table.define(OperatorStruct{ head[3].String(),
+                                     int(head[1].(Integer)),
+                                     head[2].String() })
+    }
+    return table
+}
+
+// infixNames - returns the names of the operators which the lexer
+// recognizes in goals, and in terms. Longer names come first, so that
+// #=< is found before #=.
+// Param:  operator table
+// Return: operators in goals
+//         operators in terms
+func infixNames(table operatorTable) ([]string, []string) {
+    goal := []string{}
+    term := []string{}
+    for name := range table {
+        if punctuationOperators[name] { continue }
+        goal = append(goal, name)
+        if !goalOperators[name] { term = append(term, name) }
+    }
+    longestFirst := func(names []string) {
+        sort.Slice(names, func(i, j int) bool {
+            li, lj := len([]rune(names[i])), len([]rune(names[j]))
+            if li != lj { return li > lj }
+            return names[i] < names[j]
+        })
+    }
+    longestFirst(goal)
+    longestFirst(term)
+    return goal, term
+} // infixNames
+
+// termOperator - returns the operator of a complex term which is
+// written in operator form: a term with two arguments, whose functor
+// is an operator of the global table.
+func termOperator(c Complex) (OperatorStruct, bool) {
+    if len(c) != 3 { return OperatorStruct{}, false }
+    functor, ok := c[0].(Atom)
+    if !ok { return OperatorStruct{}, false }
+    return CurrentOp(string(functor))
+}
+
+// argumentPriorities - returns the highest priorities of the left
+// and right arguments of an operator.
+func argumentPriorities(op OperatorStruct) (int, int) {
+    left, right := op.Priority - 1, op.Priority - 1
+    if op.Type == "yfx" { left = op.Priority }
+    if op.Type == "xfy" { right = op.Priority }
+    return left, right
+}
+
+// formatOperand - formats an argument of an operator or of a complex
+// term. If its priority is higher than allowed, it is put between
+// parentheses. An operation of a built-in operator (=, <, etc.) or of
+// punctuation (:-, etc.) is always put between parentheses, because
+// outside of goals, the operator is only recognized in parentheses,
+// or next to them.
+// Params: term
+//         highest priority allowed
+// Return: string representation
+func formatOperand(term Unifiable, max int) string {
+    if c, ok := term.(Complex); ok {
+        if op, ok := termOperator(c); ok && (op.Priority > max ||
+           goalOperators[op.Name] || punctuationOperators[op.Name]) {
+            return "(" + c.String() + ")"
+        }
+    }
+    return term.String()
+}
+
+// formatByPriority - formats an operand which is in parentheses,
+// where all operators are recognized. It is put between parentheses
+// only if its priority is higher than allowed.
+func formatByPriority(term Unifiable, max int) string {
+    if c, ok := term.(Complex); ok {
+        if op, ok := termOperator(c); ok && op.Priority > max {
+            return "(" + c.String() + ")"
+        }
+    }
+    return term.String()
+}
+
+// formatOperation - formats a complex term in operator form.
+// Eg.: a implies (b implies c) -> a implies b implies c
+// An operation of punctuation is always in parentheses, so its
+// operands are formatted by priority: (a :- b, c)
+func formatOperation(c Complex, op OperatorStruct) string {
+    left, right := argumentPriorities(op)
+    if punctuationOperators[op.Name] {
+        separator := " " + op.Name + " "
+        if op.Name == "," { separator = ", " }
+        return formatByPriority(c[1], left) + separator +
+               formatByPriority(c[2], right)
+    }
+    return formatOperand(c[1], left) + " " + op.Name + " " +
+           formatOperand(c[2], right)
+}
+
+//----------------------------------------------------------------
+// The built-in predicate op/3.
+//----------------------------------------------------------------
+
+type OpStruct BuiltInPredicateStruct
+
+// OpPredicate - creates the predicate op(Priority, Type, Name).
+func OpPredicate(arguments ...Unifiable) OpStruct {
+    if len(arguments) != 3 {
+        panic("OpPredicate - This predicate requires 3 arguments.")
+    }
+    return OpStruct{ Name: "op", Arguments: arguments }
+}
+
+// GetSolver - gets a solution node for this predicate.
+// This function satisfies the Goal interface.
+func (s OpStruct) GetSolver(kb KnowledgeBase,
+                            parentSolution SubstitutionSet,
+                            parentNode SolutionNode) SolutionNode {
+    node := OpSolutionNodeStruct{
+                SolutionNodeStruct: MakeSolutionNode(s, kb,
+                                        parentSolution, parentNode),
+                moreSolutions: true,
+            }
+    return &node
+}
+
+// RecreateVariables - Refer to comments in expression.go.
+func (s OpStruct) RecreateVariables(vars VarMap) Expression {
+    bip := BuiltInPredicateStruct(s).RecreateVariables(vars)
+    return Expression(OpStruct(*bip))
+}
+
+// ReplaceVariables - Refer to comments in expression.go.
+func (s OpStruct) ReplaceVariables(ss SubstitutionSet) Expression {
+    return BuiltInPredicateStruct(s).ReplaceVariables(ss)
+}
+
+// String - creates a string representation.
+// Returns: op(700, xfx, isa)
+func (s OpStruct) String() string {
+    return BuiltInPredicateStruct(s).String()
+}
+
+// operatorsOf - gets the operators of op/3. The name may be a list
+// of names.
+// Params: arguments of op/3
+//         substitution set
+// Return: operators
+//         error or nil
+func operatorsOf(arguments []Unifiable,
+                 ss SubstitutionSet) ([]OperatorStruct, error) {
+    priority, ok := ss.GetGroundTerm(arguments[0])
+    if _, isInteger := priority.(Integer); !ok || !isInteger {
+        return nil, fmt.Errorf("Op - Priority must be an integer: %v",
+                               arguments[0])
+    }
+    opType, ok := ss.CastAtom(arguments[1])
+    if !ok {
+        return nil, fmt.Errorf("Op - Type must be an atom: %v", arguments[1])
+    }
+    names := []Unifiable{ arguments[2] }
+    if list, ok := ss.CastLinkedList(arguments[2]); ok {
+        names = []Unifiable{}
+        for ptr := &list; ptr != nil && ptr.term != nil; ptr = ptr.next {
+            names = append(names, ptr.term)
+        }
+    }
+    ops := []OperatorStruct{}
+    for _, n := range names {
+        name, ok := ss.CastAtom(n)
+        if !ok { return nil, fmt.Errorf("Op - Name must be an atom: %v", n) }
+        op, err := makeOperator(int(priority.(Integer)), string(opType),
+                                string(name))
+        if err != nil { return nil, err }
+        ops = append(ops, op)
+    }
+    return ops, nil
+} // operatorsOf
+
+type OpSolutionNodeStruct struct {
+    SolutionNodeStruct
+    moreSolutions bool
+}
+
+// NextSolution - defines the operators in the knowledge base. If the
+// arguments are invalid, the predicate fails.
+// This function satisfies the SolutionNode interface.
+func (sn *OpSolutionNodeStruct) NextSolution() (SubstitutionSet, bool) {
+    if sn.NoBackTracking || !sn.moreSolutions { return nil, false }
+    sn.moreSolutions = false  // Only one solution.
+    goal := sn.Goal.(OpStruct)
+    ops, err := operatorsOf(goal.Arguments, sn.ParentSolution)
+    if err != nil { return nil, false }
+    for _, op := range ops { sn.KnowledgeBase.Add(opFact(op)) }
+    return sn.ParentSolution, true
+}
+
+// SetNoBackTracking - set the NoBackTracking flag,
+// which is used to implement Cuts.
+// This function satisfies the SolutionNode interface.
+func (sn *OpSolutionNodeStruct) SetNoBackTracking() {
+    sn.NoBackTracking = true
+}
+
+// GetParentNode
+func (sn *OpSolutionNodeStruct) GetParentNode() SolutionNode {
+    return sn.ParentNode
+}
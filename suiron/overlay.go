@@ -0,0 +1,242 @@
+package suiron
+
+// Overlay - a knowledge base which is layered on top of another one,
+// its parent. An overlay holds facts and rules for one session, such
+// as the words of the sentence being parsed, without copying or
+// changing the parent:
+//
+//    session := base.Overlay(OverlayFirst)
+//    defer session.Drop()
+//    session.Add(facts...)                   // only in the session
+//    session.Retract(word(the, $_))          // hides a clause of base
+//    solution, failure := Solve(query, session, SubstitutionSet{})
+//
+// The overlay is a KnowledgeBase, which holds only the session's own
+// clauses. When the engine looks up the clauses of a predicate, it gets
+// the clauses of the parent which have not been hidden, and those of
+// the overlay, in the order which was chosen: OverlayFirst puts the
+// clauses of the overlay before those of the parent, OverlayLast puts
+// them after.
+//
+// Retract() removes the first visible clause which unifies with its
+// argument. A clause of the overlay is removed. A clause of the parent
+// is hidden, in the overlay only.
+//
+// The overlay is linked to its parent by an entry of its own map, so an
+// overlay is freed by the garbage collector, like any map, when it is
+// no longer used. Drop() discards the clauses of an overlay early.
+// Looking up the clauses of a predicate does not lock the overlay,
+// except to fill its cache of visible clauses.
+//
+// A hidden clause is identified by its ID (see Add() in knowledgebase.go),
+// not by its position, so the parent can be changed between queries of
+// the overlay. The visible clauses of a predicate are made again when
+// the parent's list of clauses has changed. The parent must not be
+// changed while a query runs on the overlay. An overlay can be the
+// parent of another overlay. Indexing the map of an overlay,
+// eg. session["word/2"], gives only its own clauses. Flatten() gives
+// all visible clauses.
+//
+// Cleve Lendon
+
+import (
+    "sync"
+)
+
+// OverlayOrder - the order of the clauses of an overlay and its parent.
+type OverlayOrder int
+
+const (
+    OverlayFirst OverlayOrder = iota  // overlay's clauses come first
+    OverlayLast                       // parent's clauses come first
+)
+
+// overlayStruct - the parent and the hidden clauses of an overlay.
+type overlayStruct struct {
+    parent   KnowledgeBase
+    order    OverlayOrder
+    mutex    sync.Mutex                // for changes to hidden clauses
+    hidden   map[string]map[uint64]bool  // hidden clauses of the parent,
+                                         // by key and ID of clause
+    visible  sync.Map                    // visibleRules, by key (cache)
+    tx       *Transaction                // transaction of the view, or nil
+}
+
+// visibleRules - the visible clauses of a predicate, and the list of
+// the parent's clauses from which they were made. If the parent's list
+// changes, they are made again.
+type visibleRules struct {
+    parent  []RuleStruct
+    rules   []RuleStruct
+}
+
+// overlayKey - the key of the entry which links an overlay to its
+// parent. It cannot be the key of a predicate, eg. word/2.
+const overlayKey = "(overlay)"
+
+// overlayLink - the body of the rule under overlayKey. It holds the
+// state of the overlay, and is never solved.
+type overlayLink struct {
+    o  *overlayStruct
+}
+
+// Overlay - makes an empty knowledge base which is layered on top
+// of this one.
+// Param:  order of clauses: OverlayFirst or OverlayLast
+// Return: overlay
+func (kb KnowledgeBase) Overlay(order OverlayOrder) KnowledgeBase {
+    o := &overlayStruct{ parent: kb, order: order,
+                         hidden: map[string]map[uint64]bool{} }
+    link := RuleStruct{ head: Complex{ Atom(overlayKey) },
+                        body: overlayLink{ o } }
+    return KnowledgeBase{ overlayKey: []RuleStruct{ link } }
+} // Overlay
+
+// overlay - returns the state of an overlay, or nil if the knowledge
+// base is not an overlay.
+func (kb KnowledgeBase) overlay() *overlayStruct {
+    if entry := kb[overlayKey]; len(entry) > 0 {
+        return entry[0].body.(overlayLink).o
+    }
+    return nil
+}
+
+// Drop - discards the clauses of an overlay, and its link to its parent.
+// The parent is not affected. For an ordinary knowledge base, Drop does
+// nothing.
+func (kb KnowledgeBase) Drop() {
+    if kb.overlay() == nil { return }
+    for key := range kb { delete(kb, key) }
+}
+
+// GetSolver - an overlay link is not a goal. This function satisfies
+// the Goal interface.
+func (l overlayLink) GetSolver(kb KnowledgeBase,
+                               parentSolution SubstitutionSet,
+                               parentNode SolutionNode) SolutionNode {
+    panic("overlayLink, GetSolver - An overlay link cannot be solved.")
+}
+
+// RecreateVariables - Refer to comments in expression.go.
+func (l overlayLink) RecreateVariables(vars VarMap) Expression { return l }
+
+// ReplaceVariables - Refer to comments in expression.go.
+func (l overlayLink) ReplaceVariables(ss SubstitutionSet) Expression { return l }
+
+// String - returns the key of the link.
+func (l overlayLink) String() string { return overlayKey }
+
+// rules - returns the visible clauses of a predicate.
+// Params: overlay knowledge base
+//         key, eg. word/2
+// Return: list of clauses
+func (o *overlayStruct) rules(kb KnowledgeBase, key string) []RuleStruct {
+    parent := o.parent.rules(key)
+    if v, ok := o.visible.Load(key); ok &&
+       sameList(v.(visibleRules).parent, parent) {
+        return v.(visibleRules).rules
+    }
+    o.mutex.Lock()
+    defer o.mutex.Unlock()
+    var rules []RuleStruct
+    if len(kb[key]) == 0 && len(o.hidden[key]) == 0 {
+        rules = parent
+    } else {
+        o.each(kb, key, parent, func(rule RuleStruct, local bool, i int) bool {
+            rules = append(rules, rule)
+            return false
+        })
+        // The capacity is limited, so that appends make new lists.
+        rules = rules[:len(rules):len(rules)]
+    }
+    o.visible.Store(key, visibleRules{ parent, rules })
+    return rules
+} // rules
+
+// sameList - returns true if two lists of clauses are the same list.
+// Lists of clauses are never changed in place: clauses are appended,
+// or the list is copied. (See Retract() in knowledgebase.go.)
+func sameList(a, b []RuleStruct) bool {
+    return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
+
+// each - calls a function for each visible clause of a predicate,
+// in order, until it returns true.
+// Params: overlay knowledge base
+//         key
+//         clauses of the parent
+//         function of clause, true if the clause is in the overlay,
+//         and index of the clause in the overlay's or parent's list
+func (o *overlayStruct) each(kb KnowledgeBase, key string, rules []RuleStruct,
+                             f func(RuleStruct, bool, int) bool) {
+    local := func() bool {
+        for i, rule := range kb[key] {
+            if f(rule, true, i) { return true }
+        }
+        return false
+    }
+    parent := func() bool {
+        for i, rule := range rules {
+            if o.hidden[key][rule.id] { continue }
+            if f(rule, false, i) { return true }
+        }
+        return false
+    }
+    if o.order == OverlayFirst {
+        if !local() { parent() }
+    } else {
+        if !parent() { local() }
+    }
+} // each
+
+// changed - forgets the visible clauses of a predicate, after
+// a clause has been added or retracted.
+func (o *overlayStruct) changed(key string) {
+    o.visible.Delete(key)
+}
+
+// retract - removes the first visible clause which unifies with
+// the head. A clause of the parent is hidden.
+// Params: overlay knowledge base
+//         head
+// Return: true if a clause was removed or hidden
+func (o *overlayStruct) retract(kb KnowledgeBase, head Complex) bool {
+    key := head.Key()
+    parent := o.parent.rules(key)
+    o.mutex.Lock()
+    found, local, index := false, false, 0
+    var id uint64
+    o.each(kb, key, parent, func(rule RuleStruct, l bool, i int) bool {
+        _, ok := fetchRule(rule).GetHead().Unify(head, SubstitutionSet{})
+        if ok { found, local, index, id = true, l, i, rule.id }
+        return ok
+    })
+    if found && !local {
+        if o.hidden[key] == nil { o.hidden[key] = map[uint64]bool{} }
+        o.hidden[key][id] = true
+    }
+    o.mutex.Unlock()
+    if !found { return false }
+    if local { kb.removeRule(key, index) }
+    o.changed(key)
+    invalidateCaches(kb, key)
+    return true
+} // retract
+
+// Flatten - returns an ordinary knowledge base which has the visible
+// clauses of an overlay. The lists of clauses are shared, not copied.
+// An ordinary knowledge base is returned as it is.
+func (kb KnowledgeBase) Flatten() KnowledgeBase {
+    o := kb.overlay()
+    if o == nil { return kb }
+    parent := o.parent.Flatten()
+    flat := make(KnowledgeBase, len(parent))
+    for key := range parent {
+        if rules := kb.rules(key); len(rules) > 0 { flat[key] = rules }
+    }
+    for key := range kb {
+        if key == overlayKey { continue }
+        if rules := kb.rules(key); len(rules) > 0 { flat[key] = rules }
+    }
+    return flat
+} // Flatten
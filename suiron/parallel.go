@@ -0,0 +1,174 @@
+package suiron
+
+// Parallel - an OR-parallel version of SolveAll.
+//
+// The clauses which match a query are alternatives (branches of the
+// search tree). SolveAllParallel() solves these branches concurrently,
+// with a pool of worker goroutines. Each branch has its own substitution
+// set, so the workers share nothing but the knowledge base, which is
+// only read.
+//
+//    query, _ := ParseQuery("grandfather($X, $Y)")
+//    solutions, failure := SolveAllParallel(query, kb, 4, OrderedResults)
+//
+// A cut in the body of a clause prunes the clauses which follow it,
+// as in a sequential search. When a branch executes its cut, the
+// branches after it are cancelled, and their solutions are discarded.
+//
+// By default, solutions are returned in the order in which the branches
+// finish. With the option OrderedResults, they are returned in the order
+// which SolveAll() would give.
+//
+// While a parallel search is running, the ID numbers of logic variables
+// are not reset. (See restoreVariableId() in variable.go.)
+//
+// Cleve Lendon
+
+import (
+    "runtime"
+    "sync"
+    "sync/atomic"
+    "fmt"
+)
+
+// ParallelOption - options for SolveAllParallel().
+type ParallelOption int
+
+const (
+    // OrderedResults - return solutions in the same order as SolveAll().
+    OrderedResults ParallelOption = iota
+)
+
+// SolveAllParallel - finds all solutions for the given query, by
+// solving the clauses which match the query concurrently.
+// Failure is indicated as for SolveAll():
+//    "" (success)
+//    "No" (no solution)
+//    "Other reason"
+// Params:  query
+//          knowledge base
+//          number of worker goroutines (0 = number of CPUs)
+//          options (OrderedResults)
+// Returns: solutions
+//          reason for failure
+func SolveAllParallel(query Complex, kb KnowledgeBase, workers int,
+                      options ...ParallelOption) (solutions []Complex, failure string) {
+
+    defer func() {  // Catch panics.
+        if r := recover(); r != nil {
+            failure = fmt.Sprintf("%v", r)
+        }
+    }()
+
+    ordered := false
+    for _, option := range options {
+        if option == OrderedResults { ordered = true }
+    }
+    if workers < 1 { workers = runtime.NumCPU() }
+
+    atomic.AddInt32(&parallelSearches, 1)
+    defer atomic.AddInt32(&parallelSearches, -1)
+    reserveVariableIds(maxVariableId(query))
+
+    timer := MakeTimer()  // For execution time-out.
+    stop  := &stopFlag{}  // Stops all branches on time-out.
+
+    rules := kb.rules(query.Key())
+    numBranches := len(rules)
+
+    results   := make([][]Complex, numBranches)  // solutions of each branch
+    cancelled := make([]int32, numBranches)      // cancel flags
+    finished  := []int{}                          // branches in order of completion
+    cutAt     := numBranches                      // first branch which cut
+    var panicMessage string
+    var mutex sync.Mutex
+
+    // cut - cancels the branches after the given branch.
+    cut := func(branch int) {
+        mutex.Lock()
+        defer mutex.Unlock()
+        if branch >= cutAt { return }
+        cutAt = branch
+        for i := branch + 1; i < numBranches; i++ {
+            atomic.StoreInt32(&cancelled[i], 1)
+        }
+    }
+
+    // solveBranch - finds all solutions of one clause.
+    solveBranch := func(branch int) {
+        defer func() {
+            if r := recover(); r != nil {
+                mutex.Lock()
+                if len(panicMessage) == 0 {
+                    panicMessage = fmt.Sprintf("%v", r)
+                }
+                mutex.Unlock()
+                for i := range cancelled { atomic.StoreInt32(&cancelled[i], 1) }
+            }
+        }()
+        if atomic.LoadInt32(&cancelled[branch]) != 0 { return }
+        rule := fetchRule(rules[branch])
+        ss, ok := rule.GetHead().Unify(query, SubstitutionSet{})
+        if !ok { return }
+        node := makeClauseBodySolutionNode(rule.GetBody(), kb, ss,
+                    func() { cut(branch) }, &cancelled[branch])
+        node.stop = stop
+        branchSolutions := []Complex{}
+        for {
+            newSS, found := node.NextSolution()
+            if !found { break }
+            result := query.ReplaceVariables(newSS)
+            branchSolutions = append(branchSolutions, result.(Complex))
+        }
+        mutex.Lock()
+        results[branch] = branchSolutions
+        finished = append(finished, branch)
+        mutex.Unlock()
+    } // solveBranch
+
+    branches := make(chan int, numBranches)
+    for i := 0; i < numBranches; i++ { branches <- i }
+    close(branches)
+
+    var wg sync.WaitGroup
+    for w := 0; w < workers && w < numBranches; w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for branch := range branches { solveBranch(branch) }
+        }()
+    }
+
+    done := make(chan bool, 1)
+    go func() {
+        wg.Wait()
+        done <- true
+    }()
+
+    select {
+    case <-timer.C:
+        stop.set()  // Stop searching for solutions.
+        return nil, "Time out."
+    case <-done:
+        timer.Stop()
+    }
+
+    if len(panicMessage) > 0 { return nil, panicMessage }
+
+    // Solutions of branches after a cut are discarded.
+    if ordered {
+        for i := 0; i < numBranches && i <= cutAt; i++ {
+            solutions = append(solutions, results[i]...)
+        }
+    } else {
+        for _, branch := range finished {
+            if branch <= cutAt {
+                solutions = append(solutions, results[branch]...)
+            }
+        }
+    }
+
+    if len(solutions) == 0 { return solutions, "No" }
+    return solutions, ""
+
+} // SolveAllParallel
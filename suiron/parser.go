@@ -0,0 +1,889 @@
+package suiron
+
+// Parser - a recursive-descent parser for Suiron facts, rules, goals
+// and terms. The grammar is:
+//
+//    program     = { clause | directive }
+//    clause      = head [ ":-" body ] "."
+//                | head [ "," list ] "-->" grammar body "."
+//    directive   = ":-" body "."
+//    head        = expression
+//    body        = conjunction { ";" conjunction }
+//    conjunction = goal { "," goal }
+//    goal        = "(" body ")"
+//                | "not" "(" body ")"
+//                | "time" "(" term ")"
+//                | expression
+//    expression  = term { infix term }
+//    term        = text [ arguments ] | quoted | list | "(" expression ")"
+//    arguments   = "(" [ expression { "," expression } ] ")"
+//    list        = "[" [ expression { "," expression } [ "|" term ] ] "]"
+//
+// Text is classified as a variable ($X), an anonymous variable ($_),
+// an integer (-7), a float (3.14), or an atom. Escaped text (\$X) and
+// quoted text ("3.14") are always atoms. The lexer (lexer.go) explains
+// where text begins and ends.
+//
+// A complex term whose functor is join, add, subtract, multiply or
+// divide is a built-in function, unless it is a goal. A goal whose
+// functor is the name of a built-in predicate (see makeBuiltInPredicate)
+// is that predicate. Expressions are parsed according to the priority
+// and type of their infix operators. (See op.go.) The operators which
+// make built-in predicates are listed in makeInfixGoal(). A term in
+// parentheses is allowed in arguments. In goals, parentheses group
+// goals, unless they are followed by an infix operator. The directives are op/3, those which
+// load files (see consult.go) and those of modules (see module.go).
+// Grammar rules (-->) are translated into ordinary rules. (See dcg.go.)
+//
+// The parser does not stop at the first error. When a rule has an
+// error, the parser skips to the end of the rule and continues, so
+// that all errors in a file are reported at once. Each error has a
+// line, a column and an excerpt of the source:
+//
+//    kings.txt:3:28: Missing argument
+//        mother($X, $Y) :- parent($X, , $Y).
+//                                     ^
+//
+// Cleve Lendon
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "unicode"
+)
+
+// ParseError - a syntax error, with its location.
+type ParseError struct {
+    File     string
+    Line     int
+    Column   int
+    Message  string
+    Excerpt  string   // the line of source where the error occurs
+}
+
+// Error - formats the error, with the excerpt and a caret which
+// points to the column. This method satisfies the error interface.
+func (e ParseError) Error() string {
+    location := SourceLocation{ e.File, e.Line, e.Column }
+    s := fmt.Sprintf("%v: %v", location, e.Message)
+    if e.Excerpt == "" { return s }
+    // Tabs are kept, so that the caret is aligned.
+    var pad strings.Builder
+    for i, ch := range []rune(e.Excerpt) {
+        if i >= e.Column - 1 { break }
+        if ch == '\t' { pad.WriteRune('\t') } else { pad.WriteRune(' ') }
+    }
+    return s + "\n    " + e.Excerpt + "\n    " + pad.String() + "^"
+}
+
+// ParseErrors - all syntax errors of a text.
+type ParseErrors []ParseError
+
+// Error - formats the errors, one after another.
+// This method satisfies the error interface.
+func (e ParseErrors) Error() string {
+    messages := make([]string, len(e))
+    for i, err := range e { messages[i] = err.Error() }
+    return strings.Join(messages, "\n")
+}
+
+// parser - holds the state of the parser.
+type parser struct {
+    lx        *lexer
+    file      string
+    offset    int          // offset of the next token
+    last      token        // last token consumed
+    errors    ParseErrors
+    names     map[string]bool  // names of variables in the current rule
+    ops       operatorTable    // operators of the text (see op.go)
+    loader    *loader      // loads files for directives, or nil
+    keepTexts bool         // keep the source text of each rule
+    atoms     map[string]Atom  // atoms of the text (see intern.go)
+}
+
+// bailOut - is thrown (by panic) to abandon a rule which has an error.
+type bailOut struct{}
+
+// makeParser - makes a parser for the given text.
+// Params: text
+//         file name, for errors and source locations
+//         operators, which op/3 directives change
+func makeParser(text string, file string, ops operatorTable) *parser {
+    p := &parser{ lx: makeLexer(text), file: file, ops: ops,
+                  atoms: map[string]Atom{} }
+    p.lx.refreshOperators(ops)
+    return p
+}
+
+// atom - returns the atom of the given name. Atoms with the
+// same name share their bytes, within the text.
+func (p *parser) atom(name string) Atom {
+    if a, ok := p.atoms[name]; ok { return a }
+    a := Atom(name)
+    p.atoms[name] = a
+    return a
+}
+
+// peek - returns the next token, without consuming it.
+func (p *parser) peek(mode lexMode) token {
+    return p.lx.scan(p.offset, mode)
+}
+
+// next - consumes and returns the next token.
+func (p *parser) next(mode lexMode) token {
+    t := p.lx.scan(p.offset, mode)
+    p.offset = t.next
+    p.last = t
+    if t.err != "" { p.fail(t, t.err) }
+    return t
+}
+
+// expect - consumes the next token, which must be of the given kind.
+func (p *parser) expect(mode lexMode, kind tokenKind, context string) token {
+    t := p.next(mode)
+    if t.kind != kind {
+        if t.kind == tkEOF || t.kind == tkEnd {
+            p.fail(t, "Missing " + context)
+        }
+        p.fail(t, fmt.Sprintf("Expected %v, found %v",
+                              punctuation[kind], t.describe()))
+    }
+    return t
+}
+
+// adjacent - returns true if the next token follows the given
+// token without white space.
+func (p *parser) adjacent(t token, mode lexMode, kind tokenKind) bool {
+    n := p.peek(mode)
+    return n.kind == kind && n.start == t.end
+}
+
+// location - returns the source location of a token.
+func (p *parser) location(t token) SourceLocation {
+    line, column := p.lx.position(t.start)
+    return SourceLocation{ p.file, line, column }
+}
+
+// record - records an error at an offset.
+func (p *parser) record(offset int, message string) {
+    line, column := p.lx.position(offset)
+    p.errors = append(p.errors, ParseError{
+        File: p.file, Line: line, Column: column,
+        Message: message, Excerpt: p.lx.lineText(line),
+    })
+}
+
+// fail - records an error at a token, and abandons the rule.
+func (p *parser) fail(t token, message string) {
+    p.record(t.start, message)
+    panic(bailOut{})
+}
+
+// try - calls a parsing function. If the function fails, the error
+// has been recorded, and try returns false. Panics thrown by the
+// constructors of built-in predicates and functions (eg. for the
+// wrong number of arguments) are recorded as errors at the token.
+func (p *parser) try(t *token, parse func()) (ok bool) {
+    defer func() {
+        if r := recover(); r != nil {
+            if _, isBailOut := r.(bailOut); !isBailOut {
+                p.record(t.start, fmt.Sprint(r))
+            }
+            ok = false
+        }
+    }()
+    parse()
+    return true
+} // try
+
+// skipRule - after an error, skips to the end of the rule.
+func (p *parser) skipRule() {
+    if p.last.kind == tkEnd || p.last.kind == tkEOF { return }
+    p.offset = p.lx.skipRule(p.offset)
+}
+
+//----------------------------------------------------------------
+// Rules and goals
+//----------------------------------------------------------------
+
+// parseProgram - parses all facts and rules of a text. When a text is
+// loaded into a knowledge base, a directive may load other files, whose
+// rules go to the loader (consult.go). The rules before the directive
+// are given to the loader first, to keep their order.
+// Return: rules
+//         source text of each rule, if p.keepTexts is set
+func (p *parser) parseProgram() ([]RuleStruct, []string) {
+    rules := []RuleStruct{}
+    texts := []string{}
+    for p.peek(goalMode).kind != tkEOF {
+        start := p.peek(goalMode)
+        if start.kind == tkNeck {
+            if p.loader != nil && len(rules) > 0 {
+                p.loader.add(rules)
+                rules = []RuleStruct{}
+            }
+            ok := p.try(&start, func() { p.parseDirective() })
+            if p.loader != nil { p.loader.started = true }
+            if !ok { p.skipRule() }
+            continue
+        }
+        var rule RuleStruct
+        if p.loader != nil { p.loader.started = true }
+        if p.try(&start, func() { rule = p.parseRule(true) }) {
+            if p.loader != nil { rule.module = p.loader.module }
+            rules = append(rules, rule)
+            if p.keepTexts {
+                texts = append(texts,
+                               string(p.lx.runes[start.start: p.offset]))
+            }
+        } else {
+            p.skipRule()
+            if p.offset <= start.start { p.offset = start.next }
+        }
+    }
+    return rules, texts
+} // parseProgram
+
+// parseRule - parses a fact or rule.
+// Param:  true if the rule must end with a period
+// Return: rule
+func (p *parser) parseRule(needPeriod bool) RuleStruct {
+    p.names = map[string]bool{}
+    start := p.peek(goalMode)
+    head := p.parseHead()
+    rule := RuleStruct{ head: head, source: p.location(start) }
+    previous := p.last
+    t := p.next(goalMode)
+    var pushback Unifiable
+    if t.kind == tkComma && p.peek(goalMode).kind == tkLBracket {
+        pushback = p.parseDCGTerminals()
+        t = p.expect(goalMode, tkArrow, "-->")
+    }
+    if t.kind == tkNeck {
+        rule.body = p.parseBody()
+        previous = p.last
+        t = p.next(goalMode)
+    } else if t.kind == tkArrow {
+        body := p.parseDCGBody()
+        rule.head, rule.body = p.translateDCG(head, pushback, body)
+        previous = p.last
+        t = p.next(goalMode)
+    }
+    if t.kind == tkEnd || (t.kind == tkEOF && !needPeriod) { return rule }
+    if t.kind == tkEOF { p.fail(t, "Missing period at end of rule") }
+    if line, _ := p.lx.position(previous.end); line < p.location(t).Line {
+        // The next rule probably begins at t. Report the missing
+        // period, and resume there.
+        p.record(previous.end, "Missing period at end of rule")
+        p.offset, p.last = t.start, token{ kind: tkEnd }
+        panic(bailOut{})
+    }
+    if rule.body == nil {
+        p.fail(t, fmt.Sprintf("Expected :- or ., found %v", t.describe()))
+    }
+    p.fail(t, fmt.Sprintf("Expected , ; or ., found %v", t.describe()))
+    return rule
+} // parseRule
+
+// parseDirective - parses and executes a directive, such as
+// :- op(700, xfx, isa). An operator is defined for the rest of the
+// text, and recorded in the knowledge base which the text is loaded
+// into. The directives which load files are explained in consult.go.
+func (p *parser) parseDirective() {
+    p.next(goalMode)   // :-
+    t := p.peek(goalMode)
+    // The argument of initialization is a goal, or goals.
+    if n := p.lx.scan(t.next, goalMode); t.kind == tkText && !t.escaped &&
+       t.text == "initialization" && n.kind == tkLParen && n.start == t.end {
+        p.next(goalMode)
+        p.next(goalMode)
+        goal := p.parseBody()
+        p.expect(goalMode, tkRParen, "closing parenthesis")
+        p.expectEnd()
+        p.initialization(t, goal)
+        return
+    }
+    term, _ := p.parseExpression(goalMode, 1200)
+    p.expectEnd()
+    directive, _ := term.(Complex)
+    switch {
+    case isDirective(directive, "op", 3):
+        ops, err := operatorsOf(directive[1:], SubstitutionSet{})
+        if err != nil { p.fail(t, err.Error()) }
+        for _, op := range ops {
+            p.ops.define(op)
+            if p.loader != nil { p.loader.tx.Add(opFact(op)) }
+        }
+        p.lx.refreshOperators(p.ops)
+        return
+    case isDirective(directive, "consult", 1),
+         isDirective(directive, "include", 1),
+         isDirective(directive, "ensure_loaded", 1),
+         isDirective(directive, "use_module", 1),
+         isDirective(directive, "use_module", 2):
+        p.loadDirective(t, directive)
+        return
+    case isDirective(directive, "module", 2):
+        p.moduleDirective(t, directive)
+        return
+    }
+    p.fail(t, fmt.Sprintf("Unknown directive: %v", term))
+} // parseDirective
+
+// isDirective - returns true if the term has the given functor
+// and number of arguments.
+func isDirective(c Complex, name string, arity int) bool {
+    return len(c) == arity + 1 && c[0] == Atom(name)
+}
+
+// expectEnd - consumes the period at the end of a rule or directive.
+func (p *parser) expectEnd() {
+    if end := p.next(goalMode); end.kind != tkEnd {
+        p.fail(end, fmt.Sprintf("Expected ., found %v", end.describe()))
+    }
+}
+
+// parseHead - parses the head of a rule, which is a complex term
+// or an atom.
+func (p *parser) parseHead() Complex {
+    t := p.peek(goalMode)
+    term, _ := p.parseExpression(goalMode, 999)
+    switch h := term.(type) {
+    case Complex:
+        return h
+    case Atom:
+        return Complex{ h }
+    }
+    p.fail(t, fmt.Sprintf("Invalid head of rule: %v", term))
+    return nil
+}
+
+// parseBody - parses goals separated by commas (and) and semicolons (or).
+// Return: goal
+func (p *parser) parseBody() Goal {
+    operands := []Goal{ p.parseConjunction() }
+    for p.peek(goalMode).kind == tkSemicolon {
+        p.next(goalMode)
+        operands = append(operands, p.parseConjunction())
+    }
+    if len(operands) == 1 { return operands[0] }
+    return Or(operands...)
+}
+
+// parseConjunction - parses goals separated by commas.
+func (p *parser) parseConjunction() Goal {
+    operands := []Goal{ p.parseGoal() }
+    for p.peek(goalMode).kind == tkComma {
+        p.next(goalMode)
+        operands = append(operands, p.parseGoal())
+    }
+    if len(operands) == 1 { return operands[0] }
+    return And(operands...)
+}
+
+// parseGoal - parses one goal: a group in parentheses, not(...),
+// time(...), a goal with an infix operator, or a complex term.
+// Parentheses which are followed by an infix operator enclose an
+// operand, not a group: (a implies b) implies c
+func (p *parser) parseGoal() Goal {
+    t := p.peek(goalMode)
+    if t.kind == tkLParen {
+        offset, last := p.offset, p.last
+        p.next(goalMode)
+        goal := p.parseBody()
+        p.expect(goalMode, tkRParen, "closing parenthesis")
+        if p.peek(goalMode).kind != tkInfix { return goal }
+        p.offset, p.last = offset, last   // Parse it again, as an operand.
+    }
+    if t.kind == tkText && !t.escaped &&
+       (t.text == "not" || t.text == "time") {
+        p.next(goalMode)
+        if p.adjacent(t, goalMode, tkLParen) {
+            p.next(goalMode)
+            var goal Goal
+            if t.text == "not" {
+                goal = Not(p.parseBody())
+            } else {
+                operand := p.peek(argMode)
+                switch c := p.parseRawTerm(argMode).(type) {
+                case Complex:
+                    goal = Time(c)
+                case Atom:
+                    goal = Time(Complex{ c })
+                default:
+                    p.fail(operand, "time() requires a complex term")
+                }
+            }
+            p.expect(goalMode, tkRParen, "closing parenthesis")
+            return goal
+        }
+        return Complex{ p.atom(t.text) }
+    }
+    term, op := p.parseExpression(goalMode, 999)
+    if goalOperators[op] {
+        c := term.(Complex)
+        var goal Goal
+        p.call(t, func() { goal = makeInfixGoal(op, c[1], c[2]) })
+        return goal
+    }
+    return p.makeGoal(t, term)
+} // parseGoal
+
+// call - calls a constructor. A panic becomes an error at the token.
+func (p *parser) call(t token, construct func()) {
+    if !p.try(&t, construct) { panic(bailOut{}) }
+}
+
+// makeInfixGoal - makes the goal for an infix operator.
+// Params: operator
+//         left and right terms
+// Return: goal
+func makeInfixGoal(op string, left, right Unifiable) Goal {
+    switch op {
+    case "=":   return Unify(left, right)
+    case "==":  return Equal(left, right)
+    case "<":   return LessThan(left, right)
+    case "<=":  return LessThanOrEqual(left, right)
+    case ">":   return GreaterThan(left, right)
+    case ">=":  return GreaterThanOrEqual(left, right)
+    case "in":  return In(left, right)
+    case "ins": return Ins(left, right)
+    case "#=":  return FDEqual(left, right)
+    case "#\\=": return FDNotEqual(left, right)
+    case "#<":  return FDLessThan(left, right)
+    case "#>":  return FDGreaterThan(left, right)
+    case "#=<": return FDLessThanOrEqual(left, right)
+    case "#>=": return FDGreaterThanOrEqual(left, right)
+    }
+    panic("Unknown operator: " + op)
+} // makeInfixGoal
+
+// makeGoal - converts a term to a goal. An atom is a complex term
+// without arguments, or one of: ! fail nl trace notrace. A complex
+// term may be a built-in predicate.
+// Params: first token of term
+//         term
+// Return: goal
+func (p *parser) makeGoal(t token, term Unifiable) Goal {
+    switch g := term.(type) {
+    case Atom:
+        if !t.escaped {
+            switch g {
+            case "!":       return Cut()
+            case "fail":    return Fail()
+            case "nl":      return NL()
+            case "trace":   return TracePredicate()
+            case "notrace": return NoTracePredicate()
+            }
+        }
+        return Complex{ g }
+    case Complex:
+        var goal Goal = g
+        p.call(t, func() {
+            if bip, ok := makeBuiltInPredicate(string(g[0].(Atom)),
+                                               g[1:]); ok {
+                goal = bip
+            }
+        })
+        return goal
+    }
+    p.fail(t, fmt.Sprintf("Invalid goal: %v", term))
+    return nil
+} // makeGoal
+
+// makeBuiltInPredicate - creates a built-in predicate from its
+// name and arguments. If the name is not a built-in predicate,
+// the success flag is false.
+// Params: name of predicate
+//         arguments
+// Return: built-in predicate
+//         success/failure flag
+func makeBuiltInPredicate(name string, args []Unifiable) (Goal, bool) {
+    switch name {
+    case "append":     return Append(args...), true
+    case "print":      return Print(args...), true
+    case "functor":    return Functor(args...), true
+    case "include":    return Include(args...), true
+    case "exclude":    return Exclude(args...), true
+    case "print_list": return PrintList(args...), true
+    case "all_different": return AllDifferent(args...), true
+    case "sum":        return Sum(args...), true
+    case "fd_dom":     return FDDom(args...), true
+    case "label":      return Label(args...), true
+    case "labeling":   return Labeling(args...), true
+    case "freeze":     return Freeze(args...), true
+    case "dif":        return Dif(args...), true
+    case "when":       return When(args...), true
+    case "unify_with_occurs_check": return UnifyWithOccursCheck(args...), true
+    case "spy":        return SpyPredicate(args...), true
+    case "nospy":      return NoSpyPredicate(args...), true
+    case "profile":    return ProfilePredicate(args...), true
+    case "clause_property": return ClauseProperty(args...), true
+    case "op":         return OpPredicate(args...), true
+    case "phrase":     return Phrase(args...), true
+    }
+    return nil, false
+} // makeBuiltInPredicate
+
+//----------------------------------------------------------------
+// Terms
+//----------------------------------------------------------------
+
+// parseTerm - parses a term. Complex terms which are built-in
+// functions become functions.
+func (p *parser) parseTerm(mode lexMode) Unifiable {
+    t := p.peek(mode)
+    term, _ := p.parseExpression(mode, 999)
+    p.call(t, func() { term = makeFunction(term) })
+    return term
+}
+
+// parseExpression - parses a term which may contain infix operators,
+// by precedence climbing. (See op.go.) Operands become functions, as
+// arguments do.
+// Params: mode
+//         highest priority of operator allowed
+// Return: term
+//         operator of the term, or "" if it is not an operation
+func (p *parser) parseExpression(mode lexMode, max int) (Unifiable, string) {
+    left := p.parseRawTerm(mode)
+    leftOp := ""
+    leftPriority := 0
+    for {
+        t := p.peek(mode)
+        name := t.text
+        if t.kind == tkComma && mode == termMode {
+            name = ","
+        } else if t.kind != tkInfix {
+            break
+        }
+        op, ok := p.ops[name]
+        if !ok || op.Priority > max { break }
+        leftMax, rightMax := argumentPriorities(op)
+        if leftPriority > leftMax {
+            p.fail(t, fmt.Sprintf("Operator priority clash: %v", op.Name))
+        }
+        p.next(mode)
+        right, _ := p.parseExpression(mode, rightMax)
+        operand := left
+        p.call(t, func() {
+            left = Complex{ p.atom(op.Name), makeFunction(operand),
+                            makeFunction(right) }
+        })
+        leftOp, leftPriority = op.Name, op.Priority
+    }
+    return left, leftOp
+} // parseExpression
+
+// makeFunction - if the term is a complex term whose functor
+// is a built-in function, returns the function.
+func makeFunction(term Unifiable) Unifiable {
+    c, ok := term.(Complex)
+    if !ok || len(c) < 2 { return term }
+    args := c[1:]
+    switch c[0] {
+    case Atom("join"):     return Join(args...)
+    case Atom("add"):      return Add(args...)
+    case Atom("subtract"): return Subtract(args...)
+    case Atom("multiply"): return Multiply(args...)
+    case Atom("divide"):   return Divide(args...)
+    }
+    return term
+} // makeFunction
+
+// parseRawTerm - parses a term: an atom, number, variable, quoted
+// atom, list, or complex term. Built-in functions are not made.
+func (p *parser) parseRawTerm(mode lexMode) Unifiable {
+    t := p.next(mode)
+    switch t.kind {
+    case tkText:
+        if p.adjacent(t, mode, tkLParen) {
+            if !t.escaped && !isAtomText(t.text) {
+                p.fail(t, fmt.Sprintf("Invalid functor: %v", t.text))
+            }
+            p.next(mode)
+            return p.parseArguments(p.atom(t.text))
+        }
+        if p.adjacent(t, mode, tkQuoted) {
+            p.fail(p.peek(mode), "Text before opening quote")
+        }
+        return p.textToTerm(t)
+    case tkQuoted:
+        if n := p.peek(mode); n.start == t.end &&
+           (n.kind == tkText || n.kind == tkQuoted) {
+            p.fail(n, "Text after closing quote")
+        }
+        return p.atom(t.text)
+    case tkLBracket:
+        return p.parseList(t)
+    case tkLParen:
+        // A term in parentheses. In goals, parentheses which begin
+        // a goal group goals. (See parseGoal().) Here, they enclose
+        // an operand: $X = (a = b)
+        term, _ := p.parseExpression(termMode, 1200)
+        p.expect(termMode, tkRParen, "closing parenthesis")
+        return term
+    case tkEOF:
+        p.fail(t, "Unexpected end of text")
+    }
+    if mode == goalMode {
+        p.fail(t, fmt.Sprintf("Missing goal before %v", t.describe()))
+    }
+    p.fail(t, "Missing argument")
+    return nil
+} // parseRawTerm
+
+// parseArguments - parses the arguments of a complex term.
+// The left parenthesis has been consumed.
+// Param:  functor
+// Return: complex term
+func (p *parser) parseArguments(functor Atom) Complex {
+    c := Complex{ functor }
+    if p.peek(argMode).kind == tkRParen {   // eg. qsort()
+        p.next(argMode)
+        return c
+    }
+    for {
+        c = append(c, p.parseTerm(argMode))
+        t := p.next(argMode)
+        if t.kind == tkRParen { return c }
+        if t.kind == tkEOF || t.kind == tkEnd {
+            p.fail(t, "Missing closing parenthesis")
+        }
+        if t.kind != tkComma {
+            p.fail(t, fmt.Sprintf("Expected , or ), found %v", t.describe()))
+        }
+    }
+} // parseArguments
+
+// parseList - parses a list. The left bracket has been consumed.
+// The tail of a list is a variable or a list.
+// Param:  left bracket
+// Return: list
+func (p *parser) parseList(bracket token) LinkedListStruct {
+    if p.peek(listMode).kind == tkRBracket {
+        p.next(listMode)
+        return emptyList
+    }
+    items := []Unifiable{}
+    for {
+        items = append(items, p.parseTerm(listMode))
+        t := p.next(listMode)
+        switch t.kind {
+        case tkRBracket:
+            return MakeLinkedList(false, items...)
+        case tkComma:
+            continue
+        case tkBar:
+            tailToken := p.peek(listMode)
+            tail := p.parseTerm(listMode)
+            p.expect(listMode, tkRBracket, "closing bracket")
+            switch tl := tail.(type) {
+            case VariableStruct:
+                return MakeLinkedList(true, append(items, tl)...)
+            case LinkedListStruct:
+                vbar := false
+                for ptr := &tl; ptr != nil && ptr.term != nil; ptr = ptr.next {
+                    items = append(items, ptr.term)
+                    vbar = ptr.tailVar
+                }
+                return MakeLinkedList(vbar, items...)
+            }
+            p.fail(tailToken, "The tail of a list must be a variable or a list")
+        case tkEOF, tkEnd:
+            p.fail(t, "Missing closing bracket")
+        default:
+            p.fail(t, fmt.Sprintf("Expected , | or ], found %v", t.describe()))
+        }
+    }
+} // parseList
+
+// textToTerm - classifies unquoted text as a variable, anonymous
+// variable, integer, float or atom.
+func (p *parser) textToTerm(t token) Unifiable {
+    s := t.text
+    if t.escaped { return p.atom(s) }
+    if s == "$_" { return Anon() }
+    // If the text is not a valid variable ($, $10), it is an atom.
+    if v, err := LogicVar(s); err == nil {
+        // Probably a missing comma, eg. f($X $Y)
+        if strings.IndexFunc(s, unicode.IsSpace) >= 0 {
+            p.fail(t, fmt.Sprintf("Invalid variable: %v", s))
+        }
+        if p.names != nil { p.names[s] = true }
+        return v
+    }
+    if isNumberText(s) {
+        if strings.Contains(s, ".") {
+            if f, err := strconv.ParseFloat(s, 64); err == nil {
+                return Float(f)
+            }
+        } else if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+            return Integer(i)
+        }
+    }
+    return p.atom(s)
+} // textToTerm
+
+// isNumberText - returns true if the text consists of digits and
+// periods, with an optional minus sign.
+func isNumberText(s string) bool {
+    s = strings.TrimPrefix(s, "-")
+    hasDigit := false
+    for _, ch := range s {
+        if ch >= '0' && ch <= '9' {
+            hasDigit = true
+        } else if ch != '.' {
+            return false
+        }
+    }
+    return hasDigit
+}
+
+// isAtomText - returns true if the text can be a functor. Variables
+// and numbers cannot be functors.
+func isAtomText(s string) bool {
+    return !strings.HasPrefix(s, "$") && !isNumberText(s)
+}
+
+//----------------------------------------------------------------
+// Entry points
+//----------------------------------------------------------------
+
+// ParseRules - parses all facts and rules of a text. If there are
+// syntax errors, the error is ParseErrors, which lists them all.
+// Params: text of program
+//         file name, for errors and source locations (may be "")
+// Return: rules
+//         error
+func ParseRules(text string, fileName string) ([]RuleStruct, error) {
+    p := makeParser(text, fileName, globalOperators())
+    rules, _ := p.parseProgram()
+    if len(p.errors) > 0 { return rules, p.errors }
+    return rules, nil
+}
+
+// parseOne - parses a string with the given function. The whole
+// string must be parsed.
+// Params: string
+//         parsing function
+// Return: error or nil
+func parseOne(str string, parse func(p *parser)) error {
+    p := makeParser(str, "", globalOperators())
+    start := p.peek(goalMode)
+    if p.try(&start, func() {
+        parse(p)
+        if t := p.next(goalMode); t.kind != tkEOF {
+            p.fail(t, fmt.Sprintf("Unexpected %v", t.describe()))
+        }
+    }) { return nil }
+    return p.errors
+} // parseOne
+
+// ParseRule - create a fact or rule from a string representation.
+// The final period is optional. Examples of usage:
+//    c := ParseRule("male(Harold).")
+//    c := ParseRule("father($X, $Y) :- parent($X, $Y), male($X).")
+func ParseRule(str string) (RuleStruct, error) {
+    var rule RuleStruct
+    err := parseOne(str, func(p *parser) {
+        rule = p.parseRule(false)
+        rule.source = SourceLocation{}
+    })
+    return rule, err
+} // ParseRule
+
+// ParseComplex - parses a string to produce a complex term.
+// A final period is allowed. Example of usage:
+//     c, err := ParseComplex("symptom(Covid, fever)")
+func ParseComplex(str string) (Complex, error) {
+    var c Complex
+    err := parseOne(str, func(p *parser) {
+        t := p.peek(argMode)
+        term, _ := p.parseExpression(argMode, 1200)
+        switch term := term.(type) {
+        case Complex:
+            c = term
+        case Atom:
+            c = Complex{ term }
+        default:
+            p.fail(t, fmt.Sprintf("Not a complex term: %v", term))
+        }
+        if p.peek(argMode).kind == tkEnd { p.next(argMode) }
+    })
+    return c, err
+} // ParseComplex
+
+// ParseSubgoal - parses a goal, or goals separated by commas and
+// semicolons. Example of usage:
+//     g, err := ParseSubgoal("$X = $Y")
+func ParseSubgoal(subgoal string) (Goal, error) {
+    var goal Goal
+    err := parseOne(subgoal, func(p *parser) {
+        goal = p.parseBody()
+        if p.peek(goalMode).kind == tkEnd { p.next(goalMode) }
+    })
+    return goal, err
+} // ParseSubgoal
+
+// parseInfix - parses a goal with an infix operator, eg. $X = verb.
+// If the string does not contain the given operator, the success
+// flag is false. If there is an error in a term, the function panics.
+// (See ParseUnify(), ParseLessThan(), etc.)
+// Params: string
+//         operator
+// Return: left and right terms
+//         success/failure flag
+func parseInfix(str string, op string) (Unifiable, Unifiable, bool) {
+    p := makeParser(str, "", globalOperators())
+    var left, right Unifiable
+    found := false
+    start := p.peek(goalMode)
+    ok := p.try(&start, func() {
+        term, name := p.parseExpression(goalMode, 999)
+        if name != op { return }
+        found = true
+        left, right = term.(Complex)[1], term.(Complex)[2]
+        if t := p.next(goalMode); t.kind != tkEOF {
+            p.fail(t, fmt.Sprintf("Unexpected %v", t.describe()))
+        }
+    })
+    if !ok && strings.Contains(str, " " + op + " ") {
+        panic(p.errors.Error())
+    }
+    return left, right, ok && found
+} // parseInfix
+
+// ParseLinkedList - parses a string to create a linked list.
+// For example,
+//     list, err := ParseLinkedList("[a, b, c | $X]")
+// Produces an error if the string is invalid.
+func ParseLinkedList(str string) (LinkedListStruct, error) {
+    list := emptyList
+    err := parseOne(str, func(p *parser) {
+        t := p.peek(argMode)
+        if t.kind != tkLBracket { p.fail(t, "Missing opening bracket") }
+        list = p.parseTerm(argMode).(LinkedListStruct)
+    })
+    return list, err
+} // ParseLinkedList
+
+// ParseFunction - parses a string to produce a built-in Suiron function.
+// Example of usage:
+//     c := ParseFunction("add(7, 9, 4)")
+//
+// Params: string representation
+// Return: built-in suiron function
+//         error
+func ParseFunction(str string) (Function, error) {
+    var function Function
+    err := parseOne(str, func(p *parser) {
+        t := p.peek(argMode)
+        term := p.parseTerm(argMode)
+        f, ok := term.(Function)
+        if !ok { p.fail(t, fmt.Sprintf("Unknown function: %v", term)) }
+        function = f
+    })
+    return function, err
+} // ParseFunction
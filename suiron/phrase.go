@@ -0,0 +1,118 @@
+package suiron
+
+// Phrase
+//
+// The predicate phrase/2 parses a list of words with a nonterminal
+// of a grammar. (See dcg.go.) For example:
+//
+//    ..., phrase(sentence($Tree), [the, cat, sleeps]), ...
+//
+// calls sentence($Tree, [the, cat, sleeps], []). All the words must
+// be parsed. phrase/3 has a third argument, which is the list of
+// words which remain after parsing:
+//
+//    ..., phrase(noun_phrase($NP), [the, cat, sleeps], $Rest), ...
+//
+// The first argument must be an atom or a complex term. On
+// backtracking, phrase tries the next solution of the nonterminal.
+//
+// Cleve Lendon
+
+type PhraseStruct BuiltInPredicateStruct
+
+// Phrase - creates the struct which defines this built-in
+// predicate. Checks input arguments.
+func Phrase(arguments ...Unifiable) PhraseStruct {
+    if len(arguments) < 2 || len(arguments) > 3 {
+        panic("Phrase - This predicate requires 2 or 3 arguments.")
+    }
+    return PhraseStruct {
+        Name: "phrase",
+        Arguments: arguments,
+    }
+}
+
+// GetSolver - gets a solution node for this predicate.
+// This function satisfies the Goal interface.
+func (s PhraseStruct) GetSolver(kb KnowledgeBase,
+                                parentSolution SubstitutionSet,
+                                parentNode SolutionNode) SolutionNode {
+    node := PhraseSolutionNodeStruct{
+                SolutionNodeStruct: MakeSolutionNode(s, kb,
+                                        parentSolution, parentNode),
+                moreSolutions: true,
+            }
+    return &node
+}
+
+//----------------------------------------------------------------
+// RecreateVariables(), ReplaceVariables(), and String() satisfy
+// the Expression interface.
+//----------------------------------------------------------------
+
+// RecreateVariables - Refer to comments in expression.go.
+func (s PhraseStruct) RecreateVariables(vars VarMap) Expression {
+    bip := BuiltInPredicateStruct(s).RecreateVariables(vars)
+    return Expression(PhraseStruct(*bip))
+}
+
+// ReplaceVariables - Refer to comments in expression.go.
+func (s PhraseStruct) ReplaceVariables(ss SubstitutionSet) Expression {
+    return BuiltInPredicateStruct(s).ReplaceVariables(ss)
+}  // ReplaceVariables
+
+// String - creates a string representation.
+// Returns: phrase(sentence($T), [the, cat, sleeps])
+func (s PhraseStruct) String() string {
+    return BuiltInPredicateStruct(s).String()
+}
+
+// phraseGoal - makes the goal which phrase/2 or phrase/3 calls.
+// Eg.: phrase(noun($N), $In, $Rest) -> noun($N, $In, $Rest)
+// Params: arguments of phrase
+//         substitution set
+// Return: goal
+//         success/failure flag
+func phraseGoal(arguments []Unifiable, ss SubstitutionSet) (Complex, bool) {
+    nonterminal, ok := ss.CastComplex(arguments[0])
+    if !ok {
+        atom, ok := ss.CastAtom(arguments[0])
+        if !ok { return nil, false }
+        nonterminal = Complex{ atom }
+    }
+    var rest Unifiable = emptyList
+    if len(arguments) == 3 { rest = arguments[2] }
+    goal := make(Complex, 0, len(nonterminal) + 2)
+    return append(append(goal, nonterminal...), arguments[1], rest), true
+} // phraseGoal
+
+type PhraseSolutionNodeStruct struct {
+    SolutionNodeStruct
+    moreSolutions bool
+    child SolutionNode   // solution node of the nonterminal
+}
+
+// NextSolution - calls the nonterminal, and returns its next solution.
+// This function satisfies the SolutionNode interface.
+func (sn *PhraseSolutionNodeStruct) NextSolution() (SubstitutionSet, bool) {
+    if sn.NoBackTracking { return nil, false }
+    if sn.child != nil { return sn.child.NextSolution() }
+    if !sn.moreSolutions { return nil, false }
+    sn.moreSolutions = false
+    goal, ok := phraseGoal(sn.Goal.(PhraseStruct).Arguments, sn.ParentSolution)
+    if !ok { return nil, false }
+    sn.child = goal.GetSolver(sn.KnowledgeBase, sn.ParentSolution, sn)
+    return sn.child.NextSolution()
+}
+
+// SetNoBackTracking - set the NoBackTracking flag,
+// which is used to implement Cuts.
+// This function satisfies the SolutionNode interface.
+func (sn *PhraseSolutionNodeStruct) SetNoBackTracking() {
+    sn.NoBackTracking = true
+}
+
+// GetParentNode
+func (sn *PhraseSolutionNodeStruct) GetParentNode() SolutionNode {
+    return sn.ParentNode
+}
@@ -0,0 +1,652 @@
+package suiron
+
+// Profiler - an execution profiler for rules.
+//
+// The Go profiler (see test/cpu_profile.go) shows which Go functions
+// are hot, such as Unify() and NextSolution(), but not which rules.
+// This profiler counts the ports of each predicate (name/arity) and
+// of each clause, and measures the time spent in them:
+//
+//    profiler := StartProfiler()
+//    solutions, _ := SolveAll(query, kb, SubstitutionSet{})
+//    profiler.Stop()
+//    fmt.Print(profiler.Table())
+//
+// The ports are those of the tracer (see trace.go): Call, Exit, Redo
+// and Fail. The ports of a clause are counted in the same way: a clause
+// is called when its head unifies with a goal, exits when the goal
+// exits through it, and fails when the search backtracks past it.
+// Each call or redo ends with an exit or a fail, so redos are computed
+// from the other counts. (This includes backtracking into the body of
+// a rule, which the tracer does not report as Redo.)
+//
+// Time is measured by the solver of engine.go. The time of each step
+// of the search is charged to the stack of predicates and clauses which
+// led to it. The inclusive time of a predicate or clause is the time of
+// all steps with it on the stack; recursive calls are counted once. The
+// exclusive time of a predicate is the time of the steps which call it,
+// or which run the control operators (And, Or, cut) of its rules. The
+// exclusive time of a clause is the time of the steps of its body: its
+// control operators, built-in predicates, and the calls of its goals.
+//
+// WritePprof() writes the profile in the format of pprof. The stack
+// frames are predicates, and the line of a frame is the source line
+// of the clause:
+//
+//    go tool pprof -top profile.pb.gz
+//
+// The built-in predicate profile(Goal) runs Goal once, with a new
+// profiler, and prints the table of results.
+//
+// While the profiler is running, goals are traced, which makes the
+// search slower. Goals which are solved by the compiled knowledge base
+// (wam_machine.go) or by the strategies of search.go are not profiled.
+//
+// Cleve Lendon
+
+import (
+    "bytes"
+    "compress/gzip"
+    "fmt"
+    "io"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Profiler - collects the counts and times of predicates and clauses.
+type Profiler struct {
+    mutex       sync.Mutex
+    root        *profileNode
+    predicates  map[string]*portCounts
+    clauses     map[clauseId]*portCounts
+    rules       map[clauseId]*RuleStruct
+    started     time.Time
+    duration    time.Duration
+    running     bool
+}
+
+// clauseId - identifies a clause: predicate and number (1 = first).
+type clauseId struct {
+    key     string
+    clause  int
+}
+
+// portCounts - the number of times each port was passed.
+type portCounts struct {
+    calls, exits, fails  int64
+}
+
+// redos - computes the number of redos. Goals which were cut, or
+// which were still running when the search ended, have no exit or fail.
+func (c *portCounts) redos() int64 {
+    redos := c.exits + c.fails - c.calls
+    if redos < 0 { return 0 }
+    return redos
+}
+
+// profileNode - a node of the tree of stacks. Each node is a predicate
+// which was called (clause 0), or a clause of the predicate which is
+// being solved. The path from the root is the stack.
+type profileNode struct {
+    id        clauseId
+    parent    *profileNode
+    children  map[clauseId]*profileNode
+    steps     int64          // steps with this node at the top of the stack
+    nanos     int64          // time of those steps
+}
+
+// PredicateProfile - the counts and times of a predicate.
+type PredicateProfile struct {
+    Key        string  // eg. father/2
+    Calls      int64
+    Exits      int64
+    Redos      int64
+    Fails      int64
+    Inclusive  time.Duration
+    Exclusive  time.Duration
+}
+
+// ClauseProfile - the counts and times of a clause.
+type ClauseProfile struct {
+    Key        string  // predicate, eg. father/2
+    Clause     int     // number of clause (1 = first)
+    File       string  // source file, or ""
+    Line       int     // source line, or 0
+    Calls      int64
+    Exits      int64
+    Redos      int64
+    Fails      int64
+    Inclusive  time.Duration
+    Exclusive  time.Duration
+}
+
+// StartProfiler - makes a profiler, and starts it. A profiler which
+// was running is stopped.
+// Return: profiler
+func StartProfiler() *Profiler {
+    p := makeProfiler()
+    previous := setProfiler(p)
+    if previous != nil { previous.stopped() }
+    return p
+}
+
+// makeProfiler - makes a profiler, which is not yet running.
+func makeProfiler() *Profiler {
+    return &Profiler{
+               root: &profileNode{},
+               predicates: map[string]*portCounts{},
+               clauses: map[clauseId]*portCounts{},
+               rules: map[clauseId]*RuleStruct{},
+               started: time.Now(),
+               running: true,
+           }
+}
+
+// Stop - stops the profiler. The results remain available.
+func (p *Profiler) Stop() {
+    traceState.mutex.Lock()
+    if traceState.profiler == p {
+        traceState.profiler = nil
+        updateTraceActive()
+    }
+    traceState.mutex.Unlock()
+    p.stopped()
+}
+
+// stopped - records the duration of the profile.
+func (p *Profiler) stopped() {
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+    if p.running {
+        p.running = false
+        p.duration = time.Since(p.started)
+    }
+}
+
+// setProfiler - sets the active profiler.
+// Param:  profiler, or nil
+// Return: previous profiler, or nil
+func setProfiler(p *Profiler) *Profiler {
+    traceState.mutex.Lock()
+    defer traceState.mutex.Unlock()
+    previous := traceState.profiler
+    traceState.profiler = p
+    updateTraceActive()
+    return previous
+}
+
+// activeProfiler - returns the active profiler, or nil.
+func activeProfiler() *Profiler {
+    if !tracingEnabled() { return nil }
+    traceState.mutex.RLock()
+    defer traceState.mutex.RUnlock()
+    return traceState.profiler
+}
+
+// port - counts a port of a goal. For the Unify port, the clause is
+// called. For the Exit port, the goal exits through the clause.
+// Params: port
+//         predicate key
+//         number of clause, or 0
+func (p *Profiler) port(port Port, key string, clause int) {
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+    if port == UnifyPort {
+        p.clauseCounts(key, clause).calls++
+        return
+    }
+    counts := p.predicates[key]
+    if counts == nil {
+        counts = &portCounts{}
+        p.predicates[key] = counts
+    }
+    switch port {
+    case CallPort:
+        counts.calls++
+    case ExitPort:
+        counts.exits++
+        if clause > 0 { p.clauseCounts(key, clause).exits++ }
+    case FailPort:
+        counts.fails++
+    }
+} // port
+
+// clauseFail - counts the Fail port of a clause, when the search
+// backtracks to the clause after it.
+func (p *Profiler) clauseFail(key string, clause int) {
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+    p.clauseCounts(key, clause).fails++
+}
+
+// clauseCounts - gets the counts of a clause. The lock must be held.
+func (p *Profiler) clauseCounts(key string, clause int) *portCounts {
+    id := clauseId{ key, clause }
+    counts := p.clauses[id]
+    if counts == nil {
+        counts = &portCounts{}
+        p.clauses[id] = counts
+    }
+    return counts
+}
+
+// child - gets the child of a node of the tree of stacks.
+// Params: parent node (nil = root)
+//         predicate key
+//         number of clause, or 0 for a call
+//         rule (for the source of a clause), or nil
+// Return: child node
+func (p *Profiler) child(parent *profileNode, key string, clause int,
+                         rule *RuleStruct) *profileNode {
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+    if parent == nil { parent = p.root }
+    id := clauseId{ key, clause }
+    node := parent.children[id]
+    if node == nil {
+        node = &profileNode{ id: id, parent: parent }
+        if parent.children == nil {
+            parent.children = map[clauseId]*profileNode{}
+        }
+        parent.children[id] = node
+        if rule != nil { p.rules[id] = rule }
+    }
+    return node
+}
+
+// charge - charges the time of a step to the top of a stack.
+func (p *Profiler) charge(node *profileNode, elapsed time.Duration) {
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+    if node == nil { node = p.root }
+    node.steps++
+    node.nanos += int64(elapsed)
+}
+
+//----------------------------------------------------------------
+// Engine (see engine.go)
+//----------------------------------------------------------------
+
+// profileStep - begins a step of the search. The time since the
+// previous step is charged to the previous stack.
+// Param: goal of the step, or nil for control
+func (n *EngineSolutionNodeStruct) profileStep(goal Goal) {
+    now := time.Now()
+    n.profiler.charge(n.profLeaf, now.Sub(n.profMark))
+    n.profMark = now
+    n.profLeaf = n.profNode
+    switch goal.(type) {
+    case nil, AndOp, OrOp, CutOp, FailOp:
+    default:
+        n.profLeaf = n.profiler.child(n.profNode, goalKey(goal), 0, nil)
+    }
+}
+
+// profileFlush - charges the time of the last step, when the engine
+// returns a solution or fails.
+func (n *EngineSolutionNodeStruct) profileFlush() {
+    n.profiler.charge(n.profLeaf, time.Since(n.profMark))
+}
+
+// profileClause - gets the node for the body of a clause, which
+// is being used to solve a goal.
+func (n *EngineSolutionNodeStruct) profileClause(goal Complex, clause int,
+                                                 rule *RuleStruct) *profileNode {
+    if n.profiler == nil { return nil }
+    return n.profiler.child(n.profNode, goal.Key(), clause, rule)
+}
+
+//----------------------------------------------------------------
+// Reports
+//----------------------------------------------------------------
+
+// times - computes the inclusive and exclusive times of predicates and
+// clauses, from the tree of stacks. The lock must be held.
+// Return: inclusive and exclusive times of predicates (nanoseconds)
+//         inclusive and exclusive times of clauses
+func (p *Profiler) times() (predIncl, predExcl map[string]int64,
+                            clauseIncl, clauseExcl map[clauseId]int64) {
+    predIncl = map[string]int64{}
+    predExcl = map[string]int64{}
+    clauseIncl = map[clauseId]int64{}
+    clauseExcl = map[clauseId]int64{}
+    // Predicates and clauses on the path. Recursive calls are counted once.
+    predsOnPath := map[string]int{}
+    clausesOnPath := map[clauseId]int{}
+
+    var visit func(node *profileNode, innermost *profileNode)
+    visit = func(node *profileNode, innermost *profileNode) {
+        if node != p.root {
+            predsOnPath[node.id.key]++
+            if node.id.clause > 0 {
+                clausesOnPath[node.id]++
+                innermost = node
+            }
+        }
+        if node.nanos > 0 {
+            for key := range predsOnPath { predIncl[key] += node.nanos }
+            for id := range clausesOnPath { clauseIncl[id] += node.nanos }
+            if node != p.root { predExcl[node.id.key] += node.nanos }
+            if innermost != nil { clauseExcl[innermost.id] += node.nanos }
+        }
+        for _, child := range node.children { visit(child, innermost) }
+        if node != p.root {
+            predsOnPath[node.id.key]--
+            if predsOnPath[node.id.key] == 0 { delete(predsOnPath, node.id.key) }
+            if node.id.clause > 0 {
+                clausesOnPath[node.id]--
+                if clausesOnPath[node.id] == 0 { delete(clausesOnPath, node.id) }
+            }
+        }
+    }
+    visit(p.root, nil)
+    return
+} // times
+
+// Predicates - returns the profiles of the predicates, sorted by
+// inclusive time, then by key.
+func (p *Profiler) Predicates() []PredicateProfile {
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+    incl, excl, _, _ := p.times()
+    keys := map[string]bool{}
+    for key := range p.predicates { keys[key] = true }
+    for key := range incl { keys[key] = true }
+    profiles := []PredicateProfile{}
+    for key := range keys {
+        pp := PredicateProfile{ Key: key,
+                                Inclusive: time.Duration(incl[key]),
+                                Exclusive: time.Duration(excl[key]) }
+        if c := p.predicates[key]; c != nil {
+            pp.Calls, pp.Exits, pp.Redos, pp.Fails =
+                c.calls, c.exits, c.redos(), c.fails
+        }
+        profiles = append(profiles, pp)
+    }
+    sort.Slice(profiles, func(i, j int) bool {
+        if profiles[i].Inclusive != profiles[j].Inclusive {
+            return profiles[i].Inclusive > profiles[j].Inclusive
+        }
+        return profiles[i].Key < profiles[j].Key
+    })
+    return profiles
+} // Predicates
+
+// Clauses - returns the profiles of the clauses, sorted by predicate
+// (as for Predicates()), then by clause number.
+func (p *Profiler) Clauses() []ClauseProfile {
+    order := map[string]int{}
+    for i, pp := range p.Predicates() { order[pp.Key] = i }
+
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+    _, _, incl, excl := p.times()
+    ids := map[clauseId]bool{}
+    for id := range p.clauses { ids[id] = true }
+    for id := range incl { ids[id] = true }
+    profiles := []ClauseProfile{}
+    for id := range ids {
+        cp := ClauseProfile{ Key: id.key, Clause: id.clause,
+                             Inclusive: time.Duration(incl[id]),
+                             Exclusive: time.Duration(excl[id]) }
+        if rule := p.rules[id]; rule != nil {
+            cp.File, cp.Line = rule.source.File, rule.source.Line
+        }
+        if c := p.clauses[id]; c != nil {
+            cp.Calls, cp.Exits, cp.Redos, cp.Fails =
+                c.calls, c.exits, c.redos(), c.fails
+        }
+        profiles = append(profiles, cp)
+    }
+    sort.Slice(profiles, func(i, j int) bool {
+        a, b := profiles[i], profiles[j]
+        if a.Key != b.Key { return order[a.Key] < order[b.Key] }
+        return a.Clause < b.Clause
+    })
+    return profiles
+} // Clauses
+
+// Table - formats the profile as a table. Predicates are sorted by
+// inclusive time. The clauses of each predicate follow it.
+func (p *Profiler) Table() string {
+    clauses := map[string][]ClauseProfile{}
+    for _, cp := range p.Clauses() {
+        clauses[cp.Key] = append(clauses[cp.Key], cp)
+    }
+    var sb strings.Builder
+    format := "%-30v %9v %9v %9v %9v %12v %12v\n"
+    sb.WriteString(fmt.Sprintf(format, "Predicate", "Calls", "Exits",
+                               "Redos", "Fails", "Inclusive", "Exclusive"))
+    for _, pp := range p.Predicates() {
+        sb.WriteString(fmt.Sprintf(format, pp.Key, pp.Calls, pp.Exits,
+                       pp.Redos, pp.Fails, roundDuration(pp.Inclusive),
+                       roundDuration(pp.Exclusive)))
+        for _, cp := range clauses[pp.Key] {
+            name := fmt.Sprintf("  clause %v", cp.Clause)
+            if cp.Line > 0 { name += fmt.Sprintf(" (line %v)", cp.Line) }
+            sb.WriteString(fmt.Sprintf(format, name, cp.Calls, cp.Exits,
+                           cp.Redos, cp.Fails, roundDuration(cp.Inclusive),
+                           roundDuration(cp.Exclusive)))
+        }
+    }
+    return sb.String()
+} // Table
+
+// roundDuration - rounds a duration for display.
+func roundDuration(d time.Duration) time.Duration {
+    return d.Round(time.Microsecond)
+}
+
+//----------------------------------------------------------------
+// pprof format (profile.proto, compressed with gzip)
+//----------------------------------------------------------------
+
+// protoBuffer - encodes the fields of a protocol buffer message.
+type protoBuffer struct {
+    bytes.Buffer
+}
+
+func (b *protoBuffer) varint(x uint64) {
+    for x >= 0x80 {
+        b.WriteByte(byte(x) | 0x80)
+        x >>= 7
+    }
+    b.WriteByte(byte(x))
+}
+
+// integer - writes a varint field. Zero is omitted.
+func (b *protoBuffer) integer(field int, x int64) {
+    if x == 0 { return }
+    b.varint(uint64(field) << 3)
+    b.varint(uint64(x))
+}
+
+// bytesField - writes a length-delimited field.
+func (b *protoBuffer) bytesField(field int, data []byte) {
+    b.varint(uint64(field) << 3 | 2)
+    b.varint(uint64(len(data)))
+    b.Write(data)
+}
+
+// packed - writes a packed repeated varint field.
+func (b *protoBuffer) packed(field int, values []int64) {
+    var p protoBuffer
+    for _, v := range values { p.varint(uint64(v)) }
+    b.bytesField(field, p.Bytes())
+}
+
+// WritePprof - writes the profile in the format of pprof. Each sample
+// is a stack of predicates, with the number of steps and their time.
+// Param:  writer
+// Return: error
+func (p *Profiler) WritePprof(w io.Writer) error {
+
+    p.mutex.Lock()
+    strs := []string{ "" }
+    strIndex := map[string]int64{ "": 0 }
+    str := func(s string) int64 {
+        if i, ok := strIndex[s]; ok { return i }
+        strIndex[s] = int64(len(strs))
+        strs = append(strs, s)
+        return strIndex[s]
+    }
+
+    var out protoBuffer
+    valueType := func(field int, typ, unit string) {
+        var vt protoBuffer
+        vt.integer(1, str(typ))
+        vt.integer(2, str(unit))
+        out.bytesField(field, vt.Bytes())
+    }
+    valueType(1, "steps", "count")
+    valueType(1, "time", "nanoseconds")
+
+    functions := map[string]int64{}
+    locations := map[clauseId]int64{}
+    var functionData, locationData protoBuffer
+
+    // location - gets the id of the location of a node.
+    location := func(id clauseId) int64 {
+        if loc, ok := locations[id]; ok { return loc }
+        fn, ok := functions[id.key]
+        if !ok {
+            fn = int64(len(functions) + 1)
+            functions[id.key] = fn
+            var f protoBuffer
+            f.integer(1, fn)
+            f.integer(2, str(id.key))
+            f.integer(3, str(id.key))
+            if rule := p.rules[clauseId{ id.key, 1 }]; rule != nil {
+                f.integer(4, str(rule.source.File))
+            }
+            functionData.bytesField(5, f.Bytes())
+        }
+        loc := int64(len(locations) + 1)
+        locations[id] = loc
+        var line protoBuffer
+        line.integer(1, fn)
+        if rule := p.rules[id]; rule != nil {
+            line.integer(2, int64(rule.source.Line))
+        }
+        var l protoBuffer
+        l.integer(1, loc)
+        l.bytesField(4, line.Bytes())
+        locationData.bytesField(4, l.Bytes())
+        return loc
+    }
+
+    var visit func(node *profileNode)
+    visit = func(node *profileNode) {
+        if node.nanos > 0 && node != p.root {
+            stack := []int64{}
+            for n := node; n != p.root; n = n.parent {
+                stack = append(stack, location(n.id))
+            }
+            var sample protoBuffer
+            sample.packed(1, stack)
+            sample.packed(2, []int64{ node.steps, node.nanos })
+            out.bytesField(2, sample.Bytes())
+        }
+        for _, child := range node.children { visit(child) }
+    }
+    visit(p.root)
+
+    out.Write(locationData.Bytes())
+    out.Write(functionData.Bytes())
+    duration := p.duration
+    if p.running { duration = time.Since(p.started) }
+    started := p.started.UnixNano()
+    p.mutex.Unlock()
+
+    for _, s := range strs { out.bytesField(6, []byte(s)) }
+    out.integer(9, started)
+    out.integer(10, int64(duration))
+
+    zw := gzip.NewWriter(w)
+    if _, err := zw.Write(out.Bytes()); err != nil { return err }
+    return zw.Close()
+
+} // WritePprof
+
+//----------------------------------------------------------------
+// Built-in predicate: profile/1
+//----------------------------------------------------------------
+
+type ProfileStruct BuiltInPredicateStruct
+
+// ProfilePredicate - creates the predicate profile(Goal), which runs
+// Goal once with a new profiler, and prints the table of results.
+func ProfilePredicate(arguments ...Unifiable) ProfileStruct {
+    if len(arguments) != 1 {
+        panic("profile - This predicate requires 1 argument.")
+    }
+    return ProfileStruct{ Name: "profile", Arguments: arguments }
+}
+
+// GetSolver - gets a solution node for this predicate.
+// This function satisfies the Goal interface.
+func (s ProfileStruct) GetSolver(kb KnowledgeBase,
+                                 parentSolution SubstitutionSet,
+                                 parentNode SolutionNode) SolutionNode {
+    node := ProfileSolutionNodeStruct{
+                SolutionNodeStruct: MakeSolutionNode(s, kb,
+                                        parentSolution, parentNode),
+                moreSolutions: true,
+            }
+    return &node
+}
+
+// RecreateVariables - Refer to comments in expression.go.
+func (s ProfileStruct) RecreateVariables(vars VarMap) Expression {
+    bip := BuiltInPredicateStruct(s).RecreateVariables(vars)
+    return Expression(ProfileStruct(*bip))
+}
+
+// ReplaceVariables - Refer to comments in expression.go.
+func (s ProfileStruct) ReplaceVariables(ss SubstitutionSet) Expression {
+    return BuiltInPredicateStruct(s).ReplaceVariables(ss)
+}
+
+// String - creates a string representation.
+func (s ProfileStruct) String() string {
+    return BuiltInPredicateStruct(s).String()
+}
+
+type ProfileSolutionNodeStruct struct {
+    SolutionNodeStruct
+    moreSolutions bool
+}
+
+// NextSolution - runs the goal once, with a profiler. The profiler
+// which was active before is restored afterwards.
+// This function satisfies the SolutionNode interface.
+func (sn *ProfileSolutionNodeStruct) NextSolution() (SubstitutionSet, bool) {
+    if sn.NoBackTracking || !sn.moreSolutions { return nil, false }
+    sn.moreSolutions = false  // Only one solution.
+    goal := sn.Goal.(ProfileStruct)
+    g := termToGoal(goal.Arguments[0], sn.ParentSolution)
+
+    p := makeProfiler()
+    previous := setProfiler(p)
+    node := g.GetSolver(sn.KnowledgeBase, sn.ParentSolution, sn)
+    solution, found := node.NextSolution()
+    setProfiler(previous)
+    p.stopped()
+
+    fmt.Print(p.Table())
+    return solution, found
+}
+
+// SetNoBackTracking - set the NoBackTracking flag,
+// which is used to implement Cuts.
+// This function satisfies the SolutionNode interface.
+func (sn *ProfileSolutionNodeStruct) SetNoBackTracking() {
+    sn.NoBackTracking = true
+}
+
+// GetParentNode
+func (sn *ProfileSolutionNodeStruct) GetParentNode() SolutionNode {
+    return sn.ParentNode
+}
@@ -17,8 +17,39 @@ import (
 type RuleStruct struct {
     head Complex
     body Goal
+    ground bool  // fact without variables (see intern.go)
+    source SourceLocation  // where the rule begins, if loaded from a file
+    module string  // module of the rule, or "" (see module.go)
+    id uint64  // identifies the clause, when it is added to a knowledge base
 }
 
+// SourceLocation - the file, line and column where a rule begins.
+// Lines and columns are numbered from 1. Terms do not record their
+// locations; the goals of a rule share the location of the rule.
+type SourceLocation struct {
+    File    string  // source file, or ""
+    Line    int     // line, or 0 if unknown
+    Column  int     // column, in characters
+}
+
+// String - formats a source location, eg. kings.txt:28:1
+// Returns an empty string if the location is unknown.
+func (s SourceLocation) String() string {
+    if s.Line == 0 { return "" }
+    location := fmt.Sprintf("%v:%v", s.Line, s.Column)
+    if s.File == "" { return location }
+    return s.File + ":" + location
+}
+
+// Source - returns the location of the rule in its source file.
+// If the rule was not loaded by LoadKBFromFile(), the location
+// is unknown (line 0).
+func (r RuleStruct) Source() SourceLocation { return r.source }
+
+// Module - returns the name of the module which defines the rule,
+// or an empty string if the rule is not in a module.
+func (r RuleStruct) Module() string { return r.module }
+
 // Rule - Factory function to create a Rule.
 func Rule(head Complex, body Goal) RuleStruct {
     return RuleStruct{ head: head, body: body }
@@ -29,56 +60,6 @@ func Fact(head Complex) RuleStruct {
     return RuleStruct{ head: head, body: nil }
 }
 
-// ParseRule - create a fact or rule from a string representation.
-// Examples of usage:
-//    c := ParseRule("male(Harold).")
-//    c := ParseRule("father($X, $Y) :- parent($X, $Y), male($X).")
-func ParseRule(str string) (RuleStruct, error) {
-
-    s := strings.TrimSpace(str)
-    length := len(s)
-    if length < 4 {
-        err := fmt.Errorf("ParseRule() - Invalid string. >%v<\n", s)
-        return RuleStruct{}, err
-    }
-
-    // Remove final period.
-    ch := s[length - 1:]
-    if ch == "." {
-        s = s[0: length - 1]
-        length = length - 1
-    }
-    index := strings.Index(s, ":-")
-
-    if (index > -1) {
-
-        strHead := s[0: index]
-        strBody := s[index + 2:]
-
-        // Make sure there is not a second ':-'.
-        index2 := strings.Index(strBody, ":-")
-        if index2 >= 0 {
-            err := fmt.Errorf("ParseRule() - Invalid rule.\n%v", s)
-            return RuleStruct{}, err
-        }
-
-        head, err := ParseSubgoal(strHead)
-        if err != nil {
-            return RuleStruct{}, err
-        }
-        
-        body, err := generateGoal(strBody)
-        return RuleStruct{head.(Complex), body}, err
-
-    } else { // Must be a fact (no body).
-        head, err := ParseComplex(s)
-        rule := RuleStruct{ head: head, body: nil }
-        return rule, err
-    }
-
-} // ParseRule
-
-
 // Key - generates a key from the head term.
 // Eg. loves(Chandler, Monica) --> loves/2
 func (r RuleStruct) Key() string {
@@ -101,7 +82,8 @@ func (r RuleStruct) RecreateVariables(vars VarMap) Expression {
     if r.body != nil {
         newBody = r.body.RecreateVariables(vars).(Goal)
     }
-    return RuleStruct{ head: newHead, body: newBody }
+    return RuleStruct{ head: newHead, body: newBody, source: r.source,
+                       module: r.module, id: r.id }
 } // RecreateVariables
 
 // ReplaceVariables - replaces a bound variable with its binding.
@@ -1,279 +1,230 @@
 package suiron
 
-// RuleReader - reads Suiron facts and rules from a file.
+// RuleReader - reads Suiron facts and rules from a file, a reader
+// (io.Reader) or a file system (fs.FS). The facts and rules are
+// parsed by the parser in parser.go.
+//
+// Files are not read into memory all at once. They are read line by
+// line, and parsed clause by clause, so that very large files of facts
+// can be loaded. Lines are collected until they end with a complete
+// clause (a rule, fact or directive), and there are enough of them to
+// parse (chunkSize). Then the clauses are parsed, and their text is
+// discarded. A clause ends with a period which is not in
+// parentheses, brackets or quotes, or with a period at the end of a
+// line. (See skipRule() in lexer.go.)
 //
 // Cleve Lendon
 
 import (
     "bufio"
     "fmt"
+    "io"
+    "io/fs"
     "os"
     "strings"
+    "unicode"
 )
 
-// separateRules - divides a text string to create an array of rules.
-// Each rule or fact ends with a period.
-//
-// Params:
-//    original text
-// Return
-//    rules (slice)
-//    error
-func separateRules(text string) ([]string, error) {
+// chunkSize - clauses are parsed in chunks of about this size (bytes).
+const chunkSize = 64 * 1024
 
-    var str string
-    var sb strings.Builder
-    rules := []string{}
+// clauseReader - reads a text by complete clauses.
+type clauseReader struct {
+    in        *bufio.Reader
+    line      int    // number of the next line
+    depth     int    // depth of parentheses and brackets
+    complete  bool   // the text read so far ends with a complete clause
+}
 
-    roundDepth  := 0
-    squareDepth := 0
-    quoteDepth  := 0
+// makeClauseReader - makes a clauseReader for the given reader.
+func makeClauseReader(r io.Reader) *clauseReader {
+    return &clauseReader{ in: bufio.NewReader(r), line: 1, complete: true }
+}
 
-    for _, ch := range text {
-        sb.WriteRune(ch)
-        if ch == '.' && roundDepth == 0 &&
-           squareDepth == 0 && quoteDepth % 2 == 0 {
-            str = sb.String()
-            rules = append(rules, str)
-            sb.Reset()
-        } else if ch == '(' {
-            roundDepth++
-        } else if ch == '[' {
-            squareDepth++
-        } else if ch == ')' {
-            roundDepth--
-        } else if ch == ']' {
-            squareDepth--
-        } else if ch == '"' {
-            quoteDepth++
+// next - reads lines until they end with a complete clause, and
+// there are at least chunkSize bytes.
+// Return: text of one or more clauses
+//         number of the first line of the text
+//         error, or io.EOF at the end of the text
+func (cr *clauseReader) next() (string, int, error) {
+    var sb strings.Builder
+    first := cr.line
+    for {
+        line, err := cr.in.ReadString('\n')
+        if len(line) > 0 {
+            sb.WriteString(line)
+            cr.line++
+            cr.scanLine([]rune(line))
+        }
+        if err != nil { return sb.String(), first, err }
+        if cr.complete && cr.depth <= 0 && sb.Len() >= chunkSize {
+            return sb.String(), first, nil
         }
-    } // for
-
-    // Check for unmatched brackets here.
-    err := unmatchedBracket(str, roundDepth, squareDepth)
-
-    return rules, err
-
-} // separateRules
-
-
-// unmatchedBracket - checks for an unmatched bracket. If there is an
-// unmatched bracket, returns an error. Otherwise returns nil.
-// If there is no error, return nil.
-// Params:  previous string, for context
-//          depth of round brackets (int)
-//          depth of square brackets (int)
-// Return:  error or nil
-func unmatchedBracket(str string, roundDepth int, squareDepth int) error {
-
-    // If no error, return nil.
-    if roundDepth == 0 && squareDepth == 0 { return nil }
-
-    var msg  string
-    var msg2 string
-
-    if roundDepth > 0 {
-        msg = "unmatched parenthesis: (\n"
-    } else if roundDepth < 0 {
-        msg = "unmatched parenthesis: )\n"
-    } else if squareDepth > 0 {
-        msg = "unmatched bracket: [\n"
-    } else if squareDepth < 0 {
-        msg = "unmatched bracket: ]\n"
     }
-
-    if len(str) == 0 {
-        msg2 = "Check start of file."
-    } else {
-        if len(str) > 60 {
-            str = str[0: 60]
+} // next
+
+// scanLine - finds whether a line ends with a complete clause.
+// Parentheses and brackets are counted, as in skipRule().
+func (cr *clauseReader) scanLine(line []rune) {
+    lx := &lexer{ runes: line }
+    quoted := false
+    for i := 0; i < len(line); i++ {
+        ch := line[i]
+        switch {
+        case quoted:
+            if ch == '\\' {
+                i++
+            } else if ch == '"' {
+                quoted = false
+            }
+        case unicode.IsSpace(ch):
+        case cr.depth <= 0 && lx.commentAt(i):
+            return
+        case lx.endAt(i) && (cr.depth <= 0 || lx.lineEndAt(i + 1)):
+            cr.complete, cr.depth = true, 0
+        default:
+            cr.complete = false
+            switch ch {
+            case '\\':
+                i++
+            case '"':
+                quoted = true
+            case '(', '[':
+                cr.depth++
+            case ')', ']':
+                cr.depth--
+            }
         }
-        msg2 = "Error occurs after: " + str
     }
-    return fmt.Errorf("Error - " + msg + msg2)
-
-} // unmatchedBracket
-
-
-// stripComments - strips comments from a line. In Suiron, valid
-// comment delimiters are:
-//
-//   %  Comment
-//   #  Comment
-//   // Comment
-//
-// Any text which occurs after these delimiters is considered
-// a comment, and removed from the line. But, if these delimiters
-// occur within braces, they are not treated as comment delimiters.
-// For example, in the line
-//
-//    print(Your rank is %s., $Rank),   % Print user's rank.
-//
-// the first percent sign does not start a comment, but the second
-// one does.
-//
-// Params:
-//    original line
-// Return:
-//    trimmed line
-//
-func stripComments(line string) string {
-
-    previous    := 'x'
-    roundDepth  := 0
-    squareDepth := 0
-
-    index := -1
-    for i, ch := range line {
-        if ch == '(' {
-            roundDepth++
-        } else if ch == '[' {
-            squareDepth++
-        } else if ch == ')' {
-            roundDepth--
-        } else if ch == ']' {
-            squareDepth--
-        } else if roundDepth == 0 && squareDepth == 0 {
-            if ch == '#' || ch == '%' {
-                index = i
-                break
-            } else if ch == '/' && previous == '/' {
-               index = i - 1
-               break
+} // scanLine
+
+// parseStream - parses a text clause by clause, as it is read.
+// If there is a loader, the rules of each chunk go to the loader,
+// and are not returned. Otherwise, the rules are returned with their
+// source text.
+// Params: reader
+//         file name, for errors and source locations
+//         loader for directives, or nil
+// Return: rules
+//         source text of each rule
+//         syntax errors
+//         error, if the text cannot be read
+func parseStream(r io.Reader, fileName string,
+                 l *loader) ([]RuleStruct, []string, ParseErrors, error) {
+    rules := []RuleStruct{}
+    texts := []string{}
+    errors := ParseErrors{}
+    cr := makeClauseReader(r)
+    ops := globalOperators()
+    if l != nil { ops = l.ops }
+    for {
+        text, line, err := cr.next()
+        if strings.TrimSpace(text) != "" {
+            p := makeParser(text, fileName, ops)
+            p.lx.firstLine = line
+            p.loader = l
+            p.keepTexts = l == nil
+            r, tx := p.parseProgram()
+            if l != nil {
+                l.add(r)
+            } else {
+                rules = append(rules, r...)
+                texts = append(texts, tx...)
             }
+            errors = append(errors, p.errors...)
         }
-        previous = ch
-    } // for
-
-    if index >= 0 {
-        return strings.TrimSpace(line[0: index])
-    } else {
-        return strings.TrimSpace(line)
+        if err == io.EOF { return rules, texts, errors, nil }
+        if err != nil { return rules, texts, errors, err }
     }
-
-}  // stripComments
-
+} // parseStream
 
 // ReadFactsAndRules - reads Suiron facts and rules from a text file.
-// Strips out all comments. (Comments are preceded by #, % or // .)
+// Comments between rules are removed. (Comments are preceded by #, %
+// or // .)
 // Param:  file name
 // Return: array (slice) of rules
 //         error
 func ReadFactsAndRules(fileName string) ([]string, error) {
-
     file, err := os.Open(fileName)
     if err != nil { return []string{}, err }
     defer file.Close()
-
-    var sb strings.Builder
-    scanner := bufio.NewScanner(file)
-
-    lineNum := 1
-    for scanner.Scan() {
-        aLine := scanner.Text()
-        strippedLine := stripComments(aLine)
-        if len(strippedLine) > 0 {
-            err := checkEndOfLine(strippedLine, lineNum)
-            if err != nil { return []string{}, err }
-            sb.WriteString(strippedLine)
-            sb.WriteString(" ")
-        }
-        lineNum++
-    }
-
-    roolz, err := separateRules(sb.String())
-    return roolz, err
-
+    _, roolz, errors, err := parseStream(file, fileName, nil)
+    if err != nil { return roolz, err }
+    if len(errors) > 0 { return roolz, errors }
+    return roolz, nil
 } // ReadFactsAndRules
 
-
 // StringToRules - Divides string into an array of facts and rules.
-// Strips out all comments. (Comments are preceded by #, % or // .)
+// Comments between rules are removed. (Comments are preceded by #, %
+// or // .)
 //
 // Param:  string
 // Return: array (slice) of facts and rules
 //         error
 func StringToRules(str string) ([]string, error) {
-
-    var sb strings.Builder
-    scanner := bufio.NewScanner(strings.NewReader(str))
-
-    lineNum := 1
-    for scanner.Scan() {
-        aLine := scanner.Text()
-        strippedLine := stripComments(aLine)
-        if len(strippedLine) > 0 {
-            err := checkEndOfLine(strippedLine, lineNum)
-            if err != nil { return []string{}, err }
-            sb.WriteString(strippedLine)
-            sb.WriteString(" ")
-        }
-        lineNum++
-    }
-
-    roolz, err := separateRules(sb.String())
-    return roolz, err
-
+    p := makeParser(str, "", globalOperators())
+    p.keepTexts = true
+    _, roolz := p.parseProgram()
+    if len(p.errors) > 0 { return roolz, p.errors }
+    return roolz, nil
 } // StringToRules
 
-
-// checkEndOfLine - checks to ensure that a line read from a file
-// ends with a valid character. Why?
-// Rules and facts can be split over several lines. For example,
-// it is valid to write a rule as:
-//
-//   parse($In, $Out) :-
-//         words_to_pos($In, $In2),
-//         remove_punc($In2, $In3),
-//         sentence($In3, $Out).
-//
-// The lines above end in dash, comma, comma and period.
-// If a line were a simple word, such as:
+// LoadKBFromFile - reads rules and facts from a text file, parses
+// them, then adds them to the knowledge base. If there are syntax
+// errors, all of them are returned (as ParseErrors), and nothing is
+// added to the knowledge base. Each error begins with its location,
+// eg. kings.txt:28:17, and shows the line where it occurs.
+// The location of each rule is recorded. (See RuleStruct.Source().)
+// The file may load other files, and run initialization goals, with
+// directives. (See consult.go.)
 //
-//   sentence
+// Params:  knowledge base
+//          filename
+// Return:  error or nil
 //
-// That would indicate an error in the source.
+func LoadKBFromFile(kb KnowledgeBase, fileName string) error {
+    l := makeLoader(nil)
+    return l.run(kb, func() (ParseErrors, error) { return l.load(fileName) })
+} // LoadKBFromFile
+
+// LoadKB - reads rules and facts from a reader, and adds them to the
+// knowledge base. The name is used in errors and source locations.
+// Files loaded by directives are found relative to the directory of
+// the name. Otherwise, this function is the same as LoadKBFromFile().
+// Example:
+//     err := LoadKB(kb, os.Stdin, "stdin")
 //
-// Currently, valid characters are dash, comma, semicolon,
-// period and the equal sign.
+// Params:  knowledge base
+//          reader
+//          name of text
+// Return:  error or nil
 //
-// Params: line of text to check
-//         number of line to check
-// Return: error or nil
-func checkEndOfLine(line string, num int) error {
-    length := len(line)
-    if length > 0 {
-        last := line[length - 1:]
-        if last != "-" && last != "," &&
-           last != ";" && last != "." && last != "=" {
-            return fmt.Errorf("Check line %d: %v", num, line)
-        }
-    }
-    return nil
-}
+func LoadKB(kb KnowledgeBase, r io.Reader, name string) error {
+    l := makeLoader(nil)
+    return l.run(kb, func() (ParseErrors, error) {
+        return l.loadReader(r, name)
+    })
+} // LoadKB
 
-// LoadKBFromFile - reads rules and facts from a text file, parses
-// them, then adds them to the knowledge base. If a parsing error is
-// generated, add the previous line to the error message.
+// LoadKBFS - reads rules and facts from a file in a file system,
+// such as an embedded file system (embed.FS), and adds them to the
+// knowledge base. Paths are slash-separated, as in fs.FS. Otherwise,
+// this function is the same as LoadKBFromFile(). Example:
+//
+//     //go:embed rules
+//     var rules embed.FS
+//     ...
+//     err := LoadKBFS(kb, rules, "rules/kings.txt")
 //
 // Params:  knowledge base
-//          filename
+//          file system
+//          path of file
 // Return:  error or nil
 //
-func LoadKBFromFile(kb KnowledgeBase, fileName string) error {
-    factsAndRules, err := ReadFactsAndRules(fileName)
-    if err != nil { return err }
-    var previous string
-    for _, str := range factsAndRules {
-        factOrRule, err := ParseRule(str)
-        if err != nil {
-            return LoadParseError(previous, err)
-        }
-        kb.Add(factOrRule)
-        previous = str
-    }
-    return nil
-} // LoadKBFromFile
+func LoadKBFS(kb KnowledgeBase, fsys fs.FS, fileName string) error {
+    l := makeLoader(fsys)
+    return l.run(kb, func() (ParseErrors, error) { return l.load(fileName) })
+} // LoadKBFS
 
 // LoadParseError - If a parse error occurs while loading rules,
 // this function adds the previous line for context.
@@ -282,9 +233,9 @@ func LoadKBFromFile(kb KnowledgeBase, fileName string) error {
 // Return: new error
 func LoadParseError(previous string, err error) error {
     strError := err.Error()
-    if len(previous) == 0 {
+    if len(strings.TrimSpace(previous)) == 0 {
         return fmt.Errorf(strError + "Check start of file.")
     } else {
         return fmt.Errorf(strError + "Error occurs after: " + previous)
-    } 
+    }
 } // LoadParseError
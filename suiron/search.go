@@ -0,0 +1,465 @@
+package suiron
+
+// Search - strategies for searching the knowledge space.
+//
+// By default, goals are solved depth-first, left to right, as in Prolog.
+// Depth-first search is incomplete: if a rule recurses forever before
+// reaching a solution, the solution is never found, and the search ends
+// with a time-out. For example:
+//
+//    loop($X) :- loop($X).
+//    loop(a).
+//
+// A search strategy can be given to Solve() and SolveAll():
+//
+//    strategy := DepthLimited(20)
+//    solutions, failure := SolveAll(query, kb, ss, strategy)
+//    if strategy.DepthLimitReached() { ... }
+//
+// The strategies are:
+//
+//    DepthFirst()            - the default
+//    DepthLimited(n)         - depth-first, but goals at depth n fail
+//    IterativeDeepening(n)   - depth-limited searches, with limits of
+//                              1, 2, 3... up to n (0 = no maximum)
+//    BreadthFirst()          - alternatives are tried in the order in
+//                              which they were created (fair search)
+//
+// The depth of a goal is the number of rules which were used to reach
+// it. The goals of the query have depth 0. The limit also applies to
+// the goals inside built-in predicates, such as not(). If the search
+// for the operand of not() reaches the limit, not() fails, because the
+// operand might have succeeded with a higher limit.
+//
+// When no solution is found and the depth limit was reached, the
+// reason for failure is "Depth limit.", rather than "No". If solutions
+// were found, DepthLimitReached() tells whether there may be more.
+//
+// Iterative deepening finds each solution once. A solution is reported
+// by the first iteration which is deep enough to find it. It stops when
+// a search finishes without reaching the limit.
+//
+// Breadth-first search keeps a queue of alternatives. Cuts are supported.
+// A cut discards the alternatives of its rule, and of the goals before
+// it in the rule's body, but a cut is usually a sign that a program was
+// written for depth-first search.
+//
+// A strategy records whether the limit was reached, so a strategy should
+// not be shared by queries which run at the same time.
+//
+// Cleve Lendon
+
+// Kinds of search.
+const (
+    depthFirst = iota
+    depthLimited
+    iterativeDeepening
+    breadthFirst
+)
+
+// SearchStrategy - defines how the knowledge space is searched.
+type SearchStrategy struct {
+    kind      int
+    maxDepth  int   // 0 = no limit
+    limitHit  bool  // true if the depth limit was reached
+}
+
+// DepthFirst - returns the default search strategy.
+func DepthFirst() *SearchStrategy {
+    return &SearchStrategy{ kind: depthFirst }
+}
+
+// DepthLimited - returns a strategy for a depth-first search in which
+// goals at the given depth fail.
+// Param:  maximum depth
+// Return: search strategy
+func DepthLimited(maxDepth int) *SearchStrategy {
+    if maxDepth < 1 { panic("DepthLimited() - Maximum depth must be > 0.") }
+    return &SearchStrategy{ kind: depthLimited, maxDepth: maxDepth }
+}
+
+// IterativeDeepening - returns a strategy for a series of depth-limited
+// searches, with increasing limits.
+// Param:  maximum depth (0 = no maximum)
+// Return: search strategy
+func IterativeDeepening(maxDepth int) *SearchStrategy {
+    if maxDepth < 0 { maxDepth = 0 }
+    return &SearchStrategy{ kind: iterativeDeepening, maxDepth: maxDepth }
+}
+
+// BreadthFirst - returns a strategy for a breadth-first search.
+// Param:  maximum depth (optional)
+// Return: search strategy
+func BreadthFirst(maxDepth ...int) *SearchStrategy {
+    strategy := &SearchStrategy{ kind: breadthFirst }
+    if len(maxDepth) > 0 && maxDepth[0] > 0 { strategy.maxDepth = maxDepth[0] }
+    return strategy
+}
+
+// DepthLimitReached - returns true if the last search with this
+// strategy reached the depth limit. Some solutions may not have
+// been found.
+func (s *SearchStrategy) DepthLimitReached() bool { return s.limitHit }
+
+// String - returns the name of the strategy, for display.
+func (s *SearchStrategy) String() string {
+    switch s.kind {
+    case depthLimited:
+        return "depth limited"
+    case iterativeDeepening:
+        return "iterative deepening"
+    case breadthFirst:
+        return "breadth first"
+    }
+    return "depth first"
+}
+
+// getSolver - returns the root solution node for a query.
+// Params: query
+//         knowledge base
+//         substitution set
+// Return: solution node
+func (s *SearchStrategy) getSolver(query Complex, kb KnowledgeBase,
+                                   ss SubstitutionSet) SolutionNode {
+    s.limitHit = false
+    switch s.kind {
+    case depthLimited:
+        return makeDepthLimitedNode(query, kb, ss, s.maxDepth, 0, &s.limitHit)
+    case iterativeDeepening:
+        node := iterativeDeepeningNode{
+                    SolutionNodeStruct: MakeSolutionNode(query, kb, ss, nil),
+                    strategy: s,
+                }
+        return &node
+    case breadthFirst:
+        return makeBreadthFirstNode(query, kb, ss, s)
+    }
+    return query.GetSolver(kb, ss, nil)
+}
+
+// failure - returns the reason for failure, when there are no solutions.
+func (s *SearchStrategy) failure() string {
+    if s.limitHit { return "Depth limit." }
+    return "No"
+}
+
+// makeDepthLimitedNode - makes an engine node which limits the depth.
+// Params: query
+//         knowledge base
+//         substitution set
+//         maximum depth
+//         minimum depth of proofs
+//         flag to set when the limit is reached
+// Return: solution node
+func makeDepthLimitedNode(query Complex, kb KnowledgeBase,
+                          ss SubstitutionSet, maxDepth int, minDepth int,
+                          limitHit *bool) *EngineSolutionNodeStruct {
+    node := EngineSolutionNodeStruct{
+                SolutionNodeStruct: MakeSolutionNode(query, kb, ss, nil),
+                maxDepth: maxDepth,
+                minDepth: minDepth,
+                limitHit: limitHit,
+            }
+    return &node
+}
+
+//----------------------------------------------------------------
+// Iterative deepening
+//----------------------------------------------------------------
+
+// iterativeDeepeningNode - runs depth-limited searches, with increasing
+// limits. Each search reports only the proofs which are deeper than the
+// limit of the previous search.
+type iterativeDeepeningNode struct {
+    SolutionNodeStruct
+    strategy  *SearchStrategy
+    limit     int
+    search    *EngineSolutionNodeStruct
+    limitHit  bool   // the current search reached its limit
+}
+
+// NextSolution - initiates or continues the search for a solution.
+// This function satisfies the SolutionNode interface.
+func (n *iterativeDeepeningNode) NextSolution() (SubstitutionSet, bool) {
+    if n.NoBackTracking { return nil, false }
+    for {
+        if n.search == nil {
+            n.limit++
+            n.limitHit = false
+            n.search = makeDepthLimitedNode(n.Goal.(Complex), n.KnowledgeBase,
+                           n.ParentSolution, n.limit, n.limit - 1, &n.limitHit)
+            n.search.stop = n.stop
+        }
+        solution, found := n.search.NextSolution()
+        if found { return solution, true }
+        if n.stopped() || !n.limitHit { return nil, false }
+        if n.strategy.maxDepth > 0 && n.limit >= n.strategy.maxDepth {
+            n.strategy.limitHit = true
+            return nil, false
+        }
+        n.search = nil
+    }
+} // NextSolution
+
+// SetNoBackTracking - set the NoBackTracking flag.
+func (n *iterativeDeepeningNode) SetNoBackTracking() {
+    n.NoBackTracking = true
+}
+
+// GetParentNode
+func (n *iterativeDeepeningNode) GetParentNode() SolutionNode {
+    return n.ParentNode
+}
+
+//----------------------------------------------------------------
+// Breadth-first search
+//----------------------------------------------------------------
+
+// A cut barrier belongs to one call of a goal. Each rule which matches
+// the goal is a branch of the barrier. When the body of a rule executes
+// a cut, the later branches are cut off, and so are the alternatives in
+// its own branch which existed before the cut.
+type cutBarrier struct {
+    cut     bool
+    branch  int   // branch which executed the cut
+    serial  int   // serial number of the search state at the cut
+}
+
+// bfsBranch - a list of the branches which lead to a search state.
+type bfsBranch struct {
+    barrier  *cutBarrier
+    branch   int
+    next     *bfsBranch
+}
+
+// bfsFrame - one goal of the continuation.
+type bfsFrame struct {
+    goal     Goal
+    barrier  *cutBarrier   // for cut
+    depth    int
+    next     *bfsFrame
+}
+
+// bfsState - a state of the search, which waits in the queue.
+type bfsState struct {
+    continuation  *bfsFrame
+    ss            SubstitutionSet
+    path          *bfsBranch
+    serial        int
+    node          SolutionNode  // built-in predicate with more solutions
+}
+
+// BreadthFirstSolutionNodeStruct - solution node for breadth-first search.
+type BreadthFirstSolutionNodeStruct struct {
+    SolutionNodeStruct
+    strategy  *SearchStrategy
+    queue     []*bfsState
+    serial    int
+}
+
+// makeBreadthFirstNode - makes a solution node for a breadth-first search.
+func makeBreadthFirstNode(query Complex, kb KnowledgeBase,
+                          ss SubstitutionSet,
+                          strategy *SearchStrategy) SolutionNode {
+    root := &cutBarrier{}
+    node := BreadthFirstSolutionNodeStruct{
+                SolutionNodeStruct: MakeSolutionNode(query, kb, ss, nil),
+                strategy: strategy,
+            }
+    node.enqueue(&bfsState{
+        continuation: &bfsFrame{ goal: query, barrier: root },
+        ss: ss,
+        path: &bfsBranch{ barrier: root },
+    })
+    return &node
+}
+
+// NextSolution - continues the search for a solution.
+// This function satisfies the SolutionNode interface.
+func (n *BreadthFirstSolutionNodeStruct) NextSolution() (SubstitutionSet, bool) {
+    if n.NoBackTracking { return nil, false }
+    for !n.stopped() {
+        state := n.dequeue()
+        if state == nil { return nil, false }
+        if solution, found := n.run(state); found { return solution, true }
+    }
+    return nil, false
+}
+
+// SetNoBackTracking - set the NoBackTracking flag.
+func (n *BreadthFirstSolutionNodeStruct) SetNoBackTracking() {
+    n.NoBackTracking = true
+}
+
+// GetParentNode
+func (n *BreadthFirstSolutionNodeStruct) GetParentNode() SolutionNode {
+    return n.ParentNode
+}
+
+// enqueue - gives a serial number to a state, and adds it to the queue.
+func (n *BreadthFirstSolutionNodeStruct) enqueue(state *bfsState) {
+    state.serial = n.serial
+    n.serial++
+    n.queue = append(n.queue, state)
+}
+
+// dequeue - removes the first state from the queue. States which
+// were cut off are discarded.
+// Return: state, or nil if the queue is empty
+func (n *BreadthFirstSolutionNodeStruct) dequeue() *bfsState {
+    for len(n.queue) > 0 {
+        state := n.queue[0]
+        n.queue[0] = nil
+        n.queue = n.queue[1:]
+        if !state.isCut() { return state }
+    }
+    return nil
+}
+
+// isCut - returns true if a cut has discarded this state.
+func (state *bfsState) isCut() bool {
+    for b := state.path; b != nil; b = b.next {
+        barrier := b.barrier
+        if !barrier.cut { continue }
+        if b.branch > barrier.branch { return true }
+        if b.branch == barrier.branch && state.serial < barrier.serial {
+            return true
+        }
+    }
+    return false
+}
+
+// run - solves the goals of a state, until a solution is found, or the
+// state fails, or the search branches. Alternatives are put in the queue.
+// Param:  state
+// Return: solution
+//         success flag
+func (n *BreadthFirstSolutionNodeStruct) run(state *bfsState) (SubstitutionSet, bool) {
+
+    continuation := state.continuation
+    ss := state.ss
+
+    // A built-in predicate which may have more solutions.
+    if state.node != nil {
+        solution, found := state.node.NextSolution()
+        if !found { return nil, false }
+        n.enqueue(&bfsState{ continuation: continuation, ss: ss,
+                             path: state.path, node: state.node })
+        ss = solution
+    }
+
+    for !n.stopped() {
+
+        // Goals woken up by the last step (see suspension.go).
+        // A cut in a woken goal is local to that goal.
+        if woken, newSS := ss.takeWokenGoals(); len(woken) > 0 {
+            ss = newSS
+            depth := 0
+            if continuation != nil { depth = continuation.depth }
+            continuation = pushBFSGoals(woken, &cutBarrier{}, depth,
+                                        continuation)
+        }
+
+        if continuation == nil { return ss, true }
+
+        frame := continuation
+        continuation = frame.next
+
+        switch goal := frame.goal.(type) {
+        case Complex:
+            n.tryRules(goal, frame.depth, continuation, ss, state.path)
+            return nil, false
+        case AndOp:
+            continuation = pushBFSGoals(goal, frame.barrier, frame.depth,
+                                        continuation)
+        case OrOp:
+            for _, operand := range goal {
+                next := &bfsFrame{ goal: operand, barrier: frame.barrier,
+                                   depth: frame.depth, next: continuation }
+                n.enqueue(&bfsState{ continuation: next, ss: ss,
+                                     path: state.path })
+            }
+            return nil, false
+        case CutOp:
+            barrier := frame.barrier
+            branch := 0
+            for b := state.path; b != nil; b = b.next {
+                if b.barrier == barrier { branch = b.branch; break }
+            }
+            barrier.cut = true
+            barrier.branch = branch
+            barrier.serial = n.serial
+        case FailOp:
+            return nil, false
+        default:
+            if u, ok := goal.(UnifyStruct); ok && u.Name == "unify" {
+                var success bool
+                ss, success = u.Arguments[0].Unify(u.Arguments[1], ss)
+                if !success { return nil, false }
+                continue
+            }
+            var parent SolutionNode
+            if n.strategy.maxDepth > 0 || n.stop != nil {
+                parent = &searchContext{ depth: frame.depth,
+                                         maxDepth: n.strategy.maxDepth,
+                                         limitHit: &n.strategy.limitHit,
+                                         stop: n.stop }
+            }
+            node := goal.GetSolver(n.KnowledgeBase, ss, parent)
+            solution, found := node.NextSolution()
+            if !found { return nil, false }
+            if !hasOneSolution(goal) {
+                n.enqueue(&bfsState{ continuation: continuation, ss: ss,
+                                     path: state.path, node: node })
+            }
+            ss = solution
+        }
+    }
+    return nil, false
+
+} // run
+
+// tryRules - puts a state in the queue for each rule which matches
+// the goal.
+// Params: goal
+//         depth of goal
+//         continuation
+//         substitution set
+//         path of the current state
+func (n *BreadthFirstSolutionNodeStruct) tryRules(goal Complex, depth int,
+                                                  continuation *bfsFrame,
+                                                  ss SubstitutionSet,
+                                                  path *bfsBranch) {
+    kb := n.KnowledgeBase
+    count := kb.getRuleCount(goal)
+    if count > 0 && n.strategy.maxDepth > 0 && depth >= n.strategy.maxDepth {
+        n.strategy.limitHit = true
+        return
+    }
+    rules := kb.rules(goal.Key())
+    barrier := &cutBarrier{}
+    for i := 0; i < count; i++ {
+        rule := fetchRule(rules[i])
+        solution, success := rule.GetHead().Unify(goal, ss)
+        if !success { continue }
+        next := continuation
+        if body := rule.GetBody(); body != nil {
+            next = &bfsFrame{ goal: body, barrier: barrier,
+                              depth: depth + 1, next: continuation }
+        }
+        n.enqueue(&bfsState{
+            continuation: next, ss: solution,
+            path: &bfsBranch{ barrier: barrier, branch: i, next: path },
+        })
+    }
+} // tryRules
+
+// pushBFSGoals - puts a list of goals in front of the continuation.
+func pushBFSGoals(goals []Goal, barrier *cutBarrier, depth int,
+                  continuation *bfsFrame) *bfsFrame {
+    for i := len(goals) - 1; i >= 0; i-- {
+        continuation = &bfsFrame{ goal: goals[i], barrier: barrier,
+                                  depth: depth, next: continuation }
+    }
+    return continuation
+}
@@ -0,0 +1,173 @@
+package suiron
+
+// Snapshot - an immutable copy of a knowledge base, for running many
+// queries concurrently, and a batch API which distributes queries over
+// goroutines.
+//
+//    snapshot := kb.Freeze()
+//    results := snapshot.SolveBatch(queries, BatchOptions{ Workers: 8 })
+//    for i, result := range results {
+//        // result.Solutions, result.Failure are for queries[i]
+//    }
+//
+// A snapshot is compiled (see wam_compile.go), so its predicates are
+// indexed by their first argument. It is never modified, so any number
+// of goroutines can read it.
+//
+// Rules are not copied. The snapshot has its own map, but shares the
+// lists of rules of the knowledge base, up to their current length. Rules are
+// never changed. When facts and rules are added to the live knowledge
+// base, they are appended beyond the end of the snapshot's lists, or
+// into new lists (copy-on-write), so queries running on the snapshot
+// are not disturbed.
+//
+// Cleve Lendon
+
+import (
+    "runtime"
+    "sync"
+    "sync/atomic"
+    "time"
+    "fmt"
+)
+
+// FrozenKB - an immutable, indexed snapshot of a knowledge base.
+type FrozenKB struct {
+    compiled  *CompiledKB
+}
+
+// BatchOptions - options for SolveBatch().
+//    Workers      - number of goroutines (0 = number of CPUs)
+//    MaxSolutions - maximum solutions per query (0 = all)
+type BatchOptions struct {
+    Workers       int
+    MaxSolutions  int
+}
+
+// BatchResult - the solutions of one query of a batch.
+// Failure is as for SolveAll(): "" (success), "No", or another reason.
+type BatchResult struct {
+    Query      Complex
+    Solutions  []Complex
+    Failure    string
+}
+
+// Freeze - makes an immutable snapshot of the knowledge base.
+// Return: frozen knowledge base
+func (kb KnowledgeBase) Freeze() *FrozenKB {
+    flat := kb.Flatten()
+    copyOfKB := make(KnowledgeBase, len(flat))
+    for key, rules := range flat {
+        // The capacity is limited, so that an append to the
+        // snapshot's list could never write into the live list.
+        copyOfKB[key] = rules[:len(rules):len(rules)]
+    }
+    return &FrozenKB{ compiled: CompileKB(copyOfKB) }
+}
+
+// RuleCount - returns the number of rules for the given key (eg. "mother/2").
+func (fkb *FrozenKB) RuleCount(key string) int {
+    return len(fkb.compiled.kb[key])
+}
+
+// FormatKB - formats the facts and rules of the snapshot for display.
+func (fkb *FrozenKB) FormatKB() string {
+    return fkb.compiled.kb.FormatKB()
+}
+
+// GetSolver - returns a solution node which solves a query with
+// the snapshot.
+// Params: query
+//         substitution set (previous bindings)
+// Return: solution node
+func (fkb *FrozenKB) GetSolver(query Complex, ss SubstitutionSet) SolutionNode {
+    return fkb.compiled.GetSolver(query, ss)
+}
+
+// Solve - finds one solution for the given query. See Solve() in solutions.go.
+// Params:  query
+//          substitution set (previous bindings)
+// Returns: solution
+//          reason for failure
+func (fkb *FrozenKB) Solve(query Complex, ss SubstitutionSet) (Complex, string) {
+    return fkb.compiled.Solve(query, ss)
+}
+
+// SolveAll - finds all solutions for the given query. See SolveAll() in solutions.go.
+// Params:  query
+//          substitution set (previous bindings)
+// Returns: solutions
+//          reason for failure
+func (fkb *FrozenKB) SolveAll(query Complex, ss SubstitutionSet) ([]Complex, string) {
+    return fkb.compiled.SolveAll(query, ss)
+}
+
+// SolveBatch - solves a list of queries concurrently. The results are
+// returned in the same order as the queries. Each query has its own
+// maximum execution time (see SetMaxTimeMilliseconds()), from when a
+// worker starts it. A query which is not finished when its time expires
+// fails with "Time out." It does not stop the other queries.
+// Params:  queries
+//          options
+// Returns: results, one per query
+func (fkb *FrozenKB) SolveBatch(queries []Complex, opts BatchOptions) []BatchResult {
+
+    results := make([]BatchResult, len(queries))
+    if len(queries) == 0 { return results }
+
+    workers := opts.Workers
+    if workers < 1 { workers = runtime.NumCPU() }
+
+    // Variable IDs must not be reset while queries are running.
+    atomic.AddInt32(&parallelSearches, 1)
+    defer atomic.AddInt32(&parallelSearches, -1)
+    for _, query := range queries { reserveVariableIds(maxVariableId(query)) }
+    maxTime := maxExecutionTime()
+
+    // solveQuery - finds the solutions of one query.
+    solveQuery := func(i int) {
+        query := queries[i]
+        var solutions []Complex
+        defer func() {  // Catch panics.
+            if r := recover(); r != nil {
+                results[i] = BatchResult{ Query: query,
+                                          Failure: fmt.Sprintf("%v", r) }
+            }
+        }()
+        root := fkb.compiled.GetSolver(query, SubstitutionSet{})
+        stop := makeStopFlag(root)
+        timer := time.AfterFunc(maxTime, stop.set)  // For execution time-out.
+        defer timer.Stop()
+        for opts.MaxSolutions < 1 || len(solutions) < opts.MaxSolutions {
+            newSS, found := root.NextSolution()
+            if !found { break }
+            result := query.ReplaceVariables(newSS)
+            solutions = append(solutions, result.(Complex))
+        }
+        failure := ""
+        if stop.isSet() {
+            failure = "Time out."
+        } else if len(solutions) == 0 {
+            failure = "No"
+        }
+        results[i] = BatchResult{ Query: query,
+                                  Solutions: solutions, Failure: failure }
+    } // solveQuery
+
+    indices := make(chan int, len(queries))
+    for i := range queries { indices <- i }
+    close(indices)
+
+    var wg sync.WaitGroup
+    for w := 0; w < workers && w < len(queries); w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := range indices { solveQuery(i) }
+        }()
+    }
+
+    wg.Wait()
+    return results
+
+} // SolveBatch
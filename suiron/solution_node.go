@@ -23,9 +23,11 @@ type SolutionNodeStruct struct {
     Goal Goal  // goal being solved
     ruleNumber int
     count int  // counts number of rules/facts
+    stop *stopFlag  // stops the query (see timeout.go)
 }
 
-// MakeSolutionNode - makes a solution node with the given arguments:
+// MakeSolutionNode - makes a solution node with the given arguments.
+// The node takes the stop flag of its parent. (See timeout.go.)
 // Params:
 //     goal
 //     knowledgebase
@@ -41,10 +43,31 @@ func MakeSolutionNode(goal Goal, kb KnowledgeBase,
                 Goal: goal,
                 KnowledgeBase: kb,
                 ParentSolution: parentSolution,
-                ParentNode: parentNode }
+                ParentNode: parentNode,
+                stop: stopFlagOf(parentNode) }
     return node
 }
 
+// stopFlagOf - returns the stop flag of a solution node, or nil.
+func stopFlagOf(node SolutionNode) *stopFlag {
+    if n, ok := node.(interface{ stopFlag() *stopFlag }); ok {
+        return n.stopFlag()
+    }
+    return nil
+}
+
+// stopFlag - returns the stop flag of the node's query.
+func (sn *SolutionNodeStruct) stopFlag() *stopFlag {
+    if sn == nil { return nil }
+    return sn.stop
+}
+
+// setStopFlag - gives the stop flag of a query to its root node.
+func (sn *SolutionNodeStruct) setStopFlag(stop *stopFlag) { sn.stop = stop }
+
+// stopped - returns true if the node's query must stop.
+func (sn *SolutionNodeStruct) stopped() bool { return sn.stop.isSet() }
+
 
 type SolutionNode interface {
     NextSolution() (SubstitutionSet, bool)
@@ -11,6 +11,13 @@ import (
     "fmt"
 )
 
+// Solution - a solution of a query, with the goals which are still
+// delayed at the end of the search. (See coroutining.go.)
+type Solution struct {
+    Term     Complex     // query, with variables replaced by bindings
+    Delayed  []Complex   // delayed goals and undecided constraints
+}
+
 // Solve - finds one solution for the given query.
 // The solution is returned as a string.
 // A second string indicates the reason for failure, as follows:
@@ -19,35 +26,103 @@ import (
 //    "Other reason"
 // Note: This method only finds the first result.
 // See SolveAll below.
+// A search strategy (see search.go) is optional.
 // Params:  query
 //          knowledgebase
 //          substitution set (previous bindings)
+//          search strategy
 // Returns: solution
 //          reason for failure
-func Solve(query Complex, kb KnowledgeBase, ss SubstitutionSet) (solution Complex, failure string) {
+func Solve(query Complex, kb KnowledgeBase, ss SubstitutionSet,
+           strategy ...*SearchStrategy) (Complex, string) {
+    if len(strategy) > 0 && strategy[0] != nil {
+        s := strategy[0]
+        solution, failure := solveFirst(query, func() SolutionNode {
+            return s.getSolver(query, kb, ss)
+        })
+        if failure == "No" { failure = s.failure() }
+        return solution, failure
+    }
+    return solveFirst(query, func() SolutionNode {
+        return query.GetSolver(kb, ss, nil)
+    })
+}
+
+// SolveWithDelayed - finds one solution for the given query, with
+// the goals which are still delayed. (See Solve() above.)
+// Params:  query
+//          knowledgebase
+//          substitution set (previous bindings)
+//          search strategy
+// Returns: solution and delayed goals
+//          reason for failure
+func SolveWithDelayed(query Complex, kb KnowledgeBase, ss SubstitutionSet,
+                      strategy ...*SearchStrategy) (Solution, string) {
+    if len(strategy) > 0 && strategy[0] != nil {
+        s := strategy[0]
+        solution, failure := firstSolution(query, func() SolutionNode {
+            return s.getSolver(query, kb, ss)
+        }, true)
+        if failure == "No" { failure = s.failure() }
+        return solution, failure
+    }
+    return firstSolution(query, func() SolutionNode {
+        return query.GetSolver(kb, ss, nil)
+    }, true)
+}
+
+// solveFirst - finds one solution for the given query.
+// Params:  query
+//          function which gets the root solution node
+// Returns: solution
+//          reason for failure
+func solveFirst(query Complex,
+                getRoot func() SolutionNode) (Complex, string) {
+    solution, failure := firstSolution(query, getRoot, false)
+    return solution.Term, failure
+}
+
+// firstSolution - finds one solution for the given query.
+// Params:  query
+//          function which gets the root solution node
+//          true to get the delayed goals
+// Returns: solution
+//          reason for failure
+func firstSolution(query Complex, getRoot func() SolutionNode,
+                   delayed bool) (solution Solution, failure string) {
 
     defer func() {  // Catch panics.
         if r := recover(); r != nil {
-            solution = query
+            solution = Solution{ Term: query }
             failure = fmt.Sprintf("%v", r)
         }
     }()
 
     //SetMaxTimeMilliseconds(100)
-    SetStartTime()
-
     timer := MakeTimer()  // For execution time-out.
 
-    solutionChannel := make(chan Complex)
+    // The channel is buffered, so that the search can finish
+    // after a time-out.
+    solutionChannel := make(chan *Solution, 1)
+    var panicMessage string
 
     // Get the root solution node.
-    root := query.GetSolver(kb, ss, nil)
+    root := getRoot()
+    stop := makeStopFlag(root)
 
     // Get the next solution.
-    go func(out chan<- Complex) {
+    go func(out chan<- *Solution) {
+        // A panic in this goroutine cannot be caught by Solve.
+        defer func() {
+            if r := recover(); r != nil {
+                panicMessage = fmt.Sprintf("%v", r)
+                out <- nil
+            }
+        }()
         newSS, found := root.NextSolution()
         if found {
-            out <- query.ReplaceVariables(newSS).(Complex)
+            s := makeSolution(query, newSS, delayed)
+            out <- &s
         } else {
             out <- nil
         }
@@ -55,21 +130,36 @@ func Solve(query Complex, kb KnowledgeBase, ss SubstitutionSet) (solution Comple
 
     select {
     case <-timer.C:
-        solution = nil
         failure = "Time out."
-        suironHasTimedOut = true  // Stop searching for a solution.
-    case solution = <-solutionChannel:
+        stop.set()  // Stop searching for a solution.
+    case s := <-solutionChannel:
         timer.Stop()
-        if solution == nil {
+        if len(panicMessage) > 0 {
+            solution = Solution{ Term: query }
+            failure = panicMessage
+        } else if s == nil {
             failure = "No"
         } else {
+            solution = *s
             failure = ""
         }
     }
 
     return solution, failure
 
-}  // Solve
+}  // firstSolution
+
+// makeSolution - replaces the variables of a query with their
+// bindings, and gets the delayed goals, if requested.
+// Params:  query
+//          substitution set
+//          true to get the delayed goals
+// Return:  solution
+func makeSolution(query Complex, ss SubstitutionSet, delayed bool) Solution {
+    solution := Solution{ Term: query.ReplaceVariables(ss).(Complex) }
+    if delayed { solution.Delayed = ss.DelayedGoals() }
+    return solution
+}
 
 
 // SolveAll - finds all solutions for the given query.
@@ -79,15 +169,74 @@ func Solve(query Complex, kb KnowledgeBase, ss SubstitutionSet) (solution Comple
 //    "" (success)
 //    "No" (no solution)
 //    "Other reason"
+// A search strategy (see search.go) is optional.
 // Params:  query
 //          knowledge base
 //          substitution set (previous bindings)
+//          search strategy
 // Returns: solutions, failure
 //
-func SolveAll(query Complex, kb KnowledgeBase, ss SubstitutionSet) (solutions []Complex, failure string) {
+func SolveAll(query Complex, kb KnowledgeBase, ss SubstitutionSet,
+              strategy ...*SearchStrategy) ([]Complex, string) {
+    if len(strategy) > 0 && strategy[0] != nil {
+        s := strategy[0]
+        solutions, failure := solveEvery(query, func() SolutionNode {
+            return s.getSolver(query, kb, ss)
+        })
+        if failure == "No" { failure = s.failure() }
+        return solutions, failure
+    }
+    return solveEvery(query, func() SolutionNode {
+        return query.GetSolver(kb, ss, nil)
+    })
+}
+
+// SolveAllWithDelayed - finds all solutions for the given query, each
+// with the goals which are still delayed. (See SolveAll() above.)
+// Params:  query
+//          knowledge base
+//          substitution set (previous bindings)
+//          search strategy
+// Returns: solutions and delayed goals
+//          reason for failure
+func SolveAllWithDelayed(query Complex, kb KnowledgeBase, ss SubstitutionSet,
+                         strategy ...*SearchStrategy) ([]Solution, string) {
+    if len(strategy) > 0 && strategy[0] != nil {
+        s := strategy[0]
+        solutions, failure := everySolution(query, func() SolutionNode {
+            return s.getSolver(query, kb, ss)
+        }, true)
+        if failure == "No" { failure = s.failure() }
+        return solutions, failure
+    }
+    return everySolution(query, func() SolutionNode {
+        return query.GetSolver(kb, ss, nil)
+    }, true)
+}
+
+// solveEvery - finds all solutions for the given query.
+// Params:  query
+//          function which gets the root solution node
+// Returns: solutions
+//          reason for failure
+func solveEvery(query Complex,
+                getRoot func() SolutionNode) ([]Complex, string) {
+    solutions, failure := everySolution(query, getRoot, false)
+    var terms []Complex
+    for _, solution := range solutions {
+        terms = append(terms, solution.Term)
+    }
+    return terms, failure
+}
 
-    var newSS  SubstitutionSet
-    var found  bool
+// everySolution - finds all solutions for the given query.
+// Params:  query
+//          function which gets the root solution node
+//          true to get the delayed goals
+// Returns: solutions
+//          reason for failure
+func everySolution(query Complex, getRoot func() SolutionNode,
+                   delayed bool) (solutions []Solution, failure string) {
 
     defer func() {  // Catch panics.
         if r := recover(); r != nil {
@@ -96,37 +245,50 @@ func SolveAll(query Complex, kb KnowledgeBase, ss SubstitutionSet) (solutions []
     }()
 
     //SetMaxTimeMilliseconds(100)
-    SetStartTime()
     timer := MakeTimer()  // For execution time-out.
 
-    solutionChannel := make(chan []Complex)
+    // The channel is buffered, so that the search can finish
+    // after a time-out.
+    solutionChannel := make(chan []Solution, 1)
+    var panicMessage string
+
+    // Get the root solution node.
+    root := getRoot()
+    stop := makeStopFlag(root)
 
     // Get the next solution.
-    go func(out chan<- []Complex) {
+    go func(out chan<- []Solution) {
 
-        // Get the root solution node.
-        root := query.GetSolver(kb, ss, nil)
+        // A panic in this goroutine cannot be caught by SolveAll.
+        defer func() {
+            if r := recover(); r != nil {
+                panicMessage = fmt.Sprintf("%v", r)
+                out <- nil
+            }
+        }()
 
         // Get the next solution.
-        newSS, found = root.NextSolution()
+        var found []Solution
+        newSS, ok := root.NextSolution()
 
-        for found {
+        for ok {
             // Replace variables with their bound constants.
-            result := query.ReplaceVariables(newSS)
-            solutions = append(solutions, result.(Complex))
-            newSS, found = root.NextSolution()
+            found = append(found, makeSolution(query, newSS, delayed))
+            newSS, ok = root.NextSolution()
         }
-        out <- solutions
+        out <- found
 
     }(solutionChannel)
 
     select {
     case <-timer.C:
         failure = "Time out."
-        suironHasTimedOut = true  // Stop searching for a solution.
+        stop.set()  // Stop searching for a solution.
     case solutions = <-solutionChannel:
         timer.Stop()
-        if len(solutions) == 0 {
+        if len(panicMessage) > 0 {
+            failure = panicMessage
+        } else if len(solutions) == 0 {
             failure = "No"
         } else {
             failure = ""
@@ -135,7 +297,7 @@ func SolveAll(query Complex, kb KnowledgeBase, ss SubstitutionSet) (solutions []
 
     return solutions, failure
 
-}  // SolveAll
+}  // everySolution
 
 // FormatSolution - formats a string to display the variable bindings
 // of a solution. For example, if the query were: grandfather(Godwin, $X),
@@ -160,6 +322,12 @@ func FormatSolution(query Complex, bindings SubstitutionSet) string {
                 first = false
             }
         }
+        // Goals which are still delayed.
+        for _, goal := range bindings.DelayedGoals() {
+            if !first { sb.WriteString(", ") }
+            sb.WriteString(goal.String())
+            first = false
+        }
         out := sb.String()
         if len(out) == 0 { return "True " }
         return out
@@ -19,13 +19,15 @@ import (
 type SubstitutionSet []*Unifiable
 
 // IsBound() - A logic variable is bound if there exists an entry
-// for it in the substitution set.
+// for it in the substitution set. Attributes (see attributes.go)
+// are not a binding.
 // Params: logic variable
 // Return: true/false
 func (ss SubstitutionSet) IsBound(v VariableStruct) bool {
     if v.id >= len(ss) { return false }
     uni := ss[v.id]
-    return uni != nil
+    if uni == nil { return false }
+    return (*uni).TermType() != ATTRIBUTES
 }
 
 
@@ -39,7 +41,7 @@ func (ss SubstitutionSet) GetBinding(v VariableStruct) (*Unifiable, error) {
         return nil, errors.New("Not bound: " + v.String())
     }
     term := ss[v.id]
-    if term == nil {
+    if term == nil || (*term).TermType() == ATTRIBUTES {
         return nil, errors.New("Not bound: " + v.String())
     }
     return term, nil
@@ -55,11 +57,12 @@ func (ss SubstitutionSet) IsGroundVariable(v VariableStruct) bool {
         if v.id >= len(ss) { return false }
         u := ss[v.id]
         if u != nil {
-            if (*u).TermType() != VARIABLE { return true }
+            tt := (*u).TermType()
+            if tt == ATTRIBUTES { return false }
+            if tt != VARIABLE { return true }
             v = (*u).(VariableStruct)
         } else { return false }
     }
-    return false
 }
 
 // GetGroundTerm - if the given term is a ground term, return it.
@@ -78,11 +81,12 @@ func (ss SubstitutionSet) GetGroundTerm(u Unifiable) (Unifiable, bool) {
         if id >= len(ss) { return u, false }
         u2 = ss[id]
         if u2 != nil {
-            if (*u2).TermType() != VARIABLE { return *u2, true }
+            tt := (*u2).TermType()
+            if tt == ATTRIBUTES { return u, false }
+            if tt != VARIABLE { return *u2, true }
         } else { return u, false }
         u = *u2
     }
-    return *u2, false
 } // GetGroundTerm
 
 
@@ -0,0 +1,342 @@
+package suiron
+
+// Suspension - the machinery behind freeze/2, when/2 and dif/2.
+// (See coroutining.go for the predicates.)
+//
+// A delayed goal is recorded as a constraint (a propagator) in the
+// attributes of the variables which it is waiting for. Whenever one
+// of those variables is bound, the goal's condition is checked. If
+// the condition is true, the goal is 'woken up'.
+//
+// A woken goal cannot be run inside of Unify(), so it is recorded in
+// slot 0 of the substitution set, with the occurs check mode of the
+// query. Variable IDs begin at 1, so slot 0 is never used for a binding. The And solution node takes the woken
+// goals from the substitution set, and runs them before the rest of
+// the conjunction.
+//
+// Conditions for when/2:
+//
+//    nonvar($X)        $X is bound
+//    ground($T)        $T contains no unbound variables
+//    ?=($X, $Y)        $X and $Y are identical, or cannot be unified
+//    and(C1, C2)       both conditions are true
+//    or(C1, C2)        either condition is true
+//
+// Cleve Lendon
+
+import (
+    "fmt"
+    "strings"
+)
+
+// queryState - the contents of slot 0 of the substitution set: goals
+// which have been woken up, but not yet run, the occurs check mode
+// of the query (see occurs_check.go), and the trail of the engine
+// whose store it is (see trail.go). It is never changed; a new one is
+// made for each change.
+type queryState struct {
+    woken        []Unifiable
+    occursCheck  OccursCheckMode
+    trail        *bindingTrail
+}
+
+// TermType - the state of a query is not a binding.
+func (q *queryState) TermType() int { return ATTRIBUTES }
+
+// Unify - the state of a query does not unify with anything.
+// This function satisfies the Unifiable interface.
+func (q *queryState) Unify(other Unifiable, ss SubstitutionSet) (SubstitutionSet, bool) {
+    return ss, false
+}
+
+// RecreateVariables - returns the state unchanged.
+func (q *queryState) RecreateVariables(vars VarMap) Expression { return q }
+
+// ReplaceVariables - returns the state unchanged.
+func (q *queryState) ReplaceVariables(ss SubstitutionSet) Expression { return q }
+
+// String - creates a string representation, for debugging.
+func (q *queryState) String() string {
+    var sb strings.Builder
+    sb.WriteString("woken{")
+    for i, goal := range q.woken {
+        if i > 0 { sb.WriteString(", ") }
+        sb.WriteString(goal.String())
+    }
+    sb.WriteString("}")
+    return sb.String()
+}
+
+// state - gets the state of the query from slot 0 of the substitution
+// set. The state is empty if the slot is not used.
+func (ss SubstitutionSet) state() queryState {
+    if len(ss) == 0 || ss[0] == nil { return queryState{} }
+    return *(*ss[0]).(*queryState)
+}
+
+// withState - makes a copy of the substitution set which has the
+// given state in slot 0. An empty state leaves the slot unused.
+func (ss SubstitutionSet) withState(q queryState) SubstitutionSet {
+    newSS := make(SubstitutionSet, len(ss))
+    if len(newSS) == 0 { newSS = make(SubstitutionSet, 1) }
+    copy(newSS, ss)
+    newSS[0] = nil
+    if len(q.woken) > 0 || q.occursCheck != OccursCheckFalse ||
+       q.trail != nil {
+        var term Unifiable = &q
+        newSS[0] = &term
+    }
+    return newSS
+}
+
+// wake - records a goal to be run, in slot 0 of the substitution set.
+func (st *constraintStore) wake(goal Unifiable) {
+    q := st.ss.state()
+    q.woken = append(q.woken[:len(q.woken):len(q.woken)], goal)
+    st.ss = st.ss.withState(q)
+}
+
+// takeWokenGoals - removes the woken goals from the substitution set.
+// Return: goals to run
+//         new substitution set
+func (ss SubstitutionSet) takeWokenGoals() ([]Goal, SubstitutionSet) {
+    q := ss.state()
+    if len(q.woken) == 0 { return nil, ss }
+    woken := q.woken
+    q.woken = nil
+    newSS := ss.withState(q)
+    goals := []Goal{}
+    for _, term := range woken {
+        goals = append(goals, termToGoal(term, newSS))
+    }
+    return goals, newSS
+} // takeWokenGoals
+
+// termToGoal - converts a term, such as print(hello), into a goal
+// which can be solved. Built-in predicates are recognized by name,
+// and so are the operators of goals: ($X = 1, $Y = 2)
+// Other complex terms are solved from the knowledge base.
+// Params: term (or variable bound to a term)
+//         substitution set
+// Return: goal
+func termToGoal(term Unifiable, ss SubstitutionSet) Goal {
+    ground, ok := ss.GetGroundTerm(term)
+    if !ok { panic(fmt.Sprintf("Goal is not instantiated: %v", term)) }
+    if ground.TermType() == ATOM {
+        switch string(ground.(Atom)) {
+        case "!":    return Cut()
+        case "fail": return Fail()
+        case "nl":   return NL()
+        }
+        return Complex{ ground }
+    }
+    if ground.TermType() != COMPLEX {
+        panic(fmt.Sprintf("Goal is not callable: %v", ground))
+    }
+    c := ground.(Complex)
+    functor := string(c.GetFunctor())
+    args := []Unifiable(c[1:])
+    if functor == "not" && len(args) == 1 {
+        return Not(termToGoal(args[0], ss))
+    }
+    if len(args) == 2 {
+        switch {
+        case functor == ",":
+            return And(termToGoal(args[0], ss), termToGoal(args[1], ss))
+        case functor == ";":
+            return Or(termToGoal(args[0], ss), termToGoal(args[1], ss))
+        case goalOperators[functor]:
+            return makeInfixGoal(functor, args[0], args[1])
+        }
+    }
+    if goal, ok := makeBuiltInPredicate(functor, args); ok { return goal }
+    return c
+} // termToGoal
+
+// withoutAttributes - makes a copy of the substitution set in which
+// attributed variables are plain unbound variables. This allows two
+// terms to be tested for unifiability, without running constraints.
+// The occurs check mode is kept.
+func (ss SubstitutionSet) withoutAttributes() SubstitutionSet {
+    newSS := make(SubstitutionSet, len(ss))
+    for i, u := range ss {
+        if i == 0 || (u != nil && (*u).TermType() == ATTRIBUTES) { continue }
+        newSS[i] = u
+    }
+    mode := ss.state().occursCheck
+    if mode == OccursCheckFalse { return newSS }
+    return newSS.withState(queryState{ occursCheck: mode })
+}
+
+// termVariables - gets the unbound variables of a term.
+// Params: term
+//         substitution set
+// Return: list of variables
+func termVariables(term Unifiable, ss SubstitutionSet) []VariableStruct {
+    vars := []VariableStruct{}
+    var walk func(t Unifiable)
+    walk = func(t Unifiable) {
+        ground, ok := ss.GetGroundTerm(t)
+        if !ok {
+            if ground.TermType() == VARIABLE {
+                vars = append(vars, ground.(VariableStruct))
+            }
+            return
+        }
+        switch ground.TermType() {
+        case COMPLEX:
+            for _, arg := range ground.(Complex)[1:] { walk(arg) }
+        case LINKEDLIST:
+            list := ground.(LinkedListStruct)
+            for ptr := &list; ptr != nil && ptr.term != nil; ptr = ptr.next {
+                walk(ptr.term)
+            }
+        }
+    }
+    walk(term)
+    return vars
+} // termVariables
+
+// identicalOrDistinct - determines whether two terms are identical,
+// or cannot be unified. (The condition ?= of when/2.)
+// Return: true if identical
+//         true if the terms cannot be unified
+func identicalOrDistinct(t1, t2 Unifiable,
+                         ss SubstitutionSet) (identical bool, distinct bool) {
+    plain := ss.withoutAttributes()
+    newSS, ok := t1.Unify(t2, plain)
+    if !ok { return false, true }
+    for i := 1; i < len(newSS); i++ {
+        if i >= len(plain) {
+            if newSS[i] != nil { return false, false }
+        } else if newSS[i] != plain[i] { return false, false }
+    }
+    return true, false
+} // identicalOrDistinct
+
+//----------------------------------------------------------------
+// suspension - a goal delayed by freeze/2 or when/2.
+//----------------------------------------------------------------
+
+type suspension struct {
+    name      string     // freeze or when
+    condition Unifiable
+    goal      Unifiable
+}
+
+// variables - returns the terms of the condition.
+func (p *suspension) variables() []Unifiable {
+    return []Unifiable{ p.condition }
+}
+
+// propagate - if the condition is true, the goal is woken up.
+// Otherwise, the suspension is attached to the variables of the
+// condition, to be checked again when they are bound.
+func (p *suspension) propagate(st *constraintStore) bool {
+    if checkCondition(p.condition, st.ss) {
+        st.detach(p)
+        st.wake(p.goal)
+        return true
+    }
+    for _, v := range termVariables(p.condition, st.ss) {
+        st.attach(p, v)
+    }
+    return true
+}
+
+// delayedGoal - represents the suspension as a goal, for reporting.
+func (p *suspension) delayedGoal() Complex {
+    if p.name == "freeze" {
+        return Complex{ Atom("freeze"), p.condition.(Complex)[1], p.goal }
+    }
+    return Complex{ Atom("when"), p.condition, p.goal }
+}
+
+// checkCondition - evaluates a condition of when/2.
+// Invalid conditions cause a panic.
+// Params: condition
+//         substitution set
+// Return: true if the condition is satisfied
+func checkCondition(condition Unifiable, ss SubstitutionSet) bool {
+    c, ok := ss.CastComplex(condition)
+    if !ok { panic(fmt.Sprintf("when - Invalid condition: %v", condition)) }
+    functor := string(c.GetFunctor())
+    switch {
+    case functor == "nonvar" && c.Arity() == 1:
+        _, bound := ss.GetGroundTerm(c[1])
+        return bound
+    case functor == "ground" && c.Arity() == 1:
+        return len(termVariables(c[1], ss)) == 0
+    case functor == "?=" && c.Arity() == 2:
+        identical, distinct := identicalOrDistinct(c[1], c[2], ss)
+        return identical || distinct
+    case functor == "and" && c.Arity() == 2:
+        return checkCondition(c[1], ss) && checkCondition(c[2], ss)
+    case functor == "or" && c.Arity() == 2:
+        return checkCondition(c[1], ss) || checkCondition(c[2], ss)
+    }
+    panic(fmt.Sprintf("when - Invalid condition: %v", condition))
+} // checkCondition
+
+//----------------------------------------------------------------
+// difConstraint - the constraint of dif/2.
+//----------------------------------------------------------------
+
+type difConstraint struct {
+    left, right Unifiable
+}
+
+// variables - returns the two terms which must be different.
+func (p *difConstraint) variables() []Unifiable {
+    return []Unifiable{ p.left, p.right }
+}
+
+// propagate - fails if the terms have become identical. If they can
+// no longer be unified, the constraint is removed. Otherwise, it is
+// attached to all variables of both terms.
+func (p *difConstraint) propagate(st *constraintStore) bool {
+    identical, distinct := identicalOrDistinct(p.left, p.right, st.ss)
+    if identical { return false }
+    if distinct {
+        st.detach(p)
+        return true
+    }
+    for _, term := range p.variables() {
+        for _, v := range termVariables(term, st.ss) {
+            st.attach(p, v)
+        }
+    }
+    return true
+}
+
+// delayedGoal - represents the constraint as a goal, for reporting.
+func (p *difConstraint) delayedGoal() Complex {
+    return Complex{ Atom("dif"), p.left, p.right }
+}
+
+// delayed - a constraint which can be reported as a goal.
+type delayed interface {
+    delayedGoal() Complex
+}
+
+// DelayedGoals - gets the goals which are still delayed in a solution,
+// that is, goals of freeze/2 and when/2 which have not been woken up,
+// and dif/2 constraints which are not yet decided. Variables in the
+// goals are replaced by their bindings.
+// Return: list of goals
+func (ss SubstitutionSet) DelayedGoals() []Complex {
+    goals := []Complex{}
+    seen := map[propagator]bool{}
+    for i := 1; i < len(ss); i++ {
+        if ss[i] == nil { continue }
+        a, ok := (*ss[i]).(*attributes)
+        if !ok { continue }
+        for _, p := range a.constraints {
+            d, ok := p.(delayed)
+            if !ok || seen[p] { continue }
+            seen[p] = true
+            goals = append(goals, d.delayedGoal().ReplaceVariables(ss).(Complex))
+        }
+    }
+    return goals
+} // DelayedGoals
@@ -1,10 +1,29 @@
 package suiron
 
-// Time Out - functions for measuring elapsed execution time.
+// Time Out - functions for measuring elapsed execution time, and the
+// flag which stops a query.
+//
+// Each query has its own time limit (SetMaxTimeMilliseconds()). Solve(),
+// SolveAll() and the other functions which run a query start a timer
+// for it. When the timer expires, the query's stop flag is set, and the
+// search stops at its next step, so it does not keep running after the
+// query has failed with "Time out." The debugger also sets the flag,
+// to abort a query. Queries which run at the same time, in different
+// goroutines, do not stop each other. A built-in predicate which is
+// running when the timer expires (for example, one which sleeps) is not
+// interrupted; the search stops when it returns.
+//
+// The flag is kept by the solution nodes of the query. A node which is
+// made with a parent node takes the parent's flag. (See MakeSolutionNode()
+// in solution_node.go.)
+//
+// SetStartTime(), ElapsedTime() and HasTimedOut() measure the time of
+// the caller's own work. The search does not use them.
 //
 // Cleve Lendon
 
 import (
+    "sync/atomic"
     "time"
 )
 
@@ -12,46 +31,79 @@ import (
 var suironMaxTime   int64 = 300 * 1_000_000  // 300 millisecond default
 var suironStartTime time.Time
 var suironZeroTime time.Time = time.Time{}
-var suironHasTimedOut = false
 
-// SetMaxTimeMilliseconds - sets the maximum execution time.
+// SetMaxTimeMilliseconds - sets the maximum execution time of a query.
 // Param: maxTime (in milliseconds)
 func SetMaxTimeMilliseconds(maxTime int64) {
     if maxTime < 0 { return }
-    suironMaxTime = maxTime * 1_000_000 // convert to nanoseconds
+    // convert to nanoseconds
+    atomic.StoreInt64(&suironMaxTime, maxTime * 1_000_000)
 }
 
-// SetStartTime - sets the start time before starting the query.
+// SetStartTime - sets the start time.
 func SetStartTime() {
     suironStartTime = time.Now()
-    suironHasTimedOut = false
 }
 
 // ClearStartTime - clears the start time to 0.
 // This will prevent a time-out.
 func ClearStartTime() {
     suironStartTime = suironZeroTime
-    suironHasTimedOut = false
 }
 
-// ElapsedTime - returns time (in nanoseconds) since the start of the query.
+// ElapsedTime - returns time (in nanoseconds) since the start time.
 func ElapsedTime() int64 {
     return int64(time.Since(suironStartTime))
 }
 
-// HasTimedOut - returns true if the maximum execution time has been exceeded.
-// If no start time was set (zero time), return false.
+// HasTimedOut - returns true if the maximum execution time has been
+// exceeded since the start time. If no start time was set (zero time),
+// return false.
 func HasTimedOut() bool {
-    if suironHasTimedOut == true { return true }
     if suironStartTime == suironZeroTime { return false }
-    if int64(time.Since(suironStartTime)) > suironMaxTime {
-        suironHasTimedOut = true
-        return true
-    }
-    return false
+    return int64(time.Since(suironStartTime)) > atomic.LoadInt64(&suironMaxTime)
 }
 
 // MakeTimer - makes a timer to limit the runtime of the inference engine.
 func MakeTimer() *time.Timer {
-    return time.NewTimer(time.Duration(suironMaxTime))
+    return time.NewTimer(maxExecutionTime())
+}
+
+// maxExecutionTime - returns the maximum execution time of a query.
+func maxExecutionTime() time.Duration {
+    return time.Duration(atomic.LoadInt64(&suironMaxTime))
+}
+
+// stopFlag - the flag which stops a query.
+type stopFlag struct {
+    stopped  int32
+}
+
+// set - stops the query.
+func (f *stopFlag) set() { atomic.StoreInt32(&f.stopped, 1) }
+
+// isSet - returns true if the query must stop. A query without a flag
+// (nil) is never stopped.
+func (f *stopFlag) isSet() bool {
+    return f != nil && atomic.LoadInt32(&f.stopped) != 0
+}
+
+// makeStopFlag - makes the stop flag of a query, and gives it to the
+// root solution node of the query.
+// Param:  root solution node
+// Return: stop flag
+func makeStopFlag(root SolutionNode) *stopFlag {
+    stop := &stopFlag{}
+    giveStopFlag(root, stop)
+    return stop
+}
+
+// giveStopFlag - gives a stop flag to the root solution node of a query.
+// Several queries can share one flag.
+// Params: root solution node
+//         stop flag
+func giveStopFlag(root SolutionNode, stop *stopFlag) {
+    if n, ok := root.(interface{ setStopFlag(*stopFlag) }); ok {
+        n.setStopFlag(stop)
+    }
 }
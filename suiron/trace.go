@@ -0,0 +1,471 @@
+package suiron
+
+// Trace - a tracer for debugging rules, based on the four-port
+// (Byrd box) model of Prolog debuggers. A goal is a box with four ports:
+//
+//    Call - the goal is called
+//    Exit - the goal succeeds
+//    Redo - the search backtracks into the goal, for another solution
+//    Fail - the goal has no (more) solutions
+//
+// Each event has the depth of the goal (the number of rules used to
+// reach it), the goal with its current bindings, and, for Exit and
+// Redo, the number of the clause (1 = first rule or fact).
+//
+// Events are sent to a Tracer. The text tracer writes them to an
+// io.Writer:
+//
+//    SetTracer(MakeTextTracer(os.Stderr))
+//    TraceOn()
+//
+//        Call: (0) grandfather($X_1, $Y_2)
+//         Call: (1) parent($X_1, $Z_3)
+//         Exit: (1) parent(Godwin, Harold II) (clause 1)
+//         ...
+//
+// Tracing can also be controlled from rules, with built-in predicates:
+//
+//    trace           - turns tracing on
+//    notrace         - turns tracing off
+//    spy(father/2)   - traces only the ports of father/2 (spy point)
+//    spy(father)     - spy point on father, of any arity
+//    nospy(father/2) - removes a spy point
+//
+// When tracing is off, the ports of predicates with spy points are
+// still reported. If no tracer was set, events are written to stdout.
+//
+// A fifth port, Unify, reports the clause whose head has unified with
+// the goal. It is hidden unless ShowUnifyPort(true) is called. The
+// events of Exit, Redo and Unify have the source file and line of the
+// clause, if it was loaded from a file.
+//
+// A tracer which also satisfies the DebugTracer interface controls the
+// search: at each port it can let the goal continue, make it fail,
+// retry it from its Call port, or abort the search. (See debugger.go.)
+//
+// Goals are traced by the solver of engine.go. The compiled knowledge
+// base (wam_machine.go) and the strategies of search.go do not report
+// events.
+//
+// Cleve Lendon
+
+import (
+    "strings"
+    "sync"
+    "sync/atomic"
+    "fmt"
+    "io"
+    "os"
+)
+
+// Port - identifies a port of the four-port model.
+type Port int
+
+const (
+    CallPort Port = iota
+    ExitPort
+    RedoPort
+    FailPort
+    UnifyPort
+)
+
+// String - returns the name of a port.
+func (p Port) String() string {
+    switch p {
+    case CallPort:
+        return "Call"
+    case ExitPort:
+        return "Exit"
+    case RedoPort:
+        return "Redo"
+    case UnifyPort:
+        return "Unify"
+    }
+    return "Fail"
+}
+
+// TraceEvent - an event at a port of a goal.
+type TraceEvent struct {
+    Port    Port
+    Depth   int
+    Goal    Goal   // with the current bindings
+    Key     string // predicate, eg. father/2
+    Clause  int    // number of clause (Exit, Redo, Unify), or 0
+    File    string // source file of clause, or ""
+    Line    int    // source line of clause, or 0
+    Column  int    // source column of clause, or 0
+}
+
+// Tracer - receives trace events.
+type Tracer interface {
+    Trace(event TraceEvent)
+}
+
+// DebugAction - tells the solver what to do after a port.
+type DebugAction int
+
+const (
+    DebugContinue DebugAction = iota  // continue normally
+    DebugFail                         // the goal fails
+    DebugRetry                        // restart the goal from its Call port
+    DebugAbort                        // stop the search
+)
+
+// DebugTracer - a tracer which controls the search. The solver waits
+// for Debug() to return, then acts on the returned action.
+type DebugTracer interface {
+    Tracer
+    Debug(event TraceEvent) DebugAction
+}
+
+// TextTracer - writes trace events as lines of text.
+type TextTracer struct {
+    mutex   sync.Mutex
+    writer  io.Writer
+}
+
+// MakeTextTracer - makes a tracer which writes to the given writer.
+func MakeTextTracer(w io.Writer) *TextTracer {
+    return &TextTracer{ writer: w }
+}
+
+// Trace - writes one event, indented by its depth.
+// This function satisfies the Tracer interface.
+func (t *TextTracer) Trace(e TraceEvent) {
+    t.mutex.Lock()
+    defer t.mutex.Unlock()
+    fmt.Fprint(t.writer, FormatTraceEvent(e), "\n")
+}
+
+// FormatTraceEvent - formats a trace event for display.
+// If the clause was loaded from a file, its source is included.
+// Eg.:  Exit: (1) parent(Godwin, Harold II) (clause 1, kings.txt:14:1)
+func FormatTraceEvent(e TraceEvent) string {
+    indent := strings.Repeat(" ", e.Depth)
+    s := fmt.Sprintf("%v%v: (%v) %v", indent, e.Port, e.Depth, e.Goal)
+    if e.Clause > 0 {
+        source := SourceLocation{ e.File, e.Line, e.Column }.String()
+        if source == "" {
+            s += fmt.Sprintf(" (clause %v)", e.Clause)
+        } else {
+            s += fmt.Sprintf(" (clause %v, %v)", e.Clause, source)
+        }
+    }
+    return s
+}
+
+// The state of the tracer. traceActive is checked by the solver,
+// so that tracing costs nothing when it is not in use.
+var traceState = struct {
+    mutex      sync.RWMutex
+    tracer     Tracer
+    tracing    bool
+    unifyPort  bool
+    spyPoints  map[string]bool
+    profiler   *Profiler  // see profiler.go
+    coverage   *Coverage  // see coverage.go
+}{ spyPoints: map[string]bool{} }
+
+var traceActive int32
+
+// SetTracer - sets the tracer which receives events.
+// Param: tracer (nil = text tracer to stdout)
+func SetTracer(t Tracer) {
+    traceState.mutex.Lock()
+    defer traceState.mutex.Unlock()
+    traceState.tracer = t
+}
+
+// TraceOn - turns tracing of all goals on.
+func TraceOn() {
+    traceState.mutex.Lock()
+    defer traceState.mutex.Unlock()
+    traceState.tracing = true
+    updateTraceActive()
+}
+
+// TraceOff - turns tracing off. Spy points remain.
+func TraceOff() {
+    traceState.mutex.Lock()
+    defer traceState.mutex.Unlock()
+    traceState.tracing = false
+    updateTraceActive()
+}
+
+// ShowUnifyPort - shows or hides the Unify port.
+func ShowUnifyPort(on bool) {
+    traceState.mutex.Lock()
+    defer traceState.mutex.Unlock()
+    traceState.unifyPort = on
+}
+
+// Spy - sets a spy point on a predicate: name/arity, or name
+// for all arities.
+// Param: predicate, eg. father/2
+func Spy(predicate string) {
+    traceState.mutex.Lock()
+    defer traceState.mutex.Unlock()
+    traceState.spyPoints[predicate] = true
+    updateTraceActive()
+}
+
+// NoSpy - removes a spy point.
+// Param: predicate, eg. father/2
+func NoSpy(predicate string) {
+    traceState.mutex.Lock()
+    defer traceState.mutex.Unlock()
+    delete(traceState.spyPoints, predicate)
+    updateTraceActive()
+}
+
+// NoSpyAll - removes all spy points.
+func NoSpyAll() {
+    traceState.mutex.Lock()
+    defer traceState.mutex.Unlock()
+    traceState.spyPoints = map[string]bool{}
+    updateTraceActive()
+}
+
+// IsSpyPoint - returns true if the predicate (eg. father/2) has a spy point.
+func IsSpyPoint(key string) bool {
+    traceState.mutex.RLock()
+    defer traceState.mutex.RUnlock()
+    return isSpyPoint(key)
+}
+
+// isSpyPoint - checks the spy points. The lock must be held.
+func isSpyPoint(key string) bool {
+    if len(traceState.spyPoints) == 0 { return false }
+    if traceState.spyPoints[key] { return true }
+    if i := strings.LastIndex(key, "/"); i > 0 {
+        return traceState.spyPoints[key[:i]]
+    }
+    return false
+}
+
+// updateTraceActive - the lock must be held.
+func updateTraceActive() {
+    var active int32
+    if traceState.tracing || len(traceState.spyPoints) > 0 ||
+       traceState.profiler != nil || traceState.coverage != nil { active = 1 }
+    atomic.StoreInt32(&traceActive, active)
+}
+
+// tracingEnabled - returns true if events may need to be reported,
+// to a tracer, the profiler or coverage.
+func tracingEnabled() bool {
+    return atomic.LoadInt32(&traceActive) != 0
+}
+
+// traceGoal - reports an event, if the goal is being traced.
+// If the tracer is a DebugTracer, its action is returned. When the
+// search is aborted, it stops and the remaining ports are not reported.
+// Params: port
+//         depth of goal
+//         goal
+//         number of clause, or 0
+//         knowledge base (for the source of the clause)
+//         substitution set (current bindings)
+//         stop flag of the query
+// Return: action
+func traceGoal(port Port, depth int, goal Goal, clause int,
+               kb KnowledgeBase, ss SubstitutionSet,
+               stop *stopFlag) DebugAction {
+    if stop.isSet() { return DebugFail }
+    key := goalKey(goal)
+    traceState.mutex.RLock()
+    tracer := traceState.tracer
+    report := traceState.tracing || isSpyPoint(key)
+    if port == UnifyPort { report = report && traceState.unifyPort }
+    profiler := traceState.profiler
+    coverage := traceState.coverage
+    traceState.mutex.RUnlock()
+    if profiler != nil { profiler.port(port, key, clause) }
+    if !report && coverage == nil { return DebugContinue }
+    var rules []RuleStruct
+    if clause > 0 { rules = kb.rules(key) }
+    if coverage != nil && clause > 0 && clause <= len(rules) {
+        coverage.port(port, rules[clause - 1])
+    }
+    if !report { return DebugContinue }
+    if tracer == nil { tracer = defaultTracer }
+    event := TraceEvent{
+        Port: port,
+        Depth: depth,
+        Goal: bindGoal(goal, ss),
+        Key: key,
+        Clause: clause,
+    }
+    if clause > 0 && clause <= len(rules) {
+        source := rules[clause - 1].source
+        event.File, event.Line, event.Column =
+            source.File, source.Line, source.Column
+    }
+    debugger, ok := tracer.(DebugTracer)
+    if !ok {
+        tracer.Trace(event)
+        return DebugContinue
+    }
+    action := debugger.Debug(event)
+    if action == DebugAbort {
+        if stop != nil { stop.set() }  // Stop the search.
+        return DebugFail
+    }
+    return action
+} // traceGoal
+
+var defaultTracer = MakeTextTracer(os.Stdout)
+
+// bindGoal - replaces the variables of a goal with their bindings,
+// for display. The arguments of built-in predicates are replaced one
+// by one.
+func bindGoal(goal Goal, ss SubstitutionSet) Goal {
+    if c, ok := goal.(Complex); ok {
+        return c.ReplaceVariables(ss).(Complex)
+    }
+    if b, maker, ok := asBuiltIn(goal); ok {
+        args := make([]Unifiable, len(b.Arguments))
+        for i, arg := range b.Arguments {
+            args[i] = arg
+            if u, ok := arg.ReplaceVariables(ss).(Unifiable); ok { args[i] = u }
+        }
+        b.Arguments = args
+        if g, ok := maker(b).(Goal); ok { return g }
+    }
+    return goal
+}
+
+// goalKey - returns the name/arity of a goal, eg. father/2.
+func goalKey(goal Goal) string {
+    switch g := goal.(type) {
+    case Complex:
+        return g.Key()
+    case NotOp:
+        return "not/1"
+    }
+    if b, _, ok := asBuiltIn(goal); ok {
+        return fmt.Sprintf("%v/%v", b.Name, len(b.Arguments))
+    }
+    return goal.String()
+}
+
+// traceExit - information for the Exit port of a goal. The solver
+// puts it in the continuation, after the body of the rule. The state
+// at the Call port is kept, so that the goal can be retried. The marker
+// also closes the goal's proof, for explanations. (See explain.go.)
+type traceExit struct {
+    goal       Complex
+    depth      int
+    clause     int
+    mark       int              // length of the trail at Call (trail.go)
+    height     int              // height of choice point stack at Call
+    pathDepth  int
+    proof      *proofStep       // log of the proof, at Call (explain.go)
+}
+
+//----------------------------------------------------------------
+// Built-in predicates: trace, notrace, spy/1, nospy/1
+//----------------------------------------------------------------
+
+type TraceStruct BuiltInPredicateStruct
+
+// makeTracePredicate - creates a trace predicate, after checking
+// the number of arguments.
+func makeTracePredicate(name string, count int,
+                        arguments []Unifiable) TraceStruct {
+    if len(arguments) != count {
+        panic(fmt.Sprintf("%v - This predicate requires %v argument(s).",
+                          name, count))
+    }
+    return TraceStruct{ Name: name, Arguments: arguments }
+}
+
+// TracePredicate - creates the predicate trace, which turns tracing on.
+func TracePredicate() TraceStruct {
+    return makeTracePredicate("trace", 0, nil)
+}
+
+// NoTracePredicate - creates the predicate notrace, which turns tracing off.
+func NoTracePredicate() TraceStruct {
+    return makeTracePredicate("notrace", 0, nil)
+}
+
+// SpyPredicate - creates the predicate spy(name/arity).
+func SpyPredicate(arguments ...Unifiable) TraceStruct {
+    return makeTracePredicate("spy", 1, arguments)
+}
+
+// NoSpyPredicate - creates the predicate nospy(name/arity).
+func NoSpyPredicate(arguments ...Unifiable) TraceStruct {
+    return makeTracePredicate("nospy", 1, arguments)
+}
+
+// GetSolver - gets a solution node for this predicate.
+// This function satisfies the Goal interface.
+func (s TraceStruct) GetSolver(kb KnowledgeBase,
+                               parentSolution SubstitutionSet,
+                               parentNode SolutionNode) SolutionNode {
+    node := TraceSolutionNodeStruct{
+                SolutionNodeStruct: MakeSolutionNode(s, kb,
+                                        parentSolution, parentNode),
+                moreSolutions: true,
+            }
+    return &node
+}
+
+// RecreateVariables - Refer to comments in expression.go.
+func (s TraceStruct) RecreateVariables(vars VarMap) Expression {
+    bip := BuiltInPredicateStruct(s).RecreateVariables(vars)
+    return Expression(TraceStruct(*bip))
+}
+
+// ReplaceVariables - Refer to comments in expression.go.
+func (s TraceStruct) ReplaceVariables(ss SubstitutionSet) Expression {
+    return BuiltInPredicateStruct(s).ReplaceVariables(ss)
+}
+
+// String - creates a string representation.
+func (s TraceStruct) String() string {
+    if len(s.Arguments) == 0 { return s.Name }
+    return BuiltInPredicateStruct(s).String()
+}
+
+type TraceSolutionNodeStruct struct {
+    SolutionNodeStruct
+    moreSolutions bool
+}
+
+// NextSolution - turns tracing on or off, or sets a spy point.
+// This function satisfies the SolutionNode interface.
+func (sn *TraceSolutionNodeStruct) NextSolution() (SubstitutionSet, bool) {
+    if sn.NoBackTracking || !sn.moreSolutions { return nil, false }
+    sn.moreSolutions = false  // Only one solution.
+    goal := sn.Goal.(TraceStruct)
+    switch goal.Name {
+    case "trace":
+        TraceOn()
+    case "notrace":
+        TraceOff()
+    default:
+        ground, ok := sn.ParentSolution.GetGroundTerm(goal.Arguments[0])
+        if !ok { return nil, false }
+        if goal.Name == "spy" {
+            Spy(ground.String())
+        } else {
+            NoSpy(ground.String())
+        }
+    }
+    return sn.ParentSolution, true
+}
+
+// SetNoBackTracking - set the NoBackTracking flag,
+// which is used to implement Cuts.
+// This function satisfies the SolutionNode interface.
+func (sn *TraceSolutionNodeStruct) SetNoBackTracking() {
+    sn.NoBackTracking = true
+}
+
+// GetParentNode
+func (sn *TraceSolutionNodeStruct) GetParentNode() SolutionNode {
+    return sn.ParentNode
+}
@@ -0,0 +1,157 @@
+package suiron
+
+// Trail - the bindings of an engine (engine.go), which are changed in
+// place and undone on backtracking.
+//
+// A substitution set is copied when a variable is bound, so that the
+// previous set is not changed. (See Unify() in variable.go.) The copy
+// takes time in proportion to the number of variables, so a recursion
+// which binds variables at each level would take time in proportion to
+// the square of its depth.
+//
+// An engine avoids the copies. It owns one substitution set, the store,
+// and binds variables in it directly. Each change is recorded on the
+// trail, with the value it replaced. A choice point records the length
+// of the trail, and when the engine backtracks to the choice point, the
+// changes after it are undone, in reverse order.
+//
+// The store is changed in place only while the engine unifies terms
+// itself: the head of a rule with a goal, or the operands of Unify.
+// Built-in predicates receive the store as their parent solution. They
+// can keep it, because it is restored before they are asked for another
+// solution, but their own bindings are made in copies, as before. When
+// a built-in predicate returns a different substitution set, the engine
+// adopts a copy of it as its store.
+//
+// The trail is found through slot 0 of the store. (See queryState in
+// suspension.go.) A substitution set which is not the store, such as a
+// copy made by a built-in predicate, is never changed in place.
+//
+// The solutions of an engine are copies of the store, without the trail.
+//
+// Cleve Lendon
+
+// trailEntry - one change of the store.
+type trailEntry struct {
+    id     int              // variable, or -1 if another store was adopted
+    old    *Unifiable       // previous value of the variable's slot
+    store  SubstitutionSet  // store before the change
+}
+
+// bindingTrail - the store of an engine, and the changes to undo.
+type bindingTrail struct {
+    store      SubstitutionSet
+    entries    []trailEntry
+    active     bool   // true while the engine unifies terms
+    allocated  int64  // bindings made, and slots copied (see limits.go)
+}
+
+// makeBindingTrail - makes a trail, with a copy of the given
+// substitution set as its store.
+// Param:  substitution set
+// Return: trail
+func makeBindingTrail(ss SubstitutionSet) *bindingTrail {
+    t := &bindingTrail{}
+    t.store = t.copyOf(ss)
+    return t
+}
+
+// copyOf - copies a substitution set, with room to grow, and records
+// the trail in slot 0.
+// Param:  substitution set
+// Return: copy
+func (t *bindingTrail) copyOf(ss SubstitutionSet) SubstitutionSet {
+    length := len(ss)
+    if length == 0 { length = 1 }
+    newSS := make(SubstitutionSet, length, 2 * length)
+    copy(newSS, ss)
+    q := ss.state()
+    q.trail = t
+    var term Unifiable = &q
+    newSS[0] = &term
+    t.allocated += int64(length)
+    return newSS
+}
+
+// owns - returns true if the given substitution set is the store,
+// and the engine is unifying terms, so the store can be changed.
+// Param:  substitution set
+// Return: true/false
+func (t *bindingTrail) owns(ss SubstitutionSet) bool {
+    return t.active && len(ss) == len(t.store) && &ss[0] == &t.store[0]
+}
+
+// bind - binds a variable in the store, and records the change.
+// Params: variable ID
+//         term to bind to
+// Return: store
+func (t *bindingTrail) bind(id int, term *Unifiable) SubstitutionSet {
+    previous := t.store
+    if id >= len(t.store) {
+        if id < cap(t.store) {
+            t.store = t.store[:id + 1]
+        } else {
+            newSS := make(SubstitutionSet, id + 1, 2 * (id + 1))
+            copy(newSS, t.store)
+            t.store = newSS
+            t.allocated += int64(len(previous))
+        }
+    }
+    t.entries = append(t.entries,
+                       trailEntry{ id: id, old: t.store[id], store: previous })
+    t.store[id] = term
+    t.allocated++
+    return t.store
+} // bind
+
+// adopt - makes the given substitution set the store. If it is not
+// the store, a copy is adopted, so that other holders of the set do
+// not see the changes which follow.
+// Param:  substitution set
+// Return: store
+func (t *bindingTrail) adopt(ss SubstitutionSet) SubstitutionSet {
+    if len(ss) == len(t.store) && len(ss) > 0 && &ss[0] == &t.store[0] {
+        return t.store
+    }
+    t.entries = append(t.entries, trailEntry{ id: -1, store: t.store })
+    t.store = t.copyOf(ss)
+    return t.store
+}
+
+// mark - returns the length of the trail, for undo().
+func (t *bindingTrail) mark() int { return len(t.entries) }
+
+// undo - undoes the changes after the given mark.
+// Param: mark
+func (t *bindingTrail) undo(mark int) {
+    for len(t.entries) > mark {
+        last := len(t.entries) - 1
+        e := t.entries[last]
+        t.entries[last] = trailEntry{}
+        t.entries = t.entries[:last]
+        if e.id >= 0 { t.store[e.id] = e.old }
+        t.store = e.store
+    }
+}
+
+// forget - discards the changes, when no choice point can undo them.
+func (t *bindingTrail) forget() {
+    for i := range t.entries { t.entries[i] = trailEntry{} }
+    t.entries = t.entries[:0]
+}
+
+// solution - copies the store, without the trail.
+// Return: substitution set
+func (t *bindingTrail) solution() SubstitutionSet {
+    q := t.store.state()
+    q.trail = nil
+    return t.store.withState(q)
+}
+
+// trail - gets the trail of an engine's store from slot 0.
+// Return: trail, or nil
+func (ss SubstitutionSet) trail() *bindingTrail {
+    if len(ss) == 0 || ss[0] == nil { return nil }
+    if q, ok := (*ss[0]).(*queryState); ok { return q.trail }
+    return nil
+}
@@ -0,0 +1,216 @@
+package suiron
+
+// Transaction - a group of changes to a knowledge base, which become
+// visible all at once, or not at all:
+//
+//    tx := kb.Begin()
+//    tx.Add(fact1, fact2, rule1)
+//    tx.Retract(word(envy, $_))
+//    if valid {
+//        err = tx.Commit()
+//    } else {
+//        tx.Rollback()
+//    }
+//
+// Until the transaction is committed, the knowledge base is not changed.
+// The changes are kept in an overlay of the knowledge base (overlay.go),
+// so queries can see them with tx.KnowledgeBase(). Clauses which are
+// added to tx.KnowledgeBase(), or retracted from it, eg. by op/3 in a
+// goal which runs on it, are changes of the transaction too. Rollback()
+// discards them.
+//
+// Commit() makes the changes, in the order in which they were made in
+// the transaction. If the knowledge base was changed by another goroutine
+// after Retract() was called, the clause which Commit() removes may be
+// a different one.
+//
+// The changes of a transaction are made to the map of the knowledge
+// base, so readers in other goroutines must not use the map while it is
+// committed. A knowledge base which is read by other goroutines is
+// shared:
+//
+//    shared := MakeSharedKB(kb)
+//    ...
+//    solutions, failure := SolveAll(query, shared.KnowledgeBase(), ss)
+//    ...
+//    tx := shared.Begin()
+//
+// A shared knowledge base has its own map of clauses, which is changed
+// under a mutex. Readers do not lock. KnowledgeBase() returns a table
+// of the clauses, which is never changed. It is a copy of the map (not
+// of the lists of clauses), which is made when the table is needed
+// after the clauses have changed, not for every change. A query which
+// runs on a table sees the same clauses to its end. It sees all the
+// changes of a commit, or none of them.
+//
+// LoadKBFromFile(), LoadKB() and LoadKBFS() add the clauses of their
+// files in a transaction. If a file has an error, or an initialization
+// goal fails, nothing is added. (See consult.go.)
+//
+// Cleve Lendon
+
+import (
+    "fmt"
+    "sync"
+    "sync/atomic"
+)
+
+// Transaction - changes to a knowledge base which have not been
+// committed.
+type Transaction struct {
+    kb       KnowledgeBase   // knowledge base, or nil
+    shared   *SharedKB       // shared knowledge base, or nil
+    view     KnowledgeBase   // overlay, which holds the changes
+    changes  []txChange
+    done     bool            // committed or rolled back
+}
+
+// txChange - a rule to add, or the head of a rule to retract.
+type txChange struct {
+    rule     RuleStruct
+    retract  Complex   // nil, if the rule is added
+}
+
+// SharedKB - a knowledge base which is read by several goroutines.
+type SharedKB struct {
+    mutex    sync.Mutex                      // serializes changes
+    clauses  KnowledgeBase                   // changed under the mutex
+    changed  atomic.Bool                     // clauses differ from table
+    table    atomic.Pointer[KnowledgeBase]   // never changed
+}
+
+// MakeSharedKB - makes a shared knowledge base, which has the clauses
+// of the given knowledge base. The given knowledge base is not changed.
+// Param:  knowledge base
+// Return: shared knowledge base
+func MakeSharedKB(kb KnowledgeBase) *SharedKB {
+    s := &SharedKB{ clauses: KnowledgeBase{} }
+    for key, rules := range kb.Flatten() {
+        // The capacity is limited, so that an append to the
+        // shared list could never write into the list of kb.
+        s.clauses[key] = rules[:len(rules):len(rules)]
+    }
+    s.publish()
+    return s
+}
+
+// publish - makes a new table of the clauses. The mutex must be held.
+func (s *SharedKB) publish() {
+    table := make(KnowledgeBase, len(s.clauses))
+    for key, rules := range s.clauses {
+        table[key] = rules[:len(rules):len(rules)]
+    }
+    s.table.Store(&table)
+    s.changed.Store(false)
+}
+
+// KnowledgeBase - returns the current table of the clauses of a shared
+// knowledge base. The table must not be changed.
+// Return: knowledge base
+func (s *SharedKB) KnowledgeBase() KnowledgeBase {
+    if s.changed.Load() {
+        s.mutex.Lock()
+        if s.changed.Load() { s.publish() }
+        s.mutex.Unlock()
+    }
+    return *s.table.Load()
+}
+
+// Add - adds facts and rules to a shared knowledge base.
+// Eg.  shared.Add(fact1, fact2, rule1, rule2)
+func (s *SharedKB) Add(rules ...RuleStruct) {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+    s.clauses.Add(rules...)
+    s.changed.Store(true)
+}
+
+// Retract - removes the first rule or fact whose head unifies with
+// the given term, from a shared knowledge base.
+// Param:  head of rule or fact, eg. mother(Carla, $X)
+// Return: true if a rule was removed
+func (s *SharedKB) Retract(head Complex) bool {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+    if !s.clauses.Retract(head) { return false }
+    s.changed.Store(true)
+    return true
+}
+
+// Begin - begins a transaction.
+// Return: transaction
+func (kb KnowledgeBase) Begin() *Transaction {
+    return beginOn(&Transaction{ kb: kb }, kb)
+}
+
+// Begin - begins a transaction on a shared knowledge base. The
+// transaction sees the current table.
+// Return: transaction
+func (s *SharedKB) Begin() *Transaction {
+    return beginOn(&Transaction{ shared: s }, s.KnowledgeBase())
+}
+
+// beginOn - makes the view of a transaction, which records the
+// changes made to it. (See Add() and Retract() in knowledgebase.go.)
+// Params: transaction
+//         knowledge base which the transaction sees
+// Return: transaction
+func beginOn(tx *Transaction, kb KnowledgeBase) *Transaction {
+    tx.view = kb.Overlay(OverlayLast)
+    tx.view.overlay().tx = tx
+    return tx
+}
+
+// Add - adds facts and rules, in the transaction.
+// Eg.  tx.Add(fact1, fact2, rule1, rule2)
+func (tx *Transaction) Add(rules ...RuleStruct) {
+    if tx.done { panic("Transaction, Add - The transaction has ended.") }
+    tx.view.Add(rules...)
+}
+
+// Retract - removes the first rule or fact whose head unifies with
+// the given term, in the transaction. (See KnowledgeBase.Retract().)
+// Param:  head of rule or fact, eg. word(envy, $_)
+// Return: true if a rule was removed
+func (tx *Transaction) Retract(head Complex) bool {
+    if tx.done { panic("Transaction, Retract - The transaction has ended.") }
+    return tx.view.Retract(head)
+}
+
+// KnowledgeBase - returns the knowledge base as the transaction sees
+// it, with its changes. It can be queried until the transaction ends.
+func (tx *Transaction) KnowledgeBase() KnowledgeBase { return tx.view }
+
+// Commit - makes the changes of the transaction to the knowledge base,
+// all at once. (See above.)
+// Return: error, if the transaction has already ended
+func (tx *Transaction) Commit() error {
+    if tx.done {
+        return fmt.Errorf("Transaction, Commit - The transaction has ended.")
+    }
+    tx.done = true
+    tx.view.Drop()
+    kb := tx.kb
+    if tx.shared != nil {
+        tx.shared.mutex.Lock()
+        defer tx.shared.mutex.Unlock()
+        kb = tx.shared.clauses
+    }
+    for _, change := range tx.changes {
+        if change.retract != nil {
+            kb.Retract(change.retract)
+        } else {
+            kb.Add(change.rule)
+        }
+    }
+    if tx.shared != nil && len(tx.changes) > 0 { tx.shared.changed.Store(true) }
+    return nil
+} // Commit
+
+// Rollback - discards the changes of the transaction. A transaction
+// which has ended is not affected.
+func (tx *Transaction) Rollback() {
+    if tx.done { return }
+    tx.done = true
+    tx.view.Drop()
+}
@@ -20,6 +20,11 @@ package suiron
 // Note: Sometimes this is referred to as the unification operator, but
 // it's actually a predicate.
 //
+// The predicate unify_with_occurs_check/2 is the same, but it fails if
+// the unification would create a cyclic term. (See occurs_check.go.)
+//
+//   unify_with_occurs_check($X, f($X))   # fails
+//
 // Cleve Lendon
 
 import (
@@ -39,6 +44,18 @@ func Unify(arguments ...Unifiable) UnifyStruct {
     }
 }
 
+// UnifyWithOccursCheck - creates the predicate:
+//    unify_with_occurs_check($X, $Y)
+func UnifyWithOccursCheck(arguments ...Unifiable) UnifyStruct {
+    if len(arguments) != 2 {
+        panic("UnifyWithOccursCheck - This predicate requires 2 arguments.")
+    }
+    return UnifyStruct {
+        Name: "unify_with_occurs_check",
+        Arguments: arguments,
+    }
+}
+
 // ParseUnify - creates a logical Unify predicate from a string.
 // If the string does not contain "=", the function returns with
 // the success flag set to false.
@@ -50,10 +67,8 @@ func Unify(arguments ...Unifiable) UnifyStruct {
 //     unify predicate
 //     success/failure flag
 func ParseUnify(str string) (UnifyStruct, bool) {
-    runes := []rune(str)
-    infix, index := identifyInfix(runes)
-    if infix != UNIFY { return UnifyStruct{}, false }  // Not a Unify.
-    term1, term2 := getLeftAndRight(runes, index, 1)
+    term1, term2, ok := parseInfix(str, "=")
+    if !ok { return UnifyStruct{}, false }
     return Unify(term1, term2), true
 }
 
@@ -89,9 +104,8 @@ func (us UnifyStruct) ReplaceVariables(ss SubstitutionSet) Expression {
 // String - creates a string representation.
 // Returns:  "arg1 = arg2"
 func (us UnifyStruct) String() string {
-    term1 := us.Arguments[0].String()
-    term2 := us.Arguments[1].String()
-    return term1 + " = " + term2
+    if us.Name != "unify" { return BuiltInPredicateStruct(us).String() }
+    return comparisonString(us.Arguments, " = ")
 }
 
 //----------------------------------------------------------------
@@ -142,6 +156,9 @@ func (sn *UnifySolutionNodeStruct) NextSolution() (SubstitutionSet, bool) {
     goal  := sn.Goal.(UnifyStruct)
     term1 := goal.Arguments[0]
     term2 := goal.Arguments[1]
+    if goal.Name == "unify_with_occurs_check" {
+        return unifyWithOccursCheck(term1, term2, sn.ParentSolution)
+    }
     return term1.Unify(term2, sn.ParentSolution)
 }
 
@@ -10,6 +10,7 @@ package suiron
 // Cleve Lendon
 
 import (
+    "sync/atomic"
     "strings"
     "unicode"
     "fmt"
@@ -26,7 +27,62 @@ func (v VariableStruct) String() string {
     return fmt.Sprint(v.name, "_", v.id)
 }
 
-var variableId int    // next ID number for logic variables
+var variableId int64  // last ID number given to a logic variable
+
+// parallelSearches counts the searches which are running in parallel.
+// (See parallel.go.)
+var parallelSearches int32
+
+// newVariableId - returns a new, unique ID number for a logic variable.
+// The counter is atomic, so that searches can run in parallel.
+func newVariableId() int {
+    return int(atomic.AddInt64(&variableId, 1))
+}
+
+// currentVariableId - returns the last ID number which was given.
+func currentVariableId() int {
+    return int(atomic.LoadInt64(&variableId))
+}
+
+// restoreVariableId - sets the ID counter back, after a rule fails
+// or when a query is created, in order to keep substitution sets small.
+// While searches run in parallel, ID numbers must never be reused, so
+// the counter is not changed.
+// Param: ID number
+func restoreVariableId(id int) {
+    if atomic.LoadInt32(&parallelSearches) > 0 { return }
+    atomic.StoreInt64(&variableId, int64(id))
+}
+
+// reserveVariableIds - ensures that new ID numbers will be greater
+// than the given ID number. Queries which were created earlier may
+// have variables with higher ID numbers than the counter.
+// Param: ID number
+func reserveVariableIds(id int) {
+    for {
+        current := atomic.LoadInt64(&variableId)
+        if current >= int64(id) { return }
+        if atomic.CompareAndSwapInt64(&variableId, current, int64(id)) { return }
+    }
+}
+
+// maxVariableId - returns the highest ID number of the variables of a term.
+func maxVariableId(term Unifiable) int {
+    max := 0
+    switch t := term.(type) {
+    case VariableStruct:
+        max = t.id
+    case Complex:
+        for _, arg := range t {
+            if id := maxVariableId(arg); id > max { max = id }
+        }
+    case LinkedListStruct:
+        for ptr := &t; ptr != nil && ptr.term != nil; ptr = ptr.next {
+            if id := maxVariableId(ptr.term); id > max { max = id }
+        }
+    }
+    return max
+}
 
 // LogicVar - Factory function to create a logic Variable from a string.
 // The variable must begin with a dollar sign and a letter. Eg. $X
@@ -79,6 +135,13 @@ func (v VariableStruct) Unify(other Unifiable, ss SubstitutionSet) (Substitution
     // substitution set has a variable with ID = 0 at location 0.
     if v.id == 0 { return ss, false }
 
+    // Follow a chain of bound variables iteratively. Recursion
+    // would use stack in proportion to the length of the chain.
+    for v.id < len(ss) && ss[v.id] != nil &&
+        (*ss[v.id]).TermType() == VARIABLE {
+        v = (*ss[v.id]).(VariableStruct)
+    }
+
     otherType := other.TermType()
 
     if otherType == VARIABLE {
@@ -95,16 +158,23 @@ func (v VariableStruct) Unify(other Unifiable, ss SubstitutionSet) (Substitution
 
     if v.id < lengthSrc && ss[v.id] != nil {
         u = ss[v.id]
+        // An unbound variable can have attributes, such as a domain.
+        if a, ok := (*u).(*attributes); ok {
+            return unifyAttributed(v, a, other, ss)
+        }
         return (*u).Unify(other, ss)
     }
 
-    lengthDst := lengthSrc
-    if v.id >= lengthDst { lengthDst = v.id + 1 }
-    newSS := make(SubstitutionSet, lengthDst)
-    copy(newSS, ss)
+    // If the other variable has attributes, bind this one to it.
+    if otherType == VARIABLE {
+        otherVar := other.(VariableStruct)
+        if _, ok := ss.getAttributes(otherVar); ok {
+            return ss.bind(v, other), true
+        }
+    }
 
-    newSS[v.id] = &other
-    return newSS, true
+    if !occursCheck(v, other, ss) { return ss, false }
+    return ss.bind(v, other), true
 
 } // Unify
 
@@ -123,8 +193,7 @@ func (v VariableStruct) RecreateVariables(vars VarMap) Expression {
     strVar := v.String()
     if newVar, ok = vars[strVar]; !ok {
         // Name has already been validated. No need to call LogicVar().
-        variableId++
-        newVar = VariableStruct{ name: v.name, id: variableId }
+        newVar = VariableStruct{ name: v.name, id: newVariableId() }
         vars[strVar] = newVar
     }
     return Expression(newVar)
@@ -134,11 +203,51 @@ func (v VariableStruct) RecreateVariables(vars VarMap) Expression {
 // This method is used for displaying final results.
 // Refer to comments in expression.go.
 func (v VariableStruct) ReplaceVariables(ss SubstitutionSet) Expression {
-    var u *Unifiable
-    if v.id < len(ss) && ss[v.id] != nil {
-        u = ss[v.id]
-        return (*u).ReplaceVariables(ss)
-    } else {
-        return Expression(v)
-    }
+    return v.replaceVariables(ss, map[int]bool{})
 } // ReplaceVariables()
+
+// replaceVariables - replaces a bound variable with its binding.
+// A cyclic term cannot be expanded, so a variable which is already
+// being expanded is left in place. (See occurs_check.go.)
+// Params: substitution set
+//         IDs of the variables being expanded
+// Return: binding (as Expression)
+func (v VariableStruct) replaceVariables(ss SubstitutionSet,
+                                         visiting map[int]bool) Expression {
+    // A chain of bound variables is followed iteratively.
+    for v.id < len(ss) && ss[v.id] != nil {
+        u := *ss[v.id]
+        tt := u.TermType()
+        if tt == ATTRIBUTES { return Expression(v) }
+        if tt == VARIABLE {
+            v = u.(VariableStruct)
+            continue
+        }
+        if tt != COMPLEX && tt != LINKEDLIST { return u.ReplaceVariables(ss) }
+        if visiting[v.id] { return Expression(v) }
+        visiting[v.id] = true
+        result := replaceBindings(u, ss, visiting)
+        delete(visiting, v.id)
+        return result
+    }
+    return Expression(v)
+} // replaceVariables()
+
+// replaceBindings - replaces the bound variables of a term with their
+// bindings, without expanding the variables which are being expanded.
+// Params: term
+//         substitution set
+//         IDs of the variables being expanded
+// Return: new term (as Expression)
+func replaceBindings(term Unifiable, ss SubstitutionSet,
+                     visiting map[int]bool) Expression {
+    switch term.TermType() {
+    case VARIABLE:
+        return term.(VariableStruct).replaceVariables(ss, visiting)
+    case COMPLEX:
+        return term.(Complex).replaceVariables(ss, visiting)
+    case LINKEDLIST:
+        return term.(LinkedListStruct).replaceVariables(ss, visiting)
+    }
+    return term.ReplaceVariables(ss)
+} // replaceBindings
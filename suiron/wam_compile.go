@@ -0,0 +1,896 @@
+package suiron
+
+// WAM Compiler - compiles the rules of a knowledge base into
+// instructions for an abstract machine, which is modelled on
+// the Warren Abstract Machine (WAM). See wam_machine.go.
+//
+// The tree-walking solver fetches a rule from the knowledge base
+// by copying it with RecreateVariables(), and then unifies the whole
+// head with the goal. A compiled clause does not need to be copied.
+// Its variables are kept in an environment (a slice of slots), and
+// its head and body are translated into simple instructions:
+//
+//   get_variable, get_value, get_constant,   - match the head arguments
+//   get_structure, get_list                    with the argument registers
+//   unify_variable, unify_value,             - match the arguments of
+//   unify_constant, unify_void                 a structure in the head
+//   put_variable, put_value, put_constant,   - load the arguments of
+//   put_structure, put_list, put_function      a goal into registers
+//   set_variable, set_value, set_constant,   - build the arguments of
+//   set_void, set_register                     a structure
+//   call, execute, proceed                   - call a predicate, call
+//                                              the last goal (with last
+//                                              call optimization), return
+//   try_me_else, retry_me_else, trust_me     - alternatives of an Or
+//   cut, cut_to, get_level, neck, jump, fail
+//
+// For example, the clause
+//
+//   partition([$X | $L], $Y, [$X | $L1], $L2) :- $X <= $Y, !,
+//                                                partition($L, $Y, $L1, $L2).
+//
+// becomes:
+//
+//   get_list A0
+//   unify_variable Y0 ($X)
+//   unify_variable Y1 ($L)
+//   get_variable Y2 ($Y), A1
+//   get_list A2
+//   unify_value Y0 ($X)
+//   unify_variable Y3 ($L1)
+//   get_variable Y4 ($L2), A3
+//   neck
+//   put_value Y0 ($X), A0
+//   put_value Y2 ($Y), A1
+//   builtin less_than_or_equal/2
+//   cut
+//   put_value Y1 ($L), A0
+//   ...
+//   execute partition/4
+//
+// The clauses of a predicate are tried in order (try, retry, trust).
+// Clauses whose first argument cannot match the first argument of the
+// goal are skipped, so that no choice point is left behind when only
+// one clause can match.
+//
+// Built-in predicates and functions are called through their own
+// solution nodes, as in the tree-walking solver, so they are fully
+// compatible. Goals of unknown types are instantiated from templates.
+//
+// Usage:
+//
+//   compiled := CompileKB(kb)
+//   solution, failure := compiled.Solve(query, SubstitutionSet{})
+//
+// The compiled knowledge base is a snapshot. Rules which are added
+// to the knowledge base afterwards are not seen by it.
+//
+// Cleve Lendon
+
+import (
+    "sort"
+    "strings"
+    "fmt"
+)
+
+// Operation codes.
+const (
+    opGetVariable = iota
+    opGetValue
+    opGetConstant
+    opGetStructure
+    opGetList
+    opGetTerm
+    opUnifyVariable
+    opUnifyValue
+    opUnifyConstant
+    opUnifyVoid
+    opPutVariable
+    opPutValue
+    opPutConstant
+    opPutStructure
+    opPutList
+    opPutFunction
+    opSetVariable
+    opSetValue
+    opSetConstant
+    opSetVoid
+    opSetRegister
+    opInitVariable
+    opNeck
+    opCall
+    opExecute
+    opProceed
+    opUnify
+    opBuiltIn
+    opCallGoal
+    opTryMeElse
+    opRetryMeElse
+    opTrustMe
+    opJump
+    opGetLevel
+    opCut
+    opCutTo
+    opFail
+)
+
+var opNames = []string{
+    "get_variable", "get_value", "get_constant", "get_structure",
+    "get_list", "get_term", "unify_variable", "unify_value",
+    "unify_constant", "unify_void", "put_variable", "put_value",
+    "put_constant", "put_structure", "put_list", "put_function",
+    "set_variable", "set_value", "set_constant", "set_void",
+    "set_register", "init_variable", "neck", "call", "execute",
+    "proceed", "unify", "builtin", "call_goal", "try_me_else",
+    "retry_me_else", "trust_me", "jump", "get_level", "cut",
+    "cut_to", "fail",
+}
+
+// wamInstruction - one instruction of the abstract machine.
+type wamInstruction struct {
+    op     int
+    a      int          // argument or temporary register
+    y      int          // slot of the environment
+    n      int          // arity, or jump address
+    value  Unifiable    // constant or functor
+    key    string       // predicate (call, execute)
+    proc   *wamProcedure
+    name   string       // name of built-in predicate or function
+    maker  func(BuiltInPredicateStruct) Expression
+    goal   Goal         // template (call_goal)
+    vars   []wamSlot    // variables of template (call_goal)
+}
+
+// wamSlot - associates a variable name with a slot of the environment.
+type wamSlot struct {
+    name  string
+    y     int
+}
+
+// wamIndexKey - identifies the principal functor of a first argument.
+// A key of type VARIABLE matches everything.
+type wamIndexKey struct {
+    tt     int
+    value  Unifiable
+    arity  int
+}
+
+// wamClause - a compiled rule or fact.
+type wamClause struct {
+    rule    RuleStruct
+    code    []wamInstruction
+    names   []string      // variable names, by slot
+    index   wamIndexKey   // first argument of head
+}
+
+// wamProcedure - the compiled clauses of one predicate.
+type wamProcedure struct {
+    key      string
+    arity    int
+    clauses  []*wamClause
+}
+
+// CompiledKB - a knowledge base which has been compiled for the
+// abstract machine.
+type CompiledKB struct {
+    kb          KnowledgeBase
+    procedures  map[string]*wamProcedure
+    registers   int   // number of registers needed
+}
+
+// CompileKB - compiles all rules of a knowledge base.
+// Params: knowledge base
+// Return: compiled knowledge base
+func CompileKB(kb KnowledgeBase) *CompiledKB {
+    kb = kb.Flatten()
+    ckb := &CompiledKB{ kb: kb, procedures: map[string]*wamProcedure{} }
+    // Compiling must not disturb the variable IDs of the current query.
+    saveId := currentVariableId()
+    defer restoreVariableId(saveId)
+    for key, rules := range kb {
+        proc := &wamProcedure{ key: key }
+        for _, rule := range rules {
+            proc.arity = rule.head.Arity()
+            c := &wamCompiler{ slots: map[string]int{} }
+            clause := c.compileClause(rule)
+            if c.registers > ckb.registers { ckb.registers = c.registers }
+            proc.clauses = append(proc.clauses, clause)
+        }
+        ckb.procedures[key] = proc
+    }
+    // Link calls to procedures.
+    for _, proc := range ckb.procedures {
+        for _, clause := range proc.clauses {
+            for i := range clause.code {
+                instr := &clause.code[i]
+                if instr.op == opCall || instr.op == opExecute {
+                    instr.proc = ckb.procedures[instr.key]
+                }
+            }
+        }
+    }
+    return ckb
+} // CompileKB
+
+// KnowledgeBase - returns the knowledge base which was compiled.
+func (ckb *CompiledKB) KnowledgeBase() KnowledgeBase { return ckb.kb }
+
+// wamCompiler - keeps track of the variables and registers of the
+// clause being compiled.
+type wamCompiler struct {
+    code       []wamInstruction
+    slots      map[string]int   // slot of each variable
+    names      []string
+    seen       map[string]bool  // variables which have a value
+    temps      int              // next temporary register
+    registers  int              // number of registers used
+}
+
+// compileClause - compiles the head and body of a rule.
+func (c *wamCompiler) compileClause(rule RuleStruct) *wamClause {
+    c.seen = map[string]bool{}
+    head := rule.head
+    arity := head.Arity()
+    c.useRegisters(arity)
+    for i := 1; i <= arity; i++ {
+        c.temps = arity
+        c.getArgument(head[i], i - 1)
+    }
+    c.emit(wamInstruction{ op: opNeck })
+    terminated := false
+    if rule.body != nil {
+        terminated = c.compileGoal(rule.body, true, -1)
+    }
+    if !terminated { c.emit(wamInstruction{ op: opProceed }) }
+    return &wamClause{ rule: rule, code: c.code, names: c.names,
+                       index: indexKey(head) }
+} // compileClause
+
+// emit - appends an instruction to the code.
+func (c *wamCompiler) emit(instr wamInstruction) {
+    c.code = append(c.code, instr)
+}
+
+// useRegisters - records the number of registers needed.
+func (c *wamCompiler) useRegisters(n int) {
+    if n > c.registers { c.registers = n }
+}
+
+// newTemp - allocates a temporary register.
+func (c *wamCompiler) newTemp() int {
+    t := c.temps
+    c.temps++
+    c.useRegisters(c.temps)
+    return t
+}
+
+// slot - returns the environment slot for a variable name.
+// A name which begins with '#' is a hidden temporary variable.
+func (c *wamCompiler) slot(name string) int {
+    if y, ok := c.slots[name]; ok { return y }
+    y := len(c.names)
+    c.slots[name] = y
+    c.names = append(c.names, name)
+    return y
+}
+
+// tempSlot - allocates a slot for an intermediate term.
+func (c *wamCompiler) tempSlot() int {
+    return c.slot(fmt.Sprintf("#%d", len(c.names)))
+}
+
+// firstOccurrence - returns true if the variable does not yet have
+// a value, and marks it as seen.
+func (c *wamCompiler) firstOccurrence(name string) bool {
+    if c.seen[name] { return false }
+    c.seen[name] = true
+    return true
+}
+
+//----------------------------------------------------------------
+// Head
+//----------------------------------------------------------------
+
+// nestedTerm - a structure within the head, which is matched after
+// the enclosing structure.
+type nestedTerm struct {
+    y     int
+    term  Unifiable
+}
+
+// getArgument - compiles code to match a head argument with
+// the argument register a.
+func (c *wamCompiler) getArgument(term Unifiable, a int) {
+    switch term.TermType() {
+    case VARIABLE:
+        name := term.(VariableStruct).String()
+        op := opGetValue
+        if c.firstOccurrence(name) { op = opGetVariable }
+        c.emit(wamInstruction{ op: op, y: c.slot(name), a: a })
+    case ANONYMOUS:
+        // Matches anything.
+    case ATOM, INTEGER, FLOAT:
+        c.emit(wamInstruction{ op: opGetConstant, value: term, a: a })
+    case COMPLEX, LINKEDLIST:
+        if isGround(term) {
+            c.emit(wamInstruction{ op: opGetConstant, value: term, a: a })
+        } else {
+            c.getStructure(term, a, -1)
+        }
+    default:
+        y := c.tempSlot()
+        c.emit(wamInstruction{ op: opGetVariable, y: y, a: a })
+        c.getTerm(term, y)
+    }
+} // getArgument
+
+// getStructure - compiles code to match a structure (complex term or
+// list) with a register (a >= 0) or an environment slot (y).
+func (c *wamCompiler) getStructure(term Unifiable, a int, y int) {
+    var args []Unifiable
+    if term.TermType() == COMPLEX {
+        comp := term.(Complex)
+        args = comp[1:]
+        c.emit(wamInstruction{ op: opGetStructure, value: comp[0],
+                               n: len(args), a: a, y: y })
+    } else {
+        list := term.(LinkedListStruct)
+        if list.term == nil {
+            if a >= 0 {
+                c.emit(wamInstruction{ op: opGetConstant, value: term, a: a })
+            } else {
+                c.emit(wamInstruction{ op: opGetTerm, value: term, y: y,
+                                       a: -1 })
+            }
+            return
+        }
+        head, tail := listParts(list)
+        args = []Unifiable{ head, tail }
+        c.emit(wamInstruction{ op: opGetList, n: 2, a: a, y: y })
+    }
+    nested := []nestedTerm{}
+    for _, arg := range args {
+        switch arg.TermType() {
+        case VARIABLE:
+            name := arg.(VariableStruct).String()
+            op := opUnifyValue
+            if c.firstOccurrence(name) { op = opUnifyVariable }
+            c.emit(wamInstruction{ op: op, y: c.slot(name) })
+        case ANONYMOUS:
+            c.emit(wamInstruction{ op: opUnifyVoid })
+        case ATOM, INTEGER, FLOAT:
+            c.emit(wamInstruction{ op: opUnifyConstant, value: arg })
+        case COMPLEX, LINKEDLIST:
+            if isGround(arg) {
+                c.emit(wamInstruction{ op: opUnifyConstant, value: arg })
+                continue
+            }
+            fallthrough
+        default:
+            t := c.tempSlot()
+            c.emit(wamInstruction{ op: opUnifyVariable, y: t })
+            nested = append(nested, nestedTerm{ y: t, term: arg })
+        }
+    }
+    for _, nt := range nested {
+        tt := nt.term.TermType()
+        if tt == COMPLEX || tt == LINKEDLIST {
+            c.getStructure(nt.term, -1, nt.y)
+        } else {
+            c.getTerm(nt.term, nt.y)
+        }
+    }
+} // getStructure
+
+// getTerm - compiles code to match a term, such as a function,
+// with an environment slot. The term is built in a register.
+func (c *wamCompiler) getTerm(term Unifiable, y int) {
+    t := c.newTemp()
+    c.putTerm(term, t)
+    c.emit(wamInstruction{ op: opGetTerm, y: y, a: t })
+}
+
+//----------------------------------------------------------------
+// Body
+//----------------------------------------------------------------
+
+// compileGoal - compiles a goal of the body.
+// Params: goal
+//         last goal of the clause (for last call optimization)
+//         slot which holds the cut barrier (-1 for the clause)
+// Return: true if the code ends with execute or proceed
+func (c *wamCompiler) compileGoal(goal Goal, last bool, cutSlot int) bool {
+    switch g := goal.(type) {
+    case AndOp:
+        if len(g) == 0 { return false }
+        for i, operand := range g {
+            if c.compileGoal(operand, last && i == len(g) - 1, cutSlot) {
+                return true
+            }
+        }
+        return false
+    case Complex:
+        arity := g.Arity()
+        c.putArguments(g[1:])
+        op := opCall
+        if last { op = opExecute }
+        c.emit(wamInstruction{ op: op, key: g.Key(), n: arity })
+        return last
+    case CutOp:
+        if cutSlot < 0 {
+            c.emit(wamInstruction{ op: opCut })
+        } else {
+            c.emit(wamInstruction{ op: opCutTo, y: cutSlot })
+        }
+        return false
+    case FailOp:
+        c.emit(wamInstruction{ op: opFail })
+        return false
+    case OrOp:
+        return c.compileOr(g, last, cutSlot)
+    case NotOp:
+        c.compileNot(g, cutSlot)
+        return false
+    }
+
+    if bip, maker, ok := asBuiltIn(goal); ok {
+        c.putArguments(bip.Arguments)
+        if _, isUnify := goal.(UnifyStruct); isUnify && bip.Name == "unify" {
+            c.emit(wamInstruction{ op: opUnify })
+        } else {
+            c.emit(wamInstruction{ op: opBuiltIn, name: bip.Name,
+                                   n: len(bip.Arguments), maker: maker })
+        }
+        return false
+    }
+
+    // A goal of unknown type is instantiated from a template.
+    // All of its variables must have values.
+    names := c.initVariables(goal)
+    vars := []wamSlot{}
+    for _, name := range names {
+        vars = append(vars, wamSlot{ name: name, y: c.slot(name) })
+    }
+    c.emit(wamInstruction{ op: opCallGoal, goal: goal, vars: vars })
+    return false
+} // compileGoal
+
+// compileOr - compiles the operands of an Or as alternatives.
+//
+//       try_me_else L1
+//       <first operand>
+//       jump Lend
+//   L1: retry_me_else L2
+//       <second operand>
+//       jump Lend
+//   L2: trust_me
+//       <last operand>
+//   Lend:
+func (c *wamCompiler) compileOr(or OrOp, last bool, cutSlot int) bool {
+    c.initVariables(or)
+    if len(or) == 0 { return false }
+    jumps := []int{}
+    previous := -1   // try_me_else or retry_me_else to patch
+    allTerminated := true
+    for i, operand := range or {
+        if i > 0 {
+            c.code[previous].n = len(c.code)
+        }
+        if i == 0 && len(or) > 1 {
+            previous = len(c.code)
+            c.emit(wamInstruction{ op: opTryMeElse })
+        } else if i > 0 && i < len(or) - 1 {
+            previous = len(c.code)
+            c.emit(wamInstruction{ op: opRetryMeElse })
+        } else if i > 0 {
+            c.emit(wamInstruction{ op: opTrustMe })
+        }
+        terminated := c.compileGoal(operand, last, cutSlot)
+        if !terminated && last {
+            c.emit(wamInstruction{ op: opProceed })
+            terminated = true
+        }
+        if !terminated {
+            allTerminated = false
+            if i < len(or) - 1 {
+                jumps = append(jumps, len(c.code))
+                c.emit(wamInstruction{ op: opJump })
+            }
+        }
+    }
+    for _, j := range jumps { c.code[j].n = len(c.code) }
+    return allTerminated
+} // compileOr
+
+// compileNot - compiles negation as failure.
+//
+//       get_level Yn
+//       try_me_else L1
+//       <operand>        (a cut here is cut_to Yn)
+//       cut_to Yn
+//       fail
+//   L1: trust_me
+func (c *wamCompiler) compileNot(not NotOp, cutSlot int) {
+    c.initVariables(not)
+    level := c.tempSlot()
+    c.emit(wamInstruction{ op: opGetLevel, y: level })
+    try := len(c.code)
+    c.emit(wamInstruction{ op: opTryMeElse })
+    for _, operand := range not {
+        c.compileGoal(operand, false, level)
+    }
+    c.emit(wamInstruction{ op: opCutTo, y: level })
+    c.emit(wamInstruction{ op: opFail })
+    c.code[try].n = len(c.code)
+    c.emit(wamInstruction{ op: opTrustMe })
+}
+
+// initVariables - gives a value (a new variable) to the variables of
+// a goal which do not have one yet. This is necessary for alternatives
+// (Or), because the first occurrence of a variable in one alternative
+// is not executed when another alternative is chosen.
+// Return: names of all variables of the goal
+func (c *wamCompiler) initVariables(goal Expression) []string {
+    names := variableNames(goal)
+    for _, name := range names {
+        if c.firstOccurrence(name) {
+            c.emit(wamInstruction{ op: opInitVariable, y: c.slot(name) })
+        }
+    }
+    return names
+}
+
+// putArguments - compiles code to load the arguments of a goal
+// into the argument registers.
+func (c *wamCompiler) putArguments(args []Unifiable) {
+    c.temps = len(args)
+    c.useRegisters(c.temps)
+    for i, arg := range args { c.putTerm(arg, i) }
+}
+
+// putTerm - compiles code to load a term into register a.
+// Nested structures are built first, in temporary registers.
+func (c *wamCompiler) putTerm(term Unifiable, a int) {
+    if isGround(term) {
+        c.emit(wamInstruction{ op: opPutConstant, value: term, a: a })
+        return
+    }
+    switch term.TermType() {
+    case VARIABLE:
+        name := term.(VariableStruct).String()
+        op := opPutValue
+        if c.firstOccurrence(name) { op = opPutVariable }
+        c.emit(wamInstruction{ op: op, y: c.slot(name), a: a })
+        return
+    case COMPLEX:
+        comp := term.(Complex)
+        sets := c.setArguments(comp[1:])
+        c.emit(wamInstruction{ op: opPutStructure, value: comp[0],
+                               n: len(sets), a: a })
+        c.code = append(c.code, sets...)
+        return
+    case LINKEDLIST:
+        list := term.(LinkedListStruct)
+        if list.term != nil {
+            head, tail := listParts(list)
+            sets := c.setArguments([]Unifiable{ head, tail })
+            c.emit(wamInstruction{ op: opPutList, n: 2, a: a })
+            c.code = append(c.code, sets...)
+            return
+        }
+    case FUNCTION:
+        if bip, maker, ok := asBuiltIn(term); ok {
+            sets := c.setArguments(bip.Arguments)
+            c.emit(wamInstruction{ op: opPutFunction, name: bip.Name,
+                                   maker: maker, n: len(sets), a: a })
+            c.code = append(c.code, sets...)
+            return
+        }
+    }
+    c.emit(wamInstruction{ op: opPutConstant, value: term, a: a })
+} // putTerm
+
+// setArguments - compiles the arguments of a structure which is
+// being built. Nested structures are put in temporary registers
+// first. The set instructions are returned, so that they can be
+// placed after put_structure.
+func (c *wamCompiler) setArguments(args []Unifiable) []wamInstruction {
+    registers := make([]int, len(args))
+    for i, arg := range args {
+        registers[i] = -1
+        if isStructure(arg) {
+            registers[i] = c.newTemp()
+            c.putTerm(arg, registers[i])
+        }
+    }
+    sets := []wamInstruction{}
+    for i, arg := range args {
+        if registers[i] >= 0 {
+            sets = append(sets, wamInstruction{ op: opSetRegister,
+                                                a: registers[i] })
+            continue
+        }
+        switch arg.TermType() {
+        case VARIABLE:
+            name := arg.(VariableStruct).String()
+            op := opSetValue
+            if c.firstOccurrence(name) { op = opSetVariable }
+            sets = append(sets, wamInstruction{ op: op, y: c.slot(name) })
+        case ANONYMOUS:
+            sets = append(sets, wamInstruction{ op: opSetVoid })
+        default:
+            sets = append(sets, wamInstruction{ op: opSetConstant,
+                                                value: arg })
+        }
+    }
+    return sets
+} // setArguments
+
+// isStructure - returns true for terms which must be built:
+// complex terms, non-empty lists, and built-in functions, which
+// contain variables.
+func isStructure(term Unifiable) bool {
+    if isGround(term) { return false }
+    switch term.TermType() {
+    case COMPLEX:
+        return true
+    case LINKEDLIST:
+        return term.(LinkedListStruct).term != nil
+    case FUNCTION:
+        _, _, ok := asBuiltIn(term)
+        return ok
+    }
+    return false
+}
+
+//----------------------------------------------------------------
+// Utilities
+//----------------------------------------------------------------
+
+// listParts - divides a non-empty list into its head and its tail.
+func listParts(list LinkedListStruct) (Unifiable, Unifiable) {
+    next := list.next
+    if next == nil { return list.term, emptyList }
+    if next.tailVar { return list.term, next.term }
+    return list.term, *next
+}
+
+// makeList - creates the list [head | tail].
+func makeList(head, tail Unifiable) LinkedListStruct {
+    if t, ok := tail.(LinkedListStruct); ok {
+        if t.term == nil {
+            return LinkedListStruct{ term: head, next: &emptyList, count: 1 }
+        }
+        return LinkedListStruct{ term: head, next: &t, count: t.count + 1 }
+    }
+    last := &LinkedListStruct{ term: tail, next: &emptyList,
+                               count: 1, tailVar: true }
+    return LinkedListStruct{ term: head, next: last, count: 2 }
+}
+
+// indexKey - gets the index key of the first argument of a head.
+func indexKey(head Complex) wamIndexKey {
+    if len(head) < 2 { return wamIndexKey{ tt: VARIABLE } }
+    return termIndexKey(head[1], nil, false)
+}
+
+// termIndexKey - gets the index key of a term. The arguments of
+// a goal are dereferenced first.
+// Params: term
+//         substitution set
+//         dereference flag
+func termIndexKey(term Unifiable, ss SubstitutionSet, deref bool) wamIndexKey {
+    if deref { term, _ = ss.GetGroundTerm(term) }
+    switch t := term.(type) {
+    case Atom, Integer, Float:
+        return wamIndexKey{ tt: term.TermType(), value: term }
+    case Complex:
+        return wamIndexKey{ tt: COMPLEX, value: t[0], arity: len(t) }
+    case LinkedListStruct:
+        if t.term == nil { return wamIndexKey{ tt: LINKEDLIST } }
+        if t.tailVar { break }
+        return wamIndexKey{ tt: LINKEDLIST, arity: 1 }
+    }
+    return wamIndexKey{ tt: VARIABLE }
+}
+
+// matches - returns true if a clause with this key could match
+// an argument with the other key.
+func (k wamIndexKey) matches(other wamIndexKey) bool {
+    if k.tt == VARIABLE || other.tt == VARIABLE { return true }
+    return k == other
+}
+
+// isGround - returns true if a term has no variables. A ground term
+// can be treated as a constant.
+func isGround(term Unifiable) bool {
+    switch term.TermType() {
+    case VARIABLE:
+        return false
+    case ATOM, INTEGER, FLOAT, ANONYMOUS:
+        return true
+    }
+    return len(variableNames(term)) == 0
+}
+
+// variableNames - gets the names of the variables in an expression.
+// The names are sorted.
+func variableNames(e Expression) []string {
+    saveId := currentVariableId()
+    vars := VarMap{}
+    e.RecreateVariables(vars)
+    restoreVariableId(saveId)
+    names := []string{}
+    for name := range vars { names = append(names, name) }
+    sort.Strings(names)
+    return names
+}
+
+// asBuiltIn - if the expression is a built-in predicate or function,
+// returns its name and arguments, and a function which makes a new
+// predicate or function of the same type.
+// Params: expression
+// Return: built-in predicate struct
+//         maker
+//         success flag
+func asBuiltIn(e Expression) (BuiltInPredicateStruct,
+                              func(BuiltInPredicateStruct) Expression, bool) {
+    switch b := e.(type) {
+    case AddStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return AddStruct(s) }, true
+    case SubtractStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return SubtractStruct(s) }, true
+    case MultiplyStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return MultiplyStruct(s) }, true
+    case DivideStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return DivideStruct(s) }, true
+    case JoinStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return JoinStruct(s) }, true
+    case BIFTemplateStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return BIFTemplateStruct(s) }, true
+    case UnifyStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return UnifyStruct(s) }, true
+    case EqualStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return EqualStruct(s) }, true
+    case LessThanStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return LessThanStruct(s) }, true
+    case LessThanOrEqualStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return LessThanOrEqualStruct(s) }, true
+    case GreaterThanStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return GreaterThanStruct(s) }, true
+    case GreaterThanOrEqualStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return GreaterThanOrEqualStruct(s) }, true
+    case AppendStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return AppendStruct(s) }, true
+    case PrintStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return PrintStruct(s) }, true
+    case PrintListStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return PrintListStruct(s) }, true
+    case NewLineStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return NewLineStruct(s) }, true
+    case FunctorStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return FunctorStruct(s) }, true
+    case IncludeStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return IncludeStruct(s) }, true
+    case ExcludeStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return ExcludeStruct(s) }, true
+    case CountStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return CountStruct(s) }, true
+    case TimeStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return TimeStruct(s) }, true
+    case BIPTemplateStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return BIPTemplateStruct(s) }, true
+    case FDConstraintStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return FDConstraintStruct(s) }, true
+    case LabelStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return LabelStruct(s) }, true
+    case CoroutineStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return CoroutineStruct(s) }, true
+    case TraceStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return TraceStruct(s) }, true
+    case ProfileStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return ProfileStruct(s) }, true
+    case ClausePropertyStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return ClausePropertyStruct(s) }, true
+    case OpStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return OpStruct(s) }, true
+    case PhraseStruct:
+        return BuiltInPredicateStruct(b), func(s BuiltInPredicateStruct) Expression {
+            return PhraseStruct(s) }, true
+    }
+    return BuiltInPredicateStruct{}, nil, false
+} // asBuiltIn
+
+// String - formats an instruction for display.
+func (instr wamInstruction) String() string {
+    name := opNames[instr.op]
+    switch instr.op {
+    case opGetVariable, opGetValue, opPutVariable, opPutValue:
+        return fmt.Sprintf("%v Y%d, A%d", name, instr.y, instr.a)
+    case opGetConstant, opPutConstant:
+        return fmt.Sprintf("%v %v, A%d", name, instr.value, instr.a)
+    case opGetStructure, opGetList:
+        functor := ""
+        if instr.op == opGetStructure {
+            functor = fmt.Sprintf("%v/%d, ", instr.value, instr.n)
+        }
+        if instr.a < 0 {
+            return fmt.Sprintf("%v %vY%d", name, functor, instr.y)
+        }
+        return fmt.Sprintf("%v %vA%d", name, functor, instr.a)
+    case opGetTerm:
+        if instr.a < 0 { return fmt.Sprintf("%v %v, Y%d", name, instr.value, instr.y) }
+        return fmt.Sprintf("%v Y%d, A%d", name, instr.y, instr.a)
+    case opPutStructure:
+        return fmt.Sprintf("%v %v/%d, A%d", name, instr.value, instr.n, instr.a)
+    case opPutList:
+        return fmt.Sprintf("%v A%d", name, instr.a)
+    case opPutFunction:
+        return fmt.Sprintf("%v %v/%d, A%d", name, instr.name, instr.n, instr.a)
+    case opUnifyVariable, opUnifyValue, opSetVariable, opSetValue,
+         opInitVariable, opGetLevel, opCutTo:
+        return fmt.Sprintf("%v Y%d", name, instr.y)
+    case opUnifyConstant, opSetConstant:
+        return fmt.Sprintf("%v %v", name, instr.value)
+    case opSetRegister:
+        return fmt.Sprintf("%v A%d", name, instr.a)
+    case opCall, opExecute:
+        return fmt.Sprintf("%v %v", name, instr.key)
+    case opBuiltIn:
+        return fmt.Sprintf("%v %v/%d", name, instr.name, instr.n)
+    case opCallGoal:
+        return fmt.Sprintf("%v %v", name, instr.goal)
+    case opTryMeElse, opRetryMeElse, opJump:
+        return fmt.Sprintf("%v %d", name, instr.n)
+    }
+    return name
+} // String
+
+// FormatCode - formats the compiled code of the knowledge base for
+// display. This method is useful for diagnostics. The keys are sorted.
+func (ckb *CompiledKB) FormatCode() string {
+    var sb strings.Builder
+    sb.WriteString("\n########## Compiled Knowledge Base ##########\n")
+    keys := make([]string, 0, len(ckb.procedures))
+    for k := range ckb.procedures { keys = append(keys, k) }
+    sort.Strings(keys)
+    for _, k := range keys {
+        sb.WriteString(k + "\n")
+        for _, clause := range ckb.procedures[k].clauses {
+            sb.WriteString("    % " + clause.rule.String() + "\n")
+            for i, instr := range clause.code {
+                sb.WriteString(fmt.Sprintf("    %3d  %v\n", i, instr))
+            }
+        }
+    }
+    return sb.String()
+} // FormatCode
@@ -0,0 +1,526 @@
+package suiron
+
+// WAM Machine - executes the instructions of a compiled knowledge base.
+// (See wam_compile.go.)
+//
+// The state of the machine consists of:
+//
+//   registers    - the arguments of the current call
+//   environment  - the slots (variables) of the current clause, and
+//                  its continuation: the environment and instruction
+//                  to return to when the clause succeeds
+//   pc           - the next instruction of the current clause
+//   choice points
+//
+// As in engine.go, the machine is a loop, so the Go stack does not grow.
+// A call made by execute (the last goal of a clause) passes on the
+// continuation of the caller, so the caller's environment can be
+// discarded (last call optimization).
+//
+// Bindings are recorded in the substitution set, as they are by the
+// tree-walking solver. The choice point records the substitution set,
+// so backtracking does not need a trail.
+//
+// There are three kinds of choice points: the remaining clauses of a
+// predicate (try, retry, trust), the remaining alternatives of an Or
+// (try_me_else, retry_me_else, trust_me), and a built-in predicate
+// which may have more solutions.
+//
+// Cleve Lendon
+
+// Kinds of choice points.
+const (
+    wamClauses = iota
+    wamElse
+    wamNode
+)
+
+// wamEnvironment - the variables and continuation of a clause.
+type wamEnvironment struct {
+    clause  *wamClause
+    slots   []Unifiable
+    cutB    int              // height of choice point stack, for cut
+    cont    *wamEnvironment  // continuation
+    contPC  int
+}
+
+// wamChoicePoint - records alternatives which remain to be tried.
+type wamChoicePoint struct {
+    kind    int
+    ss      SubstitutionSet
+    env     *wamEnvironment  // wamElse, wamNode; continuation for wamClauses
+    pc      int              // alternative (wamElse), next instruction (wamNode)
+    cutB    int              // wamClauses
+    proc    *wamProcedure    // wamClauses
+    next    int              // wamClauses - next clause to try
+    args    []Unifiable      // wamClauses - saved argument registers
+    node    SolutionNode     // wamNode
+}
+
+// WAMSolutionNodeStruct - the solution node of the abstract machine.
+type WAMSolutionNodeStruct struct {
+    SolutionNodeStruct
+    program     *CompiledKB
+    started     bool
+    ss          SubstitutionSet
+    env         *wamEnvironment
+    pc          int
+    registers   []Unifiable
+    stack       []wamChoicePoint
+    inHead      bool
+    fallbackId  int
+    stopContext *searchContext  // passes the stop flag to built-ins
+}
+
+// GetSolver - returns a solution node which solves a query with
+// the compiled knowledge base.
+// Params: query
+//         substitution set (previous bindings)
+// Return: solution node
+func (ckb *CompiledKB) GetSolver(query Complex,
+                                 parentSolution SubstitutionSet) SolutionNode {
+    n := ckb.registers
+    if query.Arity() > n { n = query.Arity() }
+    node := WAMSolutionNodeStruct{
+                SolutionNodeStruct: MakeSolutionNode(query, ckb.kb,
+                                        parentSolution, nil),
+                program: ckb,
+                registers: make([]Unifiable, n),
+            }
+    return &node
+}
+
+// Solve - finds one solution for the given query, using the compiled
+// knowledge base. See Solve() in solutions.go.
+// Params:  query
+//          substitution set (previous bindings)
+// Returns: solution
+//          reason for failure
+func (ckb *CompiledKB) Solve(query Complex,
+                             ss SubstitutionSet) (Complex, string) {
+    return solveFirst(query, func() SolutionNode {
+        return ckb.GetSolver(query, ss)
+    })
+}
+
+// SolveAll - finds all solutions for the given query, using the
+// compiled knowledge base. See SolveAll() in solutions.go.
+// Params:  query
+//          substitution set (previous bindings)
+// Returns: solutions
+//          reason for failure
+func (ckb *CompiledKB) SolveAll(query Complex,
+                                ss SubstitutionSet) ([]Complex, string) {
+    return solveEvery(query, func() SolutionNode {
+        return ckb.GetSolver(query, ss)
+    })
+}
+
+// NextSolution - initiates or continues the search for a solution.
+// Returns:
+//    updated substitution set
+//    success/failure flag
+// This function satisfies the SolutionNode interface.
+func (m *WAMSolutionNodeStruct) NextSolution() (SubstitutionSet, bool) {
+    if m.NoBackTracking { return nil, false }
+    if !m.started {
+        m.started = true
+        m.ss = m.ParentSolution
+        query := m.Goal.(Complex)
+        copy(m.registers, query[1:])
+        proc := m.program.procedures[query.Key()]
+        if proc == nil { return nil, false }
+        if !m.enter(proc, 0, nil, 0, 0) {
+            return m.run(true)
+        }
+        return m.run(false)
+    }
+    return m.run(true)
+}
+
+// SetNoBackTracking - set the NoBackTracking flag,
+// which is used to implement Cuts.
+// This function satisfies the SolutionNode interface.
+func (m *WAMSolutionNodeStruct) SetNoBackTracking() {
+    m.NoBackTracking = true
+}
+
+// GetParentNode
+func (m *WAMSolutionNodeStruct) GetParentNode() SolutionNode {
+    return m.ParentNode
+}
+
+// newVariable - creates a new, unique variable for a slot.
+func (m *WAMSolutionNodeStruct) newVariable(y int) VariableStruct {
+    return VariableStruct{ name: m.env.clause.names[y], id: newVariableId() }
+}
+
+// run - executes instructions until a solution is found, or
+// there are no more choice points.
+// Params: backtrack first (to get the next solution)
+// Return: solution (substitution set)
+//         success/failure flag
+func (m *WAMSolutionNodeStruct) run(failed bool) (SubstitutionSet, bool) {
+
+    for {
+        if m.stopped() {
+            m.stack = nil
+            return nil, false
+        }
+
+        if failed {
+            if m.inHead { restoreVariableId(m.fallbackId) }
+            if !m.backtrack() { return nil, false }
+            failed = false
+        }
+
+        if m.env == nil { return m.ss, true }
+
+        env := m.env
+        code := env.clause.code
+        instr := &code[m.pc]
+        m.pc++
+        ok := true
+
+        switch instr.op {
+
+        // Head
+        case opGetVariable:
+            term := m.registers[instr.a]
+            if term.TermType() == ANONYMOUS { term = m.newVariable(instr.y) }
+            env.slots[instr.y] = term
+        case opGetValue:
+            term := m.registers[instr.a]
+            if term.TermType() != ANONYMOUS {
+                m.ss, ok = env.slots[instr.y].Unify(term, m.ss)
+            }
+        case opGetConstant:
+            m.ss, ok = instr.value.Unify(m.registers[instr.a], m.ss)
+        case opGetStructure, opGetList:
+            var term Unifiable
+            if instr.a >= 0 {
+                term = m.registers[instr.a]
+            } else {
+                term = env.slots[instr.y]
+            }
+            ok = m.getStructure(instr, term, code[m.pc:m.pc + instr.n])
+            m.pc += instr.n
+        case opGetTerm:
+            if instr.a < 0 {
+                m.ss, ok = instr.value.Unify(env.slots[instr.y], m.ss)
+            } else {
+                m.ss, ok = m.registers[instr.a].Unify(env.slots[instr.y], m.ss)
+            }
+        case opNeck:
+            m.inHead = false
+            ok = m.wake()
+
+        // Body
+        case opPutVariable:
+            v := m.newVariable(instr.y)
+            env.slots[instr.y] = v
+            m.registers[instr.a] = v
+        case opPutValue:
+            m.registers[instr.a] = env.slots[instr.y]
+        case opPutConstant:
+            m.registers[instr.a] = instr.value
+        case opPutStructure, opPutList, opPutFunction:
+            args := m.build(code[m.pc:m.pc + instr.n])
+            m.pc += instr.n
+            switch instr.op {
+            case opPutStructure:
+                m.registers[instr.a] = append(Complex{ instr.value }, args...)
+            case opPutList:
+                m.registers[instr.a] = makeList(args[0], args[1])
+            default:
+                bip := BuiltInPredicateStruct{ Name: instr.name, Arguments: args }
+                m.registers[instr.a] = instr.maker(bip).(Unifiable)
+            }
+        case opInitVariable:
+            env.slots[instr.y] = m.newVariable(instr.y)
+        case opCall:
+            if instr.proc == nil { ok = false; break }
+            ok = m.enter(instr.proc, 0, env, m.pc, len(m.stack))
+        case opExecute:
+            if instr.proc == nil { ok = false; break }
+            ok = m.enter(instr.proc, 0, env.cont, env.contPC, len(m.stack))
+        case opProceed:
+            m.env = env.cont
+            m.pc = env.contPC
+        case opUnify:
+            m.ss, ok = m.registers[0].Unify(m.registers[1], m.ss)
+            if ok { ok = m.wake() }
+        case opBuiltIn:
+            args := make([]Unifiable, instr.n)
+            copy(args, m.registers)
+            bip := BuiltInPredicateStruct{ Name: instr.name, Arguments: args }
+            ok = m.callNode(instr.maker(bip).(Goal))
+            if ok { ok = m.wake() }
+        case opCallGoal:
+            ok = m.callNode(m.instantiate(instr))
+            if ok { ok = m.wake() }
+
+        // Control
+        case opTryMeElse:
+            m.stack = append(m.stack, wamChoicePoint{ kind: wamElse,
+                          ss: m.ss, env: env, pc: instr.n })
+        case opRetryMeElse:
+            m.stack[len(m.stack) - 1].pc = instr.n
+        case opTrustMe:
+            m.stack = m.stack[:len(m.stack) - 1]
+        case opJump:
+            m.pc = instr.n
+        case opGetLevel:
+            env.slots[instr.y] = Integer(len(m.stack))
+        case opCut:
+            if len(m.stack) > env.cutB { m.stack = m.stack[:env.cutB] }
+        case opCutTo:
+            level := int(env.slots[instr.y].(Integer))
+            if len(m.stack) > level { m.stack = m.stack[:level] }
+        case opFail:
+            ok = false
+        }
+
+        failed = !ok
+    }
+} // run
+
+// enter - tries the clauses of a procedure, beginning with the given
+// clause. Clauses whose first argument cannot match are skipped. If
+// more clauses could match, a choice point is pushed (try).
+// Params: procedure
+//         number of first clause to try
+//         continuation (environment and pc)
+//         height of choice point stack, for cut
+// Return: success/failure flag
+func (m *WAMSolutionNodeStruct) enter(proc *wamProcedure, first int,
+                                      cont *wamEnvironment, contPC int,
+                                      cutB int) bool {
+    key := m.argumentKey(proc)
+    i := proc.nextClause(first, key)
+    if i < 0 { return false }
+    if j := proc.nextClause(i + 1, key); j >= 0 {
+        args := make([]Unifiable, proc.arity)
+        copy(args, m.registers)
+        m.stack = append(m.stack, wamChoicePoint{ kind: wamClauses,
+                      ss: m.ss, env: cont, pc: contPC, cutB: cutB,
+                      proc: proc, next: j, args: args })
+    }
+    m.startClause(proc.clauses[i], cont, contPC, cutB)
+    return true
+} // enter
+
+// startClause - creates an environment for a clause, and starts
+// executing its code.
+func (m *WAMSolutionNodeStruct) startClause(clause *wamClause,
+                                            cont *wamEnvironment, contPC int,
+                                            cutB int) {
+    m.env = &wamEnvironment{ clause: clause,
+                             slots: make([]Unifiable, len(clause.names)),
+                             cutB: cutB, cont: cont, contPC: contPC }
+    m.pc = 0
+    m.inHead = true
+    m.fallbackId = currentVariableId()
+}
+
+// argumentKey - gets the index key of the first argument register.
+func (m *WAMSolutionNodeStruct) argumentKey(proc *wamProcedure) wamIndexKey {
+    if proc.arity == 0 { return wamIndexKey{ tt: VARIABLE } }
+    return termIndexKey(m.registers[0], m.ss, true)
+}
+
+// nextClause - finds the next clause whose first argument could
+// match the given key.
+// Return: clause number, or -1
+func (proc *wamProcedure) nextClause(i int, key wamIndexKey) int {
+    for ; i < len(proc.clauses); i++ {
+        if proc.clauses[i].index.matches(key) { return i }
+    }
+    return -1
+}
+
+// backtrack - resumes the most recent choice point. A choice point
+// with no more alternatives is removed (trust).
+// Return: success/failure flag (false if there are no more choices)
+func (m *WAMSolutionNodeStruct) backtrack() bool {
+    for len(m.stack) > 0 {
+        top := len(m.stack) - 1
+        cp := &m.stack[top]
+        switch cp.kind {
+        case wamClauses:
+            m.ss = cp.ss
+            copy(m.registers, cp.args)
+            clause := cp.proc.clauses[cp.next]
+            j := cp.proc.nextClause(cp.next + 1, m.argumentKey(cp.proc))
+            cont, contPC, cutB := cp.env, cp.pc, cp.cutB
+            if j >= 0 {
+                cp.next = j                 // retry
+            } else {
+                m.stack = m.stack[:top]     // trust
+            }
+            m.startClause(clause, cont, contPC, cutB)
+            return true
+        case wamElse:
+            // The instruction at the alternative is
+            // retry_me_else or trust_me.
+            m.ss = cp.ss
+            m.env = cp.env
+            m.pc = cp.pc
+            m.inHead = false
+            return true
+        case wamNode:
+            solution, found := cp.node.NextSolution()
+            if found {
+                m.ss = solution
+                m.env = cp.env
+                m.pc = cp.pc
+                m.inHead = false
+                return true
+            }
+            m.stack = m.stack[:top]
+        }
+    }
+    return false
+} // backtrack
+
+// getStructure - matches a structure of the head (get_structure or
+// get_list, followed by unify instructions) with a term. If the term
+// is an unbound variable, the structure is built and bound to it
+// (write mode). Otherwise, the arguments are matched (read mode).
+// Params: get_structure or get_list instruction
+//         term
+//         unify instructions
+// Return: success/failure flag
+func (m *WAMSolutionNodeStruct) getStructure(instr *wamInstruction,
+                                             term Unifiable,
+                                             unify []wamInstruction) bool {
+    term, _ = m.ss.GetGroundTerm(term)
+    var subterms []Unifiable
+    switch t := term.(type) {
+    case Complex:
+        if instr.op != opGetStructure || len(t) != instr.n + 1 ||
+           t[0] != instr.value { return false }
+        subterms = t[1:]
+    case LinkedListStruct:
+        if instr.op != opGetList || t.term == nil || t.tailVar { return false }
+        head, tail := listParts(t)
+        subterms = []Unifiable{ head, tail }
+    case Atom, Integer, Float:
+        return false
+    }
+
+    if subterms == nil {   // write mode
+        var built Unifiable
+        args := m.build(unify)
+        if instr.op == opGetStructure {
+            built = append(Complex{ instr.value }, args...)
+        } else {
+            built = makeList(args[0], args[1])
+        }
+        var ok bool
+        m.ss, ok = built.Unify(term, m.ss)
+        return ok
+    }
+
+    env := m.env
+    for i := range unify {
+        sub := subterms[i]
+        u := &unify[i]
+        ok := true
+        switch u.op {
+        case opUnifyVariable:
+            if sub.TermType() == ANONYMOUS { sub = m.newVariable(u.y) }
+            env.slots[u.y] = sub
+        case opUnifyValue:
+            if sub.TermType() != ANONYMOUS {
+                m.ss, ok = env.slots[u.y].Unify(sub, m.ss)
+            }
+        case opUnifyConstant:
+            m.ss, ok = u.value.Unify(sub, m.ss)
+        }
+        if !ok { return false }
+    }
+    return true
+} // getStructure
+
+// build - gets the arguments of a structure which is being built.
+// The instructions are unify instructions (write mode), or set
+// instructions.
+// Param:  instructions
+// Return: arguments
+func (m *WAMSolutionNodeStruct) build(set []wamInstruction) []Unifiable {
+    env := m.env
+    args := make([]Unifiable, len(set))
+    for i := range set {
+        s := &set[i]
+        switch s.op {
+        case opUnifyVariable, opSetVariable:
+            v := m.newVariable(s.y)
+            env.slots[s.y] = v
+            args[i] = v
+        case opUnifyValue, opSetValue:
+            args[i] = env.slots[s.y]
+        case opUnifyConstant, opSetConstant:
+            args[i] = s.value
+        case opUnifyVoid, opSetVoid:
+            args[i] = Anon()
+        case opSetRegister:
+            args[i] = m.registers[s.a]
+        }
+    }
+    return args
+} // build
+
+// callNode - solves a goal with its own solution node. If the goal
+// may have more solutions, a choice point is pushed.
+// Param:  goal
+// Return: success/failure flag
+func (m *WAMSolutionNodeStruct) callNode(goal Goal) bool {
+    var parent SolutionNode
+    if m.stop != nil {
+        if m.stopContext == nil {
+            m.stopContext = &searchContext{ stop: m.stop }
+        }
+        parent = m.stopContext
+    }
+    node := goal.GetSolver(m.program.kb, m.ss, parent)
+    solution, found := node.NextSolution()
+    if !found { return false }
+    if !hasOneSolution(goal) {
+        m.stack = append(m.stack, wamChoicePoint{ kind: wamNode,
+                      ss: m.ss, env: m.env, pc: m.pc, node: node })
+    }
+    m.ss = solution
+    return true
+}
+
+// wake - runs goals which were woken up by the last step.
+// (See suspension.go.)
+// Return: success/failure flag
+func (m *WAMSolutionNodeStruct) wake() bool {
+    woken, newSS := m.ss.takeWokenGoals()
+    if len(woken) == 0 { return true }
+    m.ss = newSS
+    return m.callNode(And(woken...))
+}
+
+// instantiate - makes a goal from a template (call_goal). The
+// variables of the template are replaced by the values in the
+// environment. A value which is not a variable is bound to a new
+// variable.
+// Param:  call_goal instruction
+// Return: goal
+func (m *WAMSolutionNodeStruct) instantiate(instr *wamInstruction) Goal {
+    vars := VarMap{}
+    for _, s := range instr.vars {
+        value := m.env.slots[s.y]
+        if v, ok := value.(VariableStruct); ok {
+            vars[s.name] = v
+            continue
+        }
+        v := m.newVariable(s.y)
+        m.ss, _ = v.Unify(value, m.ss)
+        vars[s.name] = v
+    }
+    return instr.goal.RecreateVariables(vars).(Goal)
+}
@@ -0,0 +1,114 @@
+package main
+
+// Tests the query cache: hits, misses, and invalidation when the
+// knowledge base changes.
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "testing"
+    "fmt"
+)
+
+func TestCache(t *testing.T) {
+
+    fmt.Println("TestCache")
+
+    kb := KnowledgeBase{}
+    err := LoadKBFromFile(kb, "kings.txt")
+    if err != nil {
+        t.Error("\nTestCache:\n", err.Error())
+        return
+    }
+    rule, _ := ParseRule("color(red).")
+    kb.Add(rule)
+
+    cache := MakeQueryCache(kb)
+    defer cache.Close()
+
+    solve := func(str string) string {
+        query, _ := ParseQuery(str)
+        solutions, failure := cache.SolveAll(query)
+        return fmt.Sprint(solutions) + failure
+    }
+    checkStats := func(label string, hits, misses, invalidations int64) {
+        stats := cache.Stats()
+        if stats.Hits != hits || stats.Misses != misses ||
+           stats.Invalidations != invalidations {
+            t.Errorf("\nTestCache - %v: Expected %v/%v/%v. Was %+v",
+                     label, hits, misses, invalidations, stats)
+        }
+    }
+
+    expected := "[grandfather(Godwin, Harold) grandfather(Godwin, Skule)]"
+    if actual := solve("grandfather($X, $Y)"); actual != expected {
+        t.Error("\nTestCache - Expected: " + expected + "\n Was: " + actual)
+    }
+    // Variables are renamed by position.
+    if actual := solve("grandfather($A, $B)"); actual != expected {
+        t.Error("\nTestCache - Expected: " + expected + "\n Was: " + actual)
+    }
+    solve("grandfather($A, $A)")
+    solve("color($C)")
+    checkStats("lookups", 1, 3, 0)
+
+    // Unbound variables in solutions belong to the new query.
+    query, _ := ParseQuery("grandfather(Godwin, $Who)")
+    cache.SolveAll(query)
+    query, _ = ParseQuery("grandfather($G, $Who2)")
+    solutions, _ := cache.SolveAll(query)
+    if len(solutions) != 2 {
+        t.Errorf("\nTestCache - Expected 2 solutions. Was: %v", solutions)
+    }
+
+    // Adding a parent invalidates grandfather (through the rule body),
+    // but not color.
+    rule, _ = ParseRule("parent(Harold, Harold III).")
+    kb.Add(rule)
+    expected = "[grandfather(Godwin, Harold) grandfather(Godwin, Skule) " +
+               "grandfather(Harold II, Harold III)]"
+    if actual := solve("grandfather($X, $Y)"); actual != expected {
+        t.Error("\nTestCache - Expected: " + expected + "\n Was: " + actual)
+    }
+    solve("color($C)")
+    stats := cache.Stats()
+    if stats.Invalidations != 3 || stats.Hits != 3 {
+        t.Errorf("\nTestCache - after Add: %+v", stats)
+    }
+
+    // Retraction invalidates too.
+    head, _ := ParseComplex("color(red)")
+    if !kb.Retract(head) {
+        t.Error("\nTestCache - Retract failed.")
+    }
+    if actual := solve("color($C)"); actual != "[]No" {
+        t.Error("\nTestCache - After Retract: " + actual)
+    }
+
+    // phrase/2 calls the nonterminal with two more arguments.
+    addRules(t, kb, "sentence($L) :- phrase(greeting, $L).",
+                    "greeting --> [hello], name.",
+                    "name --> [alice].")
+    expected = "[sentence([hello, alice])]"
+    if actual := solve("sentence($L)"); actual != expected {
+        t.Error("\nTestCache - Expected: " + expected + "\n Was: " + actual)
+    }
+    rule, _ = ParseRule("name --> [bob].")
+    kb.Add(rule)
+    expected = "[sentence([hello, alice]) sentence([hello, bob])]"
+    if actual := solve("sentence($L)"); actual != expected {
+        t.Error("\nTestCache - Expected: " + expected + "\n Was: " + actual)
+    }
+
+    // Other knowledge bases do not affect the cache.
+    solve("grandfather($X, $Y)")
+    before := cache.Stats().Invalidations
+    other := KnowledgeBase{}
+    rule, _ = ParseRule("parent(Someone, Else).")
+    other.Add(rule)
+    if cache.Stats().Invalidations != before {
+        t.Error("\nTestCache - Another knowledge base invalidated entries.")
+    }
+
+} // TestCache
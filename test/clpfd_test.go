@@ -0,0 +1,110 @@
+package main
+
+// Tests finite-domain constraints (CLP(FD)): in, ins, #=, #\=, #<,
+// all_different, sum, fd_dom, label and labeling.
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "testing"
+    "fmt"
+)
+
+func TestCLPFD(t *testing.T) {
+
+    fmt.Println("TestCLPFD")
+
+    kb := KnowledgeBase{}
+    err := LoadKBFromFile(kb, "puzzle.txt")
+    if err != nil {
+        t.Error("\nTestCLPFD:\n", err.Error())
+        return
+    }
+
+    rules := []string{
+        "narrow($D) :- $X in 1..9, $X #> 7, fd_dom($X, $D).",
+        "undo($D) :- ($X in 1..3, $X #> 5; $X in 4..6), fd_dom($X, $D).",
+        "perms($L) :- $L = [$A, $B, $C], $L ins 1..3, " +
+                     "all_different($L), label($L).",
+        "down($L) :- $L = [$A, $B], $L ins 1..2, labeling([down], $L).",
+        "total($L) :- $L = [$A, $B], $L ins 0..5, sum($L, #=, 9), label($L).",
+        "late($X) :- $X #> $Y, $Y #> 3, $X in 0..5.",
+    }
+    addRules(t, kb, rules...)
+
+    // Propagation narrows the domain of $X.
+    query, _ := ParseQuery("narrow($D)")
+    solution, failure := Solve(query, kb, SubstitutionSet{})
+    if len(failure) > 0 {
+        t.Error("\nTestCLPFD - narrow: ", failure)
+        return
+    }
+    expected := "narrow(8..9)"
+    actual := solution.String()
+    if actual != expected {
+        t.Error("\nTestCLPFD - Expected: " + expected +
+                "\n                 Was: " + actual)
+    }
+
+    // Backtracking undoes domain changes.
+    query, _ = ParseQuery("undo($D)")
+    solution, failure = Solve(query, kb, SubstitutionSet{})
+    expected = "undo(4..6)"
+    if len(failure) > 0 || solution.String() != expected {
+        t.Error("\nTestCLPFD - Expected: " + expected +
+                "\n                 Was: ", solution, failure)
+    }
+
+    // A constraint is checked again when its variables are bound.
+    query, _ = ParseQuery("late($X)")
+    solution, failure = Solve(query, kb, SubstitutionSet{})
+    expected = "late(5)"
+    if len(failure) > 0 || solution.String() != expected {
+        t.Error("\nTestCLPFD - Expected: " + expected +
+                "\n                 Was: ", solution, failure)
+    }
+
+    query, _ = ParseQuery("perms($L)")
+    solutions, failure := SolveAll(query, kb, SubstitutionSet{})
+    if len(solutions) != 6 {
+        t.Error("\nTestCLPFD - all_different should have 6 solutions. Was:",
+                len(solutions), failure)
+    }
+
+    query, _ = ParseQuery("down($L)")
+    solutions, _ = SolveAll(query, kb, SubstitutionSet{})
+    actual = fmt.Sprint(solutions)
+    expected = "[down([2, 2]) down([2, 1]) down([1, 2]) down([1, 1])]"
+    if actual != expected {
+        t.Error("\nTestCLPFD - Expected: " + expected +
+                "\n                 Was: " + actual)
+    }
+
+    query, _ = ParseQuery("total($L)")
+    solutions, _ = SolveAll(query, kb, SubstitutionSet{})
+    actual = fmt.Sprint(solutions)
+    expected = "[total([4, 5]) total([5, 4])]"
+    if actual != expected {
+        t.Error("\nTestCLPFD - Expected: " + expected +
+                "\n                 Was: " + actual)
+    }
+
+    query, _ = ParseQuery("increasing($X, $Y, $Z)")
+    solutions, _ = SolveAll(query, kb, SubstitutionSet{})
+    actual = fmt.Sprint(solutions)
+    expected = "[increasing(1, 2, 3)]"
+    if actual != expected {
+        t.Error("\nTestCLPFD - Expected: " + expected +
+                "\n                 Was: " + actual)
+    }
+
+    query, _ = ParseQuery("puzzle($L)")
+    solution, failure = Solve(query, kb, SubstitutionSet{})
+    expected = "puzzle([9, 5, 6, 7, 1, 0, 8, 2])"
+    if len(failure) > 0 || solution.String() != expected {
+        t.Error("\nTestCLPFD - Expected: " + expected +
+                "\n                 Was: ", solution, failure)
+    }
+
+} // TestCLPFD
@@ -0,0 +1,168 @@
+package main
+
+// Tests the compiled knowledge base (the abstract machine), by
+// comparing its solutions with those of the tree-walking solver.
+// The benchmarks compare the speed of the two, with qsort.txt.
+//
+//   go test -bench=Qsort -run=XXX
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "strings"
+    "testing"
+    "fmt"
+)
+
+func TestCompile(t *testing.T) {
+
+    fmt.Println("TestCompile")
+
+    SetMaxTimeMilliseconds(2000)
+    defer SetMaxTimeMilliseconds(300)
+
+    kb := KnowledgeBase{}
+    err := LoadKBFromFile(kb, "qsort.txt")
+    if err != nil {
+        t.Error("\nTestCompile:\n", err.Error())
+        return
+    }
+    err = LoadKBFromFile(kb, "kings.txt")
+    if err != nil {
+        t.Error("\nTestCompile:\n", err.Error())
+        return
+    }
+
+    rules := []string{
+        "sorted($S) :- data($L), qsort($L, $S, []).",
+        "member($X, [$X | $Rest]).",
+        "member($X, [$_ | $T]) :- member($X, $T).",
+        "first($X, $L) :- member($X, $L), !.",
+        "color(red).", "color(green).", "color(blue).",
+        "either($X) :- $X = one; $X = two; color($X).",
+        "not_red($X) :- color($X), not($X = red).",
+        "count_down(0) :- !.",
+        "count_down($N) :- $M = subtract($N, 1), count_down($M).",
+        "length([], 0).",
+        "length([$_ | $T], $N) :- length($T, $M), $N = add($M, 1).",
+        "pair(p($X, $Y), [$X, $Y]).",
+        "nested(f(g($X), [$X, h($Y) | $T]), $Y, $T).",
+        "both($X, $Y) :- append([$X], [$Y], $L), $L = [$_, $_].",
+        "same($X, $X).",
+        "woken($Y) :- freeze($X, same($Y, awake)), $X = 1.",
+        "different($X, $Y) :- dif($X, $Y), color($X), color($Y).",
+        "local_cut($X) :- (color($X), !; $X = none).",
+        "double_not($X) :- not(not($X = blue)).",
+    }
+    addRules(t, kb, rules...)
+
+    compiled := CompileKB(kb)
+
+    queries := []string{
+        "sorted($S)",
+        "grandfather($X, $Y)",
+        "grandmother($X, Harold)",
+        "member($X, [a, b, c])",
+        "first($X, [a, b, c])",
+        "either($X)",
+        "not_red($X)",
+        "count_down(2000)",
+        "length([a, b, c, d], $N)",
+        "pair(p(1, 2), $L)",
+        "pair($P, [a, b])",
+        "nested(f(g(1), [1, h(2), 3, 4]), $Y, $T)",
+        "nested($F, a, [])",
+        "both(1, 2)",
+        "woken($Y)",
+        "different($X, $Y)",
+        "local_cut($X)",
+        "double_not(blue)",
+        "double_not(red)",
+        "unknown($X)",
+    }
+
+    for _, q := range queries {
+        query, _ := ParseQuery(q)
+        expected, failure1 := SolveAll(query, kb, SubstitutionSet{})
+        query, _ = ParseQuery(q)
+        actual, failure2 := compiled.SolveAll(query, SubstitutionSet{})
+        if failure1 != failure2 {
+            t.Error("\nTestCompile - " + q + ": Expected: " + failure1 +
+                    "\n                          Was: " + failure2)
+            continue
+        }
+        if len(expected) != len(actual) {
+            t.Errorf("\nTestCompile - %v: Expected %v solutions. Was %v.",
+                     q, len(expected), len(actual))
+            continue
+        }
+        for i := range expected {
+            if variableSuffix(expected[i].String()) !=
+               variableSuffix(actual[i].String()) {
+                t.Error("\nTestCompile - " + q +
+                        "\n    Expected: " + expected[i].String() +
+                        "\n         Was: " + actual[i].String())
+            }
+        }
+    }
+
+    // The compiled code can be displayed.
+    code := compiled.FormatCode()
+    if !strings.Contains(code, "execute partition/4") {
+        t.Error("\nTestCompile - FormatCode: ", code)
+    }
+
+} // TestCompile
+
+// variableSuffix - removes the ID numbers of variables, which are
+// different for the two solvers. $X_23 becomes $X.
+func variableSuffix(str string) string {
+    var sb strings.Builder
+    inVariable := false
+    skipping := false
+    for _, r := range str {
+        if r == '$' { inVariable = true; skipping = false }
+        if inVariable && r == '_' { skipping = true; continue }
+        if skipping {
+            if r >= '0' && r <= '9' { continue }
+            inVariable = false
+            skipping = false
+        }
+        sb.WriteRune(r)
+    }
+    return sb.String()
+}
+
+// qsortKB - loads the qsort benchmark.
+func qsortKB(b *testing.B) KnowledgeBase {
+    kb := KnowledgeBase{}
+    if err := LoadKBFromFile(kb, "qsort.txt"); err != nil {
+        b.Fatal(err)
+    }
+    rule, _ := ParseRule("sorted($S) :- data($L), qsort($L, $S, []).")
+    kb.Add(rule)
+    return kb
+}
+
+func BenchmarkQsortTreeWalker(b *testing.B) {
+    kb := qsortKB(b)
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        query, _ := ParseQuery("sorted($S)")
+        if _, failure := Solve(query, kb, SubstitutionSet{}); failure != "" {
+            b.Fatal(failure)
+        }
+    }
+}
+
+func BenchmarkQsortCompiled(b *testing.B) {
+    compiled := CompileKB(qsortKB(b))
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        query, _ := ParseQuery("sorted($S)")
+        if _, failure := compiled.Solve(query, SubstitutionSet{}); failure != "" {
+            b.Fatal(failure)
+        }
+    }
+}
@@ -201,10 +201,14 @@ func TestParseComplex(t *testing.T) {
     }
 
     _, e = ParseComplex("func(\"a, b, c, d, e)")
-    checkParseComplexErrors(t, "Unmatched quotes: \"a, b, c, d, e", e)
+    checkParseComplexErrors(t, "1:6: Unmatched quote mark\n" +
+                               "    func(\"a, b, c, d, e)\n" +
+                               "         ^", e)
 
     _, e = ParseComplex("func(a, b\"\", c, d, e)")
-    checkParseComplexErrors(t, "Text before opening quote: b\"\"", e)
+    checkParseComplexErrors(t, "1:10: Text before opening quote\n" +
+                               "    func(a, b\"\", c, d, e)\n" +
+                               "             ^", e)
 
 } // TestParseComplex
 
@@ -0,0 +1,99 @@
+package main
+
+// Tests the directives which load files: consult, include and
+// ensure_loaded, and initialization goals. Files are loaded from
+// the operating system, and from a file system (fs.FS).
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "strings"
+    "testing"
+    "testing/fstest"
+    "fmt"
+)
+
+func TestConsult(t *testing.T) {
+
+    fmt.Println("TestConsult")
+    defer ResetOperators()
+
+    // consult.txt consults lib/colours.txt. Paths are relative to
+    // the file which contains the directive.
+    kb := KnowledgeBase{}
+    err := LoadKBFromFile(kb, "consult.txt")
+    if err != nil {
+        t.Error("\nTestConsult:\n", err)
+        return
+    }
+    if len(kb["colour/2"]) != 2 {
+        t.Errorf("\nTestConsult - colour/2 should be loaded once: %v", kb)
+    }
+    query, _ := ParseQuery("warm($X)")
+    solution, failure := Solve(query, kb, SubstitutionSet{})
+    if len(failure) > 0 || solution.String() != "warm(red)" {
+        t.Errorf("\nTestConsult - warm($X): %v %v", solution, failure)
+    }
+
+    // The same from a file system.
+    fsys := fstest.MapFS{
+        "main.txt": { Data: []byte(
+            "letter(a).\n" +
+            ":- include(lib/more.txt).\n" +
+            "letter(d).\n" +
+            ":- initialization((op(700, xfx, likes), letter($X))).\n") },
+        "lib/more.txt": { Data: []byte(
+            ":- consult([b.txt, ../c.txt]).\n") },
+        "lib/b.txt": { Data: []byte("letter(b).\n") },
+        "c.txt":     { Data: []byte("letter(c).\n") },
+        "loop.txt":  { Data: []byte(":- consult(lib/loop2.txt).\n") },
+        "lib/loop2.txt": { Data: []byte(":- include(../loop.txt).\n") },
+        "fail.txt":  { Data: []byte("ok.\n:- initialization(missing).\n") },
+        "missing.txt": { Data: []byte(":- ensure_loaded(nothing.txt).\n") },
+    }
+    kb = KnowledgeBase{}
+    if err := LoadKBFS(kb, fsys, "main.txt"); err != nil {
+        t.Error("\nTestConsult - LoadKBFS:\n", err)
+        return
+    }
+    // Clauses are added where the directive occurs.
+    query, _ = ParseQuery("letter($X)")
+    solutions, _ := SolveAll(query, kb, SubstitutionSet{})
+    letters := []string{}
+    for _, s := range solutions { letters = append(letters, s.String()) }
+    expected := "letter(a) letter(b) letter(c) letter(d)"
+    if strings.Join(letters, " ") != expected {
+        t.Error("\nTestConsult - Should be: " + expected +
+                "\n                    Was: " + strings.Join(letters, " "))
+    }
+    // The initialization goal has run. It defined an operator.
+    LoadKB(kb, strings.NewReader("anne likes bob.\n"), "likes.txt")
+    query, _ = ParseQuery("likes(anne, $X)")
+    if _, failure := Solve(query, kb, SubstitutionSet{}); failure != "" {
+        t.Error("\nTestConsult - The initialization goal did not run.")
+    }
+
+    errors := map[string]string{
+        "loop.txt": "lib/loop2.txt:1:4: Circular include: " +
+                    "loop.txt -> loop2.txt -> loop.txt",
+        "fail.txt": "fail.txt:2:4: Initialization goal failed: missing",
+        "missing.txt": "missing.txt:1:4: open nothing.txt: file does not exist",
+    }
+    for file, expected := range errors {
+        err := LoadKBFS(KnowledgeBase{}, fsys, file)
+        if err == nil || !strings.HasPrefix(err.Error(), expected) {
+            t.Errorf("\nTestConsult - %v should produce error:\n%v\nWas:\n%v",
+                     file, expected, err)
+        }
+    }
+
+    // Files cannot be loaded without a knowledge base.
+    _, err = ParseRules(":- consult(kings.txt).", "")
+    expected = "1:4: consult/1 can only be used in a file which " +
+               "is loaded into a knowledge base"
+    if err == nil || !strings.HasPrefix(err.Error(), expected) {
+        t.Errorf("\nTestConsult - ParseRules: %v", err)
+    }
+
+} // TestConsult
@@ -0,0 +1,103 @@
+package main
+
+// Tests coroutining: freeze, when and dif.
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "testing"
+    "fmt"
+)
+
+func TestCoroutining(t *testing.T) {
+
+    fmt.Println("TestCoroutining")
+
+    kb := KnowledgeBase{}
+
+    rules := []string{
+        "note($X, $X).",
+        "seven(7).",
+        "f1($Y) :- freeze($X, note($X, $Y)), $X = 5.",
+        "f2 :- freeze($X, note(first, $A)), $X = 1, $A == first.",
+        "f3($Y) :- freeze(3, note(3, $Y)).",
+        "f4($R) :- freeze($X, fail), ($X = 1, $R = bound; $R = unbound).",
+        "f5($Y) :- freeze($X, note($X, $Y)), seven($X).",
+        "d1 :- dif($X, $Y), $X = a, $Y = a.",
+        "d2($X, $Y) :- dif($X, $Y), $X = a, $Y = b.",
+        "d3 :- dif(f($X), f($Y)), $X = $Y.",
+        "d4 :- dif(a, a).",
+        "w1($R) :- when(ground(f($X, $Y)), note(done, $R)), $X = 1, $Y = 2.",
+        "w2($R) :- when(?=($X, $Y), note(decided, $R)), $X = a, $Y = b.",
+        "w3($R) :- when(or(nonvar($X), nonvar($Y)), note(either, $R)), $Y = 1.",
+        "n1 :- freeze($X, fail), not($X = 1).",
+        "pending($X) :- freeze($X, print($X)).",
+        "undecided($X) :- dif($X, a).",
+    }
+    addRules(t, kb, rules...)
+
+    tests := []struct{ query, expected string }{
+        { "f1($Y)", "f1(5)" },      // Woken when $X is bound.
+        { "f2", "f2" },             // Woken before the next goal.
+        { "f3($Y)", "f3(3)" },      // Already bound; runs immediately.
+        { "f4($R)", "f4(unbound)" },
+        { "f5($Y)", "f5(7)" },      // Woken by a fact.
+        { "d2($X, $Y)", "d2(a, b)" },
+        { "w1($R)", "w1(done)" },
+        { "w2($R)", "w2(decided)" },
+        { "w3($R)", "w3(either)" },
+        { "n1", "n1" },             // The woken goal fails inside not().
+    }
+
+    for _, test := range tests {
+        query, _ := ParseQuery(test.query)
+        solution, failure := Solve(query, kb, SubstitutionSet{})
+        if len(failure) > 0 {
+            t.Error("\nTestCoroutining - " + test.query + ": " + failure)
+            continue
+        }
+        actual := solution.String()
+        if actual != test.expected {
+            t.Error("\nTestCoroutining - Expected: " + test.expected +
+                    "\n                      Was: " + actual)
+        }
+    }
+
+    // These queries must fail.
+    for _, q := range []string{"d1", "d3", "d4"} {
+        query, _ := ParseQuery(q)
+        _, failure := Solve(query, kb, SubstitutionSet{})
+        if failure != "No" {
+            t.Error("\nTestCoroutining - " + q + " should fail. Was: " + failure)
+        }
+    }
+
+    // A goal which is still delayed is reported with the solution.
+    query, _ := ParseQuery("pending($X)")
+    root := query.GetSolver(kb, SubstitutionSet{}, nil)
+    ss, found := root.NextSolution()
+    if !found {
+        t.Error("\nTestCoroutining - pending($X) should succeed.")
+        return
+    }
+    delayed := ss.DelayedGoals()
+    if len(delayed) != 1 || delayed[0].GetFunctor() != "freeze" {
+        t.Error("\nTestCoroutining - Expected one delayed goal. Was:", delayed)
+    }
+
+    // The solve functions return the delayed goals with each solution.
+    solution, failure := SolveWithDelayed(query, kb, SubstitutionSet{})
+    if failure != "" || len(solution.Delayed) != 1 ||
+       solution.Delayed[0].GetFunctor() != "freeze" {
+        t.Error("\nTestCoroutining - SolveWithDelayed:", solution, failure)
+    }
+    query, _ = ParseQuery("undecided($X)")
+    solutions, failure := SolveAllWithDelayed(query, kb, SubstitutionSet{})
+    if failure != "" || len(solutions) != 1 ||
+       len(solutions[0].Delayed) != 1 ||
+       solutions[0].Delayed[0].GetFunctor() != "dif" {
+        t.Error("\nTestCoroutining - SolveAllWithDelayed:", solutions, failure)
+    }
+
+} // TestCoroutining
@@ -0,0 +1,121 @@
+package main
+
+// Tests rule coverage: counts of clauses, and the text, HTML
+// and LCOV reports.
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "strings"
+    "testing"
+    "bytes"
+    "fmt"
+)
+
+func TestCoverage(t *testing.T) {
+
+    fmt.Println("TestCoverage")
+
+    kb := KnowledgeBase{}
+    err := LoadKBFromFile(kb, "kings.txt")
+    if err != nil {
+        t.Error("\nTestCoverage:\n", err.Error())
+        return
+    }
+
+    coverage := StartCoverage(kb)
+    query, _ := ParseQuery("grandfather(Godwin, $Y)")
+    solutions, _ := SolveAll(query, kb, SubstitutionSet{})
+    coverage.Stop()
+    if len(solutions) != 2 {
+        t.Errorf("\nTestCoverage - grandfather: %v", solutions)
+    }
+
+    // After Stop(), nothing is counted.
+    query, _ = ParseQuery("male($X)")
+    SolveAll(query, kb, SubstitutionSet{})
+
+    // Other knowledge bases are not counted.
+    other := KnowledgeBase{}
+    LoadKBFromFile(other, "kings.txt")
+    coverage = StartCoverage(kb)
+    SolveAll(query, other, SubstitutionSet{})
+    coverage.Stop()
+    if coverage.Summary().Unified != 0 {
+        t.Errorf("\nTestCoverage - other KB: %v", coverage.Summary())
+    }
+
+    // Goals solved with an overlay of the knowledge base are counted.
+    coverage = StartCoverage(kb)
+    SolveAll(query, kb.Overlay(OverlayFirst), SubstitutionSet{})
+    coverage.Stop()
+    if coverage.Summary().Unified == 0 {
+        t.Errorf("\nTestCoverage - overlay: %v", coverage.Summary())
+    }
+
+    coverage = StartCoverage(kb)
+    query, _ = ParseQuery("grandfather(Godwin, $Y)")
+    SolveAll(query, kb, SubstitutionSet{})
+    coverage.Stop()
+
+    clauses := map[string]ClauseCoverage{}
+    for _, cc := range coverage.Clauses() {
+        clauses[fmt.Sprintf("%v:%v", cc.File, cc.Line)] = cc
+    }
+    // grandfather rule, male(Godwin), parent(Godwin, Harold II),
+    // parent(Harold II, Harold), and male(Harold) (line 3).
+    expected := map[string][2]int64{
+        "kings.txt:28": { 1, 2 },
+        "kings.txt:2":  { 2, 2 },
+        "kings.txt:14": { 1, 1 },
+        "kings.txt:22": { 1, 1 },
+        "kings.txt:3":  { 0, 0 },
+    }
+    for source, counts := range expected {
+        cc := clauses[source]
+        actual := [2]int64{ cc.Unified, cc.Succeeded }
+        if actual != counts {
+            t.Errorf("\nTestCoverage - %v: Expected %v. Was %v.",
+                     source, counts, actual)
+        }
+    }
+    summary := coverage.Summary()
+    if summary.Clauses != len(clauses) || summary.Unified == 0 ||
+       summary.Unified == summary.Clauses {
+        t.Errorf("\nTestCoverage - summary: %v", summary)
+    }
+
+    // Text.
+    text := coverage.Text()
+    if !strings.HasPrefix(text, "Source") ||
+       !strings.Contains(text, summary.String()) ||
+       !strings.Contains(text, "***") {
+        t.Error("\nTestCoverage - text:\n" + text)
+    }
+
+    // HTML.
+    var buffer bytes.Buffer
+    coverage.WriteHTML(&buffer)
+    page := buffer.String()
+    if !strings.Contains(page, "<h2>kings.txt</h2>") ||
+       !strings.Contains(page, "<tr class=\"succeeded\"><td>28</td>") ||
+       !strings.Contains(page, "<tr class=\"unused\">") ||
+       !strings.Contains(page, ":- parent($X, $Z)") {
+        t.Error("\nTestCoverage - HTML:\n" + page)
+    }
+
+    // LCOV.
+    buffer.Reset()
+    coverage.WriteLCOV(&buffer)
+    lcov := buffer.String()
+    for _, s := range []string{
+        "SF:kings.txt\n", "FN:28,grandfather/2\n", "FNDA:1,grandfather/2\n",
+        "DA:28,1\n", "DA:3,0\n", "BRDA:28,0,1,2\n", "end_of_record\n",
+    } {
+        if !strings.Contains(lcov, s) {
+            t.Error("\nTestCoverage - LCOV: Missing " + s + "\n" + lcov)
+        }
+    }
+
+} // TestCoverage
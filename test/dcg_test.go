@@ -0,0 +1,126 @@
+package main
+
+// Tests grammar rules (-->): their translation into ordinary rules,
+// terminals, goals in braces, cuts, pushback, and the predicates
+// phrase/2 and phrase/3.
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "strings"
+    "testing"
+    "fmt"
+)
+
+func TestDCG(t *testing.T) {
+
+    fmt.Println("TestDCG")
+
+    program := "greeting --> [hello], name.\n" +
+               "name --> [world].\n" +
+               "name --> [Cleve].\n" +
+               "digits([$D | $T]) --> digit($D), !, digits($T).\n" +
+               "digits([]) --> [].\n" +
+               "digit($D) --> [$D], { $D >= 0, $D <= 9 }.\n" +
+               "colour($C) --> ([red] ; [blue]), { $C = warm_or_cool }.\n" +
+               "peek_not, [not] --> [not].\n" +
+               "uses_s($S1) --> [$S1], name.\n"
+    rules, err := ParseRules(program, "")
+    if err != nil {
+        t.Error("\nTestDCG:\n", err)
+        return
+    }
+
+    expected := []string{
+        "greeting($S0, $S) :- $S0 = [hello | $S1], name($S1, $S).",
+        "name($S0, $S) :- $S0 = [world | $S].",
+        "name($S0, $S) :- $S0 = [Cleve | $S].",
+        "digits([$D | $T], $S0, $S) :- digit($D, $S0, $S1), !, " +
+            "$S1 = $S2, digits($T, $S2, $S).",
+        "digits([], $S0, $S) :- $S0 = $S.",
+        "digit($D, $S0, $S) :- $S0 = [$D | $S1], $D >= 0, $D <= 9, $S1 = $S.",
+        "colour($C, $S0, $S) :- ($S0 = [red | $S1]; $S0 = [blue | $S1]), " +
+            "$C = warm_or_cool, $S1 = $S.",
+        "peek_not($S0, $S) :- $S0 = [not | $S1], $S = [not | $S1].",
+        "uses_s($S1, $S0, $S) :- $S0 = [$S1 | $S1_1], name($S1_1, $S).",
+    }
+    for i, rule := range rules {
+        if rule.String() != expected[i] {
+            t.Error("\nTestDCG - Rule should be: " + expected[i] +
+                    "\n                     Was: " + rule.String())
+        }
+    }
+
+    // The queries below use the printed rules, read back, so they test
+    // that the printed rules mean the same. In colour/3, the goals after
+    // the alternatives apply to both of them. (See test8.)
+    kb := KnowledgeBase{}
+    for _, rule := range rules {
+        again, err := ParseRule(rule.String())
+        if err != nil {
+            t.Errorf("\nTestDCG - Cannot read back: %v\n%v", rule, err)
+            return
+        }
+        kb.Add(again)
+    }
+    more := []string{
+        "test1 :- phrase(greeting, [hello, world]).",
+        "test2($N) :- phrase(digits($N), [1, 2, 3]).",
+        "test3($R) :- phrase(greeting, [hello, Cleve, how, are, you], $R).",
+        "test4($C) :- phrase(colour($C), [blue]).",
+        "test8($C) :- phrase(colour($C), [red]).",
+        "test5($R) :- phrase(peek_not, [not, bad], $R).",
+        "test6 :- phrase(greeting, [hello, world, again]).",
+        "test7 :- phrase(digits($N), [1, 12]).",
+    }
+    addRules(t, kb, more...)
+
+    solutions := map[string]string{
+        "test1":     "test1",
+        "test2($N)": "test2([1, 2, 3])",
+        "test3($R)": "test3([how, are, you])",
+        "test4($C)": "test4(warm_or_cool)",
+        "test8($C)": "test8(warm_or_cool)",
+        "test5($R)": "test5([not, bad])",
+    }
+    for q, expected := range solutions {
+        query, _ := ParseQuery(q)
+        solution, failure := Solve(query, kb, SubstitutionSet{})
+        if len(failure) > 0 {
+            t.Errorf("\nTestDCG - %v: %v", q, failure)
+            continue
+        }
+        result := solution.String()
+        if result != expected {
+            t.Error("\nTestDCG - Solution should be: " + expected +
+                    "\n                         Was: " + result)
+        }
+    }
+
+    // All words must be parsed. 12 is not a digit.
+    for _, q := range []string{ "test6", "test7" } {
+        query, _ := ParseQuery(q)
+        _, failure := Solve(query, kb, SubstitutionSet{})
+        if failure != "No" {
+            t.Errorf("\nTestDCG - %v should fail: %v", q, failure)
+        }
+    }
+
+    // Errors in grammar rules.
+    errors := map[string]string{
+        "a --> b, $X = c.":   "1:10: Invalid nonterminal: $X = c. " +
+                              "Goals must be in braces.",
+        "a --> [b | $T].":    "1:7: Invalid list of terminals: [b | $T]",
+        "a --> { b.":         "1:10: Missing closing brace",
+        "a, [b] :- c.":       "1:8: Expected -->, found :-",
+    }
+    for source, expected := range errors {
+        _, err := ParseRules(source, "")
+        if err == nil || !strings.HasPrefix(err.Error(), expected) {
+            t.Errorf("\nTestDCG - %v\nShould produce error: %v\nWas: %v",
+                     source, expected, err)
+        }
+    }
+
+} // TestDCG
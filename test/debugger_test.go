@@ -0,0 +1,129 @@
+package main
+
+// Tests the step debugger, with scripted commands: creep, skip, leap,
+// fail, retry and abort, spy points and line breakpoints.
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "strings"
+    "testing"
+    "bytes"
+    "fmt"
+)
+
+func TestDebugger(t *testing.T) {
+
+    fmt.Println("TestDebugger")
+
+    defer SetTracer(nil)
+    defer TraceOff()
+    defer NoSpyAll()
+    defer ShowUnifyPort(false)
+
+    kb := KnowledgeBase{}
+    err := LoadKBFromFile(kb, "kings.txt")
+    if err != nil {
+        t.Error("\nTestDebugger:\n", err.Error())
+        return
+    }
+
+    // Solves a query with a script of commands. Returns the number
+    // of solutions, the output of the debugger, and the debugger.
+    debug := func(q string, script string, creep bool,
+                  breakpoints ...string) (int, string, *Debugger) {
+        var out bytes.Buffer
+        debugger := MakeDebugger(strings.NewReader(script), &out)
+        for _, bp := range breakpoints {
+            if err := debugger.SetBreakpoint(bp); err != nil {
+                t.Error("\nTestDebugger:\n", err.Error())
+            }
+        }
+        SetTracer(debugger)
+        TraceOn()
+        debugger.Start(creep)
+        query, _ := ParseQuery(q)
+        solutions, _ := SolveAll(query, kb, SubstitutionSet{})
+        TraceOff()
+        for _, bp := range breakpoints { debugger.RemoveBreakpoint(bp) }
+        return len(solutions), variableSuffix(out.String()), debugger
+    }
+
+    // The prompts of the debugger, one per line.
+    prompts := func(out string) []string {
+        lines := strings.Split(out, " ? ")
+        lines = lines[:len(lines) - 1]
+        for i, line := range lines { lines[i] = strings.TrimSpace(line) }
+        return lines
+    }
+
+    // Fail: parent($X, $Z) fails, so grandfather fails.
+    n, out, _ := debug("grandfather(Godwin, $Y)", "c\nf\nn\n", true)
+    p := prompts(out)
+    if n != 0 || len(p) != 3 || p[2] != "Fail: (1) parent(Godwin, $Z)" {
+        t.Errorf("\nTestDebugger - fail: %v\n%v", n, out)
+    }
+
+    // Retry: parent(Godwin, $Z) is called again. The solutions
+    // do not change.
+    n, out, _ = debug("grandfather(Godwin, $Y)", "c\nc\nr\nn\n", true)
+    p = prompts(out)
+    if n != 2 || len(p) != 4 || p[3] != p[1] {
+        t.Errorf("\nTestDebugger - retry: %v\n%v", n, out)
+    }
+
+    // Skip: run to the Exit port of the query.
+    n, out, _ = debug("grandfather(Godwin, $Y)", "s\nn\n", true)
+    p = prompts(out)
+    if n != 2 || len(p) != 2 ||
+       p[1] != "Exit: (0) grandfather(Godwin, Harold) (clause 1, kings.txt:28:1)" {
+        t.Errorf("\nTestDebugger - skip: %v\n%v", n, out)
+    }
+
+    // Abort.
+    n, _, debugger := debug("grandfather(Godwin, $Y)", "c\na\n", true)
+    if n != 0 || !debugger.Aborted() {
+        t.Errorf("\nTestDebugger - abort: %v %v", n, debugger.Aborted())
+    }
+
+    // Leap to a spy point.
+    n, out, _ = debug("grandfather(Godwin, $Y)", "l\nn\n", false, "male/1")
+    p = prompts(out)
+    if n != 2 || len(p) != 2 || p[0] != "Call: (1) male(Godwin)" ||
+       p[1] != "Exit: (1) male(Godwin) (clause 1, kings.txt:2:1)" {
+        t.Errorf("\nTestDebugger - leap: %v\n%v", n, out)
+    }
+
+    // Line breakpoint: the rule for grandfather is on line 28
+    // of kings.txt. The print command shows the source.
+    n, out, _ = debug("grandfather(Godwin, $Y)", "p\nn\n", false,
+                      "kings.txt:28")
+    p = prompts(out)
+    if n != 2 || len(p) != 2 ||
+       p[0] != "Unify: (0) grandfather(Godwin, $Y) (clause 1, kings.txt:28:1)" ||
+       !strings.Contains(out, "clause 1, kings.txt:28") {
+        t.Errorf("\nTestDebugger - line breakpoint: %v\n%v", n, out)
+    }
+
+    // Fail at the Unify port: the clause is rejected.
+    n, _, _ = debug("grandfather(Godwin, $Y)", "f\n", false, "kings.txt:28")
+    if n != 0 {
+        t.Errorf("\nTestDebugger - fail at Unify: %v", n)
+    }
+
+    // Retry a built-in predicate at its Fail port, then fail it.
+    rule, _ := ParseRule("check($X) :- $X > 2.")
+    kb.Add(rule)
+    _, out, _ = debug("check(1)", "c\nc\nr\nc\nf\nn\n", true)
+    p = prompts(out)
+    if len(p) != 6 || p[1] != "Call: (1) 1 > 2" || p[2] != "Fail: (1) 1 > 2" ||
+       p[3] != p[1] {
+        t.Errorf("\nTestDebugger - built-in: %v", out)
+    }
+
+    if err := MakeDebugger(nil, nil).SetBreakpoint("kings.txt:x"); err == nil {
+        t.Error("\nTestDebugger - kings.txt:x should be invalid.")
+    }
+
+} // TestDebugger
@@ -0,0 +1,75 @@
+package main
+
+// Tests deep recursion. Tail-recursive predicates must run in
+// constant stack space. (See engine.go.) Bindings are not copied
+// (see trail.go), so the time grows with the depth, and a list of
+// 1,000,000 items can be walked.
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "runtime/debug"
+    "testing"
+    "fmt"
+)
+
+func TestDeepRecursion(t *testing.T) {
+
+    fmt.Println("TestDeepRecursion")
+
+    SetMaxTimeMilliseconds(120000)
+    defer SetMaxTimeMilliseconds(300)
+
+    // With recursive solution nodes, these queries would need
+    // more than 1 megabyte of stack.
+    defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+
+    kb := KnowledgeBase{}
+    rules := []string{
+        "count_down(0) :- !.",
+        "count_down($N) :- $M = subtract($N, 1), count_down($M).",
+        "walk([]).",
+        "walk([$_ | $T]) :- walk($T).",
+        "last_of([$X], $X) :- !.",
+        "last_of([$_ | $T], $X) :- last_of($T, $X).",
+        "concat([], $L, $L).",
+        "concat([$H | $T], $L, [$H | $R]) :- concat($T, $L, $R).",
+    }
+    addRules(t, kb, rules...)
+
+    query, _ := ParseQuery("count_down(100000)")
+    _, failure := Solve(query, kb, SubstitutionSet{})
+    if len(failure) > 0 {
+        t.Error("\nTestDeepRecursion - count_down: " + failure)
+    }
+
+    terms := []Unifiable{}
+    for i := 0; i < 1000000; i++ { terms = append(terms, Integer(i)) }
+    list := MakeLinkedList(false, terms...)
+
+    query = MakeQuery(Atom("walk"), list)
+    _, failure = Solve(query, kb, SubstitutionSet{})
+    if len(failure) > 0 {
+        t.Error("\nTestDeepRecursion - walk: " + failure)
+    }
+
+    X, _ := LogicVar("$X")
+    list = MakeLinkedList(false, terms[:100000]...)
+    query = MakeQuery(Atom("last_of"), list, X)
+    solution, failure := Solve(query, kb, SubstitutionSet{})
+    if len(failure) > 0 || solution.GetTerm(2).String() != "99999" {
+        t.Error("\nTestDeepRecursion - last_of: ", solution, failure)
+    }
+
+    // The variable of each level is bound at the next level.
+    query = MakeQuery(Atom("concat"), list, MakeLinkedList(false, Atom("end")), X)
+    solution, failure = Solve(query, kb, SubstitutionSet{})
+    if len(failure) > 0 {
+        t.Error("\nTestDeepRecursion - concat: " + failure)
+    } else if result, ok := solution.GetTerm(3).(LinkedListStruct);
+              !ok || result.GetCount() != 100001 {
+        t.Error("\nTestDeepRecursion - concat: ", solution.GetTerm(3))
+    }
+
+} // TestDeepRecursion
@@ -0,0 +1,122 @@
+package main
+
+// Tests SolveExplain: proof trees of solutions, as text, JSON and DOT.
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "encoding/json"
+    "strings"
+    "testing"
+    "fmt"
+)
+
+func TestExplain(t *testing.T) {
+
+    fmt.Println("TestExplain")
+
+    kb := KnowledgeBase{}
+    err := LoadKBFromFile(kb, "kings.txt")
+    if err != nil {
+        t.Error("\nTestExplain:\n", err.Error())
+        return
+    }
+    rules := []string{
+        "married(Godwin).",
+        "bachelor($X) :- male($X), not(married($X)).",
+        "older($X) :- $Age = 40, $Age > $X, !.",
+        "either($X) :- $X = a ; $X = b.",
+    }
+    addRules(t, kb, rules...)
+
+    // Proofs with rules and facts. Each solution has its own proof.
+    query, _ := ParseQuery("grandfather(Godwin, $Y)")
+    explanations, failure := SolveExplain(query, kb, SubstitutionSet{})
+    if failure != "" || len(explanations) != 2 {
+        t.Errorf("\nTestExplain - grandfather: %v %v", explanations, failure)
+        return
+    }
+    expected := "grandfather(Godwin, Harold)  [rule 1, kings.txt:28:1] " +
+                "{$X = Godwin, $Y = Harold, $Z = Harold II}\n" +
+                "    parent(Godwin, Harold II)  [fact 1, kings.txt:14:1]\n" +
+                "    parent(Harold II, Harold)  [fact 9, kings.txt:22:1]\n" +
+                "    male(Godwin)  [fact 1, kings.txt:2:1]\n"
+    actual := explanations[0].Proof.Text()
+    if actual != expected {
+        t.Error("\nTestExplain - grandfather:\nExpected:\n" + expected +
+                "Was:\n" + actual)
+    }
+    proof := explanations[1].Proof
+    if explanations[1].Solution.String() != "grandfather(Godwin, Skule)" ||
+       proof.Rule == nil || proof.Kind != RuleProof ||
+       proof.Children[1].Goal.String() != "parent(Tostig, Skule)" {
+        t.Error("\nTestExplain - grandfather(Godwin, Skule):\n" + proof.Text())
+    }
+
+    // Negated goals are leaves which failed to prove.
+    query, _ = ParseQuery("bachelor(Tostig)")
+    explanations, _ = SolveExplain(query, kb, SubstitutionSet{})
+    if len(explanations) != 1 {
+        t.Errorf("\nTestExplain - bachelor: %v", explanations)
+        return
+    }
+    leaf := explanations[0].Proof.Children[1]
+    if leaf.Kind != NegationProof || leaf.Goal.String() != "married(Tostig)" ||
+       !strings.Contains(explanations[0].Proof.Text(),
+                         "married(Tostig)  [failed to prove]") {
+        t.Error("\nTestExplain - bachelor:\n" + explanations[0].Proof.Text())
+    }
+
+    // Built-in predicates are leaves. Backtracking into an Or
+    // removes the steps of the failed branch.
+    query, _ = ParseQuery("older(30)")
+    explanations, _ = SolveExplain(query, kb, SubstitutionSet{})
+    expected = "older(30)  [rule 1] {$Age = 40, $X = 30}\n" +
+               "    40 = 40  [built-in]\n" +
+               "    40 > 30  [built-in]\n"
+    if len(explanations) != 1 || explanations[0].Proof.Text() != expected {
+        t.Errorf("\nTestExplain - older: %v", explanations)
+    }
+    query, _ = ParseQuery("either($X)")
+    explanations, _ = SolveExplain(query, kb, SubstitutionSet{})
+    if len(explanations) != 2 ||
+       len(explanations[1].Proof.Children) != 1 ||
+       explanations[1].Proof.Children[0].Goal.String() != "b = b" {
+        t.Errorf("\nTestExplain - either: %v", explanations)
+    }
+
+    // JSON.
+    query, _ = ParseQuery("older(30)")
+    explanations, _ = SolveExplain(query, kb, SubstitutionSet{})
+    data, err := explanations[0].Proof.JSON()
+    var tree map[string]interface{}
+    if err == nil { err = json.Unmarshal(data, &tree) }
+    if err != nil || tree["goal"] != "older(30)" || tree["kind"] != "rule" ||
+       tree["rule"] != "older($X) :- $Age = 40, $Age > $X, !." ||
+       len(tree["children"].([]interface{})) != 2 {
+        t.Errorf("\nTestExplain - JSON: %v\n%v", err, string(data))
+    }
+
+    // DOT.
+    dot := explanations[0].Proof.DOT()
+    for _, s := range []string{
+        "digraph proof {",
+        "p0 [label=\"older(30)\\nrule 1\"];",
+        "p2 [label=\"40 > 30\\nbuilt-in\", shape=ellipse];",
+        "p0 -> p2;",
+    } {
+        if !strings.Contains(dot, s) {
+            t.Error("\nTestExplain - DOT: Missing: " + s + "\n" + dot)
+        }
+    }
+
+    // No solutions.
+    query, _ = ParseQuery("bachelor(Godwin)")
+    explanations, failure = SolveExplain(query, kb, SubstitutionSet{})
+    if failure != "No" || len(explanations) != 0 {
+        t.Errorf("\nTestExplain - bachelor(Godwin): %v %v",
+                 explanations, failure)
+    }
+
+} // TestExplain
@@ -0,0 +1,25 @@
+package main
+
+// Helpers which are shared by the tests.
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "testing"
+)
+
+// addRules - parses rules and facts, one per string, and adds them
+// to a knowledge base. If a rule cannot be parsed, the test fails and
+// stops.
+// Params: test
+//         knowledge base
+//         rules and facts
+func addRules(t *testing.T, kb KnowledgeBase, rules ...string) {
+    t.Helper()
+    for _, str := range rules {
+        rule, err := ParseRule(str)
+        if err != nil { t.Fatalf("\n%v:\n%v", t.Name(), err) }
+        kb.Add(rule)
+    }
+} // addRules
@@ -0,0 +1,191 @@
+package main
+
+// Tests shared atoms and hash-consing of ground terms.
+// The benchmarks measure memory and speed with the part-of-speech
+// lexicon of the demo program (57,000 words).
+//
+//   go test -bench=Lexicon -run=XXX
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "reflect"
+    "runtime"
+    "strings"
+    "testing"
+    "bufio"
+    "fmt"
+    "os"
+    "unsafe"
+)
+
+func TestIntern(t *testing.T) {
+
+    fmt.Println("TestIntern")
+
+    // Atoms can be declared as constants.
+    const noun = Atom("noun")
+    if Atom(strings.ToLower("NOUN")) != noun {
+        t.Error("\nTestIntern - Atoms with the same name should be equal.")
+    }
+
+    // Atoms of the same text share their bytes.
+    c, _ := ParseComplex("word(noun, noun)")
+    if stringData(c[1].(Atom)) != stringData(c[2].(Atom)) {
+        t.Error("\nTestIntern - Parsed atoms should be shared.")
+    }
+
+    // Hash-consed atoms share their bytes.
+    atomTable := MakeHashConsTable()
+    a1 := atomTable.HashCons(Atom(strings.ToLower("VERB"))).(Atom)
+    a2 := atomTable.HashCons(Atom(strings.ToLower("VERB"))).(Atom)
+    if a1 != "verb" || stringData(a1) != stringData(a2) {
+        t.Error("\nTestIntern - Hash-consed atoms should be shared.")
+    }
+
+    // Identical ground terms share memory.
+    c1, _ := ParseComplex("word(dog, noun, [singular, third])")
+    c2, _ := ParseComplex("word(dog, noun, [singular, third])")
+    table := MakeHashConsTable()
+    h1 := table.HashCons(c1).(Complex)
+    h2 := table.HashCons(c2).(Complex)
+    if &h1[0] != &h2[0] {
+        t.Error("\nTestIntern - Hash-consed terms should be shared.")
+    }
+    if h1.String() != c1.String() {
+        t.Error("\nTestIntern - Expected: " + c1.String() +
+                "\n                 Was: " + h1.String())
+    }
+
+    // Terms with variables are not shared, but their ground parts are.
+    c3, _ := ParseComplex("word($W, noun, [singular, third])")
+    h3 := table.HashCons(c3).(Complex)
+    if h3.String() != c3.String() {
+        t.Error("\nTestIntern - Expected: " + c3.String() +
+                "\n                 Was: " + h3.String())
+    }
+
+    // Solutions are the same, with or without hash-consing.
+    facts := []string{
+        "word(dog, noun).", "word(cat, noun).", "word(run, verb).",
+        "word(run, noun).", "word(dog, noun).",
+    }
+    queries := []string{ "word($W, noun)", "word(run, $P)", "word(dog, $P)" }
+
+    results := [2][]string{}
+    for i, on := range []bool{ false, true } {
+        kb := KnowledgeBase{}
+        table := MakeHashConsTable()
+        rules, err := ParseRules(strings.Join(facts, "\n"), "")
+        if err != nil { t.Fatal(err) }
+        if on { rules = table.Share(rules...) }
+        kb.Add(rules...)
+        for _, q := range queries {
+            query, _ := ParseQuery(q)
+            solutions, _ := SolveAll(query, kb, SubstitutionSet{})
+            results[i] = append(results[i], fmt.Sprint(solutions))
+        }
+    }
+    for i := range queries {
+        if results[0][i] != results[1][i] {
+            t.Error("\nTestIntern - " + queries[i] +
+                    "\n    Expected: " + results[0][i] +
+                    "\n         Was: " + results[1][i])
+        }
+    }
+
+} // TestIntern
+
+// stringData - returns the address of the bytes of an atom.
+func stringData(a Atom) uintptr {
+    return (*reflect.StringHeader)(unsafe.Pointer(&a)).Data
+}
+
+// loadLexicon - makes a knowledge base of facts from the demo's
+// part-of-speech file, one fact for each tag of a word: pos(dog, NN).
+// If a hash-cons table is given, the facts are shared through it.
+func loadLexicon(b *testing.B, table *HashConsTable) KnowledgeBase {
+    file, err := os.Open("../demo/part_of_speech.txt")
+    if err != nil { b.Fatal(err) }
+    defer file.Close()
+    kb := KnowledgeBase{}
+    scanner := bufio.NewScanner(file)
+    for scanner.Scan() {
+        fields := strings.Fields(scanner.Text())
+        if len(fields) < 2 { continue }
+        for _, tag := range fields[1:] {
+            fact := Fact(Complex{ Atom("pos"), Atom(fields[0]), Atom(tag) })
+            if table != nil {
+                kb.Add(table.Share(fact)...)
+            } else {
+                kb.Add(fact)
+            }
+        }
+    }
+    return kb
+}
+
+// heapInUse - returns the size of the heap after garbage collection.
+func heapInUse() uint64 {
+    var m runtime.MemStats
+    runtime.GC()
+    runtime.ReadMemStats(&m)
+    return m.HeapAlloc
+}
+
+// benchmarkLexiconMemory - measures the memory used by the lexicon.
+// The lexicon is loaded once before measuring, and kept, so that
+// every iteration measures the same thing. The hash-cons table of an iteration is
+// dropped after loading, as a program would drop it.
+func benchmarkLexiconMemory(b *testing.B, hashCons bool) {
+    warm := loadLexicon(b, nil)
+    var total uint64
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        before := heapInUse()
+        var table *HashConsTable
+        if hashCons { table = MakeHashConsTable() }
+        kb := loadLexicon(b, table)
+        total += heapInUse() - before
+        runtime.KeepAlive(kb)
+    }
+    runtime.KeepAlive(warm)
+    b.ReportMetric(float64(total) / float64(b.N) / 1e6, "MB/kb")
+}
+
+func BenchmarkLexiconMemory(b *testing.B) {
+    benchmarkLexiconMemory(b, false)
+}
+
+func BenchmarkLexiconMemoryHashConsed(b *testing.B) {
+    benchmarkLexiconMemory(b, true)
+}
+
+// benchmarkLexiconLookup - looks up the parts of speech of the
+// words of a sentence.
+func benchmarkLexiconLookup(b *testing.B, hashCons bool) {
+    var table *HashConsTable
+    if hashCons { table = MakeHashConsTable() }
+    kb := loadLexicon(b, table)
+    SetMaxTimeMilliseconds(5000)
+    defer SetMaxTimeMilliseconds(300)
+    words := []string{ "they", "envy", "us", "dog", "quickly" }
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        for _, w := range words {
+            query, _ := ParseQuery("pos(" + w + ", $Tag)")
+            if _, failure := SolveAll(query, kb, SubstitutionSet{}); failure != "" {
+                b.Fatal(w, failure)
+            }
+        }
+    }
+}
+
+func BenchmarkLexiconLookup(b *testing.B) {
+    benchmarkLexiconLookup(b, false)
+}
+
+func BenchmarkLexiconLookupHashConsed(b *testing.B) {
+    benchmarkLexiconLookup(b, true)
+}
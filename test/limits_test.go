@@ -0,0 +1,104 @@
+package main
+
+// Tests resource limits per query: inferences, depth, solutions
+// and bindings.
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "testing"
+    "fmt"
+)
+
+func TestLimits(t *testing.T) {
+
+    fmt.Println("TestLimits")
+
+    SetMaxTimeMilliseconds(2000)
+    defer SetMaxTimeMilliseconds(300)
+
+    kb := KnowledgeBase{}
+    err := LoadKBFromFile(kb, "kings.txt")
+    if err != nil {
+        t.Error("\nTestLimits:\n", err.Error())
+        return
+    }
+    rules := []string{
+        "count(0) :- !.",
+        "count($N) :- $M = subtract($N, 1), count($M).",
+        "nat(0).",
+        "nat(s($X)) :- nat($X).",
+        "grow($L) :- grow([x | $L]).",
+        "loop($X) :- loop($X).",
+        "no_loop($X) :- not(loop($X)).",
+        "not_counted($N) :- not(count($N)).",
+    }
+    addRules(t, kb, rules...)
+
+    // No limits. The counters are still available.
+    query, _ := ParseQuery("grandfather($X, $Y)")
+    solutions, stats, err := SolveWithLimits(query, kb,
+                                             SubstitutionSet{}, Limits{})
+    if err != nil || len(solutions) != 2 || stats.Solutions != 2 ||
+       stats.Inferences == 0 || stats.MaxDepth != 1 || stats.Bindings == 0 {
+        t.Errorf("\nTestLimits - grandfather: %v %+v %v", solutions, stats, err)
+    }
+
+    // No solutions is not an error.
+    query, _ = ParseQuery("grandfather(Nobody, $Y)")
+    solutions, _, err = SolveWithLimits(query, kb, SubstitutionSet{}, Limits{})
+    if err != nil || len(solutions) != 0 {
+        t.Errorf("\nTestLimits - grandfather(Nobody): %v %v", solutions, err)
+    }
+
+    tests := []struct {
+        query    string
+        limits   Limits
+        kind     LimitKind
+        found    int    // solutions found before the limit
+    }{
+        { "count(1000)", Limits{ MaxInferences: 500 }, InferenceLimit, 0 },
+        { "count(1000)", Limits{ MaxDepth: 100 }, DepthLimit, 0 },
+        { "nat($X)", Limits{ MaxSolutions: 5 }, SolutionLimit, 5 },
+        { "grow([])", Limits{ MaxBindings: 100000 }, BindingLimit, 0 },
+        // Goals inside not() count too.
+        { "no_loop(a)", Limits{ MaxInferences: 500 }, InferenceLimit, 0 },
+        { "no_loop(a)", Limits{ MaxDepth: 100 }, DepthLimit, 0 },
+    }
+    for _, test := range tests {
+        query, _ := ParseQuery(test.query)
+        solutions, stats, err := SolveWithLimits(query, kb,
+                                         SubstitutionSet{}, test.limits)
+        limitErr, ok := err.(*LimitError)
+        if !ok || limitErr.Kind != test.kind {
+            t.Errorf("\nTestLimits - %v: Expected %v limit. Was: %v",
+                     test.query, test.kind, err)
+            continue
+        }
+        if len(solutions) != test.found || stats.Solutions != test.found {
+            t.Errorf("\nTestLimits - %v: Expected %v solutions. Was %v.",
+                     test.query, test.found, len(solutions))
+        }
+        if test.kind == InferenceLimit && stats.Inferences != 500 {
+            t.Errorf("\nTestLimits - %v: Inferences: %v",
+                     test.query, stats.Inferences)
+        }
+    }
+
+    // Within the limits.
+    query, _ = ParseQuery("count(100)")
+    _, stats, err = SolveWithLimits(query, kb, SubstitutionSet{},
+                        Limits{ MaxInferences: 1000, MaxDepth: 200 })
+    if err != nil || stats.MaxDepth != 101 {
+        t.Errorf("\nTestLimits - count(100): %+v %v", stats, err)
+    }
+
+    // The goals inside not() are counted, at their depth.
+    query, _ = ParseQuery("not_counted(100)")
+    _, stats, err = SolveWithLimits(query, kb, SubstitutionSet{}, Limits{})
+    if err != nil || stats.MaxDepth != 102 || stats.Inferences < 200 {
+        t.Errorf("\nTestLimits - not_counted(100): %+v %v", stats, err)
+    }
+
+} // TestLimits
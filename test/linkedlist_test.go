@@ -76,7 +76,7 @@ func TestLinkedList(t *testing.T) {
         return
     }
 
-    expected = "ParseLinkedList() - Missing argument: [|]"
+    expected = "1:2: Missing argument\n    [|]\n     ^"
     checkErrorMessage(t, expected, err2.Error())
 
     _, err3 := ParseLinkedList("[,]")
@@ -84,7 +84,7 @@ func TestLinkedList(t *testing.T) {
         t.Error("Should produce error: Missing argument")
         return
     }
-    expected = "ParseLinkedList() - Missing argument: [,]"
+    expected = "1:2: Missing argument\n    [,]\n     ^"
     checkErrorMessage(t, expected, err3.Error())
 
     _, err4 := ParseLinkedList("[a,]")
@@ -92,7 +92,7 @@ func TestLinkedList(t *testing.T) {
         t.Error("Should produce error: Missing argument")
         return
     }
-    expected = "ParseLinkedList() - Missing argument: [a,]"
+    expected = "1:4: Missing argument\n    [a,]\n       ^"
     checkErrorMessage(t, expected, err4.Error())
 
     _, err5 := ParseLinkedList("[a, b")
@@ -100,25 +100,29 @@ func TestLinkedList(t *testing.T) {
         t.Error("Should produce error: Missing closing bracket")
         return
     }
-    expected = "ParseLinkedList() - Missing closing bracket: [a, b"
+    expected = "1:6: Missing closing bracket\n    [a, b\n         ^"
     checkErrorMessage(t, expected, err5.Error())
 
     //-------------------------------------------------------
     // Check mismatched quotes.
     _, err6 := ParseLinkedList("[\"a, b, c, d, e]")
     if err6 == nil {
-        t.Error("Should produce error: Unmatched quotes: \"a")
+        t.Error("Should produce error: Unmatched quote mark")
         return
     }
-    expected = "Unmatched quotes: \"a"
+    expected = "1:2: Unmatched quote mark\n" +
+               "    [\"a, b, c, d, e]\n" +
+               "     ^"
     checkErrorMessage(t, expected, err6.Error())
 
     _, err7 := ParseLinkedList("[a, b\"\", c, d, e]")
     if err7 == nil {
-        t.Error("Should produce error: Text before opening quote: b\"\"")
+        t.Error("Should produce error: Text before opening quote")
         return
     }
-    expected = "Text before opening quote: b\"\""
+    expected = "1:6: Text before opening quote\n" +
+               "    [a, b\"\", c, d, e]\n" +
+               "         ^"
     checkErrorMessage(t, expected, err7.Error())
 
     expected = "[lawyer, teacher, programmer, janitor]"
@@ -0,0 +1,127 @@
+package main
+
+// Tests loading knowledge bases from a reader (io.Reader) and from
+// a file system (fs.FS). The text is parsed clause by clause as it
+// is read, so errors must have the same lines and columns as when
+// the whole text is parsed at once.
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "errors"
+    "fmt"
+    "io"
+    "strings"
+    "testing"
+    "testing/fstest"
+    "testing/iotest"
+)
+
+// factReader - produces n facts, number(0). number(1). etc.,
+// without making the whole text.
+type factReader struct {
+    n, next  int
+    pending  string
+}
+
+func (r *factReader) Read(buffer []byte) (int, error) {
+    if r.pending == "" {
+        if r.next >= r.n { return 0, io.EOF }
+        r.pending = fmt.Sprintf("number(%d).\n", r.next)
+        r.next++
+    }
+    n := copy(buffer, r.pending)
+    r.pending = r.pending[n:]
+    return n, nil
+}
+
+func TestLoadKB(t *testing.T) {
+
+    fmt.Println("TestLoadKB")
+
+    // Clauses which span lines, several clauses on one line, and
+    // periods which do not end a clause.
+    text := "colour(red).  colour(blue).\n" +
+            "say($X) :- print(\"It is %s. \", $X),\n" +
+            "           print(%s, [a. b]),\n" +
+            "           nl.   # comment.\n" +
+            "\n" +
+            "size(3.5).\n"
+    kb := KnowledgeBase{}
+    if err := LoadKB(kb, strings.NewReader(text), "text"); err != nil {
+        t.Error("\nTestLoadKB:\n", err)
+        return
+    }
+    rules, _ := ParseRules(text, "text")
+    expected := KnowledgeBase{}
+    expected.Add(rules...)
+    if kb.FormatKB() != expected.FormatKB() {
+        t.Error("\nTestLoadKB - Should be:\n" + expected.FormatKB() +
+                "\nWas:\n" + kb.FormatKB())
+    }
+    if source := kb["colour/1"][1].Source().String(); source != "text:1:15" {
+        t.Error("\nTestLoadKB - Location should be text:1:15, was " + source)
+    }
+
+    // Errors have the right lines.
+    text = "a(1).\n" +
+           "b($X) :- c($X,\n" +
+           "          , $X).\n" +
+           "d(1)\n" +
+           "e(2).\n"
+    err := LoadKB(KnowledgeBase{}, strings.NewReader(text), "bad.txt")
+    expected2 := "bad.txt:3:11: Missing argument\n" +
+                 "              , $X).\n" +
+                 "              ^\n" +
+                 "bad.txt:4:5: Missing period at end of rule\n" +
+                 "    d(1)\n" +
+                 "        ^"
+    if err == nil || err.Error() != expected2 {
+        t.Errorf("\nTestLoadKB - Should produce error:\n%v\nWas:\n%v",
+                 expected2, err)
+    }
+
+    // A large number of facts, which are never in memory as text.
+    kb = KnowledgeBase{}
+    if err := LoadKB(kb, &factReader{ n: 50000 }, "numbers"); err != nil {
+        t.Error("\nTestLoadKB - factReader:\n", err)
+    }
+    if len(kb["number/1"]) != 50000 {
+        t.Errorf("\nTestLoadKB - Should have 50000 facts: %v",
+                 len(kb["number/1"]))
+    }
+
+    // The text is parsed in chunks. An error after many lines
+    // must have the right line.
+    reader := io.MultiReader(&factReader{ n: 10000 },
+                             strings.NewReader("bad(.\n"))
+    err = LoadKB(KnowledgeBase{}, reader, "numbers")
+    if err == nil ||
+       !strings.HasPrefix(err.Error(), "numbers:10001:5: Missing argument") {
+        t.Errorf("\nTestLoadKB - Error should be at line 10001: %v", err)
+    }
+
+    // An error from the reader is returned.
+    readError := errors.New("disk on fire")
+    err = LoadKB(KnowledgeBase{}, iotest.ErrReader(readError), "broken")
+    if err != readError {
+        t.Errorf("\nTestLoadKB - Should return the reader's error: %v", err)
+    }
+
+    // From a file system.
+    fsys := fstest.MapFS{
+        "rules/kings.txt": { Data: []byte("king(Harald).\nking(Olaf).\n") },
+    }
+    kb = KnowledgeBase{}
+    if err := LoadKBFS(kb, fsys, "rules/kings.txt"); err != nil ||
+       len(kb["king/1"]) != 2 {
+        t.Errorf("\nTestLoadKB - LoadKBFS: %v %v", err, kb)
+    }
+    if source := kb["king/1"][1].Source().String();
+       source != "rules/kings.txt:2:1" {
+        t.Error("\nTestLoadKB - Location should be rules/kings.txt:2:1, " +
+                "was " + source)
+    }
+
+} // TestLoadKB
@@ -0,0 +1,167 @@
+package main
+
+// Tests modules: module/2, use_module/1 and use_module/2, qualified
+// calls, private predicates, and the grouping of FormatKB().
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "strings"
+    "testing"
+    "testing/fstest"
+    "fmt"
+)
+
+func TestModule(t *testing.T) {
+
+    fmt.Println("TestModule")
+
+    fsys := fstest.MapFS{
+        "grammar.txt": { Data: []byte(
+            ":- module(grammar, [greeting/2, check/1]).\n" +
+            "greeting --> [hello], name.\n" +
+            "name --> [world].\n" +
+            "check($X) :- helper($X).\n" +
+            "helper(ok).\n") },
+        "lib/lists.txt": { Data: []byte(
+            ":- module(lists, [check/1]).\n" +
+            "check($X) :- helper($X).\n" +
+            "helper(fine).\n") },
+        "main.txt": { Data: []byte(
+            ":- use_module(grammar.txt).\n" +
+            ":- use_module(lib/lists.txt, []).\n" +
+            "helper(user).\n" +
+            "test1 :- phrase(greeting, [hello, world]).\n" +
+            "test2($X) :- check($X).\n" +
+            "test3($X) :- lists:check($X).\n" +
+            "test4($X) :- helper($X).\n") },
+        "private.txt": { Data: []byte(
+            ":- use_module(lib/lists.txt).\n" +
+            "p($X) :- lists:helper($X).\n") },
+        "conflict.txt": { Data: []byte(
+            ":- use_module(grammar.txt).\n" +
+            ":- use_module(lib/lists.txt).\n") },
+        "not_first.txt": { Data: []byte("a.\n:- module(m, []).\n") },
+        "undefined.txt": { Data: []byte(":- module(undef, [nothing/0]).\n") },
+        "not_module.txt": { Data: []byte(":- use_module(plain.txt).\n") },
+        "plain.txt": { Data: []byte("a.\n") },
+        "duplicate.txt": { Data: []byte(":- module(lists, []).\n") },
+        "defined.txt": { Data: []byte(":- module(undef, []).\n") },
+    }
+
+    kb := KnowledgeBase{}
+    if err := LoadKBFS(kb, fsys, "main.txt"); err != nil {
+        t.Error("\nTestModule:\n", err)
+        return
+    }
+
+    // Predicates of modules are qualified. Private predicates
+    // do not collide.
+    for _, key := range []string{ "grammar:check/1", "grammar:helper/1",
+                                  "lists:check/1", "lists:helper/1",
+                                  "helper/1" } {
+        if len(kb[key]) != 1 {
+            t.Errorf("\nTestModule - %v should have one rule.", key)
+        }
+    }
+    if m := kb["grammar:name/2"][0].Module(); m != "grammar" {
+        t.Errorf("\nTestModule - Module should be grammar: %v", m)
+    }
+    rule := kb["lists:check/1"][0].String()
+    if rule != "lists:check($X) :- lists:helper($X)." {
+        t.Errorf("\nTestModule - Rule was not resolved: %v", rule)
+    }
+
+    solutions := map[string]string{
+        "test1":     "test1",
+        "test2($X)": "test2(ok)",
+        "test3($X)": "test3(fine)",
+        "test4($X)": "test4(user)",
+        "grammar:check($X)": "grammar:check(ok)",
+        // Imported predicates can be called by unqualified queries.
+        "check($X)": "check(ok)",
+        "greeting([hello, world], [])": "greeting([hello, world], [])",
+    }
+    for q, expected := range solutions {
+        query, _ := ParseQuery(q)
+        solution, failure := Solve(query, kb, SubstitutionSet{})
+        if len(failure) > 0 {
+            t.Errorf("\nTestModule - %v: %v", q, failure)
+            continue
+        }
+        if solution.String() != expected {
+            t.Error("\nTestModule - Solution should be: " + expected +
+                    "\n                            Was: " + solution.String())
+        }
+    }
+
+    // FormatKB groups rules by module.
+    formatted := kb.FormatKB()
+    headings := []string{ "helper/1", "---------- Module grammar ----------",
+                          "grammar:check/1", "---------- Module lists ----------",
+                          "lists:check/1" }
+    position := 0
+    for _, heading := range headings {
+        i := strings.Index(formatted[position:], heading + "\n")
+        if i < 0 {
+            t.Errorf("\nTestModule - FormatKB should have %v in order:\n%v",
+                     heading, formatted)
+            break
+        }
+        position += i
+    }
+
+    errors := map[string]string{
+        "private.txt": "private.txt:2:1: helper/1 is private to module lists",
+        "conflict.txt": "conflict.txt:2:4: Import conflict: check/1 is " +
+                        "exported by grammar and lists",
+        "not_first.txt": "not_first.txt:2:4: module/2 must be the first " +
+                         "clause of a file",
+        "undefined.txt": "undefined.txt:1:4: Module undef exports " +
+                         "nothing/0, which is not defined",
+        "not_module.txt": "not_module.txt:1:4: Not a module: plain.txt",
+    }
+    for file, expected := range errors {
+        kb := KnowledgeBase{}
+        err := LoadKBFS(kb, fsys, file)
+        if err == nil || !strings.HasPrefix(err.Error(), expected) {
+            t.Errorf("\nTestModule - %v\nShould produce error: %v\nWas: %v",
+                     file, expected, err)
+        }
+        if len(kb) > 0 {
+            t.Errorf("\nTestModule - %v: nothing should be loaded.", file)
+        }
+    }
+
+    // Modules belong to a knowledge base. A module can only be defined
+    // by one file in a knowledge base, and a load which fails leaves no
+    // modules behind.
+    err := LoadKBFS(kb, fsys, "duplicate.txt")
+    expected := "duplicate.txt:1:4: Module lists is already defined " +
+                "in lib/lists.txt"
+    if err == nil || !strings.HasPrefix(err.Error(), expected) {
+        t.Errorf("\nTestModule - Should produce error: %v\nWas: %v",
+                 expected, err)
+    }
+    if len(kb.Modules()) != 2 || kb.Modules()[1].File != "lib/lists.txt" {
+        t.Errorf("\nTestModule - Modules: %v", kb.Modules())
+    }
+    other := KnowledgeBase{}
+    if err := LoadKBFS(other, fsys, "duplicate.txt"); err != nil {
+        t.Error("\nTestModule - duplicate.txt in another knowledge base:\n", err)
+    }
+    LoadKBFS(other, fsys, "undefined.txt")
+    if err := LoadKBFS(other, fsys, "defined.txt"); err != nil {
+        t.Error("\nTestModule - defined.txt after a failed load:\n", err)
+    }
+
+    _, err = ParseRules(":- module(m, []).\n", "")
+    expected = "1:4: module/2 can only be used in a file which is " +
+                "loaded into a knowledge base"
+    if err == nil || !strings.HasPrefix(err.Error(), expected) {
+        t.Errorf("\nTestModule - Should produce error: %v\nWas: %v",
+                 expected, err)
+    }
+
+} // TestModule
@@ -0,0 +1,104 @@
+package main
+
+// Tests the occurs check, unify_with_occurs_check/2, and the
+// handling of cyclic terms.
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "testing"
+    "strings"
+    "fmt"
+)
+
+func TestOccursCheck(t *testing.T) {
+
+    fmt.Println("TestOccursCheck")
+
+    kb := KnowledgeBase{}
+    rules := []string{
+        "cyclic($X) :- $X = f($X).",
+        "cyclic_list($L) :- $L = [a | $L].",
+        "safe($X) :- unify_with_occurs_check($X, f($X)).",
+        "safe2($X) :- unify_with_occurs_check($X, f($Y)), $Y = a.",
+        "safe3($X) :- $X = f($X), unify_with_occurs_check($X, $Y).",
+    }
+    addRules(t, kb, rules...)
+
+    // Without an occurs check, a cyclic term is created.
+    // It must be possible to print it.
+    query, _ := ParseQuery("cyclic($X)")
+    solution, failure := Solve(query, kb, SubstitutionSet{})
+    if len(failure) > 0 || !strings.HasPrefix(solution.String(), "cyclic(f($X_") {
+        t.Error("\nTestOccursCheck - Expected cyclic(f($X_n)). Was: ",
+                solution, failure)
+    }
+
+    root := query.GetSolver(kb, SubstitutionSet{}, nil)
+    ss, _ := root.NextSolution()
+    result := FormatSolution(query, ss)
+    if !strings.HasPrefix(result, "$X = f(") {
+        t.Error("\nTestOccursCheck - FormatSolution: " + result)
+    }
+
+    query, _ = ParseQuery("cyclic_list($L)")
+    _, failure = Solve(query, kb, SubstitutionSet{})
+    if len(failure) > 0 {
+        t.Error("\nTestOccursCheck - cyclic_list: " + failure)
+    }
+
+    query, _ = ParseQuery("safe($X)")
+    _, failure = Solve(query, kb, SubstitutionSet{})
+    if failure != "No" {
+        t.Error("\nTestOccursCheck - unify_with_occurs_check should fail.")
+    }
+
+    query, _ = ParseQuery("safe2($X)")
+    solution, failure = Solve(query, kb, SubstitutionSet{})
+    expected := "safe2(f(a))"
+    if len(failure) > 0 || solution.String() != expected {
+        t.Error("\nTestOccursCheck - Expected: " + expected +
+                "\n                     Was: ", solution, failure)
+    }
+
+    // A cycle which was made before is tolerated.
+    query, _ = ParseQuery("safe3($X)")
+    _, failure = Solve(query, kb, SubstitutionSet{})
+    if len(failure) > 0 {
+        t.Error("\nTestOccursCheck - safe3: " + failure)
+    }
+
+    // Unification fails. The mode applies to one query only.
+    checked := SubstitutionSet{}.WithOccursCheck(OccursCheckTrue)
+    query, _ = ParseQuery("cyclic($X)")
+    _, failure = Solve(query, kb, checked)
+    if failure != "No" {
+        t.Error("\nTestOccursCheck - true: Expected No. Was: " + failure)
+    }
+    _, failure = Solve(query, kb, SubstitutionSet{})
+    if len(failure) > 0 {
+        t.Error("\nTestOccursCheck - false: " + failure)
+    }
+
+    // Unification causes an error.
+    mode, err := ParseOccursCheck("error")
+    if err != nil {
+        t.Error("\nTestOccursCheck - ", err)
+        return
+    }
+    checked = SubstitutionSet{}.WithOccursCheck(mode)
+    _, failure = Solve(query, kb, checked)
+    if !strings.HasPrefix(failure, "Occurs check") {
+        t.Error("\nTestOccursCheck - error: Was: " + failure)
+    }
+
+    // unify_with_occurs_check/2 fails, whatever the mode.
+    query, _ = ParseQuery("safe($X)")
+    _, failure = Solve(query, kb, checked)
+    if failure != "No" {
+        t.Error("\nTestOccursCheck - error: safe($X) should fail. Was: " +
+                failure)
+    }
+
+} // TestOccursCheck
@@ -0,0 +1,198 @@
+package main
+
+// Tests user-defined operators: the op/3 directive and predicate,
+// the Go functions Op(), CurrentOp() and Operators(), parsing by
+// priority and type, and writing operations in operator form.
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "strings"
+    "testing"
+    "fmt"
+)
+
+func TestOp(t *testing.T) {
+
+    fmt.Println("TestOp")
+    defer ResetOperators()
+
+    program := ":- op(700, xfx, isa).\n" +
+               ":- op(800, xfy, [implies, and_then]).\n" +
+               "mammal isa animal.\n" +
+               "dog isa mammal.\n" +
+               "kind($X, $Z) :- $X isa $Y, $Y isa $Z.\n" +
+               "rule(a implies b implies c).\n" +
+               "rule((a implies b) implies c).\n" +
+               "g :- (x implies y) implies z.\n"
+    rules, err := ParseRules(program, "")
+    if err != nil {
+        t.Error("\nTestOp:\n", err)
+        return
+    }
+
+    // The directives define operators for the text only. Outside of
+    // it, operations are written in standard form.
+    expected := []string{
+        "isa(mammal, animal).",
+        "isa(dog, mammal).",
+        "kind($X, $Z) :- isa($X, $Y), isa($Y, $Z).",
+        "rule(implies(a, implies(b, c))).",
+        "rule(implies(implies(a, b), c)).",
+        "g :- implies(implies(x, y), z).",
+    }
+    for i, rule := range rules {
+        if rule.String() != expected[i] {
+            t.Error("\nTestOp - Rule should be: " + expected[i] +
+                    "\n                    Was: " + rule.String())
+        }
+    }
+    c, _ := ParseComplex("rule(a implies b)")
+    if len(c) != 2 || c.GetTerm(1).String() != "a implies b" {
+        t.Errorf("\nTestOp - implies should not be an operator: %v", c)
+    }
+
+    // Operators defined by Op() are written in operator form, with
+    // parentheses where they are needed. The output can be read back.
+    Op(700, "xfx", "isa")
+    Op(800, "xfy", "implies")
+    expected = []string{
+        "mammal isa animal.",
+        "dog isa mammal.",
+        "kind($X, $Z) :- $X isa $Y, $Y isa $Z.",
+        "rule(a implies b implies c).",
+        "rule((a implies b) implies c).",
+        "g :- (x implies y) implies z.",
+    }
+    for i, rule := range rules {
+        if rule.String() != expected[i] {
+            t.Error("\nTestOp - Rule should be: " + expected[i] +
+                    "\n                    Was: " + rule.String())
+        }
+        again, err := ParseRule(rule.String())
+        if err != nil || again.String() != rule.String() {
+            t.Errorf("\nTestOp - Round trip fails: %v, %v", again, err)
+        }
+    }
+
+    // xfy is right associative.
+    c, _ = ParseComplex("rule(a implies b implies c)")
+    right, ok := c.GetTerm(1).(Complex)
+    if !ok || right.GetTerm(1).String() != "a" {
+        t.Errorf("\nTestOp - implies should be right associative: %v", c)
+    }
+    ResetOperators()
+
+    // Punctuation and goals in parentheses are terms, which are
+    // written with their parentheses.
+    for _, str := range []string{
+        "t($X) :- $X = (a :- b).",
+        "t($X) :- $X = (a ; b).",
+        "t($X) :- $X = (a :- b, c ; d).",
+        "t($X) :- freeze($X, ($Y = 1)).",
+        "t($X) :- when(nonvar($X), ($X = 1, print(($X ; b)))).",
+        "t :- print((a :- b)).",
+    } {
+        rule, err := ParseRule(str)
+        if err != nil || rule.String() != str {
+            t.Errorf("\nTestOp - Round trip fails: %v, %v", rule, err)
+        }
+    }
+
+    // A directive defines an operator in the knowledge base which the
+    // text is loaded into, unless the load fails.
+    load := func(kb KnowledgeBase, text string) error {
+        return LoadKB(kb, strings.NewReader(text), "ops.txt")
+    }
+    kb := KnowledgeBase{}
+    if err := load(kb, program); err != nil {
+        t.Error("\nTestOp:\n", err)
+        return
+    }
+    load(kb, "cat isa mammal.\n")
+    other := KnowledgeBase{}
+    load(other, "cat isa mammal.\n")
+    failed := KnowledgeBase{}
+    load(failed, ":- op(700, xfx, isa).\nbad($X, , $Y).\n")
+    load(failed, "cat isa mammal.\n")
+    query, _ := ParseQuery("kind(cat, $X)")
+    solution, failure := Solve(query, kb, SubstitutionSet{})
+    if len(failure) > 0 || solution.GetTerm(2).String() != "animal" {
+        t.Errorf("\nTestOp - kind(cat, $X): %v %v", solution, failure)
+    }
+    query, _ = ParseQuery("isa(cat, $X)")
+    for _, kb := range []KnowledgeBase{ other, failed } {
+        if _, failure := Solve(query, kb, SubstitutionSet{}); failure == "" {
+            t.Error("\nTestOp - isa should not be an operator.")
+        }
+    }
+
+    // The Go API.
+    if err := Op(200, "yfx", "minus"); err != nil {
+        t.Error("\nTestOp - Op():", err)
+    }
+    op, ok := CurrentOp("minus")
+    if !ok || op.String() != "op(200, yfx, minus)" {
+        t.Errorf("\nTestOp - CurrentOp(): %v", op)
+    }
+    c, _ = ParseComplex("f(a minus b minus c)")
+    if c.String() != "f(a minus b minus c)" ||
+       c.GetTerm(1).(Complex).GetTerm(1).String() != "a minus b" {
+        t.Errorf("\nTestOp - yfx should be left associative: %v", c)
+    }
+    Op(0, "yfx", "minus")
+    if _, ok := CurrentOp("minus"); ok {
+        t.Error("\nTestOp - Priority 0 should remove an operator.")
+    }
+    list := Operators()
+    if list[0].Name != "-->" || list[1].Name != ":-" ||
+       list[1].Priority != 1200 {
+        t.Errorf("\nTestOp - Operators(): %v", list)
+    }
+
+    errors := map[string]string{
+        "xfy ,":    "Op - Cannot change operator: ,",
+        "fy isa":   "Op - Type must be xfx, xfy or yfx: fy",
+        "xfx a b":  "Op - Invalid operator name: a b",
+        "xfx %%":   "Op - Invalid operator name: %%",
+    }
+    for args, expected := range errors {
+        parts := strings.SplitN(args, " ", 2)
+        err := Op(700, parts[0], parts[1])
+        if err == nil || err.Error() != expected {
+            t.Errorf("\nTestOp - Op(%v) should fail: %v", args, expected)
+        }
+    }
+
+    // An xfx operator is not associative.
+    _, err = ParseRules(":- op(700, xfx, isa).\na isa b isa c.", "")
+    expected2 := "2:9: Operator priority clash: isa\n" +
+                 "    a isa b isa c.\n" +
+                 "            ^"
+    if err == nil || err.Error() != expected2 {
+        t.Errorf("\nTestOp - Should produce error:\n%v\nWas:\n%v", expected2, err)
+    }
+
+    _, err = ParseRules(":- foo(bar).", "")
+    if err == nil || !strings.HasPrefix(err.Error(),
+                                        "1:4: Unknown directive: foo(bar)") {
+        t.Errorf("\nTestOp - Unknown directive: %v", err)
+    }
+
+    // op/3 can be called from a rule. It defines the operator in
+    // the knowledge base.
+    kb = KnowledgeBase{}
+    rule, _ := ParseRule("define :- op(100, xfx, likes).")
+    kb.Add(rule)
+    query, _ = ParseQuery("define")
+    _, failure = Solve(query, kb, SubstitutionSet{})
+    load(kb, "anne likes bob.\n")
+    query, _ = ParseQuery("likes(anne, $X)")
+    solution, failure2 := Solve(query, kb, SubstitutionSet{})
+    if _, ok := CurrentOp("likes"); len(failure) > 0 || ok ||
+       len(failure2) > 0 || solution.GetTerm(2).String() != "bob" {
+        t.Errorf("\nTestOp - op/3 in a rule: %v %v", failure, failure2)
+    }
+
+} // TestOp
@@ -0,0 +1,129 @@
+package main
+
+// Tests overlays: knowledge bases which are layered on top of a
+// parent, for the facts of a session.
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "runtime"
+    "strings"
+    "testing"
+    "fmt"
+)
+
+func TestOverlay(t *testing.T) {
+
+    fmt.Println("TestOverlay")
+
+    base := KnowledgeBase{}
+    rules, err := ParseRules("word(the, article).\n" +
+                             "word(cat, noun).\n" +
+                             "noun($W) :- word($W, noun).\n", "")
+    if err != nil {
+        t.Error("\nTestOverlay:\n", err)
+        return
+    }
+    base.Add(rules...)
+
+    // words - the solutions of word($W, $P), as a string.
+    words := func(kb KnowledgeBase) string {
+        query, _ := ParseQuery("word($W, $P)")
+        solutions, _ := SolveAll(query, kb, SubstitutionSet{})
+        list := []string{}
+        for _, s := range solutions { list = append(list, s.String()) }
+        return strings.Join(list, " ")
+    }
+
+    dog, _ := ParseRule("word(dog, noun)")
+    first := base.Overlay(OverlayFirst)
+    first.Add(dog)
+    last := base.Overlay(OverlayLast)
+    last.Add(dog)
+
+    expected := map[string]KnowledgeBase{
+        "word(dog, noun) word(the, article) word(cat, noun)": first,
+        "word(the, article) word(cat, noun) word(dog, noun)": last,
+        "word(the, article) word(cat, noun)": base,
+    }
+    for e, kb := range expected {
+        if result := words(kb); result != e {
+            t.Error("\nTestOverlay - Should be: " + e +
+                    "\n                   Was: " + result)
+        }
+    }
+
+    // Rules of the parent see the facts of the overlay.
+    query, _ := ParseQuery("noun($W)")
+    solutions, _ := SolveAll(query, first, SubstitutionSet{})
+    if len(solutions) != 2 {
+        t.Errorf("\nTestOverlay - noun($W) should have 2 solutions: %v",
+                 solutions)
+    }
+
+    // A retract in the overlay hides a clause of the parent.
+    cat, _ := ParseComplex("word(cat, $_)")
+    if !first.Retract(cat) {
+        t.Error("\nTestOverlay - word(cat, $_) should be retracted.")
+    }
+    if !first.Retract(dog.GetHead()) {
+        t.Error("\nTestOverlay - word(dog, noun) should be retracted.")
+    }
+    if result := words(first); result != "word(the, article)" {
+        t.Error("\nTestOverlay - Should be: word(the, article)" +
+                "\n                   Was: " + result)
+    }
+    if first.Retract(cat) {
+        t.Error("\nTestOverlay - word(cat, $_) is already hidden.")
+    }
+    if len(base["word/2"]) != 2 {
+        t.Error("\nTestOverlay - The parent should not change.")
+    }
+    if strings.Contains(first.FormatKB(), "word(cat, noun)") {
+        t.Error("\nTestOverlay - FormatKB should not show hidden clauses.")
+    }
+
+    // Hidden clauses stay hidden when the parent changes, and clauses
+    // which are added to the parent become visible.
+    parent := KnowledgeBase{}
+    parent.Add(rules...)
+    session := parent.Overlay(OverlayLast)
+    session.Retract(cat)
+    words(session)  // fills the cache
+    the, _ := ParseComplex("word(the, $_)")
+    parent.Retract(the)
+    bird, _ := ParseRule("word(bird, noun)")
+    parent.Add(bird)
+    if result := words(session); result != "word(bird, noun)" {
+        t.Error("\nTestOverlay - Should be: word(bird, noun)" +
+                "\n                   Was: " + result)
+    }
+
+    // An overlay of an overlay.
+    second := last.Overlay(OverlayFirst)
+    second.Add(dog)
+    if result := words(second); result != "word(dog, noun) " +
+       "word(the, article) word(cat, noun) word(dog, noun)" {
+        t.Error("\nTestOverlay - Overlay of overlay was: " + result)
+    }
+    second.Drop()
+    last.Drop()
+    first.Drop()
+    if result := words(first); result != "" {
+        t.Error("\nTestOverlay - A dropped overlay should be empty: " + result)
+    }
+
+    // Overlays which are not dropped are freed by the garbage collector.
+    // Knowledge bases which are made afterwards are not overlays.
+    for i := 0; i < 1000; i++ { base.Overlay(OverlayFirst).Add(dog) }
+    runtime.GC()
+    for i := 0; i < 1000; i++ {
+        if result := words(KnowledgeBase{}); result != "" {
+            t.Error("\nTestOverlay - A new knowledge base should be empty: " +
+                    result)
+            break
+        }
+    }
+
+} // TestOverlay
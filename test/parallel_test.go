@@ -0,0 +1,104 @@
+package main
+
+// Tests SolveAllParallel, by comparing its solutions with those of
+// SolveAll. Also tests that cuts prune the clauses which follow them.
+//
+//   go test -race -run=Parallel
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "sort"
+    "testing"
+    "fmt"
+)
+
+func TestParallel(t *testing.T) {
+
+    fmt.Println("TestParallel")
+
+    SetMaxTimeMilliseconds(2000)
+    defer SetMaxTimeMilliseconds(300)
+
+    kb := KnowledgeBase{}
+    err := LoadKBFromFile(kb, "kings.txt")
+    if err != nil {
+        t.Error("\nTestParallel:\n", err.Error())
+        return
+    }
+
+    rules := []string{
+        "color(red).", "color(green).", "color(blue).",
+        "pick($X) :- color($X).",
+        "pick($X) :- $X = first, !.",
+        "pick(never).",
+        "try($X) :- $X = a.",
+        "try($X) :- !, fail.",
+        "try(c).",
+        "inner($X) :- color($X), !.",
+        "inner(last).",
+        "count_down(0) :- !.",
+        "count_down($N) :- $M = subtract($N, 1), count_down($M).",
+        "loop($N) :- count_down(500), $N = 1.",
+        "loop($N) :- count_down(600), $N = 2.",
+        "loop($N) :- count_down(700), $N = 3.",
+    }
+    addRules(t, kb, rules...)
+
+    queries := []string{
+        "grandfather($X, $Y)",
+        "grandmother($X, $Y)",
+        "pick($X)",
+        "try($X)",
+        "inner($X)",
+        "loop($N)",
+        "unknown($X)",
+    }
+
+    for _, q := range queries {
+        query, _ := ParseQuery(q)
+        expected, failure1 := SolveAll(query, kb, SubstitutionSet{})
+        for _, workers := range []int{ 1, 4 } {
+            query, _ = ParseQuery(q)
+            actual, failure2 := SolveAllParallel(query, kb, workers, OrderedResults)
+            if failure1 != failure2 {
+                t.Error("\nTestParallel - " + q + ": Expected: " + failure1 +
+                        "\n                           Was: " + failure2)
+                continue
+            }
+            if !sameSolutions(expected, actual, false) {
+                t.Errorf("\nTestParallel - %v\n    Expected: %v\n         Was: %v",
+                         q, expected, actual)
+            }
+            // Unordered results are the same, in some order.
+            query, _ = ParseQuery(q)
+            actual, _ = SolveAllParallel(query, kb, workers)
+            if !sameSolutions(expected, actual, true) {
+                t.Errorf("\nTestParallel - %v\n    Expected: %v\n         Was: %v",
+                         q, expected, actual)
+            }
+        }
+    }
+
+} // TestParallel
+
+// sameSolutions - compares two lists of solutions, ignoring the
+// ID numbers of variables, and optionally the order of the solutions.
+func sameSolutions(expected, actual []Complex, anyOrder bool) bool {
+    if len(expected) != len(actual) { return false }
+    s1 := make([]string, len(expected))
+    s2 := make([]string, len(actual))
+    for i := range expected {
+        s1[i] = variableSuffix(expected[i].String())
+        s2[i] = variableSuffix(actual[i].String())
+    }
+    if anyOrder {
+        sort.Strings(s1)
+        sort.Strings(s2)
+    }
+    for i := range s1 {
+        if s1[i] != s2[i] { return false }
+    }
+    return true
+}
@@ -0,0 +1,33 @@
+package main
+
+// Fuzzes the parser. Whatever the input, parsing must not panic.
+// To fuzz for a while:
+//
+//    go test -run XXX -fuzz FuzzParse
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "os"
+    "testing"
+)
+
+func FuzzParse(f *testing.F) {
+    for _, fileName := range []string{ "kings.txt", "qsort.txt",
+                                       "puzzle.txt", "badrule1.txt" } {
+        data, err := os.ReadFile(fileName)
+        if err == nil { f.Add(string(data)) }
+    }
+    f.Add("f(\"a, b\", [c | $T], \\,) :- $X = 1, not(g($X)) ; !.")
+    f.Add("h :- ([a, (b | c), \"")
+    f.Fuzz(func(t *testing.T, text string) {
+        defer ResetOperators()   // in case of an op/3 directive
+        ParseRules(text, "fuzz.txt")
+        ParseRule(text)
+        ParseComplex(text)
+        ParseSubgoal(text)
+        ParseLinkedList(text)
+        ParseFunction(text)
+    })
+} // FuzzParse
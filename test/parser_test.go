@@ -0,0 +1,154 @@
+package main
+
+// Tests the parser: syntax errors with their locations and excerpts,
+// recovery after errors, and some tricky syntax (quotes, comments,
+// escaped characters, nested brackets).
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "testing"
+    "fmt"
+)
+
+func TestParser(t *testing.T) {
+
+    fmt.Println("TestParser")
+
+    // All errors are reported at once, with line, column and excerpt.
+    program := "# Three bad rules.\n" +
+               "mother($X, $Y) :- parent($X, , $Y).\n" +
+               "father($X, $Y) :- parent($X, $Y), male($X).\n" +
+               "sister($X, $Y) :- female($X) parent($Z, $X).\n" +
+               "\tbrother([a, b | c]).\n" +
+               "male(Harold).\n"
+    rules, err := ParseRules(program, "family.txt")
+    expected := "family.txt:2:30: Missing argument\n" +
+                "    mother($X, $Y) :- parent($X, , $Y).\n" +
+                "                                 ^\n" +
+                "family.txt:4:30: Expected , ; or ., found parent\n" +
+                "    sister($X, $Y) :- female($X) parent($Z, $X).\n" +
+                "                                 ^\n" +
+                "family.txt:5:18: The tail of a list must be a variable or a list\n" +
+                "    \tbrother([a, b | c]).\n" +
+                "    \t                ^"
+    if err == nil {
+        t.Error("\nTestParser - Should produce error:\n" + expected)
+        return
+    }
+    checkErrorMessage(t, expected, err.Error())
+
+    errors, ok := err.(ParseErrors)
+    if !ok || len(errors) != 3 || errors[1].Line != 4 ||
+       errors[1].Column != 30 || errors[1].File != "family.txt" {
+        t.Errorf("\nTestParser - ParseErrors: %#v", err)
+    }
+
+    // The good rules are still parsed.
+    if len(rules) != 2 || rules[0].String() !=
+       "father($X, $Y) :- parent($X, $Y), male($X)." {
+        t.Errorf("\nTestParser - rules after errors: %v", rules)
+    }
+
+    // A missing period is reported at the end of the line, and the
+    // next rule is parsed.
+    _, err = ParseRules("male(Harold)\nmale(Godwin).\nfemale(Edith)", "")
+    expected = "1:13: Missing period at end of rule\n" +
+               "    male(Harold)\n" +
+               "                ^\n" +
+               "3:14: Missing period at end of rule\n" +
+               "    female(Edith)\n" +
+               "                 ^"
+    if err == nil {
+        t.Error("\nTestParser - Should produce error:\n" + expected)
+    } else {
+        checkErrorMessage(t, expected, err.Error())
+    }
+
+    // The wrong number of arguments for a built-in predicate.
+    _, err = ParseRule("test :- append(a).")
+    expected = "1:9: Append - This predicate requires at least 2 arguments.\n" +
+               "    test :- append(a).\n" +
+               "            ^"
+    if err == nil {
+        t.Error("\nTestParser - Should produce error:\n" + expected)
+    } else {
+        checkErrorMessage(t, expected, err.Error())
+    }
+
+    // If a file has errors, nothing is added to the knowledge base.
+    kb := KnowledgeBase{}
+    err = LoadKBFromFile(kb, "badrule1.txt")
+    if err == nil || len(kb) != 0 {
+        t.Errorf("\nTestParser - badrule1.txt: %v, %v", err, kb)
+    }
+
+    // Tricky syntax which must parse.
+    tricky := map[string]string{
+        // Commas and parentheses between quotes, escaped characters.
+        "quote(\"Hello, (world)\", \\, , \\\").":
+            "quote(Hello, (world), ,, \").",
+        // A percent sign in arguments is not a comment.
+        "say($R) :- print(Rank: %s., $R),   % comment\n   nl.":
+            "say($R) :- print(Rank: %s., $R), nl.",
+        // A hash which begins an operator is not a comment.
+        "c($X, $Y) :- $X #\\= $Y,  # comment\n  $X #=< 9.":
+            "c($X, $Y) :- $X #\\= $Y, $X #=< 9.",
+        // Nested brackets, and a list as the tail of a list.
+        "n([[a, b], f(g(h), [c | $T])], [x | [y, z]]).":
+            "n([[a, b], f(g(h), [c | $T])], [x, y, z]).",
+        // Groups and disjunction.
+        "d($X) :- (a($X) ; b($X)), not(c($X)).":
+            "d($X) :- (a($X); b($X)), not(c($X)).",
+        // Operands of built-in operators in parentheses.
+        "t($Y) :- $Y = (a = b), $Y == (b < c).":
+            "t($Y) :- $Y = (a = b), $Y == (b < c).",
+        "t(a = (b = c), (a = b) = c, a = b).":
+            "t((a = (b = c)), ((a = b) = c), a = b).",
+        // An infix goal over two lines.
+        "s($X) :- add($X, 1) #=\n    multiply($X, 2).":
+            "s($X) :- add($X, 1) #= multiply($X, 2).",
+        // A period in a number is not the end of the rule.
+        "r($X) :- $X in 0..9, $Y = 3.5.":
+            "r($X) :- $X in 0..9, $Y = 3.500000.",
+    }
+    for source, expected := range tricky {
+        rule, err := ParseRule(source)
+        if err != nil {
+            t.Errorf("\nTestParser - %v\n%v", source, err)
+            continue
+        }
+        if rule.String() != expected {
+            t.Error("\nTestParser - Rule should be: " + expected +
+                    "\n                        Was: " + rule.String())
+        }
+    }
+
+    // Operations and groups are written so that they can be read back.
+    readBack := []string{
+        "d($X) :- (a($X) ; b($X)), not(c($X)).",
+        "t($Y) :- $Y = (a = b), $Y == (b < c).",
+        "t(a = (b = c), (a = b) = c, a = b).",
+        "t :- a, (b ; c, (d ; e)), f.",
+    }
+    for _, source := range readBack {
+        rule, _ := ParseRule(source)
+        again, err := ParseRule(rule.String())
+        if err != nil || again.String() != rule.String() {
+            t.Errorf("\nTestParser - Cannot read back: %v\n%v %v",
+                     rule, again, err)
+        }
+    }
+
+    // The terms of the arguments have the right types.
+    c, _ := ParseComplex("f(12, -7, 3.5, \"12\", \\$X, $X, $_, $10)")
+    types := []int{ INTEGER, INTEGER, FLOAT, ATOM, ATOM,
+                    VARIABLE, ANONYMOUS, ATOM }
+    for i, termType := range types {
+        if c.GetTerm(i + 1).TermType() != termType {
+            t.Errorf("\nTestParser - Term %v of %v has the wrong type.", i + 1, c)
+        }
+    }
+
+} // TestParser
@@ -0,0 +1,121 @@
+package main
+
+// Tests the profiler: port counts and times of predicates and clauses,
+// the table, the pprof output, and the built-in predicate profile/1.
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "compress/gzip"
+    "io/ioutil"
+    "strings"
+    "testing"
+    "bytes"
+    "fmt"
+)
+
+func TestProfiler(t *testing.T) {
+
+    fmt.Println("TestProfiler")
+
+    kb := KnowledgeBase{}
+    err := LoadKBFromFile(kb, "kings.txt")
+    if err != nil {
+        t.Error("\nTestProfiler:\n", err.Error())
+        return
+    }
+
+    profiler := StartProfiler()
+    query, _ := ParseQuery("grandfather($X, $Y)")
+    solutions, _ := SolveAll(query, kb, SubstitutionSet{})
+    profiler.Stop()
+    if len(solutions) != 2 {
+        t.Errorf("\nTestProfiler - grandfather: %v", solutions)
+    }
+
+    // After Stop(), nothing is counted.
+    query, _ = ParseQuery("grandfather(Godwin, $Y)")
+    SolveAll(query, kb, SubstitutionSet{})
+
+    predicates := map[string]PredicateProfile{}
+    for _, pp := range profiler.Predicates() { predicates[pp.Key] = pp }
+    expected := map[string][4]int64{
+        //                 calls exits redos fails
+        "grandfather/2": {  1,    2,    2,    1 },
+        "parent/2":      { 11,   14,   14,   11 },
+        "male/1":        {  4,    2,    2,    4 },
+    }
+    for key, counts := range expected {
+        pp := predicates[key]
+        actual := [4]int64{ pp.Calls, pp.Exits, pp.Redos, pp.Fails }
+        if actual != counts {
+            t.Errorf("\nTestProfiler - %v: Expected %v. Was %v.",
+                     key, counts, actual)
+        }
+        if pp.Inclusive <= 0 || pp.Exclusive > pp.Inclusive {
+            t.Errorf("\nTestProfiler - %v: times %v %v",
+                     key, pp.Inclusive, pp.Exclusive)
+        }
+    }
+    if predicates["grandfather/2"].Inclusive <
+       predicates["parent/2"].Inclusive {
+        t.Error("\nTestProfiler - The inclusive time of grandfather/2 " +
+                "should include parent/2.")
+    }
+
+    // Clauses. The rule for grandfather is on line 28 of kings.txt.
+    found := false
+    for _, cp := range profiler.Clauses() {
+        if cp.Key == "grandfather/2" {
+            found = true
+            if cp.Clause != 1 || cp.Line != 28 || cp.File != "kings.txt" ||
+               cp.Calls != 1 || cp.Exits != 2 || cp.Fails != 1 ||
+               cp.Inclusive <= 0 {
+                t.Errorf("\nTestProfiler - grandfather/2, clause: %+v", cp)
+            }
+        }
+    }
+    if !found { t.Error("\nTestProfiler - Missing clause of grandfather/2.") }
+
+    // The table is sorted by inclusive time.
+    table := profiler.Table()
+    lines := strings.Split(table, "\n")
+    if !strings.HasPrefix(lines[0], "Predicate") ||
+       !strings.HasPrefix(lines[1], "grandfather/2") ||
+       !strings.Contains(table, "  clause 1 (line 28)") {
+        t.Error("\nTestProfiler - table:\n" + table)
+    }
+
+    // The pprof profile is compressed with gzip. The names of the
+    // functions are predicates.
+    var buffer bytes.Buffer
+    err = profiler.WritePprof(&buffer)
+    if err != nil {
+        t.Error("\nTestProfiler - WritePprof: " + err.Error())
+    } else {
+        zr, err := gzip.NewReader(&buffer)
+        if err != nil {
+            t.Error("\nTestProfiler - pprof: " + err.Error())
+        } else {
+            data, _ := ioutil.ReadAll(zr)
+            for _, s := range []string{ "grandfather/2", "parent/2",
+                                        "nanoseconds", "kings.txt" } {
+                if !bytes.Contains(data, []byte(s)) {
+                    t.Error("\nTestProfiler - pprof: Missing " + s)
+                }
+            }
+        }
+    }
+
+    // profile/1 runs its goal once, and prints a table.
+    rule, _ := ParseRule("grandson($X) :- profile(grandfather(Godwin, $X)).")
+    kb.Add(rule)
+    query, _ = ParseQuery("grandson($X)")
+    solutions, failure := SolveAll(query, kb, SubstitutionSet{})
+    if failure != "" || len(solutions) != 1 ||
+       solutions[0].String() != "grandson(Harold)" {
+        t.Errorf("\nTestProfiler - profile/1: %v %v", solutions, failure)
+    }
+
+} // TestProfiler
@@ -28,23 +28,27 @@ func TestReadRules(t *testing.T) {
     }
 
     err = LoadKBFromFile(kb, "badrule1.txt")
-    expected = "Error - unmatched parenthesis: (\n" +
-               "Check start of file."
+    expected = "badrule1.txt:2:42: Missing closing parenthesis\n" +
+        "    father($X, $Y) :- parent($X, $Y, male($X).\n" +
+        "                                             ^"
     if err.Error() != expected {
         t.Error("\nTestReadRules - Should produce error:\n" + expected)
         return
     }
 
     err = LoadKBFromFile(kb, "badrule2.txt")
-    expected = "Error - unmatched parenthesis: )\n" +
-               "Error occurs after: parent(Godwin, Tostig)."
+    expected = "badrule2.txt:3:43: Expected , ; or ., found )\n" +
+        "    mother($X, $Y) :- parent($X, $Y), female$X).\n" +
+        "                                              ^"
     if err.Error() != expected {
         t.Error("\nTestReadRules - Should produce error:\n" + expected)
         return
     }
 
     err = LoadKBFromFile(kb, "badrule3.txt")
-    expected = "Check line 3: par"
+    expected = "badrule3.txt:3:4: Missing period at end of rule\n" +
+               "    par\n" +
+               "       ^"
     if err.Error() != expected {
         t.Error("\nTestReadRules - Should produce error:\n" + expected)
         return
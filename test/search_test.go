@@ -0,0 +1,154 @@
+package main
+
+// Tests the search strategies: depth-limited, iterative deepening
+// and breadth-first search.
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "testing"
+    "fmt"
+)
+
+func TestSearch(t *testing.T) {
+
+    fmt.Println("TestSearch")
+
+    SetMaxTimeMilliseconds(2000)
+    defer SetMaxTimeMilliseconds(300)
+
+    kb := KnowledgeBase{}
+    err := LoadKBFromFile(kb, "kings.txt")
+    if err != nil {
+        t.Error("\nTestSearch:\n", err.Error())
+        return
+    }
+    rules := []string{
+        // Depth-first search never gets past the first rule.
+        "loop($X) :- loop($X).",
+        "loop(a).",
+        // Left recursion.
+        "ancestor($X, $Y) :- ancestor($X, $Z), parent($Z, $Y).",
+        "ancestor($X, $Y) :- parent($X, $Y).",
+        "nat(0).",
+        "nat(s($X)) :- nat($X).",
+        "color(red).", "color(green).", "color(blue).",
+        "first($X) :- color($X), !.",
+        "pick($X) :- color($X).",
+        "pick($X) :- $X = first, !.",
+        "pick(never).",
+        "no_loop($X) :- not(loop($X)).",
+        "even(0).",
+        "even(s(s($X))) :- even($X).",
+        "odd($X) :- not(even($X)).",
+    }
+    addRules(t, kb, rules...)
+
+    // All strategies give the same solutions for finite searches.
+    queries := []string{
+        "grandfather($X, $Y)",
+        "first($X)",
+        "pick($X)",
+        "grandmother($X, Nobody)",
+    }
+    strategies := []*SearchStrategy{
+        DepthLimited(20), IterativeDeepening(0), BreadthFirst(),
+    }
+    for _, q := range queries {
+        query, _ := ParseQuery(q)
+        expected, failure1 := SolveAll(query, kb, SubstitutionSet{})
+        for _, strategy := range strategies {
+            query, _ = ParseQuery(q)
+            actual, failure2 := SolveAll(query, kb, SubstitutionSet{}, strategy)
+            if failure1 != failure2 || !sameSolutions(expected, actual, true) {
+                t.Errorf("\nTestSearch - %v, %v\n    Expected: %v %v\n" +
+                         "         Was: %v %v", q, strategy,
+                         expected, failure1, actual, failure2)
+            }
+            if strategy.DepthLimitReached() {
+                t.Errorf("\nTestSearch - %v, %v: Limit should not be reached.",
+                         q, strategy)
+            }
+        }
+    }
+
+    // Solutions below an infinite branch.
+    for _, strategy := range strategies {
+        query, _ := ParseQuery("loop($X)")
+        solution, failure := Solve(query, kb, SubstitutionSet{}, strategy)
+        if failure != "" || solution.String() != "loop(a)" {
+            t.Errorf("\nTestSearch - loop($X), %v: Was: %v %v",
+                     strategy, solution, failure)
+        }
+        query, _ = ParseQuery("ancestor($X, Harold)")
+        solution, failure = Solve(query, kb, SubstitutionSet{}, strategy)
+        if failure != "" {
+            t.Errorf("\nTestSearch - ancestor($X, Harold), %v: Was: %v",
+                     strategy, failure)
+        }
+    }
+
+    // A depth-limited search reports that the limit was reached.
+    strategy := DepthLimited(4)
+    query, _ := ParseQuery("nat($X)")
+    solutions, failure := SolveAll(query, kb, SubstitutionSet{}, strategy)
+    if failure != "" || len(solutions) != 4 || !strategy.DepthLimitReached() {
+        t.Errorf("\nTestSearch - nat($X), depth limited: Was: %v %v %v",
+                 solutions, failure, strategy.DepthLimitReached())
+    }
+    query, _ = ParseQuery("nat(s(s(s(s(s(0))))))")
+    _, failure = Solve(query, kb, SubstitutionSet{}, strategy)
+    if failure != "Depth limit." {
+        t.Error("\nTestSearch - nat(s(s(s(s(s(0)))))): Expected: Depth limit." +
+                "\n    Was: " + failure)
+    }
+
+    // Iterative deepening finds each solution once, in order of depth.
+    strategy = IterativeDeepening(6)
+    query, _ = ParseQuery("nat($X)")
+    solutions, _ = SolveAll(query, kb, SubstitutionSet{}, strategy)
+    expected := "[nat(0) nat(s(0)) nat(s(s(0))) nat(s(s(s(0)))) " +
+                "nat(s(s(s(s(0))))) nat(s(s(s(s(s(0))))))]"
+    if fmt.Sprint(solutions) != expected || !strategy.DepthLimitReached() {
+        t.Error("\nTestSearch - nat($X), iterative deepening:" +
+                "\n    Expected: " + expected +
+                "\n         Was: " + fmt.Sprint(solutions))
+    }
+
+    // The limit applies inside not(). A failure which is due to the
+    // limit does not prove the negation.
+    for _, strategy := range []*SearchStrategy{
+            DepthLimited(5), IterativeDeepening(5), BreadthFirst(5)} {
+        query, _ = ParseQuery("no_loop(b)")
+        _, failure = Solve(query, kb, SubstitutionSet{}, strategy)
+        if failure != "Depth limit." {
+            t.Errorf("\nTestSearch - no_loop(b), %v: Expected: Depth limit." +
+                     "\n    Was: %v", strategy, failure)
+        }
+    }
+    strategy = DepthLimited(2)
+    query, _ = ParseQuery("odd(s(s(s(0))))")
+    _, failure = Solve(query, kb, SubstitutionSet{}, strategy)
+    if failure != "Depth limit." {
+        t.Error("\nTestSearch - odd(s(s(s(0)))), depth 2: Expected: Depth limit." +
+                "\n    Was: " + failure)
+    }
+    strategy = IterativeDeepening(0)
+    query, _ = ParseQuery("odd(s(s(s(0))))")
+    solutions, failure = SolveAll(query, kb, SubstitutionSet{}, strategy)
+    if failure != "" || len(solutions) != 1 {
+        t.Errorf("\nTestSearch - odd(s(s(s(0)))), iterative deepening:" +
+                 " Was: %v %v", solutions, failure)
+    }
+
+    // Breadth-first search with a depth limit.
+    strategy = BreadthFirst(3)
+    query, _ = ParseQuery("ancestor($X, $Y)")
+    solutions, failure = SolveAll(query, kb, SubstitutionSet{}, strategy)
+    if failure != "" || !strategy.DepthLimitReached() {
+        t.Errorf("\nTestSearch - ancestor($X, $Y), breadth first: Was: %v %v",
+                 solutions, failure)
+    }
+
+} // TestSearch
@@ -0,0 +1,172 @@
+package main
+
+// Tests frozen knowledge bases (snapshots) and SolveBatch. Facts which
+// are added to the live knowledge base must not change the results of
+// queries on a snapshot, even while the queries are running.
+//
+//   go test -race -run=Snapshot
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "strconv"
+    "testing"
+    "fmt"
+)
+
+func TestSnapshot(t *testing.T) {
+
+    fmt.Println("TestSnapshot")
+
+    SetMaxTimeMilliseconds(5000)
+    defer SetMaxTimeMilliseconds(300)
+
+    kb := KnowledgeBase{}
+    err := LoadKBFromFile(kb, "kings.txt")
+    if err != nil {
+        t.Error("\nTestSnapshot:\n", err.Error())
+        return
+    }
+    rules := []string{
+        "word(dog, noun).", "word(cat, noun).", "word(run, verb).",
+        "word(run, noun).",
+        "noun($W) :- word($W, noun).",
+        "count(0) :- !.",
+        "count($N) :- $M = subtract($N, 1), count($M).",
+    }
+    addRules(t, kb, rules...)
+
+    queryStrings := []string{
+        "grandfather($X, $Y)",
+        "word(run, $P)",
+        "noun($W)",
+        "count(300)",
+        "word(fish, $P)",
+    }
+    makeQueries := func() []Complex {
+        queries := []Complex{}
+        for n := 0; n < 20; n++ {
+            for _, q := range queryStrings {
+                query, _ := ParseQuery(q)
+                queries = append(queries, query)
+            }
+        }
+        return queries
+    }
+
+    // Expected results, from the live knowledge base.
+    expected := map[string]string{}
+    for _, q := range queryStrings {
+        query, _ := ParseQuery(q)
+        solutions, failure := SolveAll(query, kb, SubstitutionSet{})
+        expected[q] = variableSuffix(fmt.Sprint(solutions)) + failure
+    }
+
+    snapshot := kb.Freeze()
+
+    // Add facts to the live knowledge base while the batch is running.
+    stop := make(chan bool)
+    finished := make(chan bool)
+    go func() {
+        defer close(finished)
+        for n := 0; ; n++ {
+            select {
+            case <-stop:
+                return
+            default:
+                word := Atom("word" + strconv.Itoa(n))
+                kb.Add(Fact(Complex{ Atom("word"), word, Atom("noun") }))
+            }
+        }
+    }()
+
+    results := snapshot.SolveBatch(makeQueries(), BatchOptions{ Workers: 4 })
+    close(stop)
+    <-finished
+
+    for i, result := range results {
+        q := queryStrings[i % len(queryStrings)]
+        actual := variableSuffix(fmt.Sprint(result.Solutions)) + result.Failure
+        if actual != expected[q] {
+            t.Error("\nTestSnapshot - " + q +
+                    "\n    Expected: " + expected[q] +
+                    "\n         Was: " + actual)
+        }
+    }
+
+    // The live knowledge base has the new facts. The snapshot does not.
+    if snapshot.RuleCount("word/2") != 4 {
+        t.Errorf("\nTestSnapshot - Snapshot should have 4 words. Has %v.",
+                 snapshot.RuleCount("word/2"))
+    }
+    query, _ := ParseQuery("word(word0, $P)")
+    if _, failure := SolveAll(query, kb, SubstitutionSet{}); failure != "" {
+        t.Error("\nTestSnapshot - Live knowledge base should have word0.")
+    }
+    query, _ = ParseQuery("word(word0, $P)")
+    if _, failure := snapshot.SolveAll(query, SubstitutionSet{}); failure != "No" {
+        t.Error("\nTestSnapshot - Snapshot should not have word0.")
+    }
+
+    // Limit the number of solutions.
+    results = snapshot.SolveBatch(makeQueries()[:5],
+                                  BatchOptions{ MaxSolutions: 1 })
+    for i, result := range results {
+        if result.Failure == "" && len(result.Solutions) != 1 {
+            t.Errorf("\nTestSnapshot - %v: Expected 1 solution. Was %v.",
+                     queryStrings[i], len(result.Solutions))
+        }
+    }
+
+} // TestSnapshot
+
+// Each query of a batch has its own time limit. A query which times
+// out does not stop the queries which follow it.
+func TestSnapshotTimeOut(t *testing.T) {
+
+    fmt.Println("TestSnapshotTimeOut")
+
+    SetMaxTimeMilliseconds(100)
+    defer SetMaxTimeMilliseconds(300)
+
+    kb := KnowledgeBase{}
+    addRules(t, kb, "endless($X) :- endless($X).", "word(dog, noun).")
+    snapshot := kb.Freeze()
+
+    // With one worker, the batch takes longer than the time limit.
+    queries := []Complex{}
+    for _, q := range []string{ "endless(loop)", "endless(loop)",
+                                "endless(loop)", "word(dog, $P)" } {
+        query, _ := ParseQuery(q)
+        queries = append(queries, query)
+    }
+    results := snapshot.SolveBatch(queries, BatchOptions{ Workers: 1 })
+    for i, result := range results[:3] {
+        if result.Failure != "Time out." {
+            t.Errorf("\nTestSnapshotTimeOut - %v: Expected time out. Was: %v",
+                     i, result.Failure)
+        }
+    }
+    actual := fmt.Sprint(results[3].Solutions) + results[3].Failure
+    if actual != "[word(dog, noun)]" {
+        t.Error("\nTestSnapshotTimeOut - Expected: [word(dog, noun)]" +
+                "\n                           Was: " + actual)
+    }
+
+    // A query which is solved at the same time is not stopped.
+    done := make(chan []BatchResult)
+    go func() { done <- snapshot.SolveBatch(queries[:1], BatchOptions{}) }()
+    query, _ := ParseQuery("word(dog, $P)")
+    for n := 0; n < 200; n++ {
+        if _, failure := Solve(query, kb, SubstitutionSet{}); failure != "" {
+            t.Errorf("\nTestSnapshotTimeOut - Solve failed: %v", failure)
+            break
+        }
+    }
+    if results := <-done; results[0].Failure != "Time out." {
+        t.Errorf("\nTestSnapshotTimeOut - Expected time out. Was: %v",
+                 results[0].Failure)
+    }
+
+} // TestSnapshotTimeOut
@@ -9,7 +9,9 @@ package main
 
 import (
     . "github.com/indrikoterio/suiron/suiron"
+    "runtime"
     "testing"
+    "time"
     "fmt"
 )
 
@@ -123,13 +125,16 @@ func TestSolve(t *testing.T) {
     // Second timeout test. Escape from endless loop.
     // endless($X) :- endless($X)
 
+    // The first query is still sleeping in too_long, so its knowledge
+    // base must not be changed.
+    kb2 := KnowledgeBase{}
     endless := Atom("endless")
     cEndless := Complex{endless, X}  // Term is:  endless($X)
     r2 := Rule(cEndless, cEndless) // Rule is: endless($X) :- endless($X).
-    kb.Add(r2)
+    kb2.Add(r2)
 
     query = MakeQuery(endless, Atom("loop")) // Query is: endless(loop)
-    _, failure = Solve(query, kb, SubstitutionSet{})
+    _, failure = Solve(query, kb2, SubstitutionSet{})
     //fmt.Printf("----------- %v\n", failure)
     if len(failure) == 0 {
         t.Error("TestTimeOut - this test should time out.")
@@ -137,3 +142,48 @@ func TestSolve(t *testing.T) {
     }
 
 }  // TestSolve
+
+// Each query has its own time limit. A query which times out does not
+// stop other queries, and its search stops.
+func TestTimeOutConcurrent(t *testing.T) {
+
+    fmt.Println("TestTimeOutConcurrent")
+    kb := KnowledgeBase{}
+    addRules(t, kb, "endless($X) :- endless($X).", "hobby(Tim, chess).")
+
+    before := runtime.NumGoroutine()
+    done := make(chan string)
+    go func() {
+        query, _ := ParseQuery("endless(loop)")
+        _, failure := Solve(query, kb, SubstitutionSet{})
+        done <- failure
+    }()
+
+    // Queries which are solved while the endless query runs succeed.
+    query, _ := ParseQuery("hobby(Tim, $X)")
+    for start := time.Now(); time.Since(start) < 400 * time.Millisecond; {
+        solution, failure := Solve(query, kb, SubstitutionSet{})
+        if len(failure) > 0 {
+            t.Errorf("\nTestTimeOutConcurrent - %v failed: %v", query, failure)
+            break
+        }
+        if solution.String() != "hobby(Tim, chess)" {
+            t.Errorf("\nTestTimeOutConcurrent - Was: %v", solution)
+            break
+        }
+    }
+
+    if failure := <-done; failure != "Time out." {
+        t.Errorf("\nTestTimeOutConcurrent - Expected time out. Was: %v", failure)
+    }
+
+    // The search goroutine of the endless query stops.
+    for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+        time.Sleep(10 * time.Millisecond)
+    }
+    if n := runtime.NumGoroutine(); n > before {
+        t.Errorf("\nTestTimeOutConcurrent - %v goroutines still running.",
+                 n - before)
+    }
+
+}  // TestTimeOutConcurrent
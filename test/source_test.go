@@ -0,0 +1,94 @@
+package main
+
+// Tests source locations of rules: RuleStruct.Source(), parse errors,
+// FormatKB() and the built-in predicate clause_property/2.
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "strings"
+    "testing"
+    "fmt"
+)
+
+func TestSource(t *testing.T) {
+
+    fmt.Println("TestSource")
+
+    kb := KnowledgeBase{}
+    err := LoadKBFromFile(kb, "source.txt")
+    if err != nil {
+        t.Error("\nTestSource:\n", err.Error())
+        return
+    }
+
+    // Two facts on line 2, and an indented rule on lines 4 and 5.
+    expected := map[string]string{
+        "colour(red).": "source.txt:2:1",
+        "colour(blue).": "source.txt:2:15",
+        "shape($X) :- colour($X).": "source.txt:4:4",
+    }
+    for _, key := range []string{ "colour/1", "shape/1" } {
+        for _, rule := range kb[key] {
+            actual := rule.Source().String()
+            if actual != expected[rule.String()] {
+                t.Errorf("\nTestSource - %v: Expected %v. Was %v.",
+                         rule, expected[rule.String()], actual)
+            }
+        }
+    }
+
+    // Rules which were not loaded from a file have no source.
+    rule, _ := ParseRule("colour(green).")
+    if rule.Source().Line != 0 || rule.Source().String() != "" {
+        t.Errorf("\nTestSource - ParseRule: %v", rule.Source())
+    }
+
+    // Parse errors begin with the location of the error.
+    err = LoadKBFromFile(KnowledgeBase{}, "badrule4.txt")
+    if err == nil ||
+       !strings.HasPrefix(err.Error(), "badrule4.txt:3:26: Missing argument") {
+        t.Errorf("\nTestSource - parse error: %v", err)
+    }
+
+    // FormatKB() lists the source of each rule.
+    if !strings.Contains(kb.FormatKB(),
+                         "    colour(blue).  % source.txt:2:15\n") {
+        t.Error("\nTestSource - FormatKB:\n" + kb.FormatKB())
+    }
+
+    // clause_property/2
+    kb.Add(rule)
+    rule, _ = ParseRule("property($H, $P) :- clause_property($H, $P).")
+    kb.Add(rule)
+    query, _ := ParseQuery("property(colour($X), $P)")
+    solutions, _ := SolveAll(query, kb, SubstitutionSet{})
+    actual := []string{}
+    for _, solution := range solutions {
+        actual = append(actual, solution.GetTerm(2).String())
+    }
+    expected2 := "file(source.txt), line_count(2), column(1), fact, " +
+                 "file(source.txt), line_count(2), column(15), fact, fact"
+    if strings.Join(actual, ", ") != expected2 {
+        t.Errorf("\nTestSource - clause_property:\nExpected: %v\nWas:      %v",
+                 expected2, strings.Join(actual, ", "))
+    }
+
+    query, _ = ParseQuery("property(shape($X), line_count($L))")
+    solution, failure := Solve(query, kb, SubstitutionSet{})
+    if failure != "" || variableSuffix(solution.String()) !=
+       "property(shape($X), line_count(4))" {
+        t.Errorf("\nTestSource - clause_property of shape: %v %v",
+                 solution, failure)
+    }
+
+    // The head selects the clauses.
+    query, _ = ParseQuery("property(colour(blue), column($C))")
+    solution, failure = Solve(query, kb, SubstitutionSet{})
+    if failure != "" ||
+       solution.String() != "property(colour(blue), column(15))" {
+        t.Errorf("\nTestSource - clause_property of blue: %v", solution)
+    }
+
+} // TestSource
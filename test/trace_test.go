@@ -0,0 +1,130 @@
+package main
+
+// Tests the tracer: the Call, Exit, Redo and Fail ports,
+// and the built-in predicates trace, notrace, spy/1 and nospy/1.
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "strings"
+    "testing"
+    "bytes"
+    "fmt"
+)
+
+// recorder - a tracer which records events.
+type recorder struct {
+    events []TraceEvent
+}
+
+func (r *recorder) Trace(e TraceEvent) { r.events = append(r.events, e) }
+
+func TestTrace(t *testing.T) {
+
+    fmt.Println("TestTrace")
+
+    defer SetTracer(nil)
+    defer TraceOff()
+    defer NoSpyAll()
+
+    kb := KnowledgeBase{}
+    rules := []string{
+        "parent(Godwin, Harold).",
+        "parent(Harold, Harold II).",
+        "parent(Tostig, Skule).",
+        "male(Godwin).",
+        "grandfather($X, $Y) :- parent($X, $Z), parent($Z, $Y), male($X).",
+        "check($X) :- $X > 2.",
+        "start_trace :- trace.",
+        "stop_trace :- notrace.",
+        "spy_parent :- spy(parent/2).",
+        "nospy_parent :- nospy(parent/2).",
+    }
+    addRules(t, kb, rules...)
+
+    rec := &recorder{}
+    SetTracer(rec)
+    solve := func(q string) {
+        query, _ := ParseQuery(q)
+        SolveAll(query, kb, SubstitutionSet{})
+    }
+
+    // Start tracing from a rule.
+    solve("start_trace")
+    rec.events = nil
+    solve("grandfather($X, $Y)")
+    solve("check(1)")
+    solve("stop_trace")
+
+    var sb strings.Builder
+    for _, e := range rec.events {
+        sb.WriteString(FormatTraceEvent(e) + "\n")
+    }
+    trace := variableSuffix(sb.String())
+    expected := []string{
+        "Call: (0) grandfather($X, $Y)\n",
+        " Call: (1) parent($X, $Z)\n",
+        " Exit: (1) parent(Godwin, Harold) (clause 1)\n",
+        " Call: (1) parent(Harold, $Y)\n",
+        " Exit: (1) parent(Harold, Harold II) (clause 2)\n",
+        " Call: (1) male(Godwin)\n",
+        " Exit: (1) male(Godwin) (clause 1)\n",
+        "Exit: (0) grandfather(Godwin, Harold II) (clause 1)\n",
+        " Redo: (1) parent($X, $Z) (clause 2)\n",
+        " Fail: (1) parent(Skule, $Y)\n",
+        " Fail: (1) parent($X, $Z)\n",
+        "Fail: (0) grandfather($X, $Y)\n",
+        " Call: (1) 1 > 2\n",
+        " Fail: (1) 1 > 2\n",
+    }
+    last := 0
+    for _, line := range expected {
+        i := strings.Index(trace[last:], line)
+        if i < 0 {
+            t.Error("\nTestTrace - Missing or out of order: " + line + trace)
+            break
+        }
+        last += i + len(line)
+    }
+
+    // Tracing is off.
+    rec.events = nil
+    solve("grandfather($X, $Y)")
+    if len(rec.events) != 0 {
+        t.Errorf("\nTestTrace - Tracing should be off: %v", rec.events)
+    }
+
+    // A spy point reports only its predicate.
+    solve("spy_parent")
+    rec.events = nil
+    solve("grandfather($X, $Y)")
+    if len(rec.events) == 0 {
+        t.Error("\nTestTrace - Spy point on parent/2 was not reported.")
+    }
+    for _, e := range rec.events {
+        if e.Key != "parent/2" {
+            t.Error("\nTestTrace - Unexpected event: " + FormatTraceEvent(e))
+        }
+    }
+    solve("nospy_parent")
+    rec.events = nil
+    solve("grandfather($X, $Y)")
+    if len(rec.events) != 0 || IsSpyPoint("parent/2") {
+        t.Errorf("\nTestTrace - Spy point should be removed: %v", rec.events)
+    }
+
+    // The text tracer.
+    var buffer bytes.Buffer
+    SetTracer(MakeTextTracer(&buffer))
+    Spy("male")
+    solve("male(Godwin)")
+    // SolveAll backtracks for more solutions, so male/1 fails at the end.
+    expectedText := "Call: (0) male(Godwin)\n" +
+                    "Exit: (0) male(Godwin) (clause 1)\n" +
+                    "Fail: (0) male(Godwin)\n"
+    if buffer.String() != expectedText {
+        t.Error("\nTestTrace - Expected:\n" + expectedText + "Was:\n" + buffer.String())
+    }
+
+} // TestTrace
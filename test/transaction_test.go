@@ -0,0 +1,182 @@
+package main
+
+// Tests transactions: changes to a knowledge base which become
+// visible all at once, or not at all.
+//
+// Cleve Lendon
+
+import (
+    . "github.com/indrikoterio/suiron/suiron"
+    "sync"
+    "testing"
+    "testing/fstest"
+    "fmt"
+)
+
+func TestTransaction(t *testing.T) {
+
+    fmt.Println("TestTransaction")
+
+    kb := KnowledgeBase{}
+    old, _ := ParseRule("colour(grey)")
+    kb.Add(old)
+
+    // count - the number of solutions of colour($C).
+    count := func(kb KnowledgeBase) int {
+        query, _ := ParseQuery("colour($C)")
+        solutions, _ := SolveAll(query, kb, SubstitutionSet{})
+        return len(solutions)
+    }
+
+    tx := kb.Begin()
+    for _, c := range []string{ "red", "green", "blue" } {
+        fact, _ := ParseRule("colour(" + c + ")")
+        tx.Add(fact)
+    }
+    if !tx.Retract(old.GetHead()) {
+        t.Error("\nTestTransaction - colour(grey) should be retracted.")
+    }
+    if count(kb) != 1 || count(tx.KnowledgeBase()) != 3 {
+        t.Errorf("\nTestTransaction - Before commit: %v %v",
+                 count(kb), count(tx.KnowledgeBase()))
+    }
+    if err := tx.Commit(); err != nil {
+        t.Error("\nTestTransaction:\n", err)
+    }
+    if count(kb) != 3 || len(kb["colour/1"]) != 3 {
+        t.Errorf("\nTestTransaction - After commit: %v", kb.FormatKB())
+    }
+    if err := tx.Commit(); err == nil {
+        t.Error("\nTestTransaction - A second commit should fail.")
+    }
+
+    tx = kb.Begin()
+    fact, _ := ParseRule("colour(black)")
+    tx.Add(fact)
+    tx.Rollback()
+    if count(kb) != 3 {
+        t.Error("\nTestTransaction - The rollback should discard changes.")
+    }
+
+    // Changes to the view of a transaction belong to it.
+    tx = kb.Begin()
+    fact, _ = ParseRule("colour(white)")
+    tx.KnowledgeBase().Add(fact)
+    tx.Commit()
+    if count(kb) != 4 {
+        t.Error("\nTestTransaction - The view's change should be committed.")
+    }
+    kb.Retract(fact.GetHead())
+
+    // A reader in another goroutine sees all the facts of a commit,
+    // or none of them, in queries and in snapshots.
+    shared := MakeSharedKB(kb)
+    facts := []RuleStruct{}
+    for j := 0; j < 100; j++ {
+        fact, _ := ParseRule(fmt.Sprintf("item(%d)", j))
+        facts = append(facts, fact)
+    }
+    query, _ := ParseQuery("item($X)")
+    var wait sync.WaitGroup
+    stop := make(chan bool)
+    wait.Add(1)
+    go func() {
+        defer wait.Done()
+        for {
+            select {
+            case <-stop:
+                return
+            default:
+            }
+            solutions, _ := SolveAll(query, shared.KnowledgeBase(),
+                                     SubstitutionSet{})
+            n := shared.KnowledgeBase().Freeze().RuleCount("item/1")
+            if len(solutions) % 100 != 0 || n % 100 != 0 {
+                t.Errorf("\nTestTransaction - Partial commit: %v %v",
+                         len(solutions), n)
+                return
+            }
+        }
+    }()
+    for i := 0; i < 20; i++ {
+        tx := shared.Begin()
+        tx.Add(facts...)
+        tx.Commit()
+    }
+    close(stop)
+    wait.Wait()
+
+    // A query sees the same clauses to its end. Each commit replaces
+    // pair(N) and mark(N) with pair(N+1) and mark(N+1), so a query which
+    // saw pair/1 before a commit and mark/1 after it would find a pair
+    // without a mark. wait/1 gives commits time to happen.
+    kb = KnowledgeBase{}
+    rules, _ := ParseRules("unmarked($X) :- pair($X), wait(20), " +
+                           "not(mark($X)).\n" +
+                           "wait(0) :- !.\n" +
+                           "wait($N) :- $M = subtract($N, 1), wait($M).\n" +
+                           "pair(0).\nmark(0).\n", "")
+    kb.Add(rules...)
+    shared = MakeSharedKB(kb)
+    query, _ = ParseQuery("unmarked($X)")
+    stop = make(chan bool)
+    wait.Add(1)
+    go func() {
+        defer wait.Done()
+        for {
+            select {
+            case <-stop:
+                return
+            default:
+            }
+            _, failure := Solve(query, shared.KnowledgeBase(), SubstitutionSet{})
+            if failure != "No" {
+                t.Error("\nTestTransaction - A query saw two tables.")
+                return
+            }
+        }
+    }()
+    for i := 0; i < 2000; i++ {
+        tx := shared.Begin()
+        pair, _ := ParseComplex(fmt.Sprintf("pair(%d)", i))
+        mark, _ := ParseComplex(fmt.Sprintf("mark(%d)", i))
+        tx.Retract(pair)
+        tx.Retract(mark)
+        newPair, _ := ParseRule(fmt.Sprintf("pair(%d)", i + 1))
+        newMark, _ := ParseRule(fmt.Sprintf("mark(%d)", i + 1))
+        tx.Add(newPair, newMark)
+        tx.Commit()
+    }
+    close(stop)
+    wait.Wait()
+
+    // Changes to a shared knowledge base are seen in its next table.
+    // Tables which were taken before do not change.
+    before := shared.KnowledgeBase()
+    fact, _ = ParseRule("colour(white)")
+    shared.Add(fact)
+    head, _ := ParseComplex("pair($X)")
+    if !shared.Retract(head) {
+        t.Error("\nTestTransaction - pair/1 should be retracted.")
+    }
+    after := shared.KnowledgeBase()
+    if len(after["colour/1"]) != 1 || len(after["pair/1"]) != 0 ||
+       len(before["colour/1"]) != 0 || len(before["pair/1"]) != 1 {
+        t.Errorf("\nTestTransaction - Shared: %v %v",
+                 before.FormatKB(), after.FormatKB())
+    }
+    if len(kb["pair/1"]) != 1 {
+        t.Error("\nTestTransaction - MakeSharedKB() should not change kb.")
+    }
+
+    // Loading is all or nothing.
+    fsys := fstest.MapFS{
+        "fail.txt": { Data: []byte("ok.\n:- initialization(missing).\n") },
+    }
+    kb = KnowledgeBase{}
+    if err := LoadKBFS(kb, fsys, "fail.txt"); err == nil || len(kb) > 0 {
+        t.Errorf("\nTestTransaction - Nothing should be loaded: %v %v",
+                 err, kb)
+    }
+
+} // TestTransaction